@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	acmeAccountKeyFile = "acme-account.pem"
+	acmeCheckInterval  = time.Hour
+	acmeTimeout        = 5 * time.Minute
+)
+
+// ACMEManager obtains and renews certificate of https server from an ACME CA
+// like Let's Encrypt. tls-alpn-01 challenges are answered by the https server
+// itself, so port 443 of domains must be forwarded to it
+type ACMEManager struct {
+	mu         sync.RWMutex
+	cert       *tls.Certificate
+	challenges map[string]*tls.Certificate
+}
+
+func NewACMEManager() *ACMEManager {
+	return &ACMEManager{challenges: make(map[string]*tls.Certificate)}
+}
+
+// GetCertificate returns challenge certificate for ACME validation requests
+// and the obtained certificate for others
+func (m *ACMEManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto {
+		cert, ok := m.challenges[strings.ToLower(hello.ServerName)]
+		if !ok {
+			return nil, fmt.Errorf("no challenge for %s", hello.ServerName)
+		}
+		return cert, nil
+	}
+	if m.cert == nil {
+		return nil, errors.New("certificate is not obtained yet")
+	}
+	return m.cert, nil
+}
+
+// Run loads saved certificate and renews it periodically, it never returns
+func (m *ACMEManager) Run() {
+	if cert, err := app.loadACMECertificate(); err == nil {
+		m.setCertificate(cert)
+	}
+	for {
+		if m.needRenew() {
+			log.WithField("domains", app.config.TLS.ACME.Domains).Info("obtain certificate by acme")
+			ctx, cancel := context.WithTimeout(context.Background(), acmeTimeout)
+			cert, err := m.obtain(ctx)
+			cancel()
+			if err != nil {
+				log.WithError(err).Warn("failed to obtain certificate by acme")
+			} else {
+				m.setCertificate(cert)
+				log.Info("certificate obtained by acme")
+			}
+		}
+		time.Sleep(acmeCheckInterval)
+	}
+}
+
+func (m *ACMEManager) setCertificate(cert *tls.Certificate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cert = cert
+}
+
+// needRenew reports whether certificate is missing, about to expire or
+// doesn't cover configured domains
+func (m *ACMEManager) needRenew() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil || m.cert.Leaf == nil {
+		return true
+	}
+	renewBefore := time.Duration(app.config.TLS.ACME.RenewBefore) * 24 * time.Hour
+	if time.Until(m.cert.Leaf.NotAfter) < renewBefore {
+		return true
+	}
+	for _, domain := range app.config.TLS.ACME.Domains {
+		if m.cert.Leaf.VerifyHostname(domain) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ACMEManager) obtain(ctx context.Context) (*tls.Certificate, error) {
+	config := app.config.TLS.ACME
+	if len(config.Domains) == 0 {
+		return nil, errors.New("no domains configured")
+	}
+	accountKey, err := app.loadACMEAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{Key: accountKey, DirectoryURL: config.DirectoryURL}
+	account := &acme.Account{}
+	if config.Email != "" {
+		account.Contact = []string{"mailto:" + config.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(config.Domains...))
+	if err != nil {
+		return nil, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := m.authorize(ctx, client, authzURL); err != nil {
+			return nil, err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: config.Domains}, key)
+	if err != nil {
+		return nil, err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+	certFile := app.GetExecFilePath(app.config.TLS.CertFile)
+	keyFile := app.GetExecFilePath(app.config.TLS.KeyFile)
+	if err := utils.WriteCertificate(certFile, keyFile, chain, key, app.config.ProtectSecrets); err != nil {
+		return nil, err
+	}
+	return app.loadACMECertificate()
+}
+
+// authorize completes tls-alpn-01 challenge of authorization
+func (m *ACMEManager) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status != acme.StatusPending {
+		return nil
+	}
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "tls-alpn-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("tls-alpn-01 challenge is not offered for %s", authz.Identifier.Value)
+	}
+
+	domain := strings.ToLower(authz.Identifier.Value)
+	cert, err := client.TLSALPN01ChallengeCert(challenge.Token, domain)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.challenges[domain] = &cert
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.challenges, domain)
+		m.mu.Unlock()
+	}()
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// loadACMECertificate loads certificate saved by ACMEManager with parsed leaf
+func (app *Application) loadACMECertificate() (*tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(app.GetExecFilePath(app.config.TLS.CertFile))
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := utils.ReadSecretFile(app.GetExecFilePath(app.config.TLS.KeyFile))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// loadACMEAccountKey loads account key of ACME CA, it will be generated if it
+// doesn't exist yet
+func (app *Application) loadACMEAccountKey() (crypto.Signer, error) {
+	path := app.GetExecFilePath(acmeAccountKeyFile)
+	if utils.IsExistFile(path) {
+		keyPEM, err := utils.ReadSecretFile(path)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found in %s", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if app.config.ProtectSecrets {
+		err = utils.WriteSecretFile(path, keyPEM)
+	} else {
+		err = ioutil.WriteFile(path, keyPEM, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,15 @@
+package action
+
+import (
+	"github.com/lxn/walk"
+)
+
+func NewAuditViewerAction(handler walk.EventHandler) (*walk.Action, error) {
+	action := walk.NewAction()
+	if err := action.SetText("访问记录"); err != nil {
+		return nil, err
+	}
+
+	action.Triggered().Attach(handler)
+	return action, nil
+}
@@ -0,0 +1,15 @@
+package action
+
+import (
+	"github.com/lxn/walk"
+)
+
+func NewExportClientCertAction(handler walk.EventHandler) (*walk.Action, error) {
+	action := walk.NewAction()
+	if err := action.SetText("导出客户端证书"); err != nil {
+		return nil, err
+	}
+
+	action.Triggered().Attach(handler)
+	return action, nil
+}
@@ -0,0 +1,14 @@
+package action
+
+import (
+	"github.com/lxn/walk"
+)
+
+// NewSnippetsAction returns action opening submenu of snippets
+func NewSnippetsAction(menu *walk.Menu) (*walk.Action, error) {
+	action := walk.NewMenuAction(menu)
+	if err := action.SetText("常用片段"); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
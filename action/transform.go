@@ -0,0 +1,33 @@
+package action
+
+import (
+	"log"
+
+	"github.com/lxn/walk"
+)
+
+// NewTransformAction returns checkable action of toggling text transforms,
+// which is unchecked again if handler fails
+func NewTransformAction(checked bool, handler func(enable bool) error) (*walk.Action, error) {
+	action := walk.NewAction()
+	if err := action.SetText("文本转换"); err != nil {
+		return nil, err
+	}
+
+	if err := action.SetCheckable(true); err != nil {
+		return nil, err
+	}
+	if err := action.SetChecked(checked); err != nil {
+		return nil, err
+	}
+
+	action.Triggered().Attach(func() {
+		enable := action.Checked()
+		if err := handler(enable); err != nil {
+			action.SetChecked(!enable)
+			log.Println(err)
+		}
+	})
+
+	return action, nil
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"net/http"
 	"path"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/YanxinTang/clipboard-online/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/lxn/walk"
 )
@@ -12,32 +15,122 @@ import (
 type Application struct {
 	config *Config
 	*walk.MainWindow
-	ni *walk.NotifyIcon
-	wg sync.WaitGroup
+	ni           *walk.NotifyIcon
+	wg           sync.WaitGroup
+	devices      *DeviceStore
+	pairing      *PairingManager
+	sensitive    *utils.SensitiveDetector
+	lockout      *utils.Lockout
+	audit        *AuditLog
+	downloads    *DownloadLinkManager
+	clients      *KnownClientStore
+	events       *EventHub
+	uploads      *UploadManager
+	serverMu     sync.Mutex
+	server       *http.Server
+	portMapper   *PortMapper
+	idempotency  *IdempotencyStore
+	transfers    *TransferTracker
+	textVersions *TextVersions
+	startedAt    time.Time
+	shares       *ShareManager
+	// code pages of charset.read and charset.write
+	readCodePage  uint32
+	writeCodePage uint32
+	thumbnails    *ThumbnailCache
+	transforms    *TextTransforms
+	changes       *ChangeTracker
+	// sequence numbers approved for reading text in ranges
+	chunkApprovals *ChunkApprovals
+	history        *HistoryStore // nil if history is disabled
+	snippets       *SnippetStore
+	queue          *QueueStore
+	outbox         *Outbox
+	historyLock    *HistoryLock
 }
 
 func (app *Application) RunHTTPServer() {
 	app.wg.Add(1)
 	go func() {
 		engin := gin.New()
-		setupRoute(engin)
-		if err := engin.Run(":" + app.config.Port); err != nil {
-			app.ni.ShowError("HTTP Server 启动失败", "您的应用可能不能正常运行")
-			app.Synchronize(func() {
-				walk.App().Exit(1)
-			})
-			log.WithError(err).Error("failed to start http server")
-			return
+		err := setupRoute(engin)
+		if err == nil {
+			if app.config.Local.Enable {
+				go func() {
+					if err := app.runLocalServer(engin); err != nil {
+						log.WithError(err).Warn("failed to start local server")
+					}
+				}()
+			}
+			err = app.runEngine(engin)
 		}
+		app.handleServeError(err)
 	}()
 }
 
+// RestartHTTPServer closes listeners and serves on listen addresses of
+// current config again
+func (app *Application) RestartHTTPServer() {
+	app.serverMu.Lock()
+	old := app.server
+	app.serverMu.Unlock()
+	if old == nil {
+		return
+	}
+	server := &http.Server{Handler: old.Handler, TLSConfig: old.TLSConfig}
+	old.Close()
+	go func() {
+		app.handleServeError(app.serve(server))
+	}()
+}
+
+func (app *Application) handleServeError(err error) {
+	if err == nil || err == http.ErrServerClosed {
+		return
+	}
+	log.WithError(err).Error("failed to start http server")
+	if isAddrInUse(err) {
+		app.Synchronize(showAddrInUseDialog)
+		return
+	}
+	app.ni.ShowError("HTTP Server 启动失败", "您的应用可能不能正常运行")
+	app.Synchronize(func() {
+		walk.App().Exit(1)
+	})
+}
+
+func (app *Application) runEngine(engin *gin.Engine) error {
+	handler, err := app.proxyHandler(engin)
+	if err != nil {
+		return err
+	}
+	if !app.config.TLS.Enable {
+		return app.serve(&http.Server{Handler: h2cHandler(handler)})
+	}
+	tlsConfig, err := app.tlsConfig()
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := app.runFingerprintServer(); err != nil {
+			log.WithError(err).Warn("failed to start fingerprint server")
+		}
+	}()
+	return app.serve(&http.Server{Handler: app.http3Handler(handler), TLSConfig: tlsConfig})
+}
+
 func (app *Application) StopHTTPServer() {
 	app.wg.Done()
 }
 
 func (app *Application) BeforeExit() {
+	if app.portMapper != nil {
+		app.portMapper.Close()
+	}
 	app.StopHTTPServer()
+	if app.history != nil {
+		app.history.Close()
+	}
 	app.ni.Dispose()
 }
 
@@ -59,19 +152,87 @@ func (app *Application) GetTempFilePath(filename string) string {
 	return filepath.Join(app.config.TempDir, filename)
 }
 
+// GetExecFilePath returns path as is if it is absolute, otherwise joins it with exec path
+func (app *Application) GetExecFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(execPath, path)
+}
+
 func NewApplication(config *Config) (*Application, error) {
 	app := new(Application)
 	var err error
 	app.config = config
+	app.startedAt = time.Now()
+	app.pairing = NewPairingManager()
+	app.audit = NewAuditLog(filepath.Join(execPath, AuditFile))
+	app.downloads = NewDownloadLinkManager()
+	app.events = NewEventHub()
+	app.uploads = NewUploadManager()
+	app.idempotency = NewIdempotencyStore()
+	app.transfers = NewTransferTracker()
+	app.textVersions = NewTextVersions()
+	app.changes = NewChangeTracker(app.startedAt)
+	app.chunkApprovals = NewChunkApprovals()
+	app.historyLock = &HistoryLock{}
+	app.thumbnails = NewThumbnailCache(app.GetTempFilePath(thumbnailsDir))
+	app.lockout = utils.NewLockout(
+		config.Lockout.MaxFailures,
+		time.Duration(config.Lockout.Window)*time.Second,
+		time.Duration(config.Lockout.BanDuration)*time.Second,
+	)
+	app.sensitive, err = utils.NewSensitiveDetector(config.Sensitive.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	app.devices, err = loadDeviceStore(filepath.Join(execPath, DevicesFile))
+	if err != nil {
+		return nil, err
+	}
+	app.clients, err = loadKnownClientStore(filepath.Join(execPath, KnownClientsFile))
+	if err != nil {
+		return nil, err
+	}
+	app.snippets, err = loadSnippetStore(filepath.Join(execPath, SnippetsFile))
+	if err != nil {
+		return nil, err
+	}
+	app.queue, err = loadQueueStore(filepath.Join(execPath, QueueFile))
+	if err != nil {
+		return nil, err
+	}
+	app.outbox, err = loadOutbox(filepath.Join(execPath, OutboxFile))
+	if err != nil {
+		return nil, err
+	}
+	app.shares, err = NewShareManager()
+	if err != nil {
+		return nil, err
+	}
+	app.readCodePage, app.writeCodePage, err = parseCharsets(config.Charset)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateLineEndings(config.LineEndings); err != nil {
+		return nil, err
+	}
+	app.history = openHistory(config.History)
+	app.transforms, err = NewTextTransforms(config.Transforms)
+	if err != nil {
+		return nil, err
+	}
 	app.MainWindow, err = walk.NewMainWindow()
 	if err != nil {
 		return nil, err
 	}
+	walk.Clipboard().ContentsChanged().Attach(publishClipboardChanged)
 
 	app.ni, err = walk.NewNotifyIcon(app.MainWindow)
 	if err != nil {
 		return nil, err
 	}
+	app.ni.MessageClicked().Attach(handleUnknownClientAlertClicked)
 
 	return app, nil
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+)
+
+const previewMaxLength = 100
+
+// approveRead asks user to approve reading clipboard if confirmRead is enabled.
+// If it is rejected or timed out, the request will be aborted with 403
+func approveRead(c *gin.Context, preview string) bool {
+	if !app.config.ConfirmRead.Enable {
+		return true
+	}
+	return requireApproval(c, preview)
+}
+
+// requireApproval asks user to approve reading clipboard regardless of confirmRead
+func requireApproval(c *gin.Context, preview string) bool {
+	clientName := c.GetString("clientName")
+	timeout := time.Duration(app.config.ConfirmRead.Timeout) * time.Second
+	if requestApproval(clientName, preview, timeout) {
+		return true
+	}
+	log.WithField("clientName", clientName).Warn("read clipboard rejected")
+	abortWithError(c, http.StatusForbidden, "read_rejected", "操作被拒绝：请求未被允许")
+	return false
+}
+
+// requestApproval shows a dialog and waits until user approves, rejects or timeout
+func requestApproval(clientName, preview string, timeout time.Duration) bool {
+	result := make(chan bool, 1)
+	app.Synchronize(func() {
+		approved, err := runApprovalDialog(clientName, preview, timeout)
+		if err != nil {
+			log.WithError(err).Warn("failed to show approval dialog")
+		}
+		result <- approved
+	})
+	select {
+	case approved := <-result:
+		return approved
+	case <-time.After(timeout + time.Second):
+		return false
+	}
+}
+
+func runApprovalDialog(clientName, preview string, timeout time.Duration) (bool, error) {
+	dlg, err := walk.NewDialogWithFixedSize(app.MainWindow)
+	if err != nil {
+		return false, err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("读取剪切板请求"); err != nil {
+		return false, err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return false, err
+	}
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		return false, err
+	}
+	message := fmt.Sprintf("设备 %s 请求读取剪切板：\n\n%s\n\n%d 秒内未允许将自动拒绝", clientName, truncate(preview, previewMaxLength), int(timeout.Seconds()))
+	if err := label.SetText(message); err != nil {
+		return false, err
+	}
+
+	buttons, err := walk.NewComposite(dlg)
+	if err != nil {
+		return false, err
+	}
+	if err := buttons.SetLayout(walk.NewHBoxLayout()); err != nil {
+		return false, err
+	}
+	approveButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return false, err
+	}
+	if err := approveButton.SetText("允许"); err != nil {
+		return false, err
+	}
+	approveButton.Clicked().Attach(dlg.Accept)
+	rejectButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return false, err
+	}
+	if err := rejectButton.SetText("拒绝"); err != nil {
+		return false, err
+	}
+	rejectButton.Clicked().Attach(dlg.Cancel)
+	if err := dlg.SetCancelButton(rejectButton); err != nil {
+		return false, err
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		dlg.Synchronize(dlg.Cancel)
+	})
+	defer timer.Stop()
+
+	return dlg.Run() == walk.DlgCmdOK, nil
+}
+
+// truncate returns at most n characters of s
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
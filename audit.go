@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+)
+
+const AuditFile = "audit.log"
+
+const (
+	AuditActionRead  = "read"
+	AuditActionWrite = "write"
+)
+
+const auditViewerLimit = 200
+
+// AuditEntry is a record of clipboard access
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	ClientName string    `json:"clientName"`
+	ClientIP   string    `json:"clientIP"`
+	Action     string    `json:"action"`
+	Type       string    `json:"type"`
+	Size       int       `json:"size"`
+	StatusCode int       `json:"statusCode"`
+}
+
+// AuditPage is response body of a page of audit entries
+type AuditPage struct {
+	Data []AuditEntry `json:"data"`
+	PageInfo
+}
+
+// AuditLog appends audit entries to file as JSON lines
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Add appends entry to audit file
+func (a *AuditLog) Add(entry AuditEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(entryBytes, '\n'))
+	return err
+}
+
+// Recent returns at most limit latest entries, newest first
+func (a *AuditLog) Recent(limit int) ([]AuditEntry, error) {
+	entries, err := a.Since(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// Since returns entries after since, newest first. All entries are returned
+// if since is zero
+func (a *AuditLog) Since(since time.Time) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if since.IsZero() || entry.Time.After(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// setAuditInfo sets type and size of content read or written by request
+func setAuditInfo(c *gin.Context, contentType string, size int) {
+	c.Set("auditType", contentType)
+	c.Set("auditSize", size)
+}
+
+// audit records every clipboard access with action after request is handled
+func audit(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Status() == http.StatusOK && grpcSucceeded(c) {
+			app.events.Publish(Event{
+				Event:      EventTransfer,
+				Type:       c.GetString("auditType"),
+				Action:     action,
+				ClientName: c.GetString("clientName"),
+				Size:       c.GetInt("auditSize"),
+				Time:       time.Now(),
+			})
+			recordHistory(c, action)
+			markQueueSet(action)
+		}
+		if !app.config.Audit {
+			return
+		}
+		entry := AuditEntry{
+			Time:       time.Now(),
+			ClientName: c.GetString("clientName"),
+			ClientIP:   c.ClientIP(),
+			Action:     action,
+			Type:       c.GetString("auditType"),
+			Size:       c.GetInt("auditSize"),
+			StatusCode: c.Writer.Status(),
+		}
+		if err := app.audit.Add(entry); err != nil {
+			log.WithError(err).Warn("failed to write audit log")
+		}
+	}
+}
+
+func auditHandler(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	entries, err := app.audit.Since(page.Since)
+	if err != nil {
+		log.WithError(err).Warn("failed to read audit log")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	start, end := page.bounds(len(entries))
+	c.JSON(http.StatusOK, AuditPage{entries[start:end], page.info(len(entries))})
+}
+
+func showAuditViewer() {
+	if err := runAuditViewer(); err != nil {
+		log.WithError(err).Warn("failed to show audit viewer")
+		walk.MsgBox(app.MainWindow, "访问记录", "无法读取访问记录", walk.MsgBoxIconError)
+	}
+}
+
+func runAuditViewer() error {
+	entries, err := app.audit.Recent(auditViewerLimit)
+	if err != nil {
+		return err
+	}
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("%s  %s (%s)  %s  %s  %d 字节  %d",
+			entry.Time.Format("2006-01-02 15:04:05"),
+			entry.ClientName,
+			entry.ClientIP,
+			entry.Action,
+			entry.Type,
+			entry.Size,
+			entry.StatusCode,
+		))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "暂无访问记录")
+	}
+
+	dlg, err := walk.NewDialog(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("访问记录"); err != nil {
+		return err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return err
+	}
+	if err := dlg.SetSize(walk.Size{Width: 720, Height: 480}); err != nil {
+		return err
+	}
+
+	textEdit, err := walk.NewTextEditWithStyle(dlg, win.WS_VSCROLL)
+	if err != nil {
+		return err
+	}
+	if err := textEdit.SetReadOnly(true); err != nil {
+		return err
+	}
+	if err := textEdit.SetText(strings.Join(lines, "\r\n")); err != nil {
+		return err
+	}
+
+	dlg.Run()
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBatchOperations = 16
+
+type batchRequestKey struct{}
+
+// BatchOperation is a request executed by /batch. Body is sent as json
+type BatchOperation struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// BatchResult is response of a BatchOperation. Body is raw json if response
+// is json, otherwise it is a string
+type BatchResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResponse is response body of /batch, results are in order of
+// operations
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// batchExcludedPaths are not allowed in batch since they stream or nest
+var batchExcludedPaths = []string{"/batch", "/ws", "/events", "/wait", "/bridge", grpcService}
+
+// batchProtectedHeaders identify or authenticate the client, or resolve its
+// address. They can't be overridden by operations, as operations skip auth
+// and would act as another device otherwise
+var batchProtectedHeaders = []string{
+	"X-Client-Name", "X-Device-Token", "X-Auth", "X-Auth-Token", "X-TOTP", "X-Signature", "X-Timestamp", "X-Nonce",
+	"Tailscale-User-Login", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Real-IP",
+}
+
+// batchHandler executes operations sequentially through engin. Operations
+// are authenticated by the batch request, but permissions, capabilities and
+// audit apply to each of them as if they were sent separately
+func batchHandler(engin *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var operations []BatchOperation
+		if err := c.ShouldBindJSON(&operations); err != nil {
+			log.WithError(err).Warn("failed to bind batch body")
+			respondError(c, http.StatusBadRequest, "invalid_batch", "批量操作格式错误")
+			return
+		}
+		if len(operations) == 0 || len(operations) > maxBatchOperations {
+			respondError(c, http.StatusBadRequest, "invalid_batch", fmt.Sprintf("批量操作数量应为 1 到 %d 个", maxBatchOperations))
+			return
+		}
+		for _, op := range operations {
+			if !batchAllowed(op) {
+				respondError(c, http.StatusBadRequest, "invalid_batch", "不支持的批量操作："+op.Method+" "+op.Path)
+				return
+			}
+		}
+
+		results := make([]BatchResult, 0, len(operations))
+		for _, op := range operations {
+			results = append(results, executeBatchOperation(engin, c, op))
+		}
+		c.JSON(http.StatusOK, BatchResponse{results})
+	}
+}
+
+func batchAllowed(op BatchOperation) bool {
+	switch op.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return false
+	}
+	if !strings.HasPrefix(op.Path, "/") {
+		return false
+	}
+	// routes are matched by decoded path, so it's checked instead of the raw
+	// one, which may escape or add slashes to an excluded path
+	u, err := url.Parse(op.Path)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return false
+	}
+	opPath := path.Clean(u.Path)
+	for _, excluded := range batchExcludedPaths {
+		if opPath == excluded || strings.HasPrefix(opPath, excluded+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+func executeBatchOperation(engin *gin.Engine, c *gin.Context, op BatchOperation) BatchResult {
+	ctx := context.WithValue(c.Request.Context(), batchRequestKey{}, true)
+	req, err := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader(op.Body))
+	if err != nil {
+		return BatchResult{Status: http.StatusBadRequest}
+	}
+	// client name, api version and encryption of batch request apply to
+	// operations unless they are overridden
+	req.Header = c.Request.Header.Clone()
+	for _, name := range []string{"Content-Length", "Content-Encoding", "Content-Type", "Accept", "If-Match", "If-None-Match", "X-Idempotency-Key"} {
+		req.Header.Del(name)
+	}
+	req.Header.Set("Accept", gin.MIMEJSON)
+	if len(op.Body) > 0 {
+		req.Header.Set("Content-Type", gin.MIMEJSON)
+	}
+	for name, value := range op.Headers {
+		if !batchProtectedHeader(name) {
+			req.Header.Set(name, value)
+		}
+	}
+	// operations act as the client authenticated by batch request, which
+	// may be named by tailscale rather than X-Client-Name
+	req.Header.Set("X-Client-Name", url.PathEscape(c.GetString("clientName")))
+	req.RemoteAddr = c.Request.RemoteAddr
+	req.Host = c.Request.Host
+	req.TLS = c.Request.TLS
+
+	w := httptest.NewRecorder()
+	engin.ServeHTTP(w, req)
+
+	result := BatchResult{Status: w.Code, Headers: make(map[string]string)}
+	for name := range w.Header() {
+		result.Headers[name] = w.Header().Get(name)
+	}
+	body := w.Body.Bytes()
+	if len(body) == 0 {
+		return result
+	}
+	if json.Valid(body) && strings.HasPrefix(w.Header().Get("Content-Type"), gin.MIMEJSON) {
+		result.Body = body
+	} else {
+		result.Body, _ = json.Marshal(string(body))
+	}
+	return result
+}
+
+func batchProtectedHeader(name string) bool {
+	for _, protected := range batchProtectedHeaders {
+		if strings.EqualFold(name, protected) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBatchRequest reports whether request is an operation of /batch
+func isBatchRequest(c *gin.Context) bool {
+	batch, _ := c.Request.Context().Value(batchRequestKey{}).(bool)
+	return batch
+}
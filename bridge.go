@@ -0,0 +1,395 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	bridgeRetry       = 10 * time.Second
+	bridgeDialTimeout = 10 * time.Second
+	// bridgeMaxRetry is the longest delay of connecting again, which doubles
+	// from bridgeRetry on every failure
+	bridgeMaxRetry = time.Minute
+	// files larger than it in total are not mirrored
+	bridgeMaxFileSize = 32 << 20
+	// bridgeMessageOverhead is room of a message for other than content,
+	// e.g. index of history
+	bridgeMessageOverhead = 4 << 20
+)
+
+// BridgeMessage carries clipboard content or history between bridged
+// instances
+type BridgeMessage struct {
+	Type         string              `json:"type"`
+	Text         string              `json:"text,omitempty"`
+	Files        []File              `json:"files,omitempty"`
+	HistoryIndex []HistoryIndexEntry `json:"historyIndex,omitempty"`
+	History      *HistorySyncEntry   `json:"history,omitempty"`
+}
+
+// hash identifies content regardless of file names, which may be changed
+// when files are saved, so that content received is not sent back
+func (m *BridgeMessage) hash() ([sha256.Size]byte, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s", m.Type, len(m.Text), m.Text)
+	for i := range m.Files {
+		data, err := m.Files[i].Bytes()
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		fmt.Fprintf(h, "\x00%d\x00", len(data))
+		h.Write(data)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// bridge mirrors clipboard with a peer instance over a websocket connection
+type bridge struct {
+	conn *websocket.Conn
+	peer string
+	// wmu serializes writes, as history is sent in background
+	wmu sync.Mutex
+	// mu guards hash of content last mirrored in either direction, and
+	// whether peer syncs history
+	mu          sync.Mutex
+	last        [sha256.Size]byte
+	historyPeer bool
+	// time of the latest history entry sent, only used by run
+	historySent time.Time
+	// historyApproved is set once by historyApproval
+	historyApproval sync.Once
+	historyApproved bool
+}
+
+// RunBridge connects to bridge.url of another instance and mirrors
+// clipboards with it. Connection is made again after it's lost, with
+// backoff while peer is unreachable
+func (app *Application) RunBridge() {
+	if !app.config.Bridge.Enable {
+		return
+	}
+	app.outbox.watchBridge()
+	app.outbox.addBridgePeer(bridgePeerName())
+	go func() {
+		retry := bridgeRetry
+		for {
+			conn, err := dialBridge()
+			if err != nil {
+				log.WithError(err).WithField("retry", retry).Warn("failed to connect bridge")
+				time.Sleep(retry)
+				if retry *= 2; retry > bridgeMaxRetry {
+					retry = bridgeMaxRetry
+				}
+				continue
+			}
+			log.WithField("url", app.config.Bridge.URL).Info("bridge connected")
+			(&bridge{conn: conn, peer: bridgePeerName()}).run()
+			log.Info("bridge disconnected")
+			retry = bridgeRetry
+			time.Sleep(retry)
+		}
+	}()
+}
+
+func bridgePeerName() string {
+	u, err := url.Parse(app.config.Bridge.URL)
+	if err != nil {
+		return app.config.Bridge.URL
+	}
+	return u.Hostname()
+}
+
+func dialBridge() (*websocket.Conn, error) {
+	config := app.config.Bridge
+	dialer := &websocket.Dialer{HandshakeTimeout: bridgeDialTimeout}
+	if config.Fingerprint != "" {
+		// certificate of peer is usually self-signed, pin it instead
+		dialer.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 || !strings.EqualFold(utils.CertFingerprint(rawCerts[0]), config.Fingerprint) {
+					return errors.New("certificate fingerprint of bridge peer mismatch")
+				}
+				return nil
+			},
+		}
+	}
+	header := http.Header{}
+	header.Set("X-API-Version", apiVersion)
+	clientName := config.ClientName
+	if clientName == "" {
+		clientName, _ = os.Hostname()
+	}
+	header.Set("X-Client-Name", url.PathEscape(clientName))
+	if config.AuthToken != "" {
+		header.Set("X-Auth-Token", config.AuthToken)
+	}
+	if config.DeviceToken != "" {
+		header.Set("X-Device-Token", config.DeviceToken)
+	}
+	conn, _, err := dialer.Dial(config.URL, header)
+	return conn, err
+}
+
+// bridgeHandler accepts bridge connection of another instance
+func bridgeHandler(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.WithError(err).Warn("failed to upgrade websocket")
+		return
+	}
+	peer := c.GetString("clientName")
+	log.WithField("peer", peer).Info("bridge connected")
+	(&bridge{conn: conn, peer: peer}).run()
+	log.WithField("peer", peer).Info("bridge disconnected")
+}
+
+// bridgeReadLimit returns size of the largest message accepted from peers,
+// which is files up to bridgeMaxFileSize, or a history entry with content and
+// zip of files up to history.maxContentSize each, encoded by base64
+func bridgeReadLimit() int64 {
+	size := int64(bridgeMaxFileSize)
+	if historySize := 2 * app.config.History.MaxContentSize << 20; historySize > size {
+		size = historySize
+	}
+	return size/3*4 + bridgeMessageOverhead
+}
+
+// run sends local clipboard on every change and applies clipboard received
+// until connection is lost. Content kept in outbox while peer was
+// disconnected is sent first. History is synced as well if it's enabled
+func (b *bridge) run() {
+	defer b.conn.Close()
+	b.conn.SetReadLimit(bridgeReadLimit())
+
+	events := app.events.Subscribe()
+	defer app.events.Unsubscribe(events)
+	pending := app.outbox.connectBridge(b.peer)
+	defer app.outbox.disconnectBridge(b.peer)
+	// nil channel of history changes is never ready
+	var historyChanges chan Event
+	if historySyncEnabled(b.peer) {
+		historyChanges = app.history.changes.Subscribe()
+		defer app.history.changes.Unsubscribe(historyChanges)
+		if err := b.startHistorySync(); err != nil {
+			log.WithError(err).Warn("failed to sync history with bridge")
+			return
+		}
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var message BridgeMessage
+			if err := b.conn.ReadJSON(&message); err != nil {
+				return
+			}
+			switch message.Type {
+			case BridgeHistoryIndex, BridgeHistoryEntry:
+				if err := b.receiveHistory(&message); err != nil {
+					log.WithError(err).Warn("failed to sync history from bridge")
+				}
+			default:
+				if err := b.apply(&message); err != nil {
+					log.WithError(err).Warn("failed to set clipboard from bridge")
+				}
+			}
+		}
+	}()
+
+	if pending != nil {
+		if err := b.sendMessage(pending); err != nil {
+			log.WithError(err).Warn("failed to send pending clipboard to bridge")
+			return
+		}
+		log.WithField("peer", b.peer).Info("pending clipboard sent to bridge")
+	}
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-events:
+			if event.Event != EventClipboard {
+				continue
+			}
+			if err := b.send(); err != nil {
+				log.WithError(err).Warn("failed to send clipboard to bridge")
+				return
+			}
+		case <-historyChanges:
+			b.sendHistoryChanges()
+		case <-ticker.C:
+			deadline := time.Now().Add(wsWriteTimeout)
+			if err := b.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// send sends local clipboard unless it's the content last mirrored
+func (b *bridge) send() error {
+	message, err := localBridgeMessage()
+	if err != nil || message == nil {
+		return err
+	}
+	return b.sendMessage(message)
+}
+
+// sendMessage sends message unless it's the content last mirrored. It's
+// kept in outbox for peer if it fails to be sent
+func (b *bridge) sendMessage(message *BridgeMessage) error {
+	hash, err := message.hash()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	if hash == b.last {
+		b.mu.Unlock()
+		return nil
+	}
+	b.last = hash
+	b.mu.Unlock()
+	if err := b.write(message); err != nil {
+		app.outbox.KeepBridge([]string{b.peer}, message)
+		return err
+	}
+	return nil
+}
+
+func (b *bridge) write(message *BridgeMessage) error {
+	b.wmu.Lock()
+	defer b.wmu.Unlock()
+	b.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return b.conn.WriteJSON(message)
+}
+
+// localBridgeMessage returns content of local clipboard, it's nil if content
+// can't be mirrored
+func localBridgeMessage() (*BridgeMessage, error) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		return nil, err
+	}
+	switch contentType {
+	case utils.TypeText:
+		text, err := clipboardText()
+		if err != nil {
+			return nil, err
+		}
+		return &BridgeMessage{Type: utils.TypeText, Text: text}, nil
+	case utils.TypeFile:
+		paths, err := utils.Clipboard().Files()
+		if err != nil {
+			return nil, err
+		}
+		message := &BridgeMessage{Type: utils.TypeFile, Files: make([]File, 0, len(paths))}
+		var size int64
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				// folders are not mirrored
+				return nil, err
+			}
+			if size += info.Size(); size > bridgeMaxFileSize {
+				log.WithField("size", size).Info("files are too large to mirror by bridge")
+				return nil, nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			message.Files = append(message.Files, File{
+				Name:   filepath.Base(path),
+				Base64: base64.StdEncoding.EncodeToString(data),
+			})
+		}
+		return message, nil
+	default:
+		return nil, nil
+	}
+}
+
+// apply sets content received to local clipboard
+func (b *bridge) apply(message *BridgeMessage) error {
+	// checked on every message, as config may change while connected
+	name := CapabilityWriteFile
+	if message.Type == utils.TypeText {
+		name = CapabilityWriteText
+	}
+	if !canWrite(deviceConfig(b.peer).Role) || isDisabled(b.peer, CapabilityWrite) || isDisabled(b.peer, name) {
+		return fmt.Errorf("%s is not allowed for bridge peer %s", name, b.peer)
+	}
+	hash, err := message.hash()
+	if err != nil {
+		return err
+	}
+	// set before clipboard changes so that it's not sent back
+	b.mu.Lock()
+	b.last = hash
+	b.mu.Unlock()
+
+	switch message.Type {
+	case utils.TypeText:
+		if err := setClipboardText(message.Text); err != nil {
+			return err
+		}
+		b.skipMirroredHistory()
+		log.WithField("text", contentSummary(message.Text)).Info("set clipboard text from bridge")
+		sendPasteNotification(log, b.peer, notificationPreview(message.Text))
+		return nil
+	case utils.TypeFile:
+		if !app.config.ReserveHistory {
+			cleanTempFiles()
+		}
+		paths := make([]string, 0, len(message.Files))
+		for i := range message.Files {
+			data, err := message.Files[i].Bytes()
+			if err != nil {
+				return err
+			}
+			path := utils.LatestFilename(app.GetTempFilePath(filepath.Base(message.Files[i].Name)))
+			if err := newFile(path, data); err != nil {
+				return err
+			}
+			paths = append(paths, path)
+		}
+		if app.config.ReserveHistory {
+			setLastFilenames(nil)
+		} else {
+			setLastFilenames(paths)
+		}
+		if err := utils.Clipboard().SetFiles(paths); err != nil {
+			return err
+		}
+		b.skipMirroredHistory()
+		log.WithField("paths", contentSummaries(paths)).Info("set clipboard files from bridge")
+		sendPasteNotification(log, b.peer, "[文件] 已复制到剪贴板")
+		return nil
+	default:
+		return fmt.Errorf("unsupported bridge message type: %s", message.Type)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/lxn/walk"
+)
+
+// parseCharsets returns code pages of charset.read and charset.write, 0 if
+// it's empty
+func parseCharsets(config ConfigCharset) (read, write uint32, err error) {
+	if config.Read != "" {
+		if read, err = utils.ParseCodePage(config.Read); err != nil {
+			return 0, 0, err
+		}
+	}
+	if config.Write != "" {
+		if write, err = utils.ParseCodePage(config.Write); err != nil {
+			return 0, 0, err
+		}
+	}
+	return read, write, nil
+}
+
+// validateLineEndings returns error if an ending of lineEndings is unknown
+func validateLineEndings(config ConfigLineEndings) error {
+	for _, ending := range []string{config.Read, config.Write} {
+		switch ending {
+		case "", utils.LineEndingLF, utils.LineEndingCRLF:
+		default:
+			return fmt.Errorf("unknown line ending: %s", ending)
+		}
+	}
+	return nil
+}
+
+// clipboardText returns text of clipboard transformed, with line endings
+// of lineEndings.read. CF_TEXT placed by legacy apps is decoded by
+// charset.read, instead of system code page
+func clipboardText() (string, error) {
+	if app.readCodePage != 0 {
+		text, ok, err := utils.Clipboard().ANSIText(app.readCodePage)
+		if err != nil {
+			log.WithError(err).Warn("failed to decode ansi text of clipboard")
+		} else if ok {
+			return utils.NormalizeLineEndings(app.transforms.Get(text), app.config.LineEndings.Read), nil
+		}
+	}
+	text, err := walk.Clipboard().Text()
+	if err != nil {
+		return "", err
+	}
+	return utils.NormalizeLineEndings(app.transforms.Get(text), app.config.LineEndings.Read), nil
+}
+
+// setClipboardText sets text on clipboard with line endings of
+// lineEndings.write, along with CF_TEXT of charset.write for legacy apps if
+// it's set
+func setClipboardText(text string) error {
+	text = utils.NormalizeLineEndings(text, app.config.LineEndings.Write)
+	if app.writeCodePage != 0 {
+		return utils.Clipboard().SetTextCodePage(text, app.writeCodePage)
+	}
+	return utils.Clipboard().SetText(text)
+}
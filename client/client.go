@@ -0,0 +1,178 @@
+// Package client is a Go client of clipboard-online http api
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiVersion = "1"
+
+// Options configures a Client. Authkey, AuthToken and DeviceToken are only
+// sent if not empty
+type Options struct {
+	// ClientName is sent as X-Client-Name
+	ClientName string
+	// Authkey is authkey of server, AuthkeyTimeout is its authkeyExpiredTimeout
+	// which is 30 seconds if zero
+	Authkey        string
+	AuthkeyTimeout time.Duration
+	AuthToken      string
+	DeviceToken    string
+	// MaxRetries is times a request is retried on network errors, 429 and
+	// 5xx responses. Writes are retried with the same X-Idempotency-Key
+	MaxRetries int
+	// RetryWait is wait before first retry, doubled for each next one. It's
+	// 500ms if zero
+	RetryWait  time.Duration
+	HTTPClient *http.Client
+}
+
+// Client calls api of a clipboard-online server
+type Client struct {
+	baseURL *url.URL
+	opts    Options
+	http    *http.Client
+}
+
+// New returns a client of server at baseURL, e.g. http://192.168.1.2:8086
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if opts.AuthkeyTimeout == 0 {
+		opts.AuthkeyTimeout = 30 * time.Second
+	}
+	if opts.RetryWait == 0 {
+		opts.RetryWait = 500 * time.Millisecond
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: u, opts: opts, http: httpClient}, nil
+}
+
+// setAuth sets auth headers of options to header
+func (c *Client) setAuth(header http.Header) {
+	header.Set("X-API-Version", apiVersion)
+	if c.opts.ClientName != "" {
+		header.Set("X-Client-Name", url.PathEscape(c.opts.ClientName))
+	}
+	if c.opts.Authkey != "" {
+		timeKey := time.Now().Unix() / int64(c.opts.AuthkeyTimeout/time.Second)
+		sum := md5.Sum([]byte(c.opts.Authkey + "." + strconv.FormatInt(timeKey, 10)))
+		header.Set("X-Auth", hex.EncodeToString(sum[:]))
+	}
+	if c.opts.AuthToken != "" {
+		header.Set("X-Auth-Token", c.opts.AuthToken)
+	}
+	if c.opts.DeviceToken != "" {
+		header.Set("X-Device-Token", c.opts.DeviceToken)
+	}
+}
+
+// request is a request which can be sent again on retry
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// do sends req with retries and returns response of 2xx or 304 status, other
+// statuses are returned as *APIError
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	if req.header == nil {
+		req.header = http.Header{}
+	}
+	if req.method != http.MethodGet && req.header.Get("X-Idempotency-Key") == "" {
+		key, err := randomKey()
+		if err != nil {
+			return nil, err
+		}
+		req.header.Set("X-Idempotency-Key", key)
+	}
+
+	wait := c.opts.RetryWait
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+		if err == nil {
+			err = readAPIError(resp)
+		}
+		if attempt >= c.opts.MaxRetries || !retryable(err) {
+			return nil, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	c.setAuth(httpReq.Header)
+	return c.http.Do(httpReq)
+}
+
+// doJSON sends req and decodes json response to v if it's not nil
+func (c *Client) doJSON(ctx context.Context, req request, v interface{}) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func jsonRequest(method, path string, v interface{}) (request, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return request{}, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return request{method: method, path: path, header: header, body: body}, nil
+}
+
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts Options) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := New(server.URL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestGetText(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/clipboard" || r.Header.Get("Accept") != "text/plain" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Accept"))
+		}
+		if r.Header.Get("X-Auth-Token") != "token" || r.Header.Get("X-Client-Name") != "my%20laptop" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		w.Write([]byte("hello"))
+	}, Options{ClientName: "my laptop", AuthToken: "token"})
+
+	text, err := client.GetText(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("GetText() = %q, want hello", text)
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	modified := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Clipboard-Sequence", "42")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"type": "file",
+			"data": []responseFile{{Name: "a.txt", Content: base64.StdEncoding.EncodeToString([]byte("foo")), Modified: &modified}},
+		})
+	}, Options{})
+
+	content, err := client.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Type != TypeFile || content.Sequence != 42 || len(content.Files) != 1 {
+		t.Fatalf("unexpected content %+v", content)
+	}
+	if content.Files[0].Name != "a.txt" || string(content.Files[0].Data) != "foo" || !content.Files[0].Modified.Equal(modified) {
+		t.Errorf("unexpected file %+v", content.Files[0])
+	}
+}
+
+func TestSetTextRetry(t *testing.T) {
+	var keys []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Idempotency-Key"))
+		if r.Method != http.MethodPut || r.Header.Get("X-Content-Type") != TypeText {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("X-Content-Type"))
+		}
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}, Options{MaxRetries: 2, RetryWait: time.Millisecond})
+
+	if err := client.SetText(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("retry should send the same idempotency key, got %q", keys)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":{"code":"read_forbidden","message":"forbidden"}}`))
+	}, Options{MaxRetries: 2, RetryWait: time.Millisecond})
+
+	_, err := client.Get(context.Background())
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("Get() error = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.Code != "read_forbidden" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if !IsForbidden(err) || IsUnauthorized(err) {
+		t.Errorf("IsForbidden() should be true and IsUnauthorized() false for %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("403 should not be retried, got %d requests", requests)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]interface{}{"event": "clipboard", "sequence": 7, "type": "text"})
+		conn.ReadMessage()
+	}, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Sequence != 7 || event.Type != TypeText {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Content types of clipboard
+const (
+	TypeText  = "text"
+	TypeFile  = "file"
+	TypeImage = "bitmap"
+)
+
+// File is a file of clipboard
+type File struct {
+	Name     string
+	Data     []byte
+	Modified time.Time // modification time, zero if unknown
+}
+
+// Content is content of clipboard. Text is set for text, Files for files
+// and images, an image is a single png file
+type Content struct {
+	Type     string
+	Text     string
+	Files    []File
+	Sequence uint32
+}
+
+type contentResponse struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type responseFile struct {
+	Name     string     `json:"name"`
+	Content  string     `json:"content"`
+	Modified *time.Time `json:"modified,omitempty"`
+}
+
+type requestFile struct {
+	Name     string     `json:"name"`
+	Base64   string     `json:"base64"`
+	Modified *time.Time `json:"modified,omitempty"`
+}
+
+// Get returns content of clipboard
+func (c *Client) Get(ctx context.Context) (*Content, error) {
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/v2/clipboard", header: header})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body contentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	sequence, _ := strconv.ParseUint(resp.Header.Get("X-Clipboard-Sequence"), 10, 32)
+	content := &Content{Type: body.Type, Sequence: uint32(sequence)}
+	switch body.Type {
+	case TypeText:
+		err = json.Unmarshal(body.Data, &content.Text)
+	case TypeFile, TypeImage:
+		var files []responseFile
+		if err = json.Unmarshal(body.Data, &files); err != nil {
+			break
+		}
+		for _, f := range files {
+			data, err := base64.StdEncoding.DecodeString(f.Content)
+			if err != nil {
+				return nil, err
+			}
+			file := File{Name: f.Name, Data: data}
+			if f.Modified != nil {
+				file.Modified = *f.Modified
+			}
+			content.Files = append(content.Files, file)
+		}
+	default:
+		return nil, ErrUnsupportedContent
+	}
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// GetText returns text of clipboard
+func (c *Client) GetText(ctx context.Context) (string, error) {
+	header := http.Header{}
+	header.Set("Accept", "text/plain")
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/v2/clipboard", header: header})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	text, err := ioutil.ReadAll(resp.Body)
+	return string(text), err
+}
+
+// SetText sets text to clipboard
+func (c *Client) SetText(ctx context.Context, text string) error {
+	req, err := jsonRequest(http.MethodPut, "/v2/clipboard", map[string]string{"data": text})
+	if err != nil {
+		return err
+	}
+	req.header.Set("X-Content-Type", TypeText)
+	return c.doJSON(ctx, req, nil)
+}
+
+// SetFiles sets files to clipboard
+func (c *Client) SetFiles(ctx context.Context, files []File) error {
+	if len(files) == 0 {
+		return fmt.Errorf("client: no files to set")
+	}
+	data := make([]requestFile, 0, len(files))
+	for _, f := range files {
+		file := requestFile{Name: f.Name, Base64: base64.StdEncoding.EncodeToString(f.Data)}
+		if !f.Modified.IsZero() {
+			modified := f.Modified
+			file.Modified = &modified
+		}
+		data = append(data, file)
+	}
+	req, err := jsonRequest(http.MethodPut, "/v2/clipboard", map[string]interface{}{"data": data})
+	if err != nil {
+		return err
+	}
+	req.header.Set("X-Content-Type", TypeFile)
+	return c.doJSON(ctx, req, nil)
+}
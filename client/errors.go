@@ -0,0 +1,80 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// ErrNoFiles is returned by Files if clipboard doesn't hold files
+var ErrNoFiles = errors.New("client: no files in clipboard")
+
+// ErrUnsupportedContent is returned by Get if clipboard content is not text,
+// image or files
+var ErrUnsupportedContent = errors.New("client: unsupported clipboard content")
+
+// APIError is an error responded by server. Code is machine readable code of
+// v2 api, e.g. invalid_token, read_forbidden or rate_limited
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("clipboard-online: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("clipboard-online: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Temporary reports whether request may succeed if retried
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsUnauthorized reports whether err is caused by missing or wrong
+// credentials
+func IsUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden && apiErr.Code == "invalid_authkey")
+}
+
+// IsForbidden reports whether err is caused by permissions of device
+func IsForbidden(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden && apiErr.Code != "invalid_authkey"
+}
+
+// readAPIError reads error envelope of v2 api from resp
+func readAPIError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return apiErr
+	}
+	var envelope struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+	}
+	return apiErr
+}
+
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const maxWatchRetryWait = 30 * time.Second
+
+// Event is pushed when clipboard changes
+type Event struct {
+	Sequence uint32    `json:"sequence"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+}
+
+// Watch returns a channel receiving an event every time clipboard changes.
+// Connection is made again after it's lost, the channel is closed when ctx
+// is done. Error is returned if the first connection fails
+func (c *Client) Watch(ctx context.Context) (<-chan Event, error) {
+	conn, err := c.dialWatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		wait := c.opts.RetryWait
+		for {
+			if conn != nil {
+				wait = c.opts.RetryWait
+				c.readEvents(ctx, conn, events)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			if wait *= 2; wait > maxWatchRetryWait {
+				wait = maxWatchRetryWait
+			}
+			conn, _ = c.dialWatch(ctx)
+		}
+	}()
+	return events, nil
+}
+
+func (c *Client) dialWatch(ctx context.Context) (*websocket.Conn, error) {
+	u := *c.baseURL
+	u.Path += "/ws"
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	dialer := websocket.Dialer{Proxy: http.ProxyFromEnvironment}
+	if transport, ok := c.http.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+	header := http.Header{}
+	c.setAuth(header)
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil && resp != nil {
+		return nil, readAPIError(resp)
+	}
+	return conn, err
+}
+
+// readEvents sends events read from conn until it's closed or ctx is done
+func (c *Client) readEvents(ctx context.Context, conn *websocket.Conn, events chan<- Event) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+	for {
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			return
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
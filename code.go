@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// codeLanguage returns language of header X-Code-Language, which marks text
+// as a code snippet
+func codeLanguage(c *gin.Context) string {
+	return c.GetHeader("X-Code-Language")
+}
+
+// setClipboardCode sets code on clipboard as text, along with html of it
+// highlighted for language, so that it pastes highlighted into OneNote or
+// Outlook and stays plain in editors
+func setClipboardCode(code, language string) error {
+	code = utils.NormalizeLineEndings(code, app.config.LineEndings.Write)
+	return utils.Clipboard().SetHTML(code, utils.HighlightCode(code, language))
+}
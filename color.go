@@ -0,0 +1,52 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ColorValue is normalized representations of a color
+type ColorValue struct {
+	Hex string `json:"hex"` // #RRGGBB, or #RRGGBBAA if it's not opaque
+	RGB string `json:"rgb"`
+	HSL string `json:"hsl"`
+}
+
+func newColorValue(color utils.Color) *ColorValue {
+	return &ColorValue{color.Hex(), color.RGB(), color.HSL()}
+}
+
+// colorSwatch returns html of a swatch of color followed by text, which
+// rich text editors paste as a colored block
+func colorSwatch(color utils.Color, text string) string {
+	return `<span style="background-color:` + color.RGB() + `">&nbsp;&nbsp;&nbsp;&nbsp;</span>&nbsp;` + html.EscapeString(text)
+}
+
+// setColorHandler sets color of TextBody as text along with a swatch of it
+// in html
+func setColorHandler(c *gin.Context) {
+	text, ok := bindText(c)
+	if !ok {
+		return
+	}
+	text = strings.TrimSpace(text)
+	color, ok := utils.ParseColor(text)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "invalid_color", "颜色格式错误")
+		return
+	}
+	if err := c.Request.Context().Err(); err != nil {
+		return
+	}
+	if err := utils.Clipboard().SetHTML(text, colorSwatch(color, text)); err != nil {
+		log.WithError(err).Warn("failed to set clipboard")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	textPasted(c, text)
+	c.Status(http.StatusOK)
+}
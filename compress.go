@@ -0,0 +1,65 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxWindow limits memory a zstd body can make decoder allocate for its
+// window, which is declared by sender. It's the limit of zstd content coding
+// of http, see RFC 9659
+const zstdMaxWindow = 8 << 20
+
+// zstdReader closes zstd decoder as io.ReadCloser
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (r zstdReader) Close() error {
+	r.Decoder.Close()
+	return nil
+}
+
+// decompress transparently decompresses request body by Content-Encoding.
+// It runs after signature so that signature covers bytes on the wire
+func decompress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		var body io.ReadCloser
+		switch encoding {
+		case "", "identity":
+			c.Next()
+			return
+		case "gzip":
+			reader, err := gzip.NewReader(c.Request.Body)
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, "decompress_failed", "无法解压请求内容")
+				return
+			}
+			body = reader
+		case "deflate":
+			body = flate.NewReader(c.Request.Body)
+		case "zstd":
+			decoder, err := zstd.NewReader(c.Request.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, "decompress_failed", "无法解压请求内容")
+				return
+			}
+			body = zstdReader{decoder}
+		default:
+			abortWithError(c, http.StatusUnsupportedMediaType, "unsupported_encoding", "不支持的压缩格式："+encoding)
+			return
+		}
+		defer body.Close()
+		c.Request.Body = body
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// clipboardETag returns etag of clipboard content by its sequence number. It
+// is weak since encrypted or negotiated representations differ in bytes
+func clipboardETag(sequence uint32) string {
+	return `W/"` + strconv.FormatUint(uint64(sequence), 10) + `"`
+}
+
+// matchETag reports whether etag is in header value of If-None-Match or
+// If-Match. Weak comparison is used, and bare sequence numbers are accepted
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || opaqueTag(candidate) == opaqueTag(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+func opaqueTag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+// notModified responds 304 if client already has clipboard content of
+// sequence
+func notModified(c *gin.Context, sequence uint32) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" || !matchETag(header, clipboardETag(sequence)) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// preconditionFailed responds 412 if clipboard has changed since the
+// sequence in If-Match, so that writes don't clobber content copied since
+// client last read it
+func preconditionFailed(c *gin.Context) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || matchETag(header, clipboardETag(utils.Clipboard().SequenceNumber())) {
+		return false
+	}
+	respondError(c, http.StatusPreconditionFailed, "clipboard_changed", "剪切板内容已变化")
+	return true
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
+	"strings"
 
 	"github.com/YanxinTang/clipboard-online/utils"
 	"github.com/sirupsen/logrus"
@@ -11,15 +13,63 @@ import (
 const ConfigFile = "config.json"
 const LogFile = "log.txt"
 
+// secretPrefix marks secrets in config file which are encrypted by DPAPI
+const secretPrefix = "dpapi:"
+
 // Config represents configuration for applicaton
 type Config struct {
-	Port                  string       `json:"port"`
-	Authkey               string       `json:"authkey"`
-	AuthkeyExpiredTimeout int64        `json:"authkeyExpiredTimeout"`
-	LogLevel              logrus.Level `json:"logLevel"`
-	TempDir               string       `json:"tempDir"`
-	ReserveHistory        bool         `json:"reserveHistory"`
-	Notify                ConfigNotify `json:"notify"`
+	Port                  string                  `json:"port"`
+	Authkey               string                  `json:"authkey"`
+	AuthkeyExpiredTimeout int64                   `json:"authkeyExpiredTimeout"`
+	AuthToken             string                  `json:"authToken"`
+	EncryptionKey         string                  `json:"encryptionKey"`
+	ProtectSecrets        bool                    `json:"protectSecrets"`
+	Pairing               bool                    `json:"pairing"`
+	LANOnly               bool                    `json:"lanOnly"`
+	AllowIPs              []string                `json:"allowIPs"`
+	DenyIPs               []string                `json:"denyIPs"`
+	LogLevel              logrus.Level            `json:"logLevel"`
+	TempDir               string                  `json:"tempDir"`
+	ReserveHistory        bool                    `json:"reserveHistory"`
+	Audit                 bool                    `json:"audit"`
+	Notify                ConfigNotify            `json:"notify"`
+	TLS                   ConfigTLS               `json:"tls"`
+	RateLimit             ConfigRateLimit         `json:"rateLimit"`
+	Signature             ConfigSignature         `json:"signature"`
+	Devices               map[string]ConfigDevice `json:"devices"`
+	ConfirmRead           ConfigConfirmRead       `json:"confirmRead"`
+	Sensitive             ConfigSensitive         `json:"sensitive"`
+	Lockout               ConfigLockout           `json:"lockout"`
+	DownloadLink          ConfigDownloadLink      `json:"downloadLink"`
+	TOTP                  ConfigTOTP              `json:"totp"`
+	Disabled              []string                `json:"disabled"` // capabilities disabled for all devices
+	Tailscale             ConfigTailscale         `json:"tailscale"`
+	UnknownClientAlert    bool                    `json:"unknownClientAlert"`
+	Privacy               bool                    `json:"privacy"`
+	Discovery             ConfigDiscovery         `json:"discovery"`
+	Local                 ConfigLocal             `json:"local"`
+	Webhook               ConfigWebhook           `json:"webhook"`
+	MQTT                  ConfigMQTT              `json:"mqtt"`
+	Listen                []string                `json:"listen"` // e.g. [::]:8086, port is listened on all interfaces if empty
+	PortMapping           ConfigPortMapping       `json:"portMapping"`
+	Proxy                 ConfigProxy             `json:"proxy"`
+	IdempotencyWindow     int64                   `json:"idempotencyWindow"` // seconds
+	RequestTimeout        int64                   `json:"requestTimeout"`    // seconds
+	Bridge                ConfigBridge            `json:"bridge"`
+	Fetch                 ConfigFetch             `json:"fetch"`
+	Share                 ConfigShare             `json:"share"`
+	PasteMediaAs          string                  `json:"pasteMediaAs"` // file, image or both
+	CustomFormats         ConfigCustomFormats     `json:"customFormats"`
+	Charset               ConfigCharset           `json:"charset"`
+	LineEndings           ConfigLineEndings       `json:"lineEndings"`
+	Thumbnail             ConfigThumbnail         `json:"thumbnail"`
+	Markdown              ConfigMarkdown          `json:"markdown"`
+	DecodeImageText       bool                    `json:"decodeImageText"`
+	Transforms            ConfigTransforms        `json:"transforms"`
+	History               ConfigHistory           `json:"history"`
+	Queue                 ConfigQueue             `json:"queue"`
+	Outbox                ConfigOutbox            `json:"outbox"`
+	ProtobufMaxSize       int64                   `json:"protobufMaxSize"` // MB
 }
 
 type ConfigNotify struct {
@@ -27,18 +77,451 @@ type ConfigNotify struct {
 	Paste bool `json:"paste"`
 }
 
+// ConfigConfirmRead represents configuration for approving clipboard reads on windows
+type ConfigConfirmRead struct {
+	Enable  bool  `json:"enable"`
+	Timeout int64 `json:"timeout"` // seconds
+}
+
+// ConfigSensitive represents configuration for detecting sensitive clipboard text
+type ConfigSensitive struct {
+	Enable   bool     `json:"enable"`
+	Patterns []string `json:"patterns"` // regular expressions
+	Action   string   `json:"action"`   // block, redact or confirm
+}
+
+// ConfigLockout represents configuration for banning client ip after repeated
+// auth failures
+type ConfigLockout struct {
+	Enable      bool  `json:"enable"`
+	MaxFailures int   `json:"maxFailures"`
+	Window      int64 `json:"window"`      // seconds
+	BanDuration int64 `json:"banDuration"` // seconds
+	Notify      bool  `json:"notify"`
+}
+
+// ConfigDevice represents configuration for device with client name
+type ConfigDevice struct {
+	Role      string   `json:"role"`      // read-only, write-only or read-write
+	Disabled  []string `json:"disabled"`  // capabilities disabled for device
+	NoHistory bool     `json:"noHistory"` // content set by device is not recorded in history
+}
+
+// ConfigRateLimit represents configuration for token bucket rate limiting
+// keyed by client ip and client name
+type ConfigRateLimit struct {
+	Enable bool    `json:"enable"`
+	Rate   float64 `json:"rate"` // requests per second
+	Burst  int     `json:"burst"`
+}
+
+// ConfigSignature represents configuration for HMAC request signing
+type ConfigSignature struct {
+	Enable  bool   `json:"enable"`
+	Key     string `json:"key"`
+	MaxSkew int64  `json:"maxSkew"` // seconds
+}
+
+// ConfigTLS represents configuration for https server. Relative paths are
+// resolved against the exec path
+type ConfigTLS struct {
+	Enable          bool       `json:"enable"`
+	CertFile        string     `json:"certFile"`
+	KeyFile         string     `json:"keyFile"`
+	ClientAuth      bool       `json:"clientAuth"`
+	CACertFile      string     `json:"caCertFile"`
+	CAKeyFile       string     `json:"caKeyFile"`
+	ACME            ConfigACME `json:"acme"`
+	FingerprintPort string     `json:"fingerprintPort"` // plain http port on localhost serving /fingerprint
+	HTTP3           bool       `json:"http3"`           // experimental, only built with http3 tag
+}
+
+// ConfigACME represents configuration for obtaining certificate from an ACME CA
+type ConfigACME struct {
+	Enable       bool     `json:"enable"`
+	Domains      []string `json:"domains"`
+	Email        string   `json:"email"`
+	DirectoryURL string   `json:"directoryURL"`
+	RenewBefore  int      `json:"renewBefore"` // days
+}
+
+// ConfigDownloadLink represents configuration for one-time download links
+type ConfigDownloadLink struct {
+	Timeout int64 `json:"timeout"` // seconds
+}
+
+// ConfigTOTP represents configuration for requiring totp codes as second factor
+type ConfigTOTP struct {
+	Enable bool   `json:"enable"`
+	Secret string `json:"secret"` // base32 encoded, generated if empty
+	Skew   int    `json:"skew"`   // periods of 30 seconds accepted before and after now
+}
+
+// ConfigTailscale represents configuration for trusting identity headers of Tailscale Serve
+type ConfigTailscale struct {
+	Enable     bool              `json:"enable"`
+	Users      map[string]string `json:"users"`      // tailscale login to device name, all users are allowed if empty
+	Tailscaled string            `json:"tailscaled"` // path of tailscaled executable, headers are only trusted from it
+}
+
+// ConfigDiscovery represents configuration for udp broadcast discovery
+type ConfigDiscovery struct {
+	Enable bool   `json:"enable"`
+	Port   string `json:"port"` // udp port
+	Name   string `json:"name"` // hostname is used if empty
+}
+
+// ConfigLocal represents configuration for the loopback listener without auth
+type ConfigLocal struct {
+	Enable bool   `json:"enable"`
+	Port   string `json:"port"`
+}
+
+// ConfigWebhook represents configuration for posting clipboard events to urls
+type ConfigWebhook struct {
+	Enable  bool     `json:"enable"`
+	URLs    []string `json:"urls"`
+	Secret  string   `json:"secret"`  // key of X-Signature, requests are not signed if empty
+	Timeout int64    `json:"timeout"` // seconds
+}
+
+// ConfigMQTT represents configuration for publishing clipboard events to mqtt broker
+type ConfigMQTT struct {
+	Enable      bool   `json:"enable"`
+	Broker      string `json:"broker"` // e.g. tcp://192.168.1.10:1883 or ssl://broker:8883
+	Topic       string `json:"topic"`
+	ClientID    string `json:"clientID"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Retain      bool   `json:"retain"`
+	IncludeText bool   `json:"includeText"` // include clipboard text in messages
+}
+
+// ConfigPortMapping represents configuration for mapping server port on router by NAT-PMP or UPnP
+type ConfigPortMapping struct {
+	Enable       bool   `json:"enable"`
+	ExternalPort string `json:"externalPort"` // same as server port if empty
+}
+
+// ConfigProxy represents configuration for running behind reverse proxies
+type ConfigProxy struct {
+	TrustedProxies []string `json:"trustedProxies"` // ips or CIDRs whose X-Forwarded-* headers are honored
+	BasePath       string   `json:"basePath"`       // e.g. /clipboard
+}
+
+// ConfigBridge represents configuration for mirroring clipboard with another instance
+type ConfigBridge struct {
+	Enable      bool   `json:"enable"`
+	URL         string `json:"url"`         // e.g. ws://192.168.1.3:8086/bridge
+	ClientName  string `json:"clientName"`  // X-Client-Name sent to peer, hostname if empty
+	AuthToken   string `json:"authToken"`   // authToken of peer
+	DeviceToken string `json:"deviceToken"` // device token paired with peer
+	Fingerprint string `json:"fingerprint"` // SHA-256 fingerprint of peer certificate to pin
+}
+
+// ConfigFetch represents configuration for setting clipboard by url fetched by server
+type ConfigFetch struct {
+	Enable       bool     `json:"enable"`
+	Schemes      []string `json:"schemes"`      // allowed url schemes
+	MaxSize      int64    `json:"maxSize"`      // MB
+	Timeout      int64    `json:"timeout"`      // seconds
+	AllowPrivate bool     `json:"allowPrivate"` // allow fetching from private network addresses
+}
+
+// ConfigShare represents configuration for signed share urls of clipboard
+type ConfigShare struct {
+	Timeout    int64 `json:"timeout"`    // seconds
+	MaxTimeout int64 `json:"maxTimeout"` // seconds
+}
+
+// ConfigCustomFormats represents configuration for reading and writing
+// registered clipboard formats by name
+type ConfigCustomFormats struct {
+	Enable  bool     `json:"enable"`
+	Allow   []string `json:"allow"`   // allowed format names, empty allows any
+	MaxSize int64    `json:"maxSize"` // MB
+}
+
+// ConfigCharset represents configuration for text of legacy apps in ansi
+// code pages
+type ConfigCharset struct {
+	Read  string `json:"read"`  // charset of CF_TEXT placed by apps, e.g. gbk
+	Write string `json:"write"` // charset of CF_TEXT set along with unicode text
+}
+
+// ConfigLineEndings represents configuration for normalizing line endings
+// of text, lf or crlf, empty keeps them as is
+type ConfigLineEndings struct {
+	Read  string `json:"read"`  // text read by clients
+	Write string `json:"write"` // text set on clipboard by clients
+}
+
+// ConfigThumbnail represents configuration for thumbnails of gif and video
+// sent as media
+type ConfigThumbnail struct {
+	Enable bool   `json:"enable"`
+	Size   int    `json:"size"`   // pixels of the longer side
+	FFmpeg string `json:"ffmpeg"` // path of ffmpeg for thumbnails of video
+}
+
+// ConfigMarkdown represents configuration for rendering markdown text to
+// html on clipboard
+type ConfigMarkdown struct {
+	Enable  bool     `json:"enable"`
+	Clients []string `json:"clients"` // client names whose text is markdown without X-Text-Format
+}
+
+// ConfigTransform represents configuration for a transform of text
+type ConfigTransform struct {
+	Type        string `json:"type"`        // trim, strip-tracking, replace, upper or lower
+	Pattern     string `json:"pattern"`     // regular expression of replace
+	Replacement string `json:"replacement"` // replacement of replace, $1 for submatches
+	On          string `json:"on"`          // set, get, or both if empty
+}
+
+// ConfigTransforms represents configuration for transforming text set and
+// got by clients in order of rules
+type ConfigTransforms struct {
+	Enable bool              `json:"enable"` // toggled from tray as well
+	Rules  []ConfigTransform `json:"rules"`
+}
+
+// ConfigHistory represents configuration for recording clipboard history
+// to a sqlite database
+type ConfigHistory struct {
+	Enable         bool                `json:"enable"`
+	Local          bool                `json:"local"`          // record copies on windows, not only content passing through server
+	MaxEntries     int                 `json:"maxEntries"`     // the oldest entries beyond it are removed, 0 for unlimited
+	MaxContentSize int64               `json:"maxContentSize"` // in MB, only preview of larger content is kept
+	Encrypt        bool                `json:"encrypt"`        // encrypt previews and content at rest, implied by lock.enable
+	Passphrase     string              `json:"passphrase"`     // key of encryption is derived from it, or kept in history.key by DPAPI if it's empty
+	TrashDays      int                 `json:"trashDays"`      // deleted entries can be recovered within it, 0 to remove them at once
+	Backup         ConfigHistoryBackup `json:"backup"`
+	Lock           ConfigHistoryLock   `json:"lock"`
+}
+
+// ConfigHistoryLock represents configuration for locking history until it's
+// unlocked by history.passphrase
+type ConfigHistoryLock struct {
+	Enable  bool  `json:"enable"`
+	Timeout int64 `json:"timeout"` // minutes history stays unlocked
+}
+
+// ConfigHistoryBackup represents configuration for periodic snapshots of
+// history database
+type ConfigHistoryBackup struct {
+	Enable   bool   `json:"enable"`
+	Dir      string `json:"dir"`      // relative to the executable unless it's absolute
+	Interval int64  `json:"interval"` // hours
+	Keep     int    `json:"keep"`     // the oldest backups beyond it are removed, 0 to keep all
+}
+
+// ConfigQueue represents configuration for queue of content pushed on
+// windows for devices to take one by one
+type ConfigQueue struct {
+	Watch bool `json:"watch"` // push every copy on windows, not only by tray menu
+}
+
+// ConfigOutbox represents configuration for keeping webhook events and bridge
+// content failed to be delivered as the target is unreachable
+type ConfigOutbox struct {
+	Enable bool  `json:"enable"`
+	MaxAge int64 `json:"maxAge"` // hours to retry, content older is dropped
+}
+
 // DefaultConfig is a default configuration for application
 var DefaultConfig = Config{
 	Port:                  "8086",
 	Authkey:               "",
 	AuthkeyExpiredTimeout: 30,
+	AuthToken:             "",
+	EncryptionKey:         "",
+	ProtectSecrets:        true,
+	Pairing:               false,
+	LANOnly:               false,
+	AllowIPs:              []string{},
+	DenyIPs:               []string{},
 	LogLevel:              logrus.WarnLevel,
 	TempDir:               "./temp",
 	ReserveHistory:        false,
+	Audit:                 true,
 	Notify: ConfigNotify{
 		Copy:  false,
 		Paste: false,
 	},
+	TLS: ConfigTLS{
+		Enable:     false,
+		CertFile:   "cert.pem",
+		KeyFile:    "key.pem",
+		ClientAuth: false,
+		CACertFile: "ca.pem",
+		CAKeyFile:  "ca-key.pem",
+		ACME: ConfigACME{
+			Enable:       false,
+			Domains:      []string{},
+			Email:        "",
+			DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+			RenewBefore:  30,
+		},
+		FingerprintPort: "8087",
+		HTTP3:           false,
+	},
+	RateLimit: ConfigRateLimit{
+		Enable: false,
+		Rate:   1,
+		Burst:  10,
+	},
+	Signature: ConfigSignature{
+		Enable:  false,
+		Key:     "",
+		MaxSkew: 300,
+	},
+	Devices: map[string]ConfigDevice{},
+	ConfirmRead: ConfigConfirmRead{
+		Enable:  false,
+		Timeout: 30,
+	},
+	Sensitive: ConfigSensitive{
+		Enable:   false,
+		Patterns: append([]string{}, utils.DefaultSensitivePatterns...),
+		Action:   "block",
+	},
+	Lockout: ConfigLockout{
+		Enable:      true,
+		MaxFailures: 5,
+		Window:      300,
+		BanDuration: 900,
+		Notify:      true,
+	},
+	DownloadLink: ConfigDownloadLink{
+		Timeout: 600,
+	},
+	TOTP: ConfigTOTP{
+		Enable: false,
+		Secret: "",
+		Skew:   1,
+	},
+	Disabled: []string{},
+	Tailscale: ConfigTailscale{
+		Enable:     false,
+		Users:      map[string]string{},
+		Tailscaled: DefaultTailscaled,
+	},
+	UnknownClientAlert: true,
+	Privacy:            true,
+	Discovery: ConfigDiscovery{
+		Enable: false,
+		Port:   "8086",
+		Name:   "",
+	},
+	Local: ConfigLocal{
+		Enable: false,
+		Port:   "8088",
+	},
+	Webhook: ConfigWebhook{
+		Enable:  false,
+		URLs:    []string{},
+		Secret:  "",
+		Timeout: 10,
+	},
+	MQTT: ConfigMQTT{
+		Enable:      false,
+		Broker:      "tcp://127.0.0.1:1883",
+		Topic:       "clipboard-online/events",
+		ClientID:    "clipboard-online",
+		Username:    "",
+		Password:    "",
+		Retain:      false,
+		IncludeText: false,
+	},
+	Listen: []string{},
+	PortMapping: ConfigPortMapping{
+		Enable:       false,
+		ExternalPort: "",
+	},
+	Proxy: ConfigProxy{
+		TrustedProxies: []string{},
+		BasePath:       "",
+	},
+	IdempotencyWindow: 600,
+	RequestTimeout:    600,
+	Bridge: ConfigBridge{
+		Enable:      false,
+		URL:         "",
+		ClientName:  "",
+		AuthToken:   "",
+		DeviceToken: "",
+		Fingerprint: "",
+	},
+	Fetch: ConfigFetch{
+		Enable:       false,
+		Schemes:      []string{"https", "http"},
+		MaxSize:      1024,
+		Timeout:      300,
+		AllowPrivate: false,
+	},
+	Share: ConfigShare{
+		Timeout:    3600,
+		MaxTimeout: 7 * 24 * 3600,
+	},
+	PasteMediaAs: PasteAsFile,
+	CustomFormats: ConfigCustomFormats{
+		Enable:  false,
+		Allow:   []string{},
+		MaxSize: 64,
+	},
+	Charset: ConfigCharset{
+		Read:  "",
+		Write: "",
+	},
+	LineEndings: ConfigLineEndings{
+		Read:  "",
+		Write: "",
+	},
+	Thumbnail: ConfigThumbnail{
+		Enable: true,
+		Size:   256,
+		FFmpeg: "",
+	},
+	Markdown: ConfigMarkdown{
+		Enable:  false,
+		Clients: []string{},
+	},
+	DecodeImageText: false,
+	Transforms: ConfigTransforms{
+		Enable: false,
+		Rules: []ConfigTransform{
+			{Type: utils.TransformTrim, On: TransformOnSet},
+			{Type: utils.TransformStripTracking},
+		},
+	},
+	History: ConfigHistory{
+		Enable:         false,
+		Local:          false,
+		MaxEntries:     1000,
+		MaxContentSize: 10,
+		Encrypt:        false,
+		Passphrase:     "",
+		TrashDays:      7,
+		Backup: ConfigHistoryBackup{
+			Enable:   true,
+			Dir:      HistoryBackupDir,
+			Interval: 24,
+			Keep:     7,
+		},
+		Lock: ConfigHistoryLock{
+			Enable:  false,
+			Timeout: 5,
+		},
+	},
+	Queue: ConfigQueue{
+		Watch: false,
+	},
+	Outbox: ConfigOutbox{
+		Enable: true,
+		MaxAge: 24,
+	},
+	ProtobufMaxSize: 64,
 }
 
 func loadConfig(path string) (*Config, error) {
@@ -59,17 +542,82 @@ func loadConfigFromFile(path string) (*Config, error) {
 	if err := json.Unmarshal(configBytes, &DefaultConfig); err != nil {
 		return nil, err
 	}
+	hasPlaintext, err := DefaultConfig.decryptSecrets()
+	if err != nil {
+		return nil, err
+	}
+	if hasPlaintext && DefaultConfig.ProtectSecrets {
+		// migrate plaintext secrets written by user or older versions
+		if err := saveConfig(path, &DefaultConfig); err != nil {
+			return nil, err
+		}
+	}
 	return &DefaultConfig, nil
 }
 
 func createConfigFile(path string) error {
-	defaultConfigJSON, err := json.MarshalIndent(DefaultConfig, "", "  ")
+	return saveConfig(path, &DefaultConfig)
+}
+
+func saveConfig(path string, config *Config) error {
+	configToSave := *config
+	if config.ProtectSecrets {
+		if err := configToSave.encryptSecrets(); err != nil {
+			return err
+		}
+	}
+
+	configJSON, err := json.MarshalIndent(configToSave, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	if err := ioutil.WriteFile(path, []byte(defaultConfigJSON), 0744); err != nil {
+	if err := ioutil.WriteFile(path, []byte(configJSON), 0744); err != nil {
 		return err
 	}
 	return nil
 }
+
+// secretFields returns pointers to fields holding secrets
+func (c *Config) secretFields() []*string {
+	return []*string{&c.Authkey, &c.AuthToken, &c.EncryptionKey, &c.Signature.Key, &c.TOTP.Secret, &c.Webhook.Secret, &c.MQTT.Password, &c.Bridge.AuthToken, &c.Bridge.DeviceToken, &c.History.Passphrase}
+}
+
+// encryptSecrets encrypts non-empty secrets by DPAPI and prefixes them with secretPrefix
+func (c *Config) encryptSecrets() error {
+	for _, field := range c.secretFields() {
+		if *field == "" || strings.HasPrefix(*field, secretPrefix) {
+			continue
+		}
+		protected, err := utils.ProtectData([]byte(*field))
+		if err != nil {
+			return err
+		}
+		*field = secretPrefix + base64.StdEncoding.EncodeToString(protected)
+	}
+	return nil
+}
+
+// decryptSecrets decrypts secrets encrypted by encryptSecrets and reports
+// whether there are any plaintext secrets
+func (c *Config) decryptSecrets() (hasPlaintext bool, err error) {
+	for _, field := range c.secretFields() {
+		if *field == "" {
+			continue
+		}
+		if !strings.HasPrefix(*field, secretPrefix) {
+			hasPlaintext = true
+			continue
+		}
+		protected, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*field, secretPrefix))
+		if err != nil {
+			return false, err
+		}
+		secret, err := utils.UnprotectData(protected)
+		if err != nil {
+			return false, err
+		}
+		*field = string(secret)
+	}
+	return hasPlaintext, nil
+}
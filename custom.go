@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// auditTypeCustom is audit type of reading and writing custom formats
+const auditTypeCustom = "custom"
+
+// CustomFormats is data of registered clipboard formats keyed by name, e.g.
+// "XML Spreadsheet" or "Biff12" of Excel. Data is base64 in json
+type CustomFormats struct {
+	Formats map[string][]byte `json:"formats"`
+}
+
+// customFormatAllowed reports whether format name is in
+// customFormats.allow, or any name if it's empty
+func customFormatAllowed(name string) bool {
+	if len(app.config.CustomFormats.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range app.config.CustomFormats.Allow {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCustomFormats responds error if custom formats are disabled or one
+// of names is not allowed
+func checkCustomFormats(c *gin.Context, names []string) bool {
+	if !app.config.CustomFormats.Enable {
+		respondError(c, http.StatusForbidden, "custom_formats_disabled", "服务端未开启自定义格式")
+		return false
+	}
+	for _, name := range names {
+		if name == "" {
+			respondError(c, http.StatusBadRequest, "invalid_format", "格式名称不能为空")
+			return false
+		}
+		if !customFormatAllowed(name) {
+			respondError(c, http.StatusForbidden, "format_not_allowed", "不允许的格式："+name)
+			return false
+		}
+	}
+	return true
+}
+
+// customFormatsPreview describes formats for approval and notifications
+func customFormatsPreview(names []string) string {
+	sort.Strings(names)
+	return "[自定义格式] " + strings.Join(names, ", ")
+}
+
+// getCustomFormatsHandler responds data of formats of query, formats that
+// are not on clipboard are omitted
+func getCustomFormatsHandler(c *gin.Context) {
+	names := c.QueryArray("format")
+	if len(names) == 0 {
+		respondError(c, http.StatusBadRequest, "missing_format", "缺少 format 参数")
+		return
+	}
+	if !checkCustomFormats(c, names) {
+		return
+	}
+	data, err := utils.Clipboard().FormatData(names)
+	if err != nil {
+		log.WithError(err).Warn("failed to get custom formats of clipboard")
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	if len(data) == 0 {
+		respondError(c, http.StatusNotFound, "format_not_found", "剪切板中没有指定的格式")
+		return
+	}
+	found := make([]string, 0, len(data))
+	size := 0
+	for name, d := range data {
+		found = append(found, name)
+		size += len(d)
+	}
+	preview := customFormatsPreview(found)
+	if !approveRead(c, preview) {
+		return
+	}
+	setAuditInfo(c, auditTypeCustom, size)
+	c.JSON(http.StatusOK, CustomFormats{data})
+	sendCopyNotification(log, c.GetString("clientName"), preview+" 被复制")
+}
+
+// setCustomFormatsHandler replaces clipboard with formats of body
+func setCustomFormatsHandler(c *gin.Context) {
+	if !checkCustomFormats(c, nil) {
+		return
+	}
+	if isEncrypted(c) {
+		respondError(c, http.StatusBadRequest, "encryption_unsupported", "加密请求不支持自定义格式")
+		return
+	}
+	if !prepareSet(c) {
+		return
+	}
+	maxSize := app.config.CustomFormats.MaxSize << 20
+	data, err := ioutil.ReadAll(io.LimitReader(utils.ContextReader(c.Request.Context(), c.Request.Body), maxSize+1))
+	if err != nil {
+		log.WithError(err).Warn("failed to read custom formats body")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("请求内容超过 %d MB", app.config.CustomFormats.MaxSize))
+		return
+	}
+	var body CustomFormats
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Formats) == 0 {
+		respondError(c, http.StatusBadRequest, "invalid_body", "请求内容格式错误")
+		return
+	}
+	names := make([]string, 0, len(body.Formats))
+	size := 0
+	for name, d := range body.Formats {
+		names = append(names, name)
+		size += len(d)
+	}
+	if !checkCustomFormats(c, names) {
+		return
+	}
+
+	if err := utils.Clipboard().SetFormats(body.Formats); err != nil {
+		log.WithError(err).Warn("failed to set custom formats")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	scheduleClipboardExpiry(c.GetInt("expireSeconds"))
+	preview := customFormatsPreview(names)
+	log.WithField("formats", names).Info("set clipboard custom formats")
+	setAuditInfo(c, auditTypeCustom, size)
+	c.Status(http.StatusOK)
+	sendPasteNotification(log, c.GetString("clientName"), preview+" 已复制到剪贴板")
+}
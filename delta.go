@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxTextVersions     = 8
+	maxTextVersionsSize = 64 << 20
+)
+
+// TextDelta is body of delta sync. Base is hash of text ops apply to, empty
+// base means empty text. Hash is hash of text after applying ops
+type TextDelta struct {
+	Base string          `json:"base"`
+	Hash string          `json:"hash"`
+	Ops  []utils.DeltaOp `json:"ops"`
+}
+
+// TextVersions keeps recent texts read or written by clients, so deltas can
+// be computed against the version a client already has
+type TextVersions struct {
+	mu    sync.Mutex
+	texts []string // oldest first
+	size  int
+}
+
+func NewTextVersions() *TextVersions {
+	return &TextVersions{}
+}
+
+// Add remembers text, oldest texts are dropped when there are too many or
+// they are too large in total
+func (v *TextVersions) Add(text string) {
+	if len(text) > maxTextVersionsSize {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, t := range v.texts {
+		if t == text {
+			v.texts = append(v.texts[:i], v.texts[i+1:]...)
+			v.size -= len(t)
+			break
+		}
+	}
+	v.texts = append(v.texts, text)
+	v.size += len(text)
+	for len(v.texts) > maxTextVersions || v.size > maxTextVersionsSize {
+		v.size -= len(v.texts[0])
+		v.texts = v.texts[1:]
+	}
+}
+
+// Get returns text of hash
+func (v *TextVersions) Get(hash string) (string, bool) {
+	if hash == "" {
+		return "", true
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := len(v.texts) - 1; i >= 0; i-- {
+		if utils.TextHash(v.texts[i]) == hash {
+			return v.texts[i], true
+		}
+	}
+	return "", false
+}
+
+// getDeltaHandler responds ops turning text of base query into clipboard
+// text. Ops are against empty text if base is unknown, i.e. the whole text
+// is inserted
+func getDeltaHandler(c *gin.Context) {
+	if isEncrypted(c) {
+		respondError(c, http.StatusBadRequest, "encryption_unsupported", "加密请求不支持增量同步")
+		return
+	}
+	setSequenceHeader(c)
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil || contentType != utils.TypeText {
+		respondError(c, http.StatusConflict, "not_text", "剪切板内容不是文本")
+		return
+	}
+	str, err := clipboardText()
+	if err != nil {
+		log.WithError(err).Warn("failed to get clipboard")
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	str, ok := filterSensitiveText(c, str)
+	if !ok || !approveRead(c, str) {
+		return
+	}
+
+	base := c.Query("base")
+	baseText, ok := app.textVersions.Get(base)
+	if !ok {
+		base, baseText = "", ""
+	}
+	app.textVersions.Add(str)
+	delta := TextDelta{Base: base, Hash: utils.TextHash(str), Ops: utils.Diff(baseText, str)}
+	log.WithField("ops", len(delta.Ops)).Info("get clipboard text delta")
+	setAuditInfo(c, utils.TypeText, len(str))
+	c.JSON(http.StatusOK, delta)
+	sendCopyNotification(log, c.GetString("clientName"), notificationPreview(str))
+}
+
+// patchDeltaHandler applies delta to its base and sets result to clipboard.
+// Base must be current clipboard text or a text recently read or written by
+// a client, otherwise 409 is responded and client should send whole text
+func patchDeltaHandler(c *gin.Context) {
+	if isEncrypted(c) {
+		respondError(c, http.StatusBadRequest, "encryption_unsupported", "加密请求不支持增量同步")
+		return
+	}
+	if !prepareSet(c) {
+		return
+	}
+	var delta TextDelta
+	if err := c.ShouldBindJSON(&delta); err != nil {
+		log.WithError(err).Warn("failed to bind delta body")
+		respondError(c, http.StatusBadRequest, "invalid_body", "请求内容格式错误")
+		return
+	}
+
+	baseText, ok := app.textVersions.Get(delta.Base)
+	if !ok {
+		if str, err := clipboardText(); err == nil && utils.TextHash(str) == delta.Base {
+			baseText, ok = str, true
+		}
+	}
+	if !ok {
+		respondError(c, http.StatusConflict, "unknown_base", "基准版本不存在，请发送完整内容")
+		return
+	}
+	text, err := utils.ApplyDelta(baseText, delta.Ops)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_delta", "增量内容与基准版本不匹配")
+		return
+	}
+	hash := utils.TextHash(text)
+	if delta.Hash != "" && delta.Hash != hash {
+		respondError(c, http.StatusBadRequest, "hash_mismatch", "应用增量后的内容校验失败")
+		return
+	}
+
+	if err := putClipboardText(c, text); err != nil {
+		log.WithError(err).Warn("failed to set clipboard")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	c.JSON(http.StatusOK, TextDelta{Base: delta.Base, Hash: hash})
+}
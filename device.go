@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+)
+
+const DevicesFile = "devices.json"
+
+var errDeviceAlreadyPaired = errors.New("device is already paired")
+
+// Device represents a paired client
+type Device struct {
+	Name      string    `json:"name"`
+	TokenHash string    `json:"tokenHash"`
+	PairedAt  time.Time `json:"pairedAt"`
+}
+
+// DeviceStore keeps paired devices and persists them to file
+type DeviceStore struct {
+	mu      sync.RWMutex
+	path    string
+	devices map[string]*Device
+}
+
+func loadDeviceStore(path string) (*DeviceStore, error) {
+	store := &DeviceStore{path: path, devices: make(map[string]*Device)}
+	if !utils.IsExistFile(path) {
+		return store, nil
+	}
+	devicesBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(devicesBytes, &store.devices); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Get returns paired device by name
+func (s *DeviceStore) Get(name string) (*Device, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	device, ok := s.devices[name]
+	return device, ok
+}
+
+// Pair saves device with name and returns a new token as its credential.
+// errDeviceAlreadyPaired is returned if name is paired, so that its token
+// can't be replaced by another device
+func (s *DeviceStore) Pair(name string) (string, error) {
+	token, err := utils.SecureRandString(32)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[name]; ok {
+		return "", errDeviceAlreadyPaired
+	}
+	s.devices[name] = &Device{
+		Name:      name,
+		TokenHash: hashToken(token),
+		PairedAt:  time.Now(),
+	}
+	if err := s.save(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Verify reports whether token is the credential of device with name
+func (s *DeviceStore) Verify(name, token string) bool {
+	device, ok := s.Get(name)
+	if !ok || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(device.TokenHash), []byte(hashToken(token))) == 1
+}
+
+func (s *DeviceStore) save() error {
+	devicesBytes, err := json.MarshalIndent(s.devices, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, devicesBytes, 0600)
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+)
+
+const discoveryProbe = "who is clipboard-online?"
+
+// DiscoveryResponse is the reply of discovery probe
+type DiscoveryResponse struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Port string `json:"port"`
+}
+
+// RunDiscoveryResponder answers udp broadcast probes with address of http
+// server, so clients can find it where mDNS is blocked
+func (app *Application) RunDiscoveryResponder() {
+	if !app.config.Discovery.Enable {
+		return
+	}
+	go func() {
+		if err := app.serveDiscovery(); err != nil {
+			log.WithError(err).Warn("failed to run discovery responder")
+		}
+	}()
+}
+
+// serverName returns discovery.name, or host name if it's not configured
+func (app *Application) serverName() string {
+	if app.config.Discovery.Name != "" {
+		return app.config.Discovery.Name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+func (app *Application) serveDiscovery() error {
+	conn, err := net.ListenPacket("udp4", ":"+app.config.Discovery.Port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	name := app.serverName()
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(buf[:n])) != discoveryProbe {
+			continue
+		}
+		response, err := json.Marshal(DiscoveryResponse{
+			Name: name,
+			URL:  app.serverURLFor(addr),
+			Port: app.serverPort(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := conn.WriteTo(response, addr); err != nil {
+			log.WithError(err).WithField("addr", addr.String()).Debug("failed to reply discovery probe")
+		}
+	}
+}
+
+// serverURLFor returns url of http server on local address which is used to
+// reach remote
+func (app *Application) serverURLFor(remote net.Addr) string {
+	// dialing udp only selects route and local address without sending packets
+	conn, err := net.Dial("udp4", remote.String())
+	if err != nil {
+		return app.serverURLs()[0]
+	}
+	defer conn.Close()
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	return app.serverURL(localIP.String(), app.serverPort())
+}
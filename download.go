@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditActionDownload is audit action of downloading by one-time link
+const AuditActionDownload = "download"
+
+type downloadLink struct {
+	paths     []string
+	expiresAt time.Time
+}
+
+// DownloadLinkManager keeps single-use links for downloading files without
+// authentication
+type DownloadLinkManager struct {
+	mu    sync.Mutex
+	links map[string]downloadLink
+}
+
+func NewDownloadLinkManager() *DownloadLinkManager {
+	return &DownloadLinkManager{links: make(map[string]downloadLink)}
+}
+
+// Mint returns token of a link to paths which expires after ttl
+func (m *DownloadLinkManager) Mint(paths []string, ttl time.Duration) (string, time.Time, error) {
+	token, err := utils.SecureRandString(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for t, link := range m.links {
+		if now.After(link.expiresAt) {
+			delete(m.links, t)
+		}
+	}
+	expiresAt := now.Add(ttl)
+	m.links[token] = downloadLink{paths: paths, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// Consume returns paths of link and invalidates it
+func (m *DownloadLinkManager) Consume(token string) ([]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, ok := m.links[token]
+	if !ok {
+		return nil, false
+	}
+	delete(m.links, token)
+	if time.Now().After(link.expiresAt) {
+		return nil, false
+	}
+	return link.paths, true
+}
+
+// DownloadLink is response body of a minted one-time link
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// createDownloadLinkHandler mints a one-time link of files in clipboard
+func createDownloadLinkHandler(c *gin.Context) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil || contentType != utils.TypeFile {
+		respondError(c, http.StatusBadRequest, "no_files", "剪切板中没有文件")
+		return
+	}
+	paths, err := utils.Clipboard().Files()
+	if err != nil || len(paths) == 0 {
+		log.WithError(err).Warn("failed to get path of files from clipboard")
+		respondError(c, http.StatusBadRequest, "no_files", "剪切板中没有文件")
+		return
+	}
+	basenames := make([]string, 0, len(paths))
+	for _, path := range paths {
+		basenames = append(basenames, filepath.Base(path))
+	}
+	// the link can be handed to anyone, so names of files are checked like
+	// text and reading is approved before it's minted
+	preview := "[文件] " + strings.Join(basenames, ", ")
+	if _, ok := filterSensitiveText(c, preview); !ok {
+		return
+	}
+	if !approveRead(c, preview) {
+		return
+	}
+
+	ttl := time.Duration(app.config.DownloadLink.Timeout) * time.Second
+	token, expiresAt, err := app.downloads.Mint(paths, ttl)
+	if err != nil {
+		log.WithError(err).Warn("failed to mint download link")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	log.WithField("files", len(paths)).Info("download link created")
+	c.JSON(http.StatusOK, DownloadLink{requestBaseURL(c) + "/download/" + token, expiresAt})
+}
+
+// downloadHandler serves files of a one-time link, multiple files are
+// packed into a zip archive
+func downloadHandler(c *gin.Context) {
+	paths, ok := app.downloads.Consume(c.Param("token"))
+	if !ok {
+		respondError(c, http.StatusNotFound, "link_not_found", "链接无效或已过期")
+		return
+	}
+
+	if len(paths) == 1 {
+		info, err := os.Stat(paths[0])
+		if err == nil && !info.IsDir() {
+			setAuditInfo(c, utils.TypeFile, int(info.Size()))
+			c.FileAttachment(paths[0], filepath.Base(paths[0]))
+			return
+		}
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="clipboard.zip"`)
+	c.Status(http.StatusOK)
+	size, err := writeZip(c.Request.Context(), c.Writer, paths)
+	if err != nil {
+		log.WithError(err).Warn("failed to write zip")
+	}
+	setAuditInfo(c, utils.TypeFile, int(size))
+}
+
+// writeZip writes files and directories of paths to w as a zip archive and
+// returns total size of files. It stops when ctx is done
+func writeZip(ctx context.Context, w io.Writer, paths []string) (int64, error) {
+	zw := zip.NewWriter(w)
+	var size int64
+	for _, root := range paths {
+		base := filepath.Dir(root)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(base, path)
+			if err != nil {
+				return err
+			}
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(name)
+			if info.IsDir() {
+				header.Name += "/"
+				_, err = zw.CreateHeader(header)
+				return err
+			}
+			header.Method = zip.Deflate
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			n, err := io.Copy(fw, utils.ContextReader(ctx, f))
+			size += n
+			return err
+		})
+		if err != nil {
+			return size, err
+		}
+	}
+	return size, zw.Close()
+}
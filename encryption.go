@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// isEncrypted reports whether payloads of request and response are
+// encrypted by AES-GCM with encryptionKey
+func isEncrypted(c *gin.Context) bool {
+	return c.GetHeader("X-Encrypted") == "1"
+}
+
+// encryption rejects encrypted requests if encryptionKey is not configured
+func encryption() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isEncrypted(c) {
+			c.Next()
+			return
+		}
+		if app.config.EncryptionKey == "" {
+			abortWithError(c, http.StatusBadRequest, "encryption_key_missing", "服务器未设置加密密钥")
+			return
+		}
+		c.Header("X-Encrypted", "1")
+		c.Next()
+	}
+}
+
+// encodeContent returns base64 string of data which is encrypted if required by request
+func encodeContent(c *gin.Context, data []byte) (string, error) {
+	if !isEncrypted(c) {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return encryptPayload(data)
+}
+
+// encodeText returns text as is or base64 string of encrypted text if required by request
+func encodeText(c *gin.Context, text string) (string, error) {
+	if !isEncrypted(c) {
+		return text, nil
+	}
+	return encryptPayload([]byte(text))
+}
+
+func encryptPayload(data []byte) (string, error) {
+	ciphertext, err := utils.Encrypt(utils.DeriveKey(app.config.EncryptionKey), data)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func decryptPayload(payload string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	return decryptBytes(ciphertext)
+}
+
+func decryptBytes(ciphertext []byte) ([]byte, error) {
+	return utils.Decrypt(utils.DeriveKey(app.config.EncryptionKey), ciphertext)
+}
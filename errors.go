@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const v2Prefix = "/v2/"
+
+// isV2 reports whether request targets the v2 api
+func isV2(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, v2Prefix) || c.Request.URL.Path == strings.TrimSuffix(v2Prefix, "/")
+}
+
+// ErrorResponse is error response body of v1
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ErrorDetail is machine readable code and message of an error
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// V2ErrorResponse is error response body of v2
+type V2ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// errorBody builds error response body. v1 keeps the plain message while v2
+// wraps it in an envelope with a machine readable code
+func errorBody(c *gin.Context, code, message string) interface{} {
+	if !isV2(c) {
+		return ErrorResponse{message}
+	}
+	return V2ErrorResponse{ErrorDetail{code, message}}
+}
+
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, errorBody(c, code, message))
+}
+
+func respondError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, errorBody(c, code, message))
+}
+
+// v2Errors makes sure v2 error responses always carry an envelope, even
+// when handler only sets a status code
+func v2Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		status := c.Writer.Status()
+		if status < http.StatusBadRequest || c.Writer.Written() {
+			return
+		}
+		c.JSON(status, V2ErrorResponse{ErrorDetail{statusCode(status), http.StatusText(status)}})
+	}
+}
+
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	default:
+		return "internal_error"
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+)
+
+const (
+	EventClipboard = "clipboard"
+	EventTransfer  = "transfer"
+	EventProgress  = "progress"
+)
+
+const eventBufferSize = 16
+
+// Event is pushed to listeners when clipboard changes or a transfer
+// completes. Contents are not included, listeners should get them from api
+// so that permissions and approvals still apply
+type Event struct {
+	Event      string    `json:"event"`
+	Sequence   uint32    `json:"sequence,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	Action     string    `json:"action,omitempty"`
+	ClientName string    `json:"clientName,omitempty"`
+	Size       int       `json:"size,omitempty"`
+	Transfer   *Transfer `json:"transfer,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// EventHub broadcasts events to subscribers
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving published events
+func (h *EventHub) Subscribe() chan Event {
+	ch := make(chan Event, eventBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe stops sending events to ch
+func (h *EventHub) Unsubscribe(ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, ch)
+}
+
+// Publish sends event to all subscribers, events are dropped for subscribers
+// which are too slow to receive them
+func (h *EventHub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// publishClipboardChanged is attached to clipboard listener
+func publishClipboardChanged() {
+	now := time.Now()
+	app.changes.Touch(now)
+	recordLocalCopy()
+	watchQueue()
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		contentType = utils.TypeUnknown
+	}
+	app.events.Publish(Event{
+		Event:    EventClipboard,
+		Sequence: utils.Clipboard().SequenceNumber(),
+		Type:     contentType,
+		Time:     now,
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// parseExpireSeconds returns seconds in X-Expire-Seconds header, 0 if absent
+func parseExpireSeconds(c *gin.Context) (int, error) {
+	header := c.GetHeader("X-Expire-Seconds")
+	if header == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0, errors.New("invalid X-Expire-Seconds")
+	}
+	return seconds, nil
+}
+
+// scheduleClipboardExpiry clears clipboard after seconds if it still holds
+// the content just set
+func scheduleClipboardExpiry(seconds int) {
+	if seconds <= 0 {
+		return
+	}
+	seq := utils.Clipboard().SequenceNumber()
+	time.AfterFunc(time.Duration(seconds)*time.Second, func() {
+		if utils.Clipboard().SequenceNumber() != seq {
+			log.Debug("clipboard changed, skip clearing expired content")
+			return
+		}
+		if err := utils.Clipboard().Clear(); err != nil {
+			log.WithError(err).Warn("failed to clear expired clipboard")
+			return
+		}
+		log.Info("expired clipboard cleared")
+	})
+}
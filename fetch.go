@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const maxFetchRedirects = 5
+
+var (
+	errFetchScheme   = errors.New("url scheme is not allowed")
+	errFetchAddress  = errors.New("fetching from private address is not allowed")
+	errFetchTooLarge = errors.New("fetched resource is too large")
+)
+
+// FetchBody is request body of setting clipboard by url
+type FetchBody struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// fetchHandler downloads resource of url and puts it on clipboard as a
+// file, so that phone doesn't need to download and upload it again
+func fetchHandler(c *gin.Context) {
+	if !app.config.Fetch.Enable {
+		respondError(c, http.StatusForbidden, "fetch_disabled", "服务端未开启链接下载")
+		return
+	}
+	if !prepareSet(c) {
+		return
+	}
+	var body FetchBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		log.WithError(err).Warn("failed to bind fetch body")
+		respondError(c, http.StatusBadRequest, "invalid_body", "请求内容格式错误")
+		return
+	}
+	u, err := url.Parse(body.URL)
+	if err != nil || u.Host == "" {
+		respondError(c, http.StatusBadRequest, "invalid_url", "链接格式错误")
+		return
+	}
+	if !fetchSchemeAllowed(u.Scheme) {
+		respondError(c, http.StatusBadRequest, "scheme_not_allowed", "不允许的链接协议："+u.Scheme)
+		return
+	}
+
+	path, size, mediaType, err := fetchFile(c.Request.Context(), u)
+	switch {
+	case err == errFetchTooLarge:
+		respondError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("文件超过 %d MB", app.config.Fetch.MaxSize))
+		return
+	case err == errFetchAddress:
+		respondError(c, http.StatusForbidden, "address_not_allowed", "不允许下载内网地址")
+		return
+	case err != nil:
+		log.WithError(err).WithField("url", contentSummary(body.URL)).Warn("failed to fetch url")
+		respondError(c, http.StatusBadGateway, "fetch_failed", "无法下载链接内容")
+		return
+	}
+
+	contentType := utils.TypeFile
+	if strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "video/") {
+		contentType = utils.TypeMedia
+	}
+	log.WithField("path", contentSummary(path)).WithField("size", size).Info("fetched url")
+	setClipboardFiles(c, contentType, []string{path}, int(size))
+}
+
+func fetchSchemeAllowed(scheme string) bool {
+	for _, s := range app.config.Fetch.Schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchClient returns client checking scheme of redirects and, unless
+// fetch.allowPrivate, address of every connection, so that resolving a
+// public name to a private address is rejected as well
+func fetchClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if app.config.Fetch.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || utils.IsInternalIP(ip) || ip.IsUnspecified() || ip.IsMulticast() {
+				return errFetchAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return errors.New("too many redirects")
+			}
+			if !fetchSchemeAllowed(req.URL.Scheme) {
+				return errFetchScheme
+			}
+			return nil
+		},
+	}
+}
+
+// fetchFile downloads u to temp directory and returns path, size and media
+// type of it
+func fetchFile(ctx context.Context, u *url.URL) (string, int64, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(app.config.Fetch.Timeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", 0, "", err
+	}
+	resp, err := fetchClient().Do(req)
+	if err != nil {
+		if errors.Is(err, errFetchAddress) {
+			return "", 0, "", errFetchAddress
+		}
+		return "", 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	maxSize := app.config.Fetch.MaxSize << 20
+	if resp.ContentLength > maxSize {
+		return "", 0, "", errFetchTooLarge
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	path := utils.LatestFilename(app.GetTempFilePath(fetchFilename(resp, mediaType)))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, "", err
+	}
+	size, err := io.Copy(file, io.LimitReader(resp.Body, maxSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > maxSize {
+		err = errFetchTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, "", err
+	}
+	return path, size, mediaType, nil
+}
+
+// fetchFilename returns name of fetched file by Content-Disposition, or
+// last segment of url, with extension of media type if it has none
+func fetchFilename(resp *http.Response, mediaType string) string {
+	var name string
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = path.Base(resp.Request.URL.Path)
+	}
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "download"
+	}
+	if path.Ext(name) == "" && mediaType != "" {
+		if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
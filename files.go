@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ClipboardFile is the information of a file in clipboard
+type ClipboardFile struct {
+	Index    int       `json:"index"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	IsDir    bool      `json:"isDir"`
+	Modified time.Time `json:"modified"`
+}
+
+// FilePage is response body of a page of clipboard files
+type FilePage struct {
+	Data []ClipboardFile `json:"data"`
+	PageInfo
+}
+
+// clipboardFiles returns paths of files in clipboard
+func clipboardFiles(c *gin.Context) ([]string, bool) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil || contentType != utils.TypeFile {
+		respondError(c, http.StatusBadRequest, "no_files", "剪切板中没有文件")
+		return nil, false
+	}
+	paths, err := utils.Clipboard().Files()
+	if err != nil {
+		log.WithError(err).Warn("failed to get path of files from clipboard")
+		respondError(c, http.StatusBadRequest, "no_files", "剪切板中没有文件")
+		return nil, false
+	}
+	return paths, true
+}
+
+// listFilesHandler responds information of files in clipboard
+func listFilesHandler(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	paths, ok := clipboardFiles(c)
+	if !ok {
+		return
+	}
+	files := make([]ClipboardFile, 0, len(paths))
+	for i, path := range paths {
+		file := ClipboardFile{Index: i, Name: filepath.Base(path)}
+		if info, err := os.Stat(path); err == nil {
+			file.Size = info.Size()
+			file.IsDir = info.IsDir()
+			file.Modified = info.ModTime()
+		}
+		if page.after(file.Modified) {
+			files = append(files, file)
+		}
+	}
+	start, end := page.bounds(len(files))
+	c.JSON(http.StatusOK, FilePage{files[start:end], page.info(len(files))})
+}
+
+// fileHandler streams file at index of clipboard files with support of
+// Range requests, so large files can be fetched efficiently and resumed
+func fileHandler(c *gin.Context) {
+	if isEncrypted(c) {
+		respondError(c, http.StatusBadRequest, "encryption_unsupported", "该接口不支持加密传输")
+		return
+	}
+	paths, ok := clipboardFiles(c)
+	if !ok {
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(paths) {
+		respondError(c, http.StatusNotFound, "file_not_found", "文件不存在")
+		return
+	}
+	path := paths[index]
+	f, err := os.Open(path)
+	if err != nil {
+		log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to open file")
+		respondError(c, http.StatusNotFound, "file_not_found", "文件不存在")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		respondError(c, http.StatusNotFound, "file_not_found", "文件不存在")
+		return
+	}
+
+	name := filepath.Base(path)
+	if !approveRead(c, "[文件] "+name) {
+		return
+	}
+	if info.IsDir() {
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name+".zip"))
+		c.Status(http.StatusOK)
+		size, err := writeFolderZip(c.Request.Context(), c.Writer, path)
+		if err != nil {
+			log.WithError(err).Warn("failed to write zip")
+		}
+		setAuditInfo(c, utils.TypeFile, int(size))
+		return
+	}
+	setAuditInfo(c, utils.TypeFile, int(info.Size()))
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name))
+	// content type is detected by extension or content, and range is handled
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), f)
+}
+
+// zipHandler streams all files in clipboard as a zip archive built on the fly
+func zipHandler(c *gin.Context) {
+	if isEncrypted(c) {
+		respondError(c, http.StatusBadRequest, "encryption_unsupported", "该接口不支持加密传输")
+		return
+	}
+	paths, ok := clipboardFiles(c)
+	if !ok {
+		return
+	}
+	basenames := make([]string, 0, len(paths))
+	for _, path := range paths {
+		basenames = append(basenames, filepath.Base(path))
+	}
+	if !approveRead(c, "[文件] "+strings.Join(basenames, ", ")) {
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="clipboard.zip"`)
+	c.Status(http.StatusOK)
+	size, err := writeZip(c.Request.Context(), c.Writer, paths)
+	if err != nil {
+		log.WithError(err).Warn("failed to write zip")
+	}
+	setAuditInfo(c, utils.TypeFile, int(size))
+	sendCopyNotification(log, c.GetString("clientName"), "[文件] 被复制")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// certFingerprint returns SHA-256 fingerprint of certificate of https server
+func (app *Application) certFingerprint() (string, error) {
+	certFile := app.GetExecFilePath(app.config.TLS.CertFile)
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", fmt.Errorf("no certificate found in %s", certFile)
+	}
+	return utils.CertFingerprint(block.Bytes), nil
+}
+
+// runFingerprintServer serves /fingerprint over plain http on localhost, so
+// that clients can pin certificate instead of trusting it blindly
+func (app *Application) runFingerprintServer() error {
+	engin := gin.New()
+	engin.Use(logger(), gin.Recovery())
+	engin.GET("/fingerprint", fingerprintHandler)
+	return engin.Run("127.0.0.1:" + app.config.TLS.FingerprintPort)
+}
+
+func fingerprintHandler(c *gin.Context) {
+	fingerprint, err := app.certFingerprint()
+	if err != nil {
+		log.WithError(err).Warn("failed to get certificate fingerprint")
+		respondError(c, http.StatusServiceUnavailable, "certificate_unavailable", "证书尚未生成")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sha256": fingerprint})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFolderSize limits size of files extracted from zip of a folder, which
+// may be far larger than the zip
+const maxFolderSize = 16 << 30
+
+// writeFolderZip writes files of dir to w as a zip archive with paths
+// relative to dir, and returns total size of files
+func writeFolderZip(ctx context.Context, w io.Writer, dir string) (int64, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	paths := make([]string, 0, len(infos))
+	for _, info := range infos {
+		paths = append(paths, filepath.Join(dir, info.Name()))
+	}
+	return writeZip(ctx, w, paths)
+}
+
+// readFolderContent returns encoded zip of dir and size of the zip
+func readFolderContent(c *gin.Context, dir string) (string, int, error) {
+	buf := new(bytes.Buffer)
+	if _, err := writeFolderZip(c.Request.Context(), buf, dir); err != nil {
+		return "", 0, err
+	}
+	content, err := encodeContent(c, buf.Bytes())
+	return content, buf.Len(), err
+}
+
+// folderName returns name of folder sent as name, which may have .zip
+func folderName(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), ".zip")
+}
+
+// folderZipPath returns temp path to save zip of folder name to
+func folderZipPath(name string) string {
+	return utils.LatestFilename(app.GetTempFilePath(folderName(name) + ".zip"))
+}
+
+// extractFolder extracts zip of zipPath into a folder of temp directory
+// named by name and returns its path. The zip is removed
+func extractFolder(zipPath, name string) (string, error) {
+	defer os.Remove(zipPath)
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	dir := utils.LatestFilename(app.GetTempFilePath(folderName(name)))
+	if err := utils.ExtractZip(&r.Reader, dir, maxFolderSize); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// FormatList is formats on clipboard and type of content GET / responds
+// from them, unknown if GET / would fail
+type FormatList struct {
+	ContentType string                  `json:"contentType"`
+	Data        []utils.ClipboardFormat `json:"data"`
+}
+
+// formatsHandler lists formats on clipboard without their data, so that
+// clients can see why clipboard can't be read and choose a representation
+func formatsHandler(c *gin.Context) {
+	setSequenceHeader(c)
+	formats, err := utils.Clipboard().Formats()
+	if err != nil {
+		log.WithError(err).Warn("failed to get formats of clipboard")
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	if formats == nil {
+		formats = []utils.ClipboardFormat{}
+	}
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		contentType = utils.TypeUnknown
+	}
+	c.JSON(http.StatusOK, FormatList{contentType, formats})
+}
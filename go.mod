@@ -4,10 +4,18 @@ go 1.15
 
 require (
 	github.com/gin-gonic/gin v1.7.4
+	github.com/gorilla/websocket v1.4.1
+	github.com/jackpal/go-nat-pmp v1.0.2
+	github.com/klauspost/compress v1.13.6
+	github.com/lucas-clemente/quic-go v0.22.1
 	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e
+	github.com/mattn/go-sqlite3 v1.14.0
 	github.com/sirupsen/logrus v1.8.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
+	golang.org/x/net v0.0.0-20210428140749-89ef3d95e781
 	golang.org/x/sys v0.0.0-20211106132015-ebca88c72f68
 	gopkg.in/Knetic/govaluate.v3 v3.0.0 // indirect
 )
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// gRPC is served over HTTP/2 of the https server, see proto/clipboard.proto
+// for the service definition.
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+const (
+	grpcService     = "/clipboard.v1.Clipboard"
+	grpcContentType = "application/grpc"
+)
+
+// gRPC status codes
+const (
+	grpcOK                 = 0
+	grpcInvalidArgument    = 3
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+)
+
+var (
+	errGRPCCompressed = errors.New("compressed grpc message is not supported")
+	errGRPCTooLarge   = errors.New("grpc message is too large")
+)
+
+func isGRPC(c *gin.Context) bool {
+	return strings.HasPrefix(c.ContentType(), grpcContentType)
+}
+
+// grpcRequest rejects requests which can't be handled as grpc calls
+func grpcRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isGRPC(c) {
+			abortWithError(c, http.StatusUnsupportedMediaType, "grpc_required", "请使用 gRPC 客户端访问")
+			return
+		}
+		if c.Request.ProtoMajor != 2 {
+			abortWithError(c, http.StatusHTTPVersionNotSupported, "http2_required", "gRPC 需要 HTTP/2")
+			return
+		}
+		if isEncrypted(c) {
+			finishGRPC(c, grpcUnimplemented, "该接口不支持加密传输")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// grpcSucceeded reports whether grpc call of request succeeded. It's always
+// true for other requests
+func grpcSucceeded(c *gin.Context) bool {
+	status := c.Writer.Header().Get("Grpc-Status")
+	return status == "" || status == strconv.Itoa(grpcOK)
+}
+
+// readGRPCMessage reads the only message of unary or server streaming call
+func readGRPCMessage(c *gin.Context) ([]utils.ProtoField, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(c.Request.Body, prefix); err != nil {
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, errGRPCCompressed
+	}
+	size := int64(binary.BigEndian.Uint32(prefix[1:]))
+	if size > app.config.ProtobufMaxSize<<20 {
+		return nil, errGRPCTooLarge
+	}
+	// message is read as it arrives rather than allocated by size declared
+	message, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return utils.ParseProto(message)
+}
+
+// writeGRPCHeader sends response headers, status is sent in trailers later
+func writeGRPCHeader(c *gin.Context) {
+	if c.Writer.Written() {
+		return
+	}
+	c.Header("Content-Type", grpcContentType)
+	c.Header("Trailer", "Grpc-Status, Grpc-Message")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+}
+
+func writeGRPCMessage(c *gin.Context, message []byte) error {
+	writeGRPCHeader(c)
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	if _, err := c.Writer.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := c.Writer.Write(message); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// finishGRPC ends call with status code. Status is sent in headers if no
+// message has been sent
+func finishGRPC(c *gin.Context, code int, message string) {
+	if !c.Writer.Written() {
+		c.Header("Content-Type", grpcContentType)
+		c.Status(http.StatusOK)
+	}
+	c.Header("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		c.Header("Grpc-Message", encodeGRPCMessage(message))
+	}
+	c.Writer.WriteHeaderNow()
+}
+
+// encodeGRPCMessage percent-encodes message as required by grpc-message
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		ch := message[i]
+		if ch >= ' ' && ch <= '~' && ch != '%' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func grpcGetClipboardHandler(c *gin.Context) {
+	if _, err := readGRPCMessage(c); err != nil {
+		finishGRPC(c, grpcInvalidArgument, "请求参数错误")
+		return
+	}
+	content, notify, err := encodeClipboardContent(c)
+	switch err {
+	case nil:
+	case errResponded:
+		return
+	case errUnknownContent:
+		finishGRPC(c, grpcFailedPrecondition, "无法识别剪切板内容")
+		return
+	default:
+		finishGRPC(c, grpcUnavailable, "无法获取剪切板内容")
+		return
+	}
+
+	if err := writeGRPCMessage(c, content); err != nil {
+		log.WithError(err).Debug("failed to write grpc message")
+		return
+	}
+	finishGRPC(c, grpcOK, "")
+	log.Info("get clipboard by grpc")
+	sendCopyNotification(log, c.GetString("clientName"), notify)
+}
+
+func grpcSetTextHandler(c *gin.Context) {
+	if !prepareSet(c) {
+		return
+	}
+	fields, err := readGRPCMessage(c)
+	if err != nil {
+		log.WithError(err).Warn("failed to read grpc message")
+		finishGRPC(c, grpcInvalidArgument, "请求参数错误")
+		return
+	}
+	var text string
+	for _, field := range fields {
+		if field.Number == 1 && field.WireType == utils.WireBytes {
+			text = string(field.Bytes)
+		}
+	}
+	if err := putClipboardText(c, text); err != nil {
+		log.WithError(err).Warn("failed to set clipboard")
+		finishGRPC(c, grpcInternal, "无法设置剪切板")
+		return
+	}
+	if err := writeGRPCMessage(c, nil); err != nil {
+		return
+	}
+	finishGRPC(c, grpcOK, "")
+}
+
+func grpcSetFilesHandler(c *gin.Context) {
+	if !prepareSet(c) {
+		return
+	}
+	fields, err := readGRPCMessage(c)
+	if err != nil {
+		log.WithError(err).Warn("failed to read grpc message")
+		finishGRPC(c, grpcInvalidArgument, "请求参数错误")
+		return
+	}
+	paths := make([]string, 0, len(fields))
+	size := 0
+	for _, field := range fields {
+		if field.Number != 1 || field.WireType != utils.WireBytes {
+			continue
+		}
+		file, err := decodeProtoFile(field.Bytes)
+		if err != nil {
+			finishGRPC(c, grpcInvalidArgument, "请求参数错误")
+			return
+		}
+		name := filepath.Base(file.Name)
+		if file.Name == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+			finishGRPC(c, grpcInvalidArgument, "文件名不能为空")
+			return
+		}
+		path := utils.LatestFilename(app.GetTempFilePath(name))
+		if err := newFile(path, file.Data); err != nil {
+			log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to create file")
+			continue
+		}
+		size += len(file.Data)
+		paths = append(paths, path)
+	}
+	if err := putClipboardFiles(c, utils.TypeFile, paths, size); err != nil {
+		log.WithError(err).Warn("failed to set clipboard")
+		finishGRPC(c, grpcInternal, "无法设置剪切板")
+		return
+	}
+	if err := writeGRPCMessage(c, nil); err != nil {
+		return
+	}
+	finishGRPC(c, grpcOK, "")
+}
+
+// grpcWatchChangesHandler streams clipboard changes until client cancels
+func grpcWatchChangesHandler(c *gin.Context) {
+	if _, err := readGRPCMessage(c); err != nil {
+		finishGRPC(c, grpcInvalidArgument, "请求参数错误")
+		return
+	}
+	events := app.events.Subscribe()
+	defer app.events.Unsubscribe(events)
+
+	writeGRPCHeader(c)
+	c.Writer.Flush()
+	for {
+		select {
+		case event := <-events:
+			if event.Event != EventClipboard {
+				continue
+			}
+			var change utils.ProtoBuffer
+			change.AppendVarint(1, uint64(event.Sequence))
+			change.AppendString(2, event.Type)
+			change.AppendVarint(3, uint64(event.Time.Unix()))
+			if err := writeGRPCMessage(c, change.Bytes()); err != nil {
+				log.WithError(err).Debug("failed to write grpc message")
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
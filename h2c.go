@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// h2cHandler serves cleartext HTTP/2 by handler, to clients with prior
+// knowledge or upgrading from HTTP/1.1, and HTTP/1.1 as before. HTTP/2 over
+// https is negotiated by net/http already
+func h2cHandler(handler http.Handler) http.Handler {
+	return h2c.NewHandler(handler, &http2.Server{})
+}
@@ -0,0 +1,1384 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	HistoryFile     = "history.db"
+	HistoryKeyFile  = "history.key"
+	HistorySaltFile = "history.salt"
+)
+
+// AuditActionHistory is audit action of reading content of a history entry
+const AuditActionHistory = "history"
+
+// EventHistory is published by changes of HistoryStore rather than app.events
+const EventHistory = "history"
+
+const (
+	historyPreviewLength = 200
+	// delay before a clipboard change is taken as a local copy, so that
+	// content set by clients is recorded with its origin first
+	historyLocalDelay = time.Second
+)
+
+// historyMigrations are applied in order, user_version of database is the
+// count of applied ones
+var historyMigrations = []string{
+	`CREATE TABLE history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		preview TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		origin TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`,
+	// text in utf-8, png of bitmap or json array of paths of files, null if
+	// it's too large to keep
+	`ALTER TABLE history ADD COLUMN content BLOB`,
+	`ALTER TABLE history ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`,
+	// preview and content of encrypted entries are sealed by history key,
+	// preview in base64
+	`ALTER TABLE history ADD COLUMN encrypted INTEGER NOT NULL DEFAULT 0`,
+	// hash identifies content to collapse repeated copies, null if content
+	// is unknown
+	`ALTER TABLE history ADD COLUMN hash TEXT`,
+	`ALTER TABLE history ADD COLUMN hits INTEGER NOT NULL DEFAULT 1`,
+	// zip of files of file entries, so that they can be restored after
+	// being removed, null if it's too large to keep
+	`ALTER TABLE history ADD COLUMN files BLOB`,
+	// uid identifies entry across bridged instances to merge their history
+	`ALTER TABLE history ADD COLUMN uid TEXT;
+	UPDATE history SET uid = lower(hex(randomblob(16)));
+	CREATE UNIQUE INDEX history_uid ON history (uid)`,
+	// png thumbnail of image, or of the first file which is an image, gif or
+	// video, null if there's none
+	`ALTER TABLE history ADD COLUMN thumbnail BLOB`,
+	// time entry was moved to trash, null if it's not deleted
+	`ALTER TABLE history ADD COLUMN deleted_at TIMESTAMP`,
+	`CREATE TABLE history_tags (
+		history_id INTEGER NOT NULL REFERENCES history (id) ON DELETE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (history_id, tag)
+	);
+	CREATE INDEX history_tags_tag ON history_tags (tag)`,
+}
+
+// historyLockedPreview is preview of encrypted entries which can't be
+// decrypted, e.g. after history.encrypt is turned off
+const historyLockedPreview = "[已加密]"
+
+var (
+	errHistoryNotFound = errors.New("history entry not found")
+	errHistoryKey      = errors.New("history is encrypted by another key")
+	// errHistoryUnavailable means content of entry was not kept, or files of
+	// it have been removed
+	errHistoryUnavailable = errors.New("content of history entry is unavailable")
+)
+
+// HistoryEntry is a clipboard item recorded in history
+type HistoryEntry struct {
+	ID      int64     `json:"id"`
+	UID     string    `json:"uid"` // unique across bridged instances, unlike ID
+	Type    string    `json:"type"`
+	Preview string    `json:"preview"`
+	Size    int64     `json:"size"`
+	Origin  string    `json:"origin"` // client name which set it, empty for content copied on windows
+	Created time.Time `json:"created"`
+	Pinned  bool      `json:"pinned"` // kept beyond maxEntries and listed first
+	Hits    int       `json:"hits"`   // times it was copied in a row, Created is the last time
+	// thumbnail of image or file can be got by GET /history/:id/thumbnail
+	HasThumbnail bool       `json:"hasThumbnail"`
+	Deleted      *time.Time `json:"deleted,omitempty"` // time it was moved to trash, nil if it's not deleted
+	Tags         []string   `json:"tags"`              // sorted, not encrypted even if history is
+}
+
+// HistoryContent is content of an entry to record
+type HistoryContent struct {
+	Data      []byte // text in utf-8, png of bitmap or json array of paths of files, nil if not kept
+	Files     []byte // zip of files of file entries, nil if not kept
+	Thumbnail []byte // png thumbnail, nil if there's none
+	Identity  []byte // identifies content even if it's not kept, e.g. large text, nil for sensitive text
+}
+
+// HistoryStore records clipboard items to a sqlite database. An item is
+// recorded once by its clipboard sequence number, however many times it's
+// read. Previews and content are encrypted at rest if store has a key.
+// Deleted entries are kept in trash for trashDays before being purged
+type HistoryStore struct {
+	mu           sync.Mutex
+	db           *sql.DB
+	maxEntries   int
+	trashDays    int    // 0 if deleted entries are removed at once
+	key          []byte // nil if not encrypted
+	lastSequence uint32
+	// changes publishes an event when an entry is recorded or hit
+	changes *EventHub
+}
+
+// NewHistoryStore opens history database of path. If key is not nil,
+// entries recorded without encryption are encrypted by it, and
+// errHistoryKey is returned if entries are encrypted by another key
+func NewHistoryStore(path string, maxEntries, trashDays int, key []byte) (*HistoryStore, error) {
+	// _loc=auto reads times in local time zone, _secure_delete overwrites
+	// deleted content, including plaintext replaced by encryption,
+	// _foreign_keys removes tags of deleted entries
+	db, err := sql.Open("sqlite3", path+"?_loc=auto&_secure_delete=on&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows one writer at a time
+	db.SetMaxOpenConns(1)
+	if err := migrateHistory(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	h := &HistoryStore{db: db, maxEntries: maxEntries, trashDays: trashDays, key: key, changes: NewEventHub()}
+	if key != nil {
+		if err := h.encryptEntries(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := h.purgeTrash(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// encryptEntries checks key against an encrypted entry, then encrypts
+// entries recorded without encryption
+func (h *HistoryStore) encryptEntries() error {
+	var preview string
+	err := h.db.QueryRow("SELECT preview FROM history WHERE encrypted = 1 LIMIT 1").Scan(&preview)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if _, err := h.openPreview(preview); err != nil {
+			return errHistoryKey
+		}
+	}
+
+	type plainEntry struct {
+		id        int64
+		preview   string
+		content   []byte
+		files     []byte
+		thumbnail []byte
+	}
+	rows, err := h.db.Query("SELECT id, preview, content, files, thumbnail FROM history WHERE encrypted = 0")
+	if err != nil {
+		return err
+	}
+	var entries []plainEntry
+	for rows.Next() {
+		var entry plainEntry
+		if err := rows.Scan(&entry.id, &entry.preview, &entry.content, &entry.files, &entry.thumbnail); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(entries) == 0 {
+		return err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		preview, content, err := h.seal(entry.preview, entry.content)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		files, err := h.sealBytes(entry.files)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		thumbnail, err := h.sealBytes(entry.thumbnail)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		// unkeyed hash would reveal whether content equals a guess
+		hash := h.digest(entry.content)
+		if _, err := tx.Exec("UPDATE history SET preview = ?, content = ?, files = ?, thumbnail = ?, encrypted = 1, hash = ? WHERE id = ?",
+			preview, content, files, thumbnail, hash, entry.id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.WithField("count", len(entries)).Info("encrypted history entries")
+	return nil
+}
+
+// Rekey encrypts entries again by key, which becomes key of store. It must be
+// called before store is used. Hashes of entries without content kept can't
+// be computed by key, so they are cleared
+func (h *HistoryStore) Rekey(key []byte) error {
+	type sealedEntry struct {
+		id        int64
+		preview   string
+		content   []byte
+		files     []byte
+		thumbnail []byte
+	}
+	rows, err := h.db.Query("SELECT id, preview, content, files, thumbnail FROM history WHERE encrypted = 1")
+	if err != nil {
+		return err
+	}
+	var entries []sealedEntry
+	for rows.Next() {
+		var entry sealedEntry
+		if err := rows.Scan(&entry.id, &entry.preview, &entry.content, &entry.files, &entry.thumbnail); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rekeyed := &HistoryStore{key: key}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		preview, err := h.openPreview(entry.preview)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		sealed := [][]byte{entry.content, entry.files, entry.thumbnail}
+		for i, data := range sealed {
+			if data == nil {
+				continue
+			}
+			if data, err = utils.Decrypt(h.key, data); err != nil {
+				tx.Rollback()
+				return err
+			}
+			if sealed[i], err = utils.Encrypt(key, data); err != nil {
+				tx.Rollback()
+				return err
+			}
+			if i == 0 {
+				entry.content = data
+			}
+		}
+		sealedPreview, _, err := rekeyed.seal(preview, nil)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		hash := rekeyed.digest(entry.content)
+		if _, err := tx.Exec("UPDATE history SET preview = ?, content = ?, files = ?, thumbnail = ?, hash = ? WHERE id = ?",
+			sealedPreview, sealed[0], sealed[1], sealed[2], hash, entry.id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.key = key
+	log.WithField("count", len(entries)).Info("encrypted history entries by new key")
+	return nil
+}
+
+// digest returns hash of data identifying content, which is keyed if store
+// has a key, so that it doesn't reveal content of encrypted entries
+func (h *HistoryStore) digest(data []byte) sql.NullString {
+	if data == nil {
+		return sql.NullString{}
+	}
+	if h.key == nil {
+		sum := sha256.Sum256(data)
+		return sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+	}
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(data)
+	return sql.NullString{String: hex.EncodeToString(mac.Sum(nil)), Valid: true}
+}
+
+// seal encrypts preview and content if store has a key
+func (h *HistoryStore) seal(preview string, content []byte) (string, []byte, error) {
+	if h.key == nil {
+		return preview, content, nil
+	}
+	sealed, err := utils.Encrypt(h.key, []byte(preview))
+	if err != nil {
+		return "", nil, err
+	}
+	if content, err = h.sealBytes(content); err != nil {
+		return "", nil, err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), content, nil
+}
+
+// sealBytes encrypts data if store has a key and data is not nil
+func (h *HistoryStore) sealBytes(data []byte) ([]byte, error) {
+	if h.key == nil || data == nil {
+		return data, nil
+	}
+	return utils.Encrypt(h.key, data)
+}
+
+// openPreview decrypts preview of an encrypted entry
+func (h *HistoryStore) openPreview(preview string) (string, error) {
+	if h.key == nil {
+		return "", errHistoryKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(preview)
+	if err != nil {
+		return "", err
+	}
+	data, err := utils.Decrypt(h.key, sealed)
+	return string(data), err
+}
+
+// open decrypts preview and content of entry if it's encrypted. Entries
+// which can't be decrypted have historyLockedPreview and no content
+func (h *HistoryStore) open(entry *HistoryEntry, content []byte, encrypted bool) []byte {
+	if !encrypted {
+		return content
+	}
+	preview, err := h.openPreview(entry.Preview)
+	if err != nil {
+		entry.Preview = historyLockedPreview
+		return nil
+	}
+	entry.Preview = preview
+	return h.openBytes(content, true)
+}
+
+// openBytes decrypts data if it's encrypted, or returns nil if it can't be
+// decrypted
+func (h *HistoryStore) openBytes(data []byte, encrypted bool) []byte {
+	if !encrypted || data == nil {
+		return data
+	}
+	if h.key == nil {
+		return nil
+	}
+	data, err := utils.Decrypt(h.key, data)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func migrateHistory(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(historyMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(historyMigrations[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// PRAGMA doesn't take parameters
+		if _, err := tx.Exec("PRAGMA user_version = " + strconv.Itoa(version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add records entry and its content of clipboard sequence unless it has
+// been recorded, and removes the oldest unpinned entries beyond maxEntries.
+// If the latest entry has the same identity, it's updated with time and
+// origin of entry and counted as another hit instead
+func (h *HistoryStore) Add(sequence uint32, entry HistoryEntry, content HistoryContent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if sequence == h.lastSequence {
+		return nil
+	}
+	hash := h.digest(content.Identity)
+	if hash.Valid {
+		hit, err := h.exec(`UPDATE history SET created_at = ?, origin = ?, hits = hits + 1
+			WHERE id = (SELECT id FROM history WHERE deleted_at IS NULL ORDER BY julianday(created_at) DESC, id DESC LIMIT 1) AND type = ? AND hash = ?`,
+			entry.Created, entry.Origin, entry.Type, hash)
+		if err != nil {
+			return err
+		}
+		if hit {
+			h.lastSequence = sequence
+			h.changes.Publish(Event{Event: EventHistory, Time: entry.Created})
+			return nil
+		}
+	}
+	if err := h.insert(entry, content, hash); err != nil {
+		return err
+	}
+	h.lastSequence = sequence
+	h.changes.Publish(Event{Event: EventHistory, Time: entry.Created})
+	return h.prune()
+}
+
+// insert adds entry with a new uid unless it has one. Invalid tags of entry
+// are dropped
+func (h *HistoryStore) insert(entry HistoryEntry, content HistoryContent, hash sql.NullString) error {
+	preview, data, err := h.seal(entry.Preview, content.Data)
+	if err != nil {
+		return err
+	}
+	files, err := h.sealBytes(content.Files)
+	if err != nil {
+		return err
+	}
+	thumbnail, err := h.sealBytes(content.Thumbnail)
+	if err != nil {
+		return err
+	}
+	if entry.Hits < 1 {
+		entry.Hits = 1
+	}
+	if entry.UID == "" {
+		if entry.UID, err = newHistoryUID(); err != nil {
+			return err
+		}
+	}
+	result, err := h.db.Exec("INSERT INTO history (uid, type, preview, size, origin, created_at, pinned, hits, hash, content, files, thumbnail, encrypted) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.UID, entry.Type, preview, entry.Size, entry.Origin, entry.Created, entry.Pinned, entry.Hits, hash, data, files, thumbnail, h.key != nil)
+	if err != nil {
+		return err
+	}
+	tags, err := normalizeTags(entry.Tags)
+	if err != nil || len(tags) == 0 {
+		return nil
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return addTags(h.db, id, tags)
+}
+
+// newHistoryUID returns a random uid of entry, like uids given to entries
+// recorded before uid was added
+func newHistoryUID() (string, error) {
+	uid := make([]byte, 16)
+	if _, err := rand.Read(uid); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(uid), nil
+}
+
+// prune removes the oldest unpinned entries beyond maxEntries, entries in
+// trash are not counted, and purges trash
+func (h *HistoryStore) prune() error {
+	if err := h.purgeTrash(); err != nil {
+		return err
+	}
+	if h.maxEntries <= 0 {
+		return nil
+	}
+	_, err := h.db.Exec(`DELETE FROM history WHERE pinned = 0 AND deleted_at IS NULL AND id NOT IN
+		(SELECT id FROM history WHERE pinned = 0 AND deleted_at IS NULL ORDER BY julianday(created_at) DESC, id DESC LIMIT ?)`, h.maxEntries)
+	return err
+}
+
+// purgeTrash removes entries which have been in trash for trashDays
+func (h *HistoryStore) purgeTrash() error {
+	_, err := h.db.Exec("DELETE FROM history WHERE julianday(deleted_at) <= julianday(?)", time.Now().AddDate(0, 0, -h.trashDays))
+	return err
+}
+
+// Recorded reports whether clipboard of sequence has been recorded
+func (h *HistoryStore) Recorded(sequence uint32) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return sequence == h.lastSequence
+}
+
+// Skip takes clipboard of sequence as recorded without recording it, e.g.
+// content mirrored from a bridged instance which records it itself
+func (h *HistoryStore) Skip(sequence uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSequence = sequence
+}
+
+// HistoryFilter filters entries listed, empty fields are not filtered
+type HistoryFilter struct {
+	Type   string
+	Search string // matches previews case-insensitively
+	Client string // matches origin
+	Tag    string
+	Trash  bool // list entries in trash instead
+}
+
+// List returns entries of page, pinned and then newest first, and count of
+// all entries passing filter. Encrypted previews are searched after
+// decryption
+func (h *HistoryStore) List(page Page, filter HistoryFilter) ([]HistoryEntry, int, error) {
+	where := "WHERE deleted_at IS NULL"
+	if filter.Trash {
+		where = "WHERE deleted_at IS NOT NULL"
+	}
+	var args []interface{}
+	if !page.Since.IsZero() {
+		where += " AND julianday(created_at) > julianday(?)"
+		args = append(args, page.Since)
+	}
+	if filter.Type != "" {
+		where += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.Client != "" {
+		where += " AND origin = ?"
+		args = append(args, filter.Client)
+	}
+	if filter.Tag != "" {
+		where += " AND id IN (SELECT history_id FROM history_tags WHERE tag = ?)"
+		args = append(args, filter.Tag)
+	}
+	search := filter.Search
+	searchDecrypted := search != "" && h.key != nil
+	if search != "" && !searchDecrypted {
+		where += ` AND preview LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(search)+"%")
+	}
+
+	query := "SELECT id, uid, type, preview, size, origin, created_at, pinned, hits, thumbnail IS NOT NULL, deleted_at, " + historyTagsColumn + ", encrypted FROM history " + where + " ORDER BY pinned DESC, julianday(created_at) DESC, id DESC"
+	var total int
+	if !searchDecrypted {
+		if err := h.db.QueryRow("SELECT COUNT(*) FROM history "+where, args...).Scan(&total); err != nil {
+			return nil, 0, err
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, page.Limit, page.Offset)
+	}
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := make([]HistoryEntry, 0, page.Limit)
+	search = strings.ToLower(search)
+	for rows.Next() {
+		var entry HistoryEntry
+		var deleted sql.NullTime
+		var tags sql.NullString
+		var encrypted bool
+		if err := rows.Scan(&entry.ID, &entry.UID, &entry.Type, &entry.Preview, &entry.Size, &entry.Origin, &entry.Created, &entry.Pinned, &entry.Hits, &entry.HasThumbnail, &deleted, &tags, &encrypted); err != nil {
+			return nil, 0, err
+		}
+		if deleted.Valid {
+			entry.Deleted = &deleted.Time
+		}
+		entry.Tags = splitTags(tags)
+		h.open(&entry, nil, encrypted)
+		if searchDecrypted && !strings.Contains(strings.ToLower(entry.Preview), search) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if searchDecrypted {
+		total = len(entries)
+		start, end := page.bounds(total)
+		entries = entries[start:end]
+	}
+	return entries, total, nil
+}
+
+// likeEscaper escapes wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Get returns entry of id and its content, which is nil if it was not
+// kept or can't be decrypted. Entries in trash can be got as well.
+// errHistoryNotFound is returned if there's no such entry
+func (h *HistoryStore) Get(id int64) (HistoryEntry, []byte, error) {
+	entry := HistoryEntry{ID: id}
+	var content []byte
+	var deleted sql.NullTime
+	var tags sql.NullString
+	var encrypted bool
+	err := h.db.QueryRow("SELECT uid, type, preview, size, origin, created_at, pinned, hits, thumbnail IS NOT NULL, deleted_at, "+historyTagsColumn+", content, encrypted FROM history WHERE id = ?", id).
+		Scan(&entry.UID, &entry.Type, &entry.Preview, &entry.Size, &entry.Origin, &entry.Created, &entry.Pinned, &entry.Hits, &entry.HasThumbnail, &deleted, &tags, &content, &encrypted)
+	if err == sql.ErrNoRows {
+		return entry, nil, errHistoryNotFound
+	}
+	if err != nil {
+		return entry, nil, err
+	}
+	if deleted.Valid {
+		entry.Deleted = &deleted.Time
+	}
+	entry.Tags = splitTags(tags)
+	return entry, h.open(&entry, content, encrypted), nil
+}
+
+// Latest returns id of the newest entry not in trash after skipping offset
+// entries, pins are not considered. errHistoryNotFound is returned if there
+// are not so many entries
+func (h *HistoryStore) Latest(offset int) (int64, error) {
+	var id int64
+	err := h.db.QueryRow("SELECT id FROM history WHERE deleted_at IS NULL ORDER BY julianday(created_at) DESC, id DESC LIMIT 1 OFFSET ?", offset).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errHistoryNotFound
+	}
+	return id, err
+}
+
+// Files returns zip of files of entry of id, which is nil if it was not kept
+// or can't be decrypted
+func (h *HistoryStore) Files(id int64) ([]byte, error) {
+	return h.blob("files", id)
+}
+
+// Thumbnail returns png thumbnail of entry of id, which is nil if there's
+// none or it can't be decrypted
+func (h *HistoryStore) Thumbnail(id int64) ([]byte, error) {
+	return h.blob("thumbnail", id)
+}
+
+// blob returns decrypted data of column of entry of id
+func (h *HistoryStore) blob(column string, id int64) ([]byte, error) {
+	var data []byte
+	var encrypted bool
+	err := h.db.QueryRow("SELECT "+column+", encrypted FROM history WHERE id = ?", id).Scan(&data, &encrypted)
+	if err == sql.ErrNoRows {
+		return nil, errHistoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.openBytes(data, encrypted), nil
+}
+
+// Delete moves entry of id to trash, or removes it if it's in trash
+// already or trash is disabled, and reports whether it existed
+func (h *HistoryStore) Delete(id int64) (bool, error) {
+	if h.trashDays > 0 {
+		trashed, err := h.exec("UPDATE history SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now(), id)
+		if err != nil || trashed {
+			return trashed, err
+		}
+	}
+	return h.exec("DELETE FROM history WHERE id = ?", id)
+}
+
+// Recover moves entry of id out of trash and reports whether it was in trash
+func (h *HistoryStore) Recover(id int64) (bool, error) {
+	return h.exec("UPDATE history SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+}
+
+// SetPinned pins or unpins entry of id and reports whether it exists
+func (h *HistoryStore) SetPinned(id int64, pinned bool) (bool, error) {
+	return h.exec("UPDATE history SET pinned = ? WHERE id = ?", pinned, id)
+}
+
+// exec executes query and reports whether any row is affected
+func (h *HistoryStore) exec(query string, args ...interface{}) (bool, error) {
+	result, err := h.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Clear moves all unpinned entries to trash, or removes them if trash is
+// disabled
+func (h *HistoryStore) Clear() error {
+	if h.trashDays > 0 {
+		_, err := h.db.Exec("UPDATE history SET deleted_at = ? WHERE pinned = 0 AND deleted_at IS NULL", time.Now())
+		return err
+	}
+	_, err := h.db.Exec("DELETE FROM history WHERE pinned = 0 AND deleted_at IS NULL")
+	return err
+}
+
+// EmptyTrash removes all entries in trash
+func (h *HistoryStore) EmptyTrash() error {
+	_, err := h.db.Exec("DELETE FROM history WHERE deleted_at IS NOT NULL")
+	return err
+}
+
+func (h *HistoryStore) Close() error {
+	return h.db.Close()
+}
+
+// openHistory opens history database if history.enable. History is
+// disabled if it can't be opened, e.g. built without cgo
+func openHistory(config ConfigHistory) *HistoryStore {
+	if !config.Enable {
+		return nil
+	}
+	if config.Lock.Enable && config.Passphrase == "" {
+		log.Warn("history.lock needs history.passphrase to unlock, history stays locked")
+	}
+	key, legacy, err := historyKey(config)
+	if err != nil {
+		log.WithError(err).Warn("failed to get key of history, history is disabled")
+		return nil
+	}
+	history, err := openHistoryStore(filepath.Join(execPath, HistoryFile), config, key, legacy)
+	if err != nil {
+		log.WithError(err).Warn("failed to open history, history is disabled")
+		return nil
+	}
+	return history
+}
+
+// openHistoryStore opens history database of path by key. If it was
+// encrypted by legacy key, it's encrypted again by key
+func openHistoryStore(path string, config ConfigHistory, key, legacy []byte) (*HistoryStore, error) {
+	history, err := NewHistoryStore(path, config.MaxEntries, config.TrashDays, key)
+	if err != errHistoryKey || legacy == nil {
+		return history, err
+	}
+	if history, err = NewHistoryStore(path, config.MaxEntries, config.TrashDays, legacy); err != nil {
+		return nil, err
+	}
+	if err := history.Rekey(key); err != nil {
+		history.Close()
+		return nil, err
+	}
+	return history, nil
+}
+
+// historyKey returns key to encrypt history if history.encrypt, or if
+// history.lock.enable as locking plaintext history would only hide it from
+// the tray and api but not from history.db. The key is derived from
+// history.passphrase and salt kept in history.salt, or generated and kept in
+// history.key protected by DPAPI if passphrase is empty. Legacy is the
+// unsalted key of passphrase, which encrypted history before history.salt
+func historyKey(config ConfigHistory) (key, legacy []byte, err error) {
+	if !config.Encrypt && !config.Lock.Enable {
+		return nil, nil, nil
+	}
+	if config.Passphrase != "" {
+		salt, err := historySalt()
+		if err != nil {
+			return nil, nil, err
+		}
+		if key, err = utils.DeriveKeySalted(config.Passphrase, salt); err != nil {
+			return nil, nil, err
+		}
+		return key, utils.DeriveKey(config.Passphrase), nil
+	}
+	path := filepath.Join(execPath, HistoryKeyFile)
+	if utils.IsExistFile(path) {
+		key, err := utils.ReadSecretFile(path)
+		return key, nil, err
+	}
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	if err := utils.WriteSecretFile(path, key); err != nil {
+		return nil, nil, err
+	}
+	return key, nil, nil
+}
+
+// historySalt returns salt of history.passphrase kept in history.salt, which
+// is generated if it doesn't exist
+func historySalt() ([]byte, error) {
+	path := filepath.Join(execPath, HistorySaltFile)
+	if utils.IsExistFile(path) {
+		return ioutil.ReadFile(path)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// historyEntry describes current clipboard content as an entry of origin,
+// and returns its content. Content larger than history.maxContentSize is not
+// kept, and neither is zip of such files. Sensitive text has no content at
+// all, not even identity. Thumbnail is made of image kept, or of the first
+// file which is an image, gif or video if thumbnail.enable
+func historyEntry(origin string) (entry HistoryEntry, content HistoryContent, ok bool) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		return
+	}
+	entry = HistoryEntry{Type: contentType, Origin: origin, Created: time.Now()}
+	maxSize := app.config.History.MaxContentSize << 20
+	switch contentType {
+	case utils.TypeText:
+		text, err := clipboardText()
+		if err != nil {
+			return entry, content, false
+		}
+		entry.Size = int64(len(text))
+		entry.Preview = truncate(text, historyPreviewLength)
+		if app.config.Sensitive.Enable && app.sensitive.Match(text) {
+			// not even hash of sensitive text is kept, short secrets like
+			// codes could be guessed from it
+			entry.Preview = utils.RedactedText
+			break
+		}
+		content.Identity = []byte(text)
+		if entry.Size <= maxSize {
+			content.Data = content.Identity
+		}
+	case utils.TypeBitmap:
+		entry.Size = clipboardSize(contentType)
+		entry.Preview = "[图片]"
+		if entry.Size >= 0 && entry.Size <= maxSize {
+			if content.Data, err = clipboardPNG(); err != nil {
+				log.WithError(err).Warn("failed to encode bitmap of history")
+			}
+		}
+		content.Identity = content.Data
+		if app.config.Thumbnail.Enable && content.Data != nil {
+			if img, err := png.Decode(bytes.NewReader(content.Data)); err == nil {
+				content.Thumbnail, _ = thumbnailPNG(img)
+			}
+		}
+	case utils.TypeFile:
+		paths, err := utils.Clipboard().Files()
+		if err != nil {
+			return entry, content, false
+		}
+		names := make([]string, 0, len(paths))
+		for _, path := range paths {
+			names = append(names, filepath.Base(path))
+		}
+		entry.Size = clipboardSize(contentType)
+		entry.Preview = truncate("[文件] "+strings.Join(names, ", "), historyPreviewLength)
+		content.Data, _ = json.Marshal(paths)
+		content.Identity = content.Data
+		// paths may be removed later, so files are kept as well
+		if entry.Size >= 0 && entry.Size <= maxSize {
+			var buf bytes.Buffer
+			if _, err := writeZip(context.Background(), &buf, paths); err != nil {
+				log.WithError(err).Warn("failed to zip files of history")
+			} else {
+				content.Files = buf.Bytes()
+			}
+		}
+		if app.config.Thumbnail.Enable {
+			content.Thumbnail = historyThumbnail(paths, maxSize)
+		}
+	default:
+		return entry, content, false
+	}
+	return entry, content, true
+}
+
+// recordHistory records clipboard read or written by request. Content set
+// by client has the client as origin, while content read is from windows
+func recordHistory(c *gin.Context, action string) {
+	if app.history == nil || action != AuditActionRead && action != AuditActionWrite {
+		return
+	}
+	sequence := utils.Clipboard().SequenceNumber()
+	if app.history.Recorded(sequence) {
+		return
+	}
+	origin := ""
+	if action == AuditActionWrite {
+		origin = c.GetString("clientName")
+		if deviceConfig(origin).NoHistory {
+			// so that it's not recorded as copied on windows either
+			app.history.Skip(sequence)
+			return
+		}
+	}
+	addHistory(sequence, origin)
+}
+
+// recordLocalCopy records clipboard copied on windows after a while, unless
+// it has been recorded as content set by a client
+func recordLocalCopy() {
+	if app.history == nil || !app.config.History.Local {
+		return
+	}
+	sequence := utils.Clipboard().SequenceNumber()
+	time.AfterFunc(historyLocalDelay, func() {
+		if app.history.Recorded(sequence) || utils.Clipboard().SequenceNumber() != sequence {
+			return
+		}
+		addHistory(sequence, "")
+	})
+}
+
+func addHistory(sequence uint32, origin string) {
+	entry, content, ok := historyEntry(origin)
+	if !ok {
+		return
+	}
+	if err := app.history.Add(sequence, entry, content); err != nil {
+		log.WithError(err).Warn("failed to record history")
+	}
+}
+
+// historyEnabled rejects history requests if history is disabled or failed
+// to open
+func historyEnabled() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.history == nil {
+			abortWithError(c, http.StatusForbidden, "history_disabled", "服务端未开启剪切板历史")
+			return
+		}
+		c.Next()
+	}
+}
+
+// HistoryPage is response body of a page of history entries
+type HistoryPage struct {
+	Data []HistoryEntry `json:"data"`
+	PageInfo
+}
+
+// listHistoryHandler responds entries of history or trash, pinned and then
+// newest first, filtered by type, search of previews, client which set
+// them and tag
+func listHistoryHandler(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	entries, total, err := app.history.List(page, HistoryFilter{
+		Type:   c.Query("type"),
+		Search: c.Query("q"),
+		Client: c.Query("client"),
+		Tag:    c.Query("tag"),
+		Trash:  c.Query("trash") == "true",
+	})
+	if err != nil {
+		log.WithError(err).Warn("failed to list history")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, HistoryPage{entries, page.info(total)})
+}
+
+// historyID returns id of entry in path. latest stands for the newest entry
+// not in trash regardless of pins, or the one before it by query offset,
+// e.g. offset=1 is the item copied before the latest
+func historyID(c *gin.Context) (int64, bool) {
+	if c.Param("id") != "latest" {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "id 参数错误")
+			return 0, false
+		}
+		return id, true
+	}
+	offset := 0
+	if q := c.Query("offset"); q != "" {
+		var err error
+		if offset, err = strconv.Atoi(q); err != nil || offset < 0 {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "offset 参数错误")
+			return 0, false
+		}
+	}
+	id, err := app.history.Latest(offset)
+	if err == errHistoryNotFound {
+		respondError(c, http.StatusNotFound, "history_not_found", "历史记录不存在")
+		return 0, false
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to get latest history")
+		c.Status(http.StatusInternalServerError)
+		return 0, false
+	}
+	return id, true
+}
+
+// historyContent returns entry of id in path and its content. Errors are
+// responded if there's no such entry, its content was not kept or reading
+// its type is disabled for client
+func historyContent(c *gin.Context) (HistoryEntry, []byte, bool) {
+	id, ok := historyID(c)
+	if !ok {
+		return HistoryEntry{}, nil, false
+	}
+	entry, content, err := app.history.Get(id)
+	if err == errHistoryNotFound {
+		respondError(c, http.StatusNotFound, "history_not_found", "历史记录不存在")
+		return entry, nil, false
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to get history")
+		c.Status(http.StatusInternalServerError)
+		return entry, nil, false
+	}
+	if content == nil {
+		respondError(c, http.StatusGone, "history_content_unavailable", "未保存该记录的内容")
+		return entry, nil, false
+	}
+	return entry, content, true
+}
+
+// historyPaths returns paths of files entry and their total size. 410 is
+// responded if none of them is left
+func historyPaths(c *gin.Context, entry HistoryEntry, content []byte) ([]string, int64, bool) {
+	paths, err := historyFiles(entry, content)
+	if err == errHistoryUnavailable {
+		respondError(c, http.StatusGone, "history_content_unavailable", "文件已不存在")
+		return nil, 0, false
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to get files of history")
+		c.Status(http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	return paths, pathsSize(paths), true
+}
+
+// historyFiles returns paths in content of files entry which still exist.
+// If some of them have been removed and zip of the files was kept, the files
+// are extracted into _history-<id> of temp directory and paths of them are
+// returned instead. Entries imported or synced have names instead of paths,
+// which are only resolved into the extracted files, never paths on this
+// computer. errHistoryUnavailable is returned if nothing is left
+func historyFiles(entry HistoryEntry, content []byte) ([]string, error) {
+	var paths []string
+	if err := json.Unmarshal(content, &paths); err != nil {
+		return nil, err
+	}
+	for i, path := range paths {
+		if filepath.IsAbs(path) {
+			continue
+		}
+		name, ok := historyFileName(path)
+		if !ok {
+			return nil, errHistoryUnavailable
+		}
+		paths[i] = filepath.Join(historyFilesDir(entry), name)
+	}
+	existing := existingPaths(paths)
+	if len(existing) < len(paths) {
+		extracted, err := extractHistoryFiles(entry, paths)
+		if err != nil {
+			return nil, err
+		}
+		if len(extracted) > len(existing) {
+			existing = extracted
+		}
+	}
+	if len(existing) == 0 {
+		return nil, errHistoryUnavailable
+	}
+	return existing, nil
+}
+
+// extractHistoryFiles extracts kept zip of files entry unless it has been
+// extracted, and returns paths of extracted files in paths order. Nothing is
+// returned if zip was not kept
+func extractHistoryFiles(entry HistoryEntry, paths []string) ([]string, error) {
+	dir := historyFilesDir(entry)
+	extracted := make([]string, 0, len(paths))
+	for _, path := range paths {
+		extracted = append(extracted, filepath.Join(dir, filepath.Base(path)))
+	}
+	if len(existingPaths(extracted)) == len(extracted) {
+		return extracted, nil
+	}
+	files, err := app.history.Files(entry.ID)
+	if err != nil || files == nil {
+		return nil, err
+	}
+	r, err := zip.NewReader(bytes.NewReader(files), int64(len(files)))
+	if err != nil {
+		return nil, err
+	}
+	maxSize := app.config.History.MaxContentSize << 20
+	if entry.Size > maxSize {
+		maxSize = entry.Size
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := utils.ExtractZip(r, dir, maxSize); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	log.WithField("id", entry.ID).Info("extracted files of history")
+	return existingPaths(extracted), nil
+}
+
+// historyFilesDir returns directory of files extracted for entry
+func historyFilesDir(entry HistoryEntry) string {
+	return app.GetTempFilePath(fmt.Sprintf("_history-%d", entry.ID))
+}
+
+// existingPaths returns those of paths which still exist
+func existingPaths(paths []string) []string {
+	existing := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if utils.IsExistFile(path) {
+			existing = append(existing, path)
+		}
+	}
+	return existing
+}
+
+// getHistoryHandler responds content of a history entry like GET /. Files
+// which have been removed since are skipped, unless they were kept
+func getHistoryHandler(c *gin.Context) {
+	entry, content, ok := historyContent(c)
+	if !ok {
+		return
+	}
+	capabilityName := CapabilityReadFile
+	if entry.Type == utils.TypeText {
+		capabilityName = CapabilityReadText
+	}
+	if isDisabled(c.GetString("clientName"), capabilityName) {
+		abortDisabled(c)
+		return
+	}
+
+	switch entry.Type {
+	case utils.TypeText:
+		if !approveRead(c, string(content)) {
+			return
+		}
+		data, err := encodeText(c, string(content))
+		if err != nil {
+			log.WithError(err).Warn("failed to encrypt history text")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		setAuditInfo(c, utils.TypeText, len(content))
+		c.JSON(http.StatusOK, TextResponse{Type: "text", Data: data})
+	case utils.TypeBitmap:
+		if !approveRead(c, "[图片媒体]") {
+			return
+		}
+		data, err := encodeContent(c, content)
+		if err != nil {
+			log.WithError(err).Warn("failed to encrypt history png")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		setAuditInfo(c, utils.TypeBitmap, len(content))
+		c.JSON(http.StatusOK, FilesResponse{"file", []ResponseFile{{Name: "clipboard.png", Content: data}}})
+	default:
+		paths, _, ok := historyPaths(c, entry, content)
+		if !ok || !approveRead(c, entry.Preview) {
+			return
+		}
+		responseFiles, size := readResponseFiles(c, paths)
+		setAuditInfo(c, utils.TypeFile, size)
+		c.JSON(http.StatusOK, FilesResponse{"file", responseFiles})
+	}
+}
+
+// historyThumbnailHandler responds png thumbnail of a history entry
+func historyThumbnailHandler(c *gin.Context) {
+	id, ok := historyID(c)
+	if !ok {
+		return
+	}
+	thumbnail, err := app.history.Thumbnail(id)
+	if err == errHistoryNotFound {
+		respondError(c, http.StatusNotFound, "history_not_found", "历史记录不存在")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to get thumbnail of history")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if thumbnail == nil {
+		respondError(c, http.StatusNotFound, "thumbnail_not_found", "该记录没有缩略图")
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, MIMEPNG, thumbnail)
+}
+
+// deleteHistoryHandler moves a history entry to trash, or removes it if
+// it's in trash
+func deleteHistoryHandler(c *gin.Context) {
+	updateHistory(c, app.history.Delete)
+}
+
+// recoverHistoryHandler moves a history entry out of trash
+func recoverHistoryHandler(c *gin.Context) {
+	updateHistory(c, app.history.Recover)
+}
+
+// pinHistoryHandler pins a history entry
+func pinHistoryHandler(c *gin.Context) {
+	updateHistory(c, func(id int64) (bool, error) {
+		return app.history.SetPinned(id, true)
+	})
+}
+
+// unpinHistoryHandler unpins a history entry
+func unpinHistoryHandler(c *gin.Context) {
+	updateHistory(c, func(id int64) (bool, error) {
+		return app.history.SetPinned(id, false)
+	})
+}
+
+// updateHistory applies update to entry of id in path, which reports
+// whether the entry exists
+func updateHistory(c *gin.Context, update func(id int64) (bool, error)) {
+	id, ok := historyID(c)
+	if !ok {
+		return
+	}
+	found, err := update(id)
+	if err != nil {
+		log.WithError(err).Warn("failed to update history")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		respondError(c, http.StatusNotFound, "history_not_found", "历史记录不存在")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// clearHistoryHandler moves all unpinned history entries to trash, or
+// empties trash if query trash is true
+func clearHistoryHandler(c *gin.Context) {
+	if c.Query("trash") == "true" {
+		if err := app.history.EmptyTrash(); err != nil {
+			log.WithError(err).Warn("failed to empty trash of history")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		log.Info("trash of history emptied")
+		c.Status(http.StatusOK)
+		return
+	}
+	if err := app.history.Clear(); err != nil {
+		log.WithError(err).Warn("failed to clear history")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	log.Info("history cleared")
+	c.Status(http.StatusOK)
+}
+
+// restoreHistoryHandler puts content of a history entry back on clipboard
+// on behalf of client, which is recorded as a new entry. Files which have
+// been removed are extracted into temp directory if they were kept. Temp
+// files are not cleaned, as files of the entry may be among them
+func restoreHistoryHandler(c *gin.Context) {
+	if !parseSetHeaders(c) {
+		return
+	}
+	entry, content, ok := historyContent(c)
+	if !ok {
+		return
+	}
+	capabilityName := CapabilityWriteFile
+	if entry.Type == utils.TypeText {
+		capabilityName = CapabilityWriteText
+	}
+	if isDisabled(c.GetString("clientName"), capabilityName) {
+		abortDisabled(c)
+		return
+	}
+
+	var err error
+	switch entry.Type {
+	case utils.TypeText:
+		if err = setClipboardText(string(content)); err == nil {
+			textPasted(c, string(content))
+		}
+	case utils.TypeBitmap:
+		err = restoreHistoryImage(c, content)
+	default:
+		paths, size, ok := historyPaths(c, entry, content)
+		if !ok {
+			return
+		}
+		err = restoreHistoryFiles(c, paths, int(size))
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to restore history")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// restoreHistoryFiles puts files of history entry on clipboard. Unlike
+// uploaded files, they are not temp files, so they're kept out of
+// _filename.txt and never removed by the next set
+func restoreHistoryFiles(c *gin.Context, paths []string, size int) error {
+	if err := utils.Clipboard().SetFiles(paths); err != nil {
+		return err
+	}
+	scheduleClipboardExpiry(c.GetInt("expireSeconds"))
+	setAuditInfo(c, utils.TypeFile, size)
+	log.WithField("paths", contentSummaries(paths)).Info("restored clipboard files from history")
+	sendPasteNotification(log, c.GetString("clientName"), "[文件] 已复制到剪贴板")
+	return nil
+}
+
+func restoreHistoryImage(c *gin.Context, pngBytes []byte) error {
+	dib, pngBytes, err := decodeImage(pngBytes)
+	if err != nil {
+		return err
+	}
+	if err := utils.Clipboard().SetImage(dib, pngBytes, nil); err != nil {
+		return err
+	}
+	scheduleClipboardExpiry(c.GetInt("expireSeconds"))
+	setAuditInfo(c, utils.TypeBitmap, len(pngBytes))
+	log.Info("restored clipboard image from history")
+	sendPasteNotification(log, c.GetString("clientName"), "[图片媒体] 已复制到剪贴板")
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestoredFilesKeptAfterSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig
+	config.TempDir = filepath.Join(dir, "temp")
+	config.Notify.Paste = false
+	app = &Application{config: &config}
+	if err := os.MkdirAll(config.TempDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	history, err := NewHistoryStore(filepath.Join(dir, "history.db"), 10, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	app.history = history
+
+	original := filepath.Join(dir, "original.txt")
+	if err := ioutil.WriteFile(original, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal([]string{original})
+	entry := HistoryEntry{Type: utils.TypeFile, Preview: "original.txt", Created: time.Now()}
+	if err := history.Add(1, entry, HistoryContent{Data: data, Identity: data}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := history.Latest(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/history/:id/restore", restoreHistoryHandler)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/history/"+strconv.FormatInt(id, 10)+"/restore", nil)
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore responded %d: %s", w.Code, w.Body.String())
+	}
+
+	// every set cleans temp files of the previous one first
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	if !prepareSet(c) {
+		t.Fatal("prepareSet failed")
+	}
+	if !utils.IsExistFile(original) {
+		t.Errorf("%s was removed by the set after it was restored", original)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	historyArchiveIndex   = "history.json"
+	historyArchiveVersion = 1
+	// auditTypeHistory is audit type of exporting and importing history
+	auditTypeHistory = "history"
+	// maxHistoryArchiveSize is the largest archive accepted by import
+	maxHistoryArchiveSize = 1 << 30
+)
+
+var errHistoryArchive = errors.New("invalid history archive")
+
+// HistoryArchive is index of a history archive, which is a zip with the
+// index as history.json, content of entries as files named by Content, zip
+// of files of file entries named by Files and thumbnails named by
+// Thumbnail. They are decrypted, so that they can be imported with another
+// key
+type HistoryArchive struct {
+	Version int                   `json:"version"`
+	Entries []HistoryArchiveEntry `json:"entries"`
+}
+
+// HistoryArchiveEntry is an entry in history archive
+type HistoryArchiveEntry struct {
+	HistoryEntry
+	Content   string `json:"content,omitempty"`   // name of file of content in archive, empty if content was not kept
+	Files     string `json:"files,omitempty"`     // name of zip of files in archive, empty if files were not kept
+	Thumbnail string `json:"thumbnail,omitempty"` // name of png thumbnail in archive, empty if there's none
+}
+
+// HistoryImportResult is response body of importing history
+type HistoryImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"` // entries which exist already
+}
+
+// ids returns ids of all entries not in trash, oldest first
+func (h *HistoryStore) ids() ([]int64, error) {
+	rows, err := h.db.Query("SELECT id FROM history WHERE deleted_at IS NULL ORDER BY julianday(created_at), id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Export writes all entries as a history archive to w and returns count of
+// them. It stops when ctx is done
+func (h *HistoryStore) Export(ctx context.Context, w io.Writer) (int, error) {
+	ids, err := h.ids()
+	if err != nil {
+		return 0, err
+	}
+	zw := zip.NewWriter(w)
+	archive := HistoryArchive{Version: historyArchiveVersion, Entries: make([]HistoryArchiveEntry, 0, len(ids))}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		entry, content, err := h.Get(id)
+		if err == errHistoryNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		archiveEntry := HistoryArchiveEntry{HistoryEntry: entry}
+		if content != nil {
+			archiveEntry.Content = "content/" + strconv.FormatInt(id, 10)
+			if err := writeZipFile(zw, archiveEntry.Content, entry.Created, content); err != nil {
+				return 0, err
+			}
+		}
+		files, err := h.Files(id)
+		if err != nil && err != errHistoryNotFound {
+			return 0, err
+		}
+		if files != nil {
+			archiveEntry.Files = "files/" + strconv.FormatInt(id, 10) + ".zip"
+			if err := writeZipFile(zw, archiveEntry.Files, entry.Created, files); err != nil {
+				return 0, err
+			}
+		}
+		thumbnail, err := h.Thumbnail(id)
+		if err != nil && err != errHistoryNotFound {
+			return 0, err
+		}
+		if thumbnail != nil {
+			archiveEntry.Thumbnail = "thumbnails/" + strconv.FormatInt(id, 10) + ".png"
+			if err := writeZipFile(zw, archiveEntry.Thumbnail, entry.Created, thumbnail); err != nil {
+				return 0, err
+			}
+		}
+		archive.Entries = append(archive.Entries, archiveEntry)
+	}
+	fw, err := zw.Create(historyArchiveIndex)
+	if err != nil {
+		return 0, err
+	}
+	if err := json.NewEncoder(fw).Encode(archive); err != nil {
+		return 0, err
+	}
+	return len(archive.Entries), zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, modified time.Time, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+// exists reports whether there's an entry of the same uid, or an entry of
+// the same type, size and origin recorded at the same time
+func (h *HistoryStore) exists(entry HistoryEntry) (bool, error) {
+	var count int
+	err := h.db.QueryRow("SELECT COUNT(*) FROM history WHERE uid = ? OR type = ? AND size = ? AND origin = ? AND julianday(created_at) = julianday(?)",
+		entry.UID, entry.Type, entry.Size, entry.Origin, entry.Created).Scan(&count)
+	return count > 0, err
+}
+
+// Import adds entries of history archive r which don't exist, then removes
+// the oldest unpinned entries beyond maxEntries. Content, zip of files and
+// thumbnails larger than maxContentSize are dropped
+func (h *HistoryStore) Import(r *zip.Reader, maxContentSize int64) (HistoryImportResult, error) {
+	var result HistoryImportResult
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	index, ok := files[historyArchiveIndex]
+	if !ok {
+		return result, errHistoryArchive
+	}
+	var archive HistoryArchive
+	if err := readZipJSON(index, &archive); err != nil || archive.Version != historyArchiveVersion {
+		return result, errHistoryArchive
+	}
+	sort.SliceStable(archive.Entries, func(i, j int) bool {
+		return archive.Entries[i].Created.Before(archive.Entries[j].Created)
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, entry := range archive.Entries {
+		if entry.Type == "" || entry.Created.IsZero() {
+			return result, errHistoryArchive
+		}
+		exists, err := h.exists(entry.HistoryEntry)
+		if err != nil {
+			return result, err
+		}
+		if exists {
+			result.Skipped++
+			continue
+		}
+		var content HistoryContent
+		if content.Data, err = readArchiveFile(files, entry.Content, maxContentSize); err != nil {
+			return result, err
+		}
+		if entry.Type == utils.TypeFile && content.Data != nil {
+			content.Data = historyFileNames(content.Data)
+		}
+		if content.Files, err = readArchiveFile(files, entry.Files, maxContentSize); err != nil {
+			return result, err
+		}
+		if content.Thumbnail, err = readArchiveFile(files, entry.Thumbnail, maxContentSize); err != nil {
+			return result, err
+		}
+		if err := h.insert(entry.HistoryEntry, content, h.digest(content.Data)); err != nil {
+			return result, err
+		}
+		result.Imported++
+	}
+	return result, h.prune()
+}
+
+// historyFileNames rewrites paths of files entry from another computer to
+// names of the files, which are resolved into files extracted for the entry
+// rather than paths on this computer. nil is returned if paths are invalid
+func historyFileNames(data []byte) []byte {
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		// paths may be of either windows or unix
+		name, ok := historyFileName(filepath.Base(filepath.FromSlash(strings.ReplaceAll(path, "\\", "/"))))
+		if !ok {
+			return nil
+		}
+		names = append(names, name)
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// historyFileName reports whether name is a plain file name
+func historyFileName(name string) (string, bool) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\:`) {
+		return "", false
+	}
+	return name, true
+}
+
+// readArchiveFile reads file of name in archive, nil is returned if name is
+// empty, or the file is missing or larger than maxSize
+func readArchiveFile(files map[string]*zip.File, name string, maxSize int64) ([]byte, error) {
+	f, ok := files[name]
+	if !ok || name == "" {
+		return nil, nil
+	}
+	data, err := readZipFile(f, maxSize)
+	if err == errHistoryArchive {
+		return nil, nil
+	}
+	return data, err
+}
+
+func readZipJSON(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
+}
+
+// readZipFile reads content of f, errHistoryArchive is returned if it's
+// larger than maxSize
+func readZipFile(f *zip.File, maxSize int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(rc, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errHistoryArchive
+	}
+	return data, nil
+}
+
+// exportHistoryHandler downloads all history entries as a history archive
+func exportHistoryHandler(c *gin.Context) {
+	if !approveRead(c, "[剪切板历史]") {
+		return
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="clipboard-history.zip"`)
+	c.Status(http.StatusOK)
+	count, err := app.history.Export(c.Request.Context(), c.Writer)
+	if err != nil {
+		log.WithError(err).Warn("failed to export history")
+	}
+	log.WithField("count", count).Info("history exported")
+	setAuditInfo(c, auditTypeHistory, c.Writer.Size())
+}
+
+// importHistoryHandler adds entries of a history archive in body
+func importHistoryHandler(c *gin.Context) {
+	file, err := ioutil.TempFile(app.GetTempFilePath(""), "_history-*.zip")
+	if err != nil {
+		log.WithError(err).Warn("failed to create temp file of history archive")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	size, err := io.Copy(file, io.LimitReader(utils.ContextReader(c.Request.Context(), c.Request.Body), maxHistoryArchiveSize+1))
+	if err != nil {
+		log.WithError(err).Warn("failed to read history archive")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if size > maxHistoryArchiveSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("请求内容超过 %d MB", maxHistoryArchiveSize>>20))
+		return
+	}
+	result, err := importHistoryArchive(file, size)
+	if err == errHistoryArchive {
+		respondError(c, http.StatusBadRequest, "invalid_body", "不是有效的剪切板历史文件")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to import history")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	setAuditInfo(c, auditTypeHistory, int(size))
+	c.JSON(http.StatusOK, result)
+}
+
+// importHistoryArchive imports history archive of r with size
+func importHistoryArchive(r io.ReaderAt, size int64) (HistoryImportResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return HistoryImportResult{}, errHistoryArchive
+	}
+	result, err := app.history.Import(zr, app.config.History.MaxContentSize<<20)
+	if err != nil {
+		return result, err
+	}
+	log.WithField("imported", result.Imported).WithField("skipped", result.Skipped).Info("history imported")
+	return result, nil
+}
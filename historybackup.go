@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/lxn/walk"
+)
+
+const (
+	HistoryBackupDir    = "backup"
+	historyBackupPrefix = "history-"
+	historyBackupLayout = "20060102-150405"
+	// historyBackupCheck is interval of checking whether a backup is due,
+	// so that backups are taken on time after sleep or restart
+	historyBackupCheck = time.Hour
+)
+
+var (
+	errHistoryDisabled       = errors.New("history is not opened")
+	errHistoryBackupNotFound = errors.New("history backup not found")
+)
+
+// historyBackupMu serializes backups and restores
+var historyBackupMu sync.Mutex
+
+// historyRestoreQueries replace entries and tags by those of the attached
+// database restored, including sequence of ids
+var historyRestoreQueries = []string{
+	"DELETE FROM main.history_tags",
+	"DELETE FROM main.history",
+	"INSERT INTO main.history SELECT * FROM restored.history",
+	"INSERT INTO main.history_tags SELECT * FROM restored.history_tags",
+	"DELETE FROM main.sqlite_sequence",
+	"INSERT INTO main.sqlite_sequence SELECT * FROM restored.sqlite_sequence",
+}
+
+// HistoryBackup is a snapshot of history database in backup folder
+type HistoryBackup struct {
+	Name string
+	Time time.Time
+	Size int64
+}
+
+// Backup writes a consistent snapshot of database to path, which must not
+// exist. Encrypted entries stay encrypted in it
+func (h *HistoryStore) Backup(path string) error {
+	_, err := h.db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// Restore replaces all entries by those of database of path, which must be
+// migrated and encrypted by key of store. Entries are replaced in a
+// transaction, so database is never closed while it's in use
+func (h *HistoryStore) Restore(path string) error {
+	ctx := context.Background()
+	// attached database is only visible to the connection attaching it
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS restored", path); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE restored")
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, query := range historyRestoreQueries {
+		if _, err := tx.Exec(query); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.changes.Publish(Event{Event: EventHistory, Time: time.Now()})
+	return nil
+}
+
+// historyBackupDir returns history.backup.dir, relative to the executable
+// unless it's absolute
+func historyBackupDir() string {
+	dir := app.config.History.Backup.Dir
+	if dir == "" {
+		dir = HistoryBackupDir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(execPath, dir)
+	}
+	return dir
+}
+
+// listHistoryBackups returns backups in dir, newest first. Files not named
+// by backupHistory are ignored
+func listHistoryBackups(dir string) ([]HistoryBackup, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []HistoryBackup
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, historyBackupPrefix) || filepath.Ext(name) != ".db" {
+			continue
+		}
+		t, err := time.ParseInLocation(historyBackupLayout, strings.TrimSuffix(strings.TrimPrefix(name, historyBackupPrefix), ".db"), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, HistoryBackup{Name: name, Time: t, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// backupHistory snapshots history database to backup folder, then removes
+// the oldest backups beyond history.backup.keep
+func backupHistory() (HistoryBackup, error) {
+	historyBackupMu.Lock()
+	defer historyBackupMu.Unlock()
+	return backupHistoryLocked()
+}
+
+// backupHistoryLocked is backupHistory with historyBackupMu held
+func backupHistoryLocked() (HistoryBackup, error) {
+	if app.history == nil {
+		return HistoryBackup{}, errHistoryDisabled
+	}
+	dir := historyBackupDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return HistoryBackup{}, err
+	}
+	now := time.Now()
+	name := historyBackupPrefix + now.Format(historyBackupLayout) + ".db"
+	path := filepath.Join(dir, name)
+	// snapshot is written to a temporary file, so that a partial one is
+	// never taken as a backup
+	tempPath := path + ".tmp"
+	os.Remove(tempPath)
+	if err := app.history.Backup(tempPath); err != nil {
+		os.Remove(tempPath)
+		return HistoryBackup{}, err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return HistoryBackup{}, err
+	}
+	backup := HistoryBackup{Name: name, Time: now}
+	if info, err := os.Stat(path); err == nil {
+		backup.Size = info.Size()
+	}
+	log.WithField("path", path).Info("history backed up")
+
+	keep := app.config.History.Backup.Keep
+	if keep <= 0 {
+		return backup, nil
+	}
+	backups, err := listHistoryBackups(dir)
+	if err != nil {
+		return backup, err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(dir, backups[i].Name)); err != nil {
+			log.WithError(err).WithField("name", backups[i].Name).Warn("failed to remove old history backup")
+		}
+	}
+	return backup, nil
+}
+
+// RunHistoryBackups backs up history every history.backup.interval hours
+func (app *Application) RunHistoryBackups() {
+	config := app.config.History.Backup
+	if !app.config.History.Enable || !config.Enable || config.Interval <= 0 {
+		return
+	}
+	interval := time.Duration(config.Interval) * time.Hour
+	go func() {
+		for ; ; time.Sleep(historyBackupCheck) {
+			// history may be opened later by restoring a backup
+			if app.history == nil {
+				continue
+			}
+			backups, err := listHistoryBackups(historyBackupDir())
+			if err != nil {
+				log.WithError(err).Warn("failed to list history backups")
+			} else if len(backups) == 0 || time.Since(backups[0].Time) >= interval {
+				if _, err := backupHistory(); err != nil {
+					log.WithError(err).Warn("failed to back up history")
+				}
+			}
+		}
+	}()
+}
+
+// checkHistoryBackup reports an error if database of path is corrupted
+func checkHistoryBackup(path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("history backup is corrupted: %s", result)
+	}
+	return nil
+}
+
+// restoreHistory replaces history by backup of name, which is checked and
+// migrated on a copy first. History replaced is backed up first rather than
+// removed, as it may still be wanted
+func restoreHistory(name string) error {
+	if !app.config.History.Enable {
+		return errHistoryDisabled
+	}
+	historyBackupMu.Lock()
+	defer historyBackupMu.Unlock()
+	dir := historyBackupDir()
+	backups, err := listHistoryBackups(dir)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, backup := range backups {
+		found = found || backup.Name == name
+	}
+	if !found {
+		return errHistoryBackupNotFound
+	}
+
+	dbPath := filepath.Join(execPath, HistoryFile)
+	tempPath := dbPath + ".restore"
+	defer os.Remove(tempPath)
+	temp, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = copyFileTo(temp, filepath.Join(dir, name))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = prepareHistoryRestore(tempPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	if app.history == nil {
+		err = openRestoredHistory(dir, dbPath, tempPath)
+	} else if _, err = backupHistoryLocked(); err == nil {
+		err = app.history.Restore(tempPath)
+	}
+	if err != nil {
+		return err
+	}
+	removeHistoryFiles()
+	log.WithField("name", name).Info("history restored")
+	return nil
+}
+
+// openRestoredHistory replaces database of history, which couldn't be opened,
+// by database of tempPath and opens it. The database replaced is moved to dir
+// as a backup, or back if it can't be replaced
+func openRestoredHistory(dir, dbPath, tempPath string) error {
+	replaced := ""
+	if utils.IsExistFile(dbPath) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		replaced = filepath.Join(dir, historyBackupPrefix+time.Now().Format(historyBackupLayout)+".db")
+		if err := os.Rename(dbPath, replaced); err != nil {
+			return err
+		}
+	}
+	// journal left by the database replaced would be rolled back into the
+	// backup
+	os.Remove(dbPath + "-journal")
+	if err := os.Rename(tempPath, dbPath); err != nil {
+		if replaced != "" {
+			os.Rename(replaced, dbPath)
+		}
+		return err
+	}
+	if app.history = openHistory(app.config.History); app.history == nil {
+		return errors.New("failed to open history restored")
+	}
+	return nil
+}
+
+// removeHistoryFiles removes files extracted for entries, as ids of entries
+// restored may be of other entries before
+func removeHistoryFiles() {
+	dirs, err := filepath.Glob(app.GetTempFilePath("_history-*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.WithError(err).WithField("path", dir).Warn("failed to remove files of history")
+		}
+	}
+}
+
+// prepareHistoryRestore checks copy of backup of path, and opens it as
+// history so that it's migrated and checked against key of history
+func prepareHistoryRestore(path string) error {
+	if err := checkHistoryBackup(path); err != nil {
+		return err
+	}
+	key, legacy, err := historyKey(app.config.History)
+	if err != nil {
+		return err
+	}
+	history, err := openHistoryStore(path, app.config.History, key, legacy)
+	if err != nil {
+		return err
+	}
+	return history.Close()
+}
+
+func showHistoryBackups() {
+	if !app.config.History.Enable {
+		walk.MsgBox(app.MainWindow, "历史备份", "未开启剪切板历史，请在配置文件中开启 history.enable", walk.MsgBoxIconInformation)
+		return
+	}
+	if err := runHistoryBackupsDialog(); err != nil {
+		log.WithError(err).Warn("failed to show history backups")
+		walk.MsgBox(app.MainWindow, "历史备份", "无法读取历史备份", walk.MsgBoxIconError)
+	}
+}
+
+func runHistoryBackupsDialog() error {
+	dlg, err := walk.NewDialog(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("历史备份"); err != nil {
+		return err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return err
+	}
+	if err := dlg.SetSize(walk.Size{Width: 420, Height: 360}); err != nil {
+		return err
+	}
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		return err
+	}
+	if err := label.SetText("备份目录：" + historyBackupDir()); err != nil {
+		return err
+	}
+	listBox, err := walk.NewListBox(dlg)
+	if err != nil {
+		return err
+	}
+	var backups []HistoryBackup
+	reload := func() error {
+		var err error
+		if backups, err = listHistoryBackups(historyBackupDir()); err != nil {
+			return err
+		}
+		items := make([]string, 0, len(backups))
+		for _, backup := range backups {
+			items = append(items, fmt.Sprintf("%s  %.1f MB", backup.Time.Format("2006-01-02 15:04:05"), float64(backup.Size)/(1<<20)))
+		}
+		return listBox.SetModel(items)
+	}
+	if err := reload(); err != nil {
+		return err
+	}
+
+	buttons, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	if err := buttons.SetLayout(walk.NewHBoxLayout()); err != nil {
+		return err
+	}
+	backupButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := backupButton.SetText("立即备份"); err != nil {
+		return err
+	}
+	backupButton.Clicked().Attach(func() {
+		if _, err := backupHistory(); err != nil {
+			log.WithError(err).Warn("failed to back up history")
+			walk.MsgBox(dlg, "历史备份", "备份失败："+err.Error(), walk.MsgBoxIconError)
+			return
+		}
+		if err := reload(); err != nil {
+			log.WithError(err).Warn("failed to list history backups")
+		}
+	})
+	restoreButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := restoreButton.SetText("恢复"); err != nil {
+		return err
+	}
+	restoreButton.Clicked().Attach(func() {
+		i := listBox.CurrentIndex()
+		if i < 0 || i >= len(backups) {
+			return
+		}
+		message := fmt.Sprintf("确定将剪切板历史恢复到 %s 的备份吗？恢复前会先备份当前的历史", backups[i].Time.Format("2006-01-02 15:04:05"))
+		if walk.MsgBox(dlg, "历史备份", message, walk.MsgBoxYesNo|walk.MsgBoxIconQuestion) != walk.DlgCmdYes {
+			return
+		}
+		if err := restoreHistory(backups[i].Name); err != nil {
+			log.WithError(err).Warn("failed to restore history")
+			walk.MsgBox(dlg, "历史备份", "恢复失败："+err.Error(), walk.MsgBoxIconError)
+			return
+		}
+		walk.MsgBox(dlg, "历史备份", "已恢复剪切板历史", walk.MsgBoxIconInformation)
+		if err := reload(); err != nil {
+			log.WithError(err).Warn("failed to list history backups")
+		}
+	})
+	closeButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := closeButton.SetText("关闭"); err != nil {
+		return err
+	}
+	closeButton.Clicked().Attach(dlg.Cancel)
+	if err := dlg.SetCancelButton(closeButton); err != nil {
+		return err
+	}
+
+	dlg.Run()
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+)
+
+// HistoryLock keeps history locked if history.lock.enable, until it's
+// unlocked by history.passphrase for history.lock.timeout
+type HistoryLock struct {
+	mu            sync.Mutex
+	unlockedUntil time.Time
+}
+
+// Locked reports whether history is locked now
+func (l *HistoryLock) Locked() bool {
+	if !app.config.History.Lock.Enable {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !time.Now().Before(l.unlockedUntil)
+}
+
+// Unlock unlocks history if passphrase is history.passphrase
+func (l *HistoryLock) Unlock(passphrase string) bool {
+	if !checkHistoryPassphrase(passphrase) {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unlockedUntil = time.Now().Add(time.Duration(app.config.History.Lock.Timeout) * time.Minute)
+	log.Info("history unlocked")
+	return true
+}
+
+// checkHistoryPassphrase reports whether passphrase is history.passphrase.
+// Nothing unlocks history if passphrase is not configured
+func checkHistoryPassphrase(passphrase string) bool {
+	configured := app.config.History.Passphrase
+	if configured == "" || passphrase == "" {
+		return false
+	}
+	// hashes are compared so that time doesn't reveal length
+	a, b := sha256.Sum256([]byte(passphrase)), sha256.Sum256([]byte(configured))
+	return hmac.Equal(a[:], b[:])
+}
+
+// historyUnlocked rejects requests to history while it's locked, unless they
+// have history.passphrase in X-History-Passphrase, which is only valid for
+// the request. Wrong passphrases count as auth failures
+func historyUnlocked() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.historyLock.Locked() {
+			c.Next()
+			return
+		}
+		header := c.GetHeader("X-History-Passphrase")
+		if header == "" {
+			abortWithError(c, http.StatusLocked, "history_locked", "剪切板历史已锁定，请在托盘中解锁或提供 X-History-Passphrase")
+			return
+		}
+		passphrase, err := url.PathUnescape(header)
+		if err != nil || !checkHistoryPassphrase(passphrase) {
+			log.WithField("clientName", c.GetString("clientName")).Warn("wrong history passphrase")
+			abortWithAuthError(c, http.StatusForbidden, "history_passphrase_invalid", "操作被拒绝：历史密码错误")
+			return
+		}
+		c.Next()
+	}
+}
+
+// unlockHistory asks user for passphrase if history is locked, and reports
+// whether history is unlocked
+func unlockHistory(owner walk.Form) bool {
+	if !app.historyLock.Locked() {
+		return true
+	}
+	for {
+		passphrase, ok, err := runHistoryUnlockDialog(owner)
+		if err != nil {
+			log.WithError(err).Warn("failed to show history unlock dialog")
+			return false
+		}
+		if !ok {
+			return false
+		}
+		if app.historyLock.Unlock(passphrase) {
+			return true
+		}
+		log.Warn("wrong history passphrase")
+		walk.MsgBox(owner, "解锁剪切板历史", "密码错误", walk.MsgBoxIconError)
+	}
+}
+
+func runHistoryUnlockDialog(owner walk.Form) (string, bool, error) {
+	dlg, err := walk.NewDialogWithFixedSize(owner)
+	if err != nil {
+		return "", false, err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("解锁剪切板历史"); err != nil {
+		return "", false, err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return "", false, err
+	}
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		return "", false, err
+	}
+	if err := label.SetText("剪切板历史已锁定，请输入 history.passphrase"); err != nil {
+		return "", false, err
+	}
+	passphraseEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return "", false, err
+	}
+	passphraseEdit.SetPasswordMode(true)
+
+	buttons, err := walk.NewComposite(dlg)
+	if err != nil {
+		return "", false, err
+	}
+	if err := buttons.SetLayout(walk.NewHBoxLayout()); err != nil {
+		return "", false, err
+	}
+	unlockButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return "", false, err
+	}
+	if err := unlockButton.SetText("解锁"); err != nil {
+		return "", false, err
+	}
+	unlockButton.Clicked().Attach(dlg.Accept)
+	if err := dlg.SetDefaultButton(unlockButton); err != nil {
+		return "", false, err
+	}
+	cancelButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return "", false, err
+	}
+	if err := cancelButton.SetText("取消"); err != nil {
+		return "", false, err
+	}
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	if err := dlg.SetCancelButton(cancelButton); err != nil {
+		return "", false, err
+	}
+
+	if dlg.Run() != walk.DlgCmdOK {
+		return "", false, nil
+	}
+	return passphraseEdit.Text(), true, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+)
+
+const (
+	// BridgeHistoryIndex is type of bridge message listing uid and time of
+	// all entries, the peer responds entries which are missing or newer
+	BridgeHistoryIndex = "history-index"
+	// BridgeHistoryEntry is type of bridge message carrying an entry
+	BridgeHistoryEntry = "history"
+)
+
+// HistoryIndexEntry identifies an entry and the last time it was copied
+type HistoryIndexEntry struct {
+	UID     string    `json:"uid"`
+	Created time.Time `json:"created"`
+}
+
+// HistorySyncEntry is an entry with its content sent to a bridged instance.
+// Content is decrypted, as the peer encrypts it by its own key
+type HistorySyncEntry struct {
+	HistoryEntry
+	Content   []byte `json:"content,omitempty"`
+	Files     []byte `json:"files,omitempty"` // zip of files of file entries
+	Thumbnail []byte `json:"thumbnail,omitempty"`
+}
+
+// Index returns uid and time of all entries, and their ids by uid
+func (h *HistoryStore) Index() ([]HistoryIndexEntry, map[string]int64, error) {
+	rows, err := h.db.Query("SELECT id, uid, created_at FROM history WHERE deleted_at IS NULL ORDER BY julianday(created_at), id")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var index []HistoryIndexEntry
+	ids := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var entry HistoryIndexEntry
+		if err := rows.Scan(&id, &entry.UID, &entry.Created); err != nil {
+			return nil, nil, err
+		}
+		index = append(index, entry)
+		ids[entry.UID] = id
+	}
+	return index, ids, rows.Err()
+}
+
+// Newer returns ids of entries which are missing from index of the peer or
+// copied later than it knows, oldest first
+func (h *HistoryStore) Newer(peerIndex []HistoryIndexEntry) ([]int64, error) {
+	index, ids, err := h.Index()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]time.Time, len(peerIndex))
+	for _, entry := range peerIndex {
+		known[entry.UID] = entry.Created
+	}
+	var newer []int64
+	for _, entry := range index {
+		if created, ok := known[entry.UID]; !ok || entry.Created.After(created) {
+			newer = append(newer, ids[entry.UID])
+		}
+	}
+	return newer, nil
+}
+
+// Since returns ids of entries copied after t, oldest first, and the time
+// of the latest one
+func (h *HistoryStore) Since(t time.Time) ([]int64, time.Time, error) {
+	rows, err := h.db.Query("SELECT id, created_at FROM history WHERE deleted_at IS NULL AND julianday(created_at) > julianday(?) ORDER BY julianday(created_at), id", t)
+	if err != nil {
+		return nil, t, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, t, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, t, rows.Err()
+}
+
+// SyncEntry returns entry of id with its content to send to a peer
+func (h *HistoryStore) SyncEntry(id int64) (HistorySyncEntry, error) {
+	entry, content, err := h.Get(id)
+	if err != nil {
+		return HistorySyncEntry{}, err
+	}
+	files, err := h.Files(id)
+	if err != nil {
+		return HistorySyncEntry{}, err
+	}
+	thumbnail, err := h.Thumbnail(id)
+	if err != nil {
+		return HistorySyncEntry{}, err
+	}
+	return HistorySyncEntry{HistoryEntry: entry, Content: content, Files: files, Thumbnail: thumbnail}, nil
+}
+
+// Merge adds entry received from a peer unless its uid exists. An existing
+// entry is updated with time, origin and hits of entry if it was copied
+// later, so both instances end up with the same entry whichever order
+// they merge in. Pins are not merged. It reports whether anything changed
+func (h *HistoryStore) Merge(entry HistorySyncEntry) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var created time.Time
+	err := h.db.QueryRow("SELECT created_at FROM history WHERE uid = ?", entry.UID).Scan(&created)
+	if err == nil {
+		if !entry.Created.After(created) {
+			return false, nil
+		}
+		return h.exec("UPDATE history SET created_at = ?, origin = ?, hits = ? WHERE uid = ?", entry.Created, entry.Origin, entry.Hits, entry.UID)
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	entry.Pinned = false
+	// paths on peer are resolved into files of the entry extracted here
+	if entry.Type == utils.TypeFile && entry.Content != nil {
+		entry.Content = historyFileNames(entry.Content)
+	}
+	content := HistoryContent{Data: entry.Content, Files: entry.Files, Thumbnail: entry.Thumbnail, Identity: entry.Content}
+	if err := h.insert(entry.HistoryEntry, content, h.digest(content.Identity)); err != nil {
+		return false, err
+	}
+	return true, h.prune()
+}
+
+// historySyncEnabled reports whether history is synced with bridge peer,
+// which needs history enabled on both instances
+func historySyncEnabled(peer string) bool {
+	return app.history != nil && !isDisabled(peer, CapabilityHistory)
+}
+
+// startHistorySync sends index of history to peer, so that it responds
+// entries this instance misses
+func (b *bridge) startHistorySync() error {
+	index, _, err := app.history.Index()
+	if err != nil {
+		return err
+	}
+	if len(index) > 0 {
+		b.historySent = index[len(index)-1].Created
+	}
+	return b.write(&BridgeMessage{Type: BridgeHistoryIndex, HistoryIndex: index})
+}
+
+// receiveHistory handles history messages of peer. Entries are sent in
+// background, so that messages of peer keep being read meanwhile
+func (b *bridge) receiveHistory(message *BridgeMessage) error {
+	if !historySyncEnabled(b.peer) {
+		return nil
+	}
+	switch message.Type {
+	case BridgeHistoryIndex:
+		b.mu.Lock()
+		b.historyPeer = true
+		b.mu.Unlock()
+		ids, err := app.history.Newer(message.HistoryIndex)
+		if err != nil {
+			return err
+		}
+		go b.sendHistory(ids)
+		return nil
+	default:
+		if message.History == nil || message.History.UID == "" {
+			return nil
+		}
+		entry := *message.History
+		// entries copied on peer are from it here
+		if entry.Origin == "" {
+			entry.Origin = b.peer
+		}
+		merged, err := app.history.Merge(entry)
+		if merged {
+			log.WithField("uid", entry.UID).Info("merged history entry from bridge")
+		}
+		return err
+	}
+}
+
+// sendHistory sends entries of ids to peer if it syncs history, and user
+// approves it once per connection if confirmRead is enabled
+func (b *bridge) sendHistory(ids []int64) {
+	b.mu.Lock()
+	syncing := b.historyPeer
+	b.mu.Unlock()
+	if !syncing || !b.approveHistory() {
+		return
+	}
+	if app.historyLock.Locked() {
+		log.WithField("peer", b.peer).Info("history is locked, not sent to bridge")
+		return
+	}
+	for _, id := range ids {
+		entry, err := app.history.SyncEntry(id)
+		if err == errHistoryNotFound {
+			continue
+		}
+		if err == nil {
+			err = b.write(&BridgeMessage{Type: BridgeHistoryEntry, History: &entry})
+		}
+		if err != nil {
+			log.WithError(err).Warn("failed to send history to bridge")
+			return
+		}
+	}
+}
+
+// approveHistory asks user to approve sending history to peer if
+// confirmRead is enabled, as it would be by GET /history. It's asked once
+// per connection, later calls wait for and get the same answer
+func (b *bridge) approveHistory() bool {
+	b.historyApproval.Do(func() {
+		if !app.config.ConfirmRead.Enable {
+			b.historyApproved = true
+			return
+		}
+		timeout := time.Duration(app.config.ConfirmRead.Timeout) * time.Second
+		if b.historyApproved = requestApproval(b.peer, "[剪切板历史]", timeout); !b.historyApproved {
+			log.WithField("peer", b.peer).Warn("history sync to bridge rejected")
+		}
+	})
+	return b.historyApproved
+}
+
+// sendHistoryChanges sends entries recorded or hit since those last sent.
+// Entries merged from peer may be sent back, which it ignores as they are
+// not newer
+func (b *bridge) sendHistoryChanges() {
+	ids, latest, err := app.history.Since(b.historySent)
+	if err != nil {
+		log.WithError(err).Warn("failed to get history changes")
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	b.historySent = latest
+	go b.sendHistory(ids)
+}
+
+// skipMirroredHistory takes clipboard mirrored from peer as recorded, as
+// peer syncs its own entry of it
+func (b *bridge) skipMirroredHistory() {
+	b.mu.Lock()
+	syncing := b.historyPeer
+	b.mu.Unlock()
+	if syncing && historySyncEnabled(b.peer) {
+		app.history.Skip(utils.Clipboard().SequenceNumber())
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxHistoryTags      = 20
+	maxHistoryTagLength = 50
+	// historyTagSeparator separates tags concatenated by sqlite, which is
+	// rejected in tags as a control character
+	historyTagSeparator = "\x1f"
+	// historyTagsColumn selects tags of entries of history table
+	historyTagsColumn = "(SELECT group_concat(tag, char(31)) FROM history_tags WHERE history_id = history.id)"
+)
+
+// HistoryTags is request and response body of setting tags of an entry
+type HistoryTags struct {
+	Tags []string `json:"tags"`
+}
+
+// normalizeTags trims tags and removes duplicates, then sorts them. An
+// error describing the invalid tag is returned if any is empty, too long
+// or has control characters, or there are too many
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len([]rune(tag)) > maxHistoryTagLength || strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("标签不能为空，不能超过 %d 个字符，也不能包含控制字符", maxHistoryTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxHistoryTags {
+		return nil, fmt.Errorf("标签不能超过 %d 个", maxHistoryTags)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// splitTags returns sorted tags selected by historyTagsColumn
+func splitTags(tags sql.NullString) []string {
+	if !tags.Valid || tags.String == "" {
+		return []string{}
+	}
+	split := strings.Split(tags.String, historyTagSeparator)
+	sort.Strings(split)
+	return split
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// addTags adds tags to entry of id
+func addTags(db execer, id int64, tags []string) error {
+	for _, tag := range tags {
+		if _, err := db.Exec("INSERT OR IGNORE INTO history_tags (history_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTags replaces tags of entry of id by normalized tags, and reports
+// whether the entry exists
+func (h *HistoryStore) SetTags(id int64, tags []string) (bool, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var exists bool
+	if err := tx.QueryRow("SELECT COUNT(*) > 0 FROM history WHERE id = ?", id).Scan(&exists); err != nil || !exists {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM history_tags WHERE history_id = ?", id); err != nil {
+		return false, err
+	}
+	if err := addTags(tx, id, tags); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// setHistoryTagsHandler replaces tags of a history entry
+func setHistoryTagsHandler(c *gin.Context) {
+	id, ok := historyID(c)
+	if !ok {
+		return
+	}
+	var body HistoryTags
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_body", "请求内容格式错误")
+		return
+	}
+	tags, err := normalizeTags(body.Tags)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+	found, err := app.history.SetTags(id, tags)
+	if err != nil {
+		log.WithError(err).Warn("failed to set tags of history")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		respondError(c, http.StatusNotFound, "history_not_found", "历史记录不存在")
+		return
+	}
+	c.JSON(http.StatusOK, HistoryTags{tags})
+}
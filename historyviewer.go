@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/lxn/walk"
+)
+
+const (
+	historyViewerLimit = 500
+	// width of thumbnail pane of history viewer
+	historyThumbnailWidth = 160
+)
+
+// historyTypeNames are names of entry types shown in history viewer
+var historyTypeNames = map[string]string{
+	utils.TypeText:   "文本",
+	utils.TypeBitmap: "图片",
+	utils.TypeFile:   "文件",
+}
+
+var previewLineReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+// historyModel is table model of history entries
+type historyModel struct {
+	walk.TableModelBase
+	entries []HistoryEntry
+}
+
+func (m *historyModel) RowCount() int {
+	return len(m.entries)
+}
+
+func (m *historyModel) Value(row, col int) interface{} {
+	entry := m.entries[row]
+	switch col {
+	case 0:
+		if entry.Pinned {
+			return "★"
+		}
+		return ""
+	case 1:
+		return entry.Created.Format("01-02 15:04:05")
+	case 2:
+		return entry.Hits
+	case 3:
+		if entry.Origin == "" {
+			return "本机"
+		}
+		return entry.Origin
+	case 4:
+		return historyTypeNames[entry.Type]
+	case 5:
+		return strings.Join(entry.Tags, ", ")
+	}
+	return previewLineReplacer.Replace(entry.Preview)
+}
+
+// load reads the latest entries matching search and tag, of trash if trash
+func (m *historyModel) load(search, tag string, trash bool) error {
+	entries, _, err := app.history.List(Page{Limit: historyViewerLimit}, HistoryFilter{Search: search, Tag: tag, Trash: trash})
+	if err != nil {
+		return err
+	}
+	m.entries = entries
+	m.PublishRowsReset()
+	return nil
+}
+
+func showHistoryViewer() {
+	if app.history == nil {
+		walk.MsgBox(app.MainWindow, "剪切板历史", "未开启剪切板历史，请在配置文件中开启 history.enable", walk.MsgBoxIconInformation)
+		return
+	}
+	if !unlockHistory(app.MainWindow) {
+		return
+	}
+	if err := runHistoryViewer(); err != nil {
+		log.WithError(err).Warn("failed to show history viewer")
+		walk.MsgBox(app.MainWindow, "剪切板历史", "无法读取剪切板历史", walk.MsgBoxIconError)
+	}
+}
+
+func runHistoryViewer() error {
+	model := &historyModel{}
+	if err := model.load("", "", false); err != nil {
+		return err
+	}
+
+	dlg, err := walk.NewDialog(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("剪切板历史"); err != nil {
+		return err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return err
+	}
+	if err := dlg.SetSize(walk.Size{Width: 720, Height: 480}); err != nil {
+		return err
+	}
+
+	toolbar, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	toolbarLayout := walk.NewHBoxLayout()
+	if err := toolbarLayout.SetMargins(walk.Margins{}); err != nil {
+		return err
+	}
+	if err := toolbar.SetLayout(toolbarLayout); err != nil {
+		return err
+	}
+	searchEdit, err := walk.NewLineEdit(toolbar)
+	if err != nil {
+		return err
+	}
+	if err := searchEdit.SetCueBanner("搜索"); err != nil {
+		return err
+	}
+	tagEdit, err := walk.NewLineEdit(toolbar)
+	if err != nil {
+		return err
+	}
+	if err := tagEdit.SetCueBanner("标签"); err != nil {
+		return err
+	}
+	if err := tagEdit.SetMinMaxSize(walk.Size{}, walk.Size{Width: 120}); err != nil {
+		return err
+	}
+	trashCheck, err := walk.NewCheckBox(toolbar)
+	if err != nil {
+		return err
+	}
+	if err := trashCheck.SetText("回收站"); err != nil {
+		return err
+	}
+
+	body, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	bodyLayout := walk.NewHBoxLayout()
+	if err := bodyLayout.SetMargins(walk.Margins{}); err != nil {
+		return err
+	}
+	if err := body.SetLayout(bodyLayout); err != nil {
+		return err
+	}
+	tableView, err := walk.NewTableView(body)
+	if err != nil {
+		return err
+	}
+	for _, column := range []struct {
+		title string
+		width int
+	}{{"置顶", 40}, {"时间", 110}, {"次数", 40}, {"来源", 100}, {"类型", 50}, {"标签", 100}, {"预览", 320}} {
+		tvc := walk.NewTableViewColumn()
+		if err := tvc.SetTitle(column.title); err != nil {
+			return err
+		}
+		if err := tvc.SetWidth(column.width); err != nil {
+			return err
+		}
+		if err := tableView.Columns().Add(tvc); err != nil {
+			return err
+		}
+	}
+	if err := tableView.SetLastColumnStretched(true); err != nil {
+		return err
+	}
+	if err := tableView.SetModel(model); err != nil {
+		return err
+	}
+	thumbnailView, err := walk.NewImageView(body)
+	if err != nil {
+		return err
+	}
+	thumbnailView.SetMode(walk.ImageViewModeShrink)
+	if err := thumbnailView.SetMinMaxSize(walk.Size{Width: historyThumbnailWidth}, walk.Size{Width: historyThumbnailWidth}); err != nil {
+		return err
+	}
+
+	reload := func() {
+		if err := model.load(searchEdit.Text(), strings.TrimSpace(tagEdit.Text()), trashCheck.Checked()); err != nil {
+			log.WithError(err).Warn("failed to list history")
+		}
+	}
+	searchEdit.TextChanged().Attach(reload)
+	tagEdit.TextChanged().Attach(reload)
+
+	// current returns selected entry, or false if there's none
+	current := func() (HistoryEntry, bool) {
+		index := tableView.CurrentIndex()
+		if index < 0 || index >= len(model.entries) {
+			return HistoryEntry{}, false
+		}
+		return model.entries[index], true
+	}
+	copyCurrent := func() {
+		entry, ok := current()
+		if !ok {
+			return
+		}
+		if err := copyHistoryEntry(entry); err != nil {
+			log.WithError(err).Warn("failed to copy history")
+			walk.MsgBox(dlg, "剪切板历史", historyErrorMessage(err, "复制失败"), walk.MsgBoxIconError)
+			return
+		}
+		dlg.Accept()
+	}
+	tableView.ItemActivated().Attach(copyCurrent)
+
+	buttons, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	if err := buttons.SetLayout(walk.NewHBoxLayout()); err != nil {
+		return err
+	}
+	copyButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := copyButton.SetText("复制"); err != nil {
+		return err
+	}
+	copyButton.Clicked().Attach(copyCurrent)
+
+	pinButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := pinButton.SetText("置顶"); err != nil {
+		return err
+	}
+	// thumbnail shown of the selected entry, disposed when it changes
+	var thumbnail *walk.Bitmap
+	defer func() {
+		if thumbnail != nil {
+			thumbnail.Dispose()
+		}
+	}()
+	tableView.CurrentIndexChanged().Attach(func() {
+		text := "置顶"
+		entry, ok := current()
+		if ok && entry.Pinned {
+			text = "取消置顶"
+		}
+		if err := pinButton.SetText(text); err != nil {
+			log.WithError(err).Warn("failed to set text of pin button")
+		}
+
+		// image stays nil rather than a nil bitmap to clear the view
+		var bitmap *walk.Bitmap
+		var image walk.Image
+		if ok && entry.HasThumbnail {
+			var err error
+			if bitmap, err = historyThumbnailBitmap(entry); err != nil {
+				log.WithError(err).Warn("failed to load thumbnail of history")
+			} else {
+				image = bitmap
+			}
+		}
+		if err := thumbnailView.SetImage(image); err != nil {
+			log.WithError(err).Warn("failed to show thumbnail of history")
+		}
+		if thumbnail != nil {
+			thumbnail.Dispose()
+		}
+		thumbnail = bitmap
+	})
+	pinButton.Clicked().Attach(func() {
+		entry, ok := current()
+		if !ok {
+			return
+		}
+		if _, err := app.history.SetPinned(entry.ID, !entry.Pinned); err != nil {
+			log.WithError(err).Warn("failed to pin history")
+			walk.MsgBox(dlg, "剪切板历史", "置顶失败", walk.MsgBoxIconError)
+			return
+		}
+		reload()
+	})
+
+	tagButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := tagButton.SetText("标签"); err != nil {
+		return err
+	}
+	tagButton.Clicked().Attach(func() {
+		entry, ok := current()
+		if !ok {
+			return
+		}
+		tags, ok, err := runHistoryTagsDialog(dlg, entry.Tags)
+		if err != nil {
+			log.WithError(err).Warn("failed to show tags dialog")
+			return
+		}
+		if !ok {
+			return
+		}
+		if _, err := app.history.SetTags(entry.ID, tags); err != nil {
+			log.WithError(err).Warn("failed to set tags of history")
+			walk.MsgBox(dlg, "剪切板历史", "设置标签失败", walk.MsgBoxIconError)
+			return
+		}
+		reload()
+	})
+
+	deleteButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := deleteButton.SetText("删除"); err != nil {
+		return err
+	}
+	deleteButton.Clicked().Attach(func() {
+		entry, ok := current()
+		if !ok {
+			return
+		}
+		if entry.Deleted != nil && walk.MsgBox(dlg, "剪切板历史", "彻底删除后无法恢复，确定删除吗？", walk.MsgBoxYesNo|walk.MsgBoxIconQuestion) != walk.DlgCmdYes {
+			return
+		}
+		if _, err := app.history.Delete(entry.ID); err != nil {
+			log.WithError(err).Warn("failed to delete history")
+			walk.MsgBox(dlg, "剪切板历史", "删除失败", walk.MsgBoxIconError)
+			return
+		}
+		reload()
+	})
+
+	recoverButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := recoverButton.SetText("恢复"); err != nil {
+		return err
+	}
+	recoverButton.SetVisible(false)
+	recoverButton.Clicked().Attach(func() {
+		entry, ok := current()
+		if !ok {
+			return
+		}
+		if _, err := app.history.Recover(entry.ID); err != nil {
+			log.WithError(err).Warn("failed to recover history")
+			walk.MsgBox(dlg, "剪切板历史", "恢复失败", walk.MsgBoxIconError)
+			return
+		}
+		reload()
+	})
+	// trash shows recover button instead of pin button, and deletes
+	// entries permanently
+	trashCheck.CheckedChanged().Attach(func() {
+		trash := trashCheck.Checked()
+		pinButton.SetVisible(!trash)
+		recoverButton.SetVisible(trash)
+		text := "删除"
+		if trash {
+			text = "彻底删除"
+		}
+		if err := deleteButton.SetText(text); err != nil {
+			log.WithError(err).Warn("failed to set text of delete button")
+		}
+		reload()
+	})
+
+	saveButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := saveButton.SetText("保存到文件"); err != nil {
+		return err
+	}
+	saveButton.Clicked().Attach(func() {
+		entry, ok := current()
+		if !ok {
+			return
+		}
+		if err := saveHistoryEntry(dlg, entry); err != nil {
+			log.WithError(err).Warn("failed to save history")
+			walk.MsgBox(dlg, "剪切板历史", historyErrorMessage(err, "保存失败"), walk.MsgBoxIconError)
+		}
+	})
+
+	exportButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := exportButton.SetText("导出"); err != nil {
+		return err
+	}
+	exportButton.Clicked().Attach(func() {
+		if err := exportHistoryFile(dlg); err != nil {
+			log.WithError(err).Warn("failed to export history")
+			walk.MsgBox(dlg, "剪切板历史", "导出失败："+err.Error(), walk.MsgBoxIconError)
+		}
+	})
+
+	importButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := importButton.SetText("导入"); err != nil {
+		return err
+	}
+	importButton.Clicked().Attach(func() {
+		result, ok, err := importHistoryFile(dlg)
+		if err != nil {
+			log.WithError(err).Warn("failed to import history")
+			message := "导入失败：" + err.Error()
+			if err == errHistoryArchive {
+				message = "不是有效的剪切板历史文件"
+			}
+			walk.MsgBox(dlg, "剪切板历史", message, walk.MsgBoxIconError)
+			return
+		}
+		if !ok {
+			return
+		}
+		reload()
+		walk.MsgBox(dlg, "剪切板历史", fmt.Sprintf("已导入 %d 条记录，跳过 %d 条已有记录", result.Imported, result.Skipped), walk.MsgBoxIconInformation)
+	})
+
+	closeButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return err
+	}
+	if err := closeButton.SetText("关闭"); err != nil {
+		return err
+	}
+	closeButton.Clicked().Attach(dlg.Cancel)
+	if err := dlg.SetCancelButton(closeButton); err != nil {
+		return err
+	}
+
+	dlg.Run()
+	return nil
+}
+
+// runHistoryTagsDialog edits tags separated by commas, and reports whether
+// they are saved
+func runHistoryTagsDialog(owner walk.Form, tags []string) ([]string, bool, error) {
+	dlg, err := walk.NewDialogWithFixedSize(owner)
+	if err != nil {
+		return nil, false, err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("标签"); err != nil {
+		return nil, false, err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return nil, false, err
+	}
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := label.SetText("标签（多个以逗号分隔）"); err != nil {
+		return nil, false, err
+	}
+	tagsEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := tagsEdit.SetText(strings.Join(tags, ", ")); err != nil {
+		return nil, false, err
+	}
+
+	buttons, err := walk.NewComposite(dlg)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := buttons.SetLayout(walk.NewHBoxLayout()); err != nil {
+		return nil, false, err
+	}
+	saveButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := saveButton.SetText("保存"); err != nil {
+		return nil, false, err
+	}
+	saveButton.Clicked().Attach(func() {
+		fields := make([]string, 0)
+		for _, field := range strings.FieldsFunc(tagsEdit.Text(), func(r rune) bool { return r == ',' || r == '，' }) {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+		var err error
+		if tags, err = normalizeTags(fields); err != nil {
+			walk.MsgBox(dlg, "标签", err.Error(), walk.MsgBoxIconError)
+			return
+		}
+		dlg.Accept()
+	})
+	if err := dlg.SetDefaultButton(saveButton); err != nil {
+		return nil, false, err
+	}
+	cancelButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := cancelButton.SetText("取消"); err != nil {
+		return nil, false, err
+	}
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	if err := dlg.SetCancelButton(cancelButton); err != nil {
+		return nil, false, err
+	}
+
+	if dlg.Run() != walk.DlgCmdOK {
+		return nil, false, nil
+	}
+	return tags, true, nil
+}
+
+func historyErrorMessage(err error, message string) string {
+	if err == errHistoryUnavailable {
+		return "未保存该记录的内容，或文件已不存在"
+	}
+	return message + "：" + err.Error()
+}
+
+// historyEntryContent returns content of entry, or paths of its files which
+// are extracted if they have been removed. errHistoryUnavailable is returned
+// if there's nothing left
+func historyEntryContent(entry HistoryEntry) ([]byte, []string, error) {
+	_, content, err := app.history.Get(entry.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if content == nil {
+		return nil, nil, errHistoryUnavailable
+	}
+	if entry.Type != utils.TypeFile {
+		return content, nil, nil
+	}
+	paths, err := historyFiles(entry, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, paths, nil
+}
+
+// historyThumbnailBitmap returns bitmap of thumbnail of entry
+func historyThumbnailBitmap(entry HistoryEntry) (*walk.Bitmap, error) {
+	data, err := app.history.Thumbnail(entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errNoThumbnail
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return walk.NewBitmapFromImage(img)
+}
+
+// copyHistoryEntry puts content of entry back on clipboard
+func copyHistoryEntry(entry HistoryEntry) error {
+	content, paths, err := historyEntryContent(entry)
+	if err != nil {
+		return err
+	}
+	switch entry.Type {
+	case utils.TypeText:
+		return setClipboardText(string(content))
+	case utils.TypeBitmap:
+		dib, pngBytes, err := decodeImage(content)
+		if err != nil {
+			return err
+		}
+		return utils.Clipboard().SetImage(dib, pngBytes, nil)
+	}
+	return utils.Clipboard().SetFiles(paths)
+}
+
+// saveHistoryEntry saves content of entry to a path chosen by user. Text is
+// saved as txt, image as png, and files other than a single file are zipped
+func saveHistoryEntry(owner walk.Form, entry HistoryEntry) error {
+	content, paths, err := historyEntryContent(entry)
+	if err != nil {
+		return err
+	}
+	name := "clipboard.txt"
+	switch {
+	case entry.Type == utils.TypeBitmap:
+		name = "clipboard.png"
+	case entry.Type == utils.TypeFile && isSingleFile(paths):
+		name = filepath.Base(paths[0])
+	case entry.Type == utils.TypeFile:
+		name = "clipboard.zip"
+	}
+	dlg := &walk.FileDialog{Title: "保存到文件", FilePath: name, Filter: "所有文件 (*.*)|*.*"}
+	if ok, err := dlg.ShowSave(owner); err != nil || !ok {
+		return err
+	}
+
+	if entry.Type != utils.TypeFile {
+		return ioutil.WriteFile(dlg.FilePath, content, 0644)
+	}
+	file, err := os.Create(dlg.FilePath)
+	if err != nil {
+		return err
+	}
+	if isSingleFile(paths) {
+		err = copyFileTo(file, paths[0])
+	} else {
+		_, err = writeZip(context.Background(), file, paths)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// historyArchiveFilter is filter of file dialogs of history archives
+const historyArchiveFilter = "剪切板历史 (*.zip)|*.zip"
+
+// exportHistoryFile exports history to an archive chosen by user
+func exportHistoryFile(owner walk.Form) error {
+	dlg := &walk.FileDialog{Title: "导出剪切板历史", FilePath: "clipboard-history.zip", Filter: historyArchiveFilter}
+	if ok, err := dlg.ShowSave(owner); err != nil || !ok {
+		return err
+	}
+	file, err := os.Create(dlg.FilePath)
+	if err != nil {
+		return err
+	}
+	count, err := app.history.Export(context.Background(), file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dlg.FilePath)
+		return err
+	}
+	log.WithField("count", count).Info("history exported")
+	return nil
+}
+
+// importHistoryFile imports an archive chosen by user. ok is false if user
+// cancelled
+func importHistoryFile(owner walk.Form) (result HistoryImportResult, ok bool, err error) {
+	dlg := &walk.FileDialog{Title: "导入剪切板历史", Filter: historyArchiveFilter}
+	if ok, err := dlg.ShowOpen(owner); err != nil || !ok {
+		return result, false, err
+	}
+	file, err := os.Open(dlg.FilePath)
+	if err != nil {
+		return result, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return result, false, err
+	}
+	result, err = importHistoryArchive(file, info.Size())
+	return result, err == nil, err
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
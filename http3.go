@@ -0,0 +1,62 @@
+//go:build http3
+// +build http3
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+// serveHTTP3 serves handler of server over HTTP/3 on udp of every listen
+// address, next to tcp listeners of server. It's experimental, so an address
+// failed to be listened on is skipped and tcp keeps serving. Returned func
+// closes the listeners
+func (app *Application) serveHTTP3(server *http.Server) func() {
+	if server.TLSConfig == nil || !app.config.TLS.HTTP3 {
+		return func() {}
+	}
+	conns := make([]net.PacketConn, 0, len(app.listenAddrs()))
+	servers := make([]*http3.Server, 0, len(app.listenAddrs()))
+	for _, addr := range app.listenAddrs() {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			log.WithError(err).WithField("addr", addr).Warn("failed to listen http3")
+			continue
+		}
+		h3 := &http3.Server{Server: &http.Server{Handler: server.Handler, TLSConfig: server.TLSConfig}}
+		conns = append(conns, conn)
+		servers = append(servers, h3)
+		go func() {
+			if err := h3.Serve(conn); err != nil {
+				log.WithError(err).Debug("http3 listener closed")
+			}
+		}()
+	}
+	return func() {
+		for i := range servers {
+			servers[i].Close()
+			// Serve doesn't close conn it's given
+			conns[i].Close()
+		}
+	}
+}
+
+// http3Handler advertises HTTP/3 on the port of tcp connection of requests,
+// so that clients switch to it for later requests
+func (app *Application) http3Handler(handler http.Handler) http.Handler {
+	if !app.config.TLS.HTTP3 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && r.ProtoMajor < 3 {
+			if _, port, err := net.SplitHostPort(addr.String()); err == nil {
+				w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%s"; ma=86400, h3-29=":%s"; ma=86400`, port, port))
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,18 @@
+//go:build !http3
+// +build !http3
+
+package main
+
+import "net/http"
+
+// serveHTTP3 does nothing as HTTP/3 is only built with http3 tag
+func (app *Application) serveHTTP3(server *http.Server) func() {
+	if server.TLSConfig != nil && app.config.TLS.HTTP3 {
+		log.Warn("tls.http3 is enabled, but http3 is not built in, build with -tags http3")
+	}
+	return func() {}
+}
+
+func (app *Application) http3Handler(handler http.Handler) http.Handler {
+	return handler
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotentResponseSize is max size of response body kept for replay
+const maxIdempotentResponseSize = 64 * 1024
+
+type idempotentResponse struct {
+	done      chan struct{}
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+}
+
+// IdempotencyStore keeps responses of requests with X-Idempotency-Key, so a
+// request retried with the same key is answered without being executed again
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+}
+
+func NewIdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{responses: make(map[string]*idempotentResponse)}
+}
+
+// begin returns response of key and whether it's created by this call. The
+// caller which creates it must call finish or abandon
+func (s *IdempotencyStore) begin(key string) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, resp := range s.responses {
+		if !resp.expiresAt.IsZero() && now.After(resp.expiresAt) {
+			delete(s.responses, k)
+		}
+	}
+	if resp, ok := s.responses[key]; ok {
+		return resp, false
+	}
+	resp := &idempotentResponse{done: make(chan struct{})}
+	s.responses[key] = resp
+	return resp, true
+}
+
+func (s *IdempotencyStore) finish(resp *idempotentResponse, ttl time.Duration) {
+	s.mu.Lock()
+	resp.expiresAt = time.Now().Add(ttl)
+	s.mu.Unlock()
+	close(resp.done)
+}
+
+// abandon removes response so that the request can be retried
+func (s *IdempotencyStore) abandon(key string, resp *idempotentResponse) {
+	s.mu.Lock()
+	delete(s.responses, key)
+	s.mu.Unlock()
+	close(resp.done)
+}
+
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.body.Len()+len(data) <= maxIdempotentResponseSize {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// idempotency replays response of the first request with the same
+// X-Idempotency-Key from the same client within idempotencyWindow. A retry
+// arriving while the first request is running waits for it. Server errors
+// are not kept so that they can be retried
+func idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Idempotency-Key")
+		ttl := time.Duration(app.config.IdempotencyWindow) * time.Second
+		if key == "" || ttl <= 0 {
+			c.Next()
+			return
+		}
+		key = c.GetString("clientName") + "|" + c.Request.Method + " " + c.Request.URL.Path + "|" + key
+
+		resp, created := app.idempotency.begin(key)
+		if !created {
+			select {
+			case <-resp.done:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			if resp.header == nil {
+				// the first request failed and is abandoned
+				respondError(c, http.StatusConflict, "idempotency_retry", "相同 X-Idempotency-Key 的请求失败，请重试")
+				return
+			}
+			for name, values := range resp.header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Status(resp.status)
+			c.Writer.Write(resp.body)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		finished := false
+		defer func() {
+			// also abandoned if handler panics
+			if !finished {
+				app.idempotency.abandon(key, resp)
+			}
+		}()
+		c.Next()
+		if c.Writer.Status() >= http.StatusInternalServerError || writer.body.Len() < c.Writer.Size() {
+			return
+		}
+		resp.status = c.Writer.Status()
+		resp.header = c.Writer.Header().Clone()
+		resp.body = writer.body.Bytes()
+		app.idempotency.finish(resp, ttl)
+		finished = true
+	}
+}
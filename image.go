@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const MIMEPNG = "image/png"
+
+// Ways to paste media sent by clients, see pasteMediaAs of config
+const (
+	PasteAsFile  = "file"
+	PasteAsImage = "image"
+	PasteAsBoth  = "both"
+)
+
+// maxImagePixels limits size of images put on clipboard as bitmap, which
+// takes 4 bytes a pixel
+const maxImagePixels = 64 << 20
+
+var errImageTooLarge = errors.New("image is too large")
+
+// pasteMediaMode returns X-Paste-As header, or pasteMediaAs if it's absent
+func pasteMediaMode(c *gin.Context) string {
+	mode := c.GetHeader("X-Paste-As")
+	if mode == "" {
+		mode = app.config.PasteMediaAs
+	}
+	switch mode {
+	case PasteAsImage, PasteAsBoth:
+		return mode
+	}
+	return PasteAsFile
+}
+
+// setClipboardPaths puts files on clipboard. A single media file is put as
+// image if requested and it can be decoded, e.g. jpeg, png and gif, while
+// other media like heic and video is still put as file
+func setClipboardPaths(c *gin.Context, contentType string, paths []string) error {
+	mode := pasteMediaMode(c)
+	if contentType == utils.TypeMedia && len(paths) == 1 && mode != PasteAsFile {
+		dib, pngBytes, err := decodeImageFile(paths[0])
+		if err == nil {
+			var files []string
+			if mode == PasteAsBoth {
+				files = paths
+			}
+			return utils.Clipboard().SetImage(dib, pngBytes, files)
+		}
+		log.WithError(err).WithField("path", contentSummary(paths[0])).Info("failed to decode media as image, paste it as file")
+	}
+	return utils.Clipboard().SetFiles(paths)
+}
+
+// decodeImageFile returns image of path as dib and png
+func decodeImageFile(path string) ([]byte, []byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeImage(data)
+}
+
+// decodeImage returns image of data as dib and png
+func decodeImage(data []byte) ([]byte, []byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, nil, errImageTooLarge
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	if format == "png" {
+		return utils.EncodeDIB(img), data, nil
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, nil, err
+	}
+	return utils.EncodeDIB(img), buf.Bytes(), nil
+}
+
+// imageHandler responds image on clipboard, e.g. a screenshot, as png
+// without wrapping it in json
+func imageHandler(c *gin.Context) {
+	sequence := setSequenceHeader(c)
+	if notModified(c, sequence) {
+		return
+	}
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil || contentType != utils.TypeBitmap {
+		respondError(c, http.StatusNotFound, "no_image", "剪切板中没有图片")
+		return
+	}
+	pngBytes, err := clipboardPNG()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	if !approveRead(c, "[图片媒体]") {
+		return
+	}
+	setAuditInfo(c, utils.TypeBitmap, len(pngBytes))
+	c.Header("Content-Disposition", `inline; filename="clipboard.png"`)
+	c.Data(http.StatusOK, MIMEPNG, pngBytes)
+	sendCopyNotification(log, c.GetString("clientName"), "[图片媒体] 被复制")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+)
+
+const KnownClientsFile = "clients.json"
+
+const (
+	// client names are sent before auth, so names and ips kept are capped
+	// to keep unauthenticated requests from growing the store without bound
+	maxKnownClients = 1000
+	// unknown clients are alerted at most once in the interval, so a
+	// scanner trying names doesn't flood notifications
+	unknownClientAlertInterval = time.Minute
+)
+
+// KnownClient is a client name or ip which has accessed the api
+type KnownClient struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	Blocked   bool      `json:"blocked"`
+}
+
+type knownClients struct {
+	Names map[string]*KnownClient `json:"names"`
+	IPs   map[string]*KnownClient `json:"ips"`
+}
+
+type unknownClient struct {
+	name string
+	ip   string
+}
+
+// KnownClientStore keeps client names and ips seen before and persists them
+// to file
+type KnownClientStore struct {
+	mu        sync.Mutex
+	path      string
+	clients   knownClients
+	pending   *unknownClient // latest unknown client to be allowed or blocked
+	lastAlert time.Time
+}
+
+func loadKnownClientStore(path string) (*KnownClientStore, error) {
+	store := &KnownClientStore{
+		path: path,
+		clients: knownClients{
+			Names: make(map[string]*KnownClient),
+			IPs:   make(map[string]*KnownClient),
+		},
+	}
+	if !utils.IsExistFile(path) {
+		return store, nil
+	}
+	clientsBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(clientsBytes, &store.clients); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Visit records client name and ip, and reports whether either of them is
+// never seen before or blocked. Once maxKnownClients are kept, new ones are
+// still reported but not recorded
+func (s *KnownClientStore) Visit(name, ip string) (isNew, blocked bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	recorded := false
+	for _, item := range []struct {
+		clients map[string]*KnownClient
+		key     string
+	}{{s.clients.Names, name}, {s.clients.IPs, ip}} {
+		client, ok := item.clients[item.key]
+		if !ok {
+			isNew = true
+			if len(item.clients) < maxKnownClients {
+				item.clients[item.key] = &KnownClient{FirstSeen: now}
+				recorded = true
+			}
+			continue
+		}
+		blocked = blocked || client.Blocked
+	}
+	if isNew {
+		s.pending = &unknownClient{name: name, ip: ip}
+	}
+	if recorded {
+		err = s.save()
+	}
+	return isNew, blocked, err
+}
+
+// AllowAlert reports whether an unknown client can be alerted now, which is
+// once in unknownClientAlertInterval
+func (s *KnownClientStore) AllowAlert() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastAlert) < unknownClientAlertInterval {
+		return false
+	}
+	s.lastAlert = now
+	return true
+}
+
+// TakePending returns and clears the latest unknown client
+func (s *KnownClientStore) TakePending() (name, ip string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return "", "", false
+	}
+	pending := s.pending
+	s.pending = nil
+	return pending.name, pending.ip, true
+}
+
+// SetBlocked blocks or allows client name and ip
+func (s *KnownClientStore) SetBlocked(name, ip string, blocked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, client := range []*KnownClient{s.clients.Names[name], s.clients.IPs[ip]} {
+		if client != nil {
+			client.Blocked = blocked
+		}
+	}
+	return s.save()
+}
+
+func (s *KnownClientStore) save() error {
+	clientsBytes, err := json.MarshalIndent(s.clients, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, clientsBytes, 0600)
+}
+
+// knownClient rejects blocked clients and alerts when a client name or ip
+// accesses the api for the first time
+func knownClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientName := c.GetString("clientName")
+		clientIP := c.ClientIP()
+		isNew, blocked, err := app.clients.Visit(clientName, clientIP)
+		if err != nil {
+			log.WithError(err).Warn("failed to save known clients")
+		}
+		if isNew {
+			log.WithField("clientIP", clientIP).WithField("clientName", clientName).Warn("unknown client accessed")
+			if app.config.UnknownClientAlert && app.clients.AllowAlert() {
+				message := fmt.Sprintf("%s (%s) 首次访问，点击此通知允许或阻止", clientName, clientIP)
+				if err := app.ni.ShowWarning("发现未知设备", message); err != nil {
+					log.WithError(err).Warn("failed to send notification")
+				}
+			}
+		}
+		if blocked {
+			abortWithError(c, http.StatusForbidden, "device_blocked", "操作被拒绝：该设备已被阻止")
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleUnknownClientAlertClicked asks whether to allow or block the latest
+// unknown client
+func handleUnknownClientAlertClicked() {
+	name, ip, ok := app.clients.TakePending()
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("设备 %s (%s) 首次访问了剪切板服务，是否允许其继续访问？\n\n选择“否”将阻止该设备名和 IP", name, ip)
+	result := walk.MsgBox(app.MainWindow, "发现未知设备", message, walk.MsgBoxYesNo|walk.MsgBoxIconWarning)
+	if err := app.clients.SetBlocked(name, ip, result == win.IDNO); err != nil {
+		log.WithError(err).Warn("failed to save known clients")
+	}
+}
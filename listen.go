@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/lxn/walk"
+	"golang.org/x/sys/windows"
+)
+
+// listenAddrs returns addresses http server listens on. All interfaces of
+// port are listened if listen is not configured
+func (app *Application) listenAddrs() []string {
+	if len(app.config.Listen) == 0 {
+		return []string{":" + app.config.Port}
+	}
+	return app.config.Listen
+}
+
+// serverPort returns port of the first listen address
+func (app *Application) serverPort() string {
+	_, port, err := net.SplitHostPort(app.listenAddrs()[0])
+	if err != nil {
+		return app.config.Port
+	}
+	return port
+}
+
+func (app *Application) serverScheme() string {
+	if app.config.TLS.Enable {
+		return "https"
+	}
+	return "http"
+}
+
+// serve listens on all listen addresses and serves server on them until any
+// of them fails
+func (app *Application) serve(server *http.Server) error {
+	// set before listening so that server can be restarted after failure
+	app.serverMu.Lock()
+	app.server = server
+	app.serverMu.Unlock()
+	server.ConnContext = saveConn
+
+	listeners := make([]net.Listener, 0, len(app.listenAddrs()))
+	for _, addr := range app.listenAddrs() {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, ln)
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			if server.TLSConfig != nil {
+				errs <- server.ServeTLS(ln, "", "")
+			} else {
+				errs <- server.Serve(ln)
+			}
+		}(ln)
+	}
+	closeHTTP3 := app.serveHTTP3(server)
+	err := <-errs
+	server.Close()
+	closeHTTP3()
+	return err
+}
+
+// serverURLs returns urls of http server on all listen addresses. Unspecified
+// addresses are expanded to local ip addresses, ipv4 ones come first. External
+// url of port mapping comes last
+func (app *Application) serverURLs() []string {
+	ips, err := utils.LocalIPs()
+	if err != nil {
+		log.WithError(err).Warn("failed to get local ip addresses")
+	}
+	v4 := make([]string, 0)
+	v6 := make([]string, 0)
+	for _, addr := range app.listenAddrs() {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if host != "" && !ip.IsUnspecified() {
+			if ip != nil && ip.To4() == nil {
+				v6 = append(v6, app.serverURL(host, port))
+			} else {
+				v4 = append(v4, app.serverURL(host, port))
+			}
+			continue
+		}
+		for _, localIP := range ips {
+			if localIP.To4() != nil && (ip == nil || ip.To4() != nil) {
+				v4 = append(v4, app.serverURL(localIP.String(), port))
+			}
+			// a dual-stack socket is listened if host is empty or "::"
+			if localIP.To4() == nil && (ip == nil || ip.To4() == nil) {
+				v6 = append(v6, app.serverURL(localIP.String(), port))
+			}
+		}
+	}
+	urls := append(v4, v6...)
+	if len(urls) == 0 {
+		urls = append(urls, app.serverURL("127.0.0.1", app.serverPort()))
+	}
+	if app.portMapper != nil {
+		if externalURL := app.portMapper.ExternalURL(); externalURL != "" {
+			urls = append(urls, externalURL)
+		}
+	}
+	return urls
+}
+
+func (app *Application) serverURL(host, port string) string {
+	return fmt.Sprintf("%s://%s%s", app.serverScheme(), net.JoinHostPort(host, port), app.basePath())
+}
+
+// toolTip returns tooltip of notify icon showing listen addresses
+func (app *Application) toolTip() string {
+	return "clipboard-online " + version + " " + strings.Join(app.listenAddrs(), " ")
+}
+
+func isAddrInUse(err error) bool {
+	return errors.Is(err, windows.WSAEADDRINUSE)
+}
+
+func showAddrInUseDialog() {
+	message := fmt.Sprintf("无法监听 %s，地址已被其他程序占用。\n\n请修改监听地址或端口", strings.Join(app.listenAddrs(), ", "))
+	walk.MsgBox(app.MainWindow, "HTTP Server 启动失败", message, walk.MsgBoxIconError)
+	showListenSettings()
+}
+
+func showListenSettings() {
+	changed, err := runListenSettingsDialog()
+	if err != nil {
+		log.WithError(err).Warn("failed to show listen settings")
+		return
+	}
+	if !changed {
+		return
+	}
+	if err := saveConfig(app.GetExecFilePath(ConfigFile), app.config); err != nil {
+		log.WithError(err).Warn("failed to save config")
+	}
+	if err := app.ni.SetToolTip(app.toolTip()); err != nil {
+		log.WithError(err).Warn("failed to set tooltip")
+	}
+	log.WithField("listen", app.listenAddrs()).Info("restart http server")
+	app.RestartHTTPServer()
+}
+
+// runListenSettingsDialog edits hosts and port of listen addresses. It
+// reports whether config is changed
+func runListenSettingsDialog() (bool, error) {
+	dlg, err := walk.NewDialogWithFixedSize(app.MainWindow)
+	if err != nil {
+		return false, err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("监听设置"); err != nil {
+		return false, err
+	}
+	if err := dlg.SetLayout(walk.NewGridLayout()); err != nil {
+		return false, err
+	}
+
+	hosts := make([]string, 0, len(app.config.Listen))
+	for _, addr := range app.config.Listen {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			hosts = append(hosts, host)
+		}
+	}
+	hostLabel, err := walk.NewLabel(dlg)
+	if err != nil {
+		return false, err
+	}
+	if err := hostLabel.SetText("监听地址（多个以空格分隔，留空监听所有网卡）"); err != nil {
+		return false, err
+	}
+	hostEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return false, err
+	}
+	if err := hostEdit.SetText(strings.Join(hosts, " ")); err != nil {
+		return false, err
+	}
+	portLabel, err := walk.NewLabel(dlg)
+	if err != nil {
+		return false, err
+	}
+	if err := portLabel.SetText("端口"); err != nil {
+		return false, err
+	}
+	portEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return false, err
+	}
+	if err := portEdit.SetText(app.serverPort()); err != nil {
+		return false, err
+	}
+
+	buttons, err := walk.NewComposite(dlg)
+	if err != nil {
+		return false, err
+	}
+	if err := buttons.SetLayout(walk.NewHBoxLayout()); err != nil {
+		return false, err
+	}
+	saveButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return false, err
+	}
+	if err := saveButton.SetText("保存并重启服务"); err != nil {
+		return false, err
+	}
+	saveButton.Clicked().Attach(func() {
+		port, err := strconv.Atoi(strings.TrimSpace(portEdit.Text()))
+		if err != nil || port <= 0 || port > 65535 {
+			walk.MsgBox(dlg, "监听设置", "端口应为 1-65535 之间的数字", walk.MsgBoxIconError)
+			return
+		}
+		listen := make([]string, 0)
+		for _, host := range strings.Fields(hostEdit.Text()) {
+			host = strings.Trim(host, "[]")
+			if net.ParseIP(host) == nil {
+				walk.MsgBox(dlg, "监听设置", "无效的 ip 地址："+host, walk.MsgBoxIconError)
+				return
+			}
+			listen = append(listen, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+		app.config.Port = strconv.Itoa(port)
+		app.config.Listen = listen
+		dlg.Accept()
+	})
+	cancelButton, err := walk.NewPushButton(buttons)
+	if err != nil {
+		return false, err
+	}
+	if err := cancelButton.SetText("取消"); err != nil {
+		return false, err
+	}
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	if err := dlg.SetCancelButton(cancelButton); err != nil {
+		return false, err
+	}
+
+	return dlg.Run() == walk.DlgCmdOK, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type localRequestKey struct{}
+
+// runLocalServer serves api over plain http on localhost for scripts running
+// on this computer. Requests of it skip auth, but are still subject to
+// permissions of device named by X-Client-Name
+func (app *Application) runLocalServer(engin *gin.Engine) error {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), localRequestKey{}, true)
+		engin.ServeHTTP(w, r.WithContext(ctx))
+	})
+	return http.ListenAndServe("127.0.0.1:"+app.config.Local.Port, handler)
+}
+
+// localGuard rejects requests to local listener by browsers. Host must be
+// the loopback address, so that a website resolving its name to 127.0.0.1
+// (DNS rebinding) is rejected, and requests with Origin are sent by web
+// pages, even simple ones without preflight
+func localGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isLocalRequest(c) {
+			c.Next()
+			return
+		}
+		port := app.config.Local.Port
+		if host := c.Request.Host; host != "127.0.0.1:"+port && host != "localhost:"+port {
+			log.WithField("host", host).Warn("local request of unexpected host rejected")
+			abortWithError(c, http.StatusForbidden, "local_host_forbidden", "操作被拒绝：无效的 Host")
+			return
+		}
+		if c.GetHeader("Origin") != "" {
+			log.WithField("origin", c.GetHeader("Origin")).Warn("local request from web page rejected")
+			abortWithError(c, http.StatusForbidden, "local_origin_forbidden", "操作被拒绝：不允许网页访问")
+			return
+		}
+		c.Next()
+	}
+}
+
+// isLocalRequest reports whether request is received by local listener
+func isLocalRequest(c *gin.Context) bool {
+	local, _ := c.Request.Context().Value(localRequestKey{}).(bool)
+	return local
+}
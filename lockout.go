@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lockout rejects requests from client ip banned for too many auth failures
+func lockout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.config.Lockout.Enable {
+			c.Next()
+			return
+		}
+		banned, remaining := app.lockout.Banned(c.ClientIP())
+		if !banned {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+		abortWithError(c, http.StatusForbidden, "ip_banned", "操作被拒绝：验证失败次数过多，请稍后再试")
+	}
+}
+
+// abortWithAuthError aborts request and records an auth failure of client ip
+func abortWithAuthError(c *gin.Context, status int, code, message string) {
+	recordAuthFailure(c)
+	abortWithError(c, status, code, message)
+}
+
+func recordAuthFailure(c *gin.Context) {
+	if !app.config.Lockout.Enable {
+		return
+	}
+	clientIP := c.ClientIP()
+	if !app.lockout.Fail(clientIP) {
+		return
+	}
+	clientName := c.GetString("clientName")
+	log.WithField("clientIP", clientIP).WithField("clientName", clientName).Warn("client ip banned for too many auth failures")
+	if app.config.Lockout.Notify {
+		message := fmt.Sprintf("%s (%s) 验证失败次数过多，已封禁 %d 分钟", clientIP, clientName, app.config.Lockout.BanDuration/60)
+		if err := app.ni.ShowWarning("已封禁可疑设备", message); err != nil {
+			log.WithError(err).Warn("failed to send notification")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	longPollDefaultTimeout = 30
+	longPollMaxTimeout     = 120
+)
+
+// setSequenceHeader sets current clipboard sequence number to
+// X-Clipboard-Sequence header, which can be used as since of /wait, and to
+// ETag header. The sequence number is returned
+func setSequenceHeader(c *gin.Context) uint32 {
+	sequence := utils.Clipboard().SequenceNumber()
+	c.Header("X-Clipboard-Sequence", strconv.FormatUint(uint64(sequence), 10))
+	c.Header("ETag", clipboardETag(sequence))
+	return sequence
+}
+
+// waitHandler blocks until clipboard sequence number exceeds since or timeout
+// elapses, then responds new clipboard content like getHandler
+func waitHandler(c *gin.Context) {
+	since, err := strconv.ParseUint(c.Query("since"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "since 参数错误")
+		return
+	}
+	timeout, err := strconv.Atoi(c.DefaultQuery("timeout", strconv.Itoa(longPollDefaultTimeout)))
+	if err != nil || timeout <= 0 || timeout > longPollMaxTimeout {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "timeout 参数错误")
+		return
+	}
+
+	events := app.events.Subscribe()
+	defer app.events.Unsubscribe(events)
+	timer := time.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+	for uint64(utils.Clipboard().SequenceNumber()) <= since {
+		select {
+		case <-events:
+		case <-timer.C:
+			setSequenceHeader(c)
+			c.Status(http.StatusNoContent)
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+
+	if isReadDisabled(c.GetString("clientName")) {
+		abortDisabled(c)
+		return
+	}
+	getHandler(c)
+}
@@ -33,6 +33,10 @@ func init() {
 	config, err = loadConfig(configFilePath)
 	if err != nil {
 		log.WithError(err).Warn("failed to load config")
+		config = &DefaultConfig
+	}
+	if err := ensureTOTPSecret(configFilePath, config); err != nil {
+		log.WithError(err).Warn("failed to generate totp secret")
 	}
 	log.SetLevel(config.LogLevel)
 
@@ -71,7 +75,7 @@ func main() {
 		log.WithError(err).Fatal("failed to set icon")
 	}
 
-	if err := app.ni.SetToolTip("clipboard-online " + version + " :" + config.Port); err != nil {
+	if err := app.ni.SetToolTip(app.toolTip()); err != nil {
 		log.WithError(err).Fatal("failed to set tooltip")
 	}
 
@@ -83,6 +87,62 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("failed to create ExitAction")
 	}
+	pairingQRCodeAction, err := action.NewPairingQRCodeAction(showPairingQRCode)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create PairingQRCodeAction")
+	}
+	auditViewerAction, err := action.NewAuditViewerAction(showAuditViewer)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create AuditViewerAction")
+	}
+	statsViewerAction, err := action.NewStatsViewerAction(showStatsViewer)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create StatsViewerAction")
+	}
+	historyViewerAction, err := action.NewHistoryViewerAction(showHistoryViewer)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create HistoryViewerAction")
+	}
+	historyBackupsAction, err := action.NewHistoryBackupsAction(showHistoryBackups)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create HistoryBackupsAction")
+	}
+	listenSettingsAction, err := action.NewListenSettingsAction(showListenSettings)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create ListenSettingsAction")
+	}
+	shareAction, err := action.NewShareAction(showShareDialog)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create ShareAction")
+	}
+	queueAction, err := action.NewQueueAction(pushClipboardToQueue)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create QueueAction")
+	}
+	snippetsMenu, err := newSnippetsMenu()
+	if err != nil {
+		log.WithError(err).Fatal("failed to create snippets menu")
+	}
+	snippetsAction, err := action.NewSnippetsAction(snippetsMenu)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create SnippetsAction")
+	}
+	transformAction, err := action.NewTransformAction(config.Transforms.Enable, toggleTransforms)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create TransformAction")
+	}
+	if err := app.AddActions(pairingQRCodeAction, shareAction, queueAction, snippetsAction, historyViewerAction, historyBackupsAction, auditViewerAction, statsViewerAction, listenSettingsAction, transformAction); err != nil {
+		log.WithError(err).Fatal("failed to add action")
+	}
+	if config.TLS.Enable && config.TLS.ClientAuth {
+		exportClientCertAction, err := action.NewExportClientCertAction(exportClientCertHandler)
+		if err != nil {
+			log.WithError(err).Fatal("failed to create ExportClientCertAction")
+		}
+		if err := app.AddActions(exportClientCertAction); err != nil {
+			log.WithError(err).Fatal("failed to add action")
+		}
+	}
 	if err := app.AddActions(autoRunAction, exitAction); err != nil {
 		log.WithError(err).Fatal("failed to add action")
 	}
@@ -93,6 +153,12 @@ func main() {
 
 	log.Debug("start http server")
 	app.RunHTTPServer()
+	app.RunDiscoveryResponder()
+	app.RunWebhooks()
+	app.RunMQTTPublisher()
+	app.RunPortMapping()
+	app.RunBridge()
+	app.RunHistoryBackups()
 	log.Debug("start app")
 	app.Run()
 }
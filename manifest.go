@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ManifestFile is information of a file in clipboard with sha256 of it,
+// which is empty for folders
+type ManifestFile struct {
+	ClipboardFile
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// FileManifest is response body of GET /?mode=list, which lists clipboard
+// without content so that client can confirm before downloading. Data is
+// empty for text
+type FileManifest struct {
+	Type      string         `json:"type"`
+	Data      []ManifestFile `json:"data"`
+	TotalSize int64          `json:"totalSize"`
+}
+
+// fileSHA256 returns hex encoded sha256 of file of path
+func fileSHA256(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, utils.ContextReader(ctx, f)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// folderSize returns total size of files in dir
+func folderSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// manifestHandler responds names, sizes and hashes of clipboard content
+func manifestHandler(c *gin.Context) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	manifest := FileManifest{Type: contentType, Data: []ManifestFile{}}
+	switch contentType {
+	case utils.TypeText:
+		str, err := clipboardText()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+			return
+		}
+		manifest.TotalSize = int64(len(str))
+	case utils.TypeBitmap:
+		pngBytes, err := clipboardPNG()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+			return
+		}
+		sum := sha256.Sum256(pngBytes)
+		file := ClipboardFile{Name: "clipboard.png", Size: int64(len(pngBytes))}
+		manifest.Data = append(manifest.Data, ManifestFile{file, hex.EncodeToString(sum[:])})
+		manifest.TotalSize = file.Size
+	case utils.TypeFile:
+		paths, err := utils.Clipboard().Files()
+		if err != nil {
+			log.WithError(err).Warn("failed to get path of files from clipboard")
+			respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+			return
+		}
+		for i, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			file := ManifestFile{ClipboardFile: ClipboardFile{
+				Index:    i,
+				Name:     filepath.Base(path),
+				IsDir:    info.IsDir(),
+				Modified: info.ModTime(),
+			}}
+			if info.IsDir() {
+				file.Size = folderSize(path)
+			} else {
+				file.Size = info.Size()
+				if file.SHA256, err = fileSHA256(c.Request.Context(), path); err != nil {
+					log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to hash file")
+				}
+			}
+			manifest.Data = append(manifest.Data, file)
+			manifest.TotalSize += file.Size
+		}
+	default:
+		respondError(c, http.StatusBadRequest, "unknown_content", "无法识别剪切板内容")
+		return
+	}
+	c.JSON(http.StatusOK, manifest)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TextFormatPlain    = "plain"
+	TextFormatMarkdown = "markdown"
+)
+
+// isMarkdown reports whether text of request is markdown to render, by
+// header X-Text-Format, or markdown.clients if it's absent
+func isMarkdown(c *gin.Context) bool {
+	if !app.config.Markdown.Enable {
+		return false
+	}
+	if format := c.GetHeader("X-Text-Format"); format != "" {
+		return strings.EqualFold(format, TextFormatMarkdown)
+	}
+	clientName := c.GetString("clientName")
+	for _, client := range app.config.Markdown.Clients {
+		if client == clientName {
+			return true
+		}
+	}
+	return false
+}
+
+// setClipboardMarkdown sets text rendered to html on clipboard, with text
+// itself as fallback for apps which don't read html
+func setClipboardMarkdown(text string) error {
+	text = utils.NormalizeLineEndings(text, app.config.LineEndings.Write)
+	return utils.Clipboard().SetHTML(text, utils.MarkdownToHTML(text))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+)
+
+const (
+	mqttKeepAlive   = 60 * time.Second
+	mqttDialTimeout = 10 * time.Second
+)
+
+// MQTTMessage is payload published to broker. Text is only included if
+// includeText is enabled
+type MQTTMessage struct {
+	Event
+	Text string `json:"text,omitempty"`
+}
+
+// RunMQTTPublisher publishes events to mqtt broker. Connection is made on
+// first event and made again after it's lost
+func (app *Application) RunMQTTPublisher() {
+	if !app.config.MQTT.Enable {
+		return
+	}
+	events := app.events.Subscribe()
+	go func() {
+		var client *utils.MQTTClient
+		ticker := time.NewTicker(mqttKeepAlive / 2)
+		defer ticker.Stop()
+		for {
+			var done <-chan struct{}
+			if client != nil {
+				done = client.Done()
+			}
+			select {
+			case event := <-events:
+				if event.Event == EventProgress {
+					continue
+				}
+				payload, err := json.Marshal(mqttMessage(event))
+				if err != nil {
+					log.WithError(err).Warn("failed to marshal mqtt message")
+					continue
+				}
+				if client == nil {
+					if client, err = dialMQTT(); err != nil {
+						log.WithError(err).Warn("failed to connect mqtt broker")
+						continue
+					}
+				}
+				if err := client.Publish(app.config.MQTT.Topic, payload, app.config.MQTT.Retain); err != nil {
+					log.WithError(err).Warn("failed to publish mqtt message")
+					client.Close()
+					client = nil
+				}
+			case <-ticker.C:
+				if client != nil {
+					client.Ping()
+				}
+			case <-done:
+				log.Info("mqtt connection lost")
+				client.Close()
+				client = nil
+			}
+		}
+	}()
+}
+
+func mqttMessage(event Event) MQTTMessage {
+	message := MQTTMessage{Event: event}
+	if !app.config.MQTT.IncludeText || event.Event != EventClipboard || event.Type != utils.TypeText {
+		return message
+	}
+	text, err := clipboardText()
+	if err != nil {
+		log.WithError(err).Warn("failed to get clipboard")
+		return message
+	}
+	if app.config.Sensitive.Enable && app.sensitive.Match(text) {
+		text = app.sensitive.Redact(text)
+	}
+	message.Text = text
+	return message
+}
+
+// dialMQTT connects to broker. Scheme of broker url is tcp, mqtt, ssl or mqtts
+func dialMQTT() (*utils.MQTTClient, error) {
+	config := app.config.MQTT
+	broker, err := url.Parse(config.Broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: mqttDialTimeout}
+	var conn net.Conn
+	switch broker.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.Dial("tcp", hostWithPort(broker, "1883"))
+	case "ssl", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostWithPort(broker, "8883"), &tls.Config{ServerName: broker.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported mqtt broker scheme: %s", broker.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(mqttDialTimeout))
+	client, err := utils.NewMQTTClient(conn, utils.MQTTOptions{
+		ClientID:  config.ClientID,
+		Username:  config.Username,
+		Password:  config.Password,
+		KeepAlive: mqttKeepAlive,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return client, nil
+}
+
+func hostWithPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
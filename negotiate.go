@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+const MIMEOctetStream = "application/octet-stream"
+
+// responseFormat negotiates representation of clipboard by Accept header.
+// json is served if Accept is missing, and is the only format of encrypted
+// responses. protobuf is available on v2 only
+func responseFormat(c *gin.Context) string {
+	if isEncrypted(c) {
+		return gin.MIMEJSON
+	}
+	if isV2(c) {
+		return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain, MIMEOctetStream, MIMEProtobuf)
+	}
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain, MIMEOctetStream)
+}
+
+func respondNotAcceptable(c *gin.Context) {
+	respondError(c, http.StatusNotAcceptable, "not_acceptable", "剪切板内容无法以请求的格式返回")
+}
+
+// isSingleFile reports whether paths is a single regular file
+func isSingleFile(paths []string) bool {
+	if len(paths) != 1 {
+		return false
+	}
+	info, err := os.Stat(paths[0])
+	return err == nil && !info.IsDir()
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	apiVersionHeader   = utils.OpenAPIParameter{Name: "X-API-Version", In: "header", Required: true, Description: "v1 接口版本，当前为 " + apiVersion}
+	clientNameHeader   = utils.OpenAPIParameter{Name: "X-Client-Name", In: "header", Description: "URL 编码的设备名称"}
+	contentTypeHeader  = utils.OpenAPIParameter{Name: "X-Content-Type", In: "header", Required: true, Description: "text、file、media、color 或 Apple UTI，例如 public.png"}
+	filenameHeader     = utils.OpenAPIParameter{Name: "X-Filename", In: "header", Required: true, Description: "URL 编码的文件名"}
+	modifiedHeader     = utils.OpenAPIParameter{Name: "X-Modified", In: "header", Description: "文件修改时间，Unix 时间戳（秒）或 RFC 3339 时间"}
+	folderHeader       = utils.OpenAPIParameter{Name: "X-Folder", In: "header", Description: "为 1 时请求内容为文件夹的 zip"}
+	idempotencyHeader  = utils.OpenAPIParameter{Name: "X-Idempotency-Key", In: "header", Description: "重试时保持不变的幂等键"}
+	pasteAsHeader      = utils.OpenAPIParameter{Name: "X-Paste-As", In: "header", Description: "file、image 或 both，单张媒体图片放入剪切板的方式"}
+	textFormatHeader   = utils.OpenAPIParameter{Name: "X-Text-Format", In: "header", Description: "为 markdown 时文本渲染为 HTML 放入剪切板，为 plain 时不渲染"}
+	codeLanguageHeader = utils.OpenAPIParameter{Name: "X-Code-Language", In: "header", Description: "代码语言，如 go、python，文本同时以语法高亮的 HTML 放入剪切板"}
+	formatQuery        = utils.OpenAPIParameter{Name: "format", In: "query", Required: true, Description: "注册的格式名称，可重复"}
+	modeQuery          = utils.OpenAPIParameter{Name: "mode", In: "query", Description: "为 list 时只返回文件名称、大小和哈希"}
+	pageQuery          = []utils.OpenAPIParameter{
+		{Name: "limit", In: "query", Description: "每页数量，默认 100，最大 1000"},
+		{Name: "offset", In: "query", Description: "跳过的数量"},
+		{Name: "since", In: "query", Description: "Unix 时间戳（秒）或 RFC 3339 时间"},
+	}
+	statsSinceQuery = utils.OpenAPIParameter{Name: "since", In: "query", Description: "Unix 时间戳（秒）或 RFC 3339 时间，默认为 30 天前"}
+	textRangeQuery  = []utils.OpenAPIParameter{
+		{Name: "offset", In: "query", Description: "文本起始字符位置，分段获取文本"},
+		{Name: "length", In: "query", Description: "文本字符数，默认到文本结尾"},
+	}
+	historyQuery = append([]utils.OpenAPIParameter{
+		{Name: "type", In: "query", Description: "text、bitmap 或 file"},
+		{Name: "q", In: "query", Description: "搜索预览中的文字"},
+		{Name: "client", In: "query", Description: "设置该内容的设备名称"},
+		{Name: "tag", In: "query", Description: "只列出有该标签的记录"},
+		historyTrashQuery,
+	}, pageQuery...)
+	historyTrashQuery       = utils.OpenAPIParameter{Name: "trash", In: "query", Description: "为 true 时针对回收站中的记录"}
+	historyPassphraseHeader = utils.OpenAPIParameter{Name: "X-History-Passphrase", In: "header", Description: "URL 编码的 history.passphrase，开启 history.lock 且历史未解锁时需要"}
+	historyOffsetQuery      = utils.OpenAPIParameter{Name: "offset", In: "query", Description: "id 为 latest 时跳过的最新记录数量，1 为上一条"}
+)
+
+// apiDocument describes routes of setupRoute. Streaming routes (/ws,
+// /events, /bridge) and gRPC are not included since OpenAPI can't describe
+// them
+func apiDocument(baseURL string) *utils.OpenAPI {
+	documentVersion := version
+	if documentVersion == "" {
+		documentVersion = "dev"
+	}
+	doc := utils.NewOpenAPI("clipboard-online", documentVersion)
+	doc.AddServer(baseURL)
+	doc.AddHeaderSecurity("authkey", "X-Auth", "md5(authkey + \".\" + floor(unix / authkeyExpiredTimeout)) 的十六进制，配置 authkey 时需要")
+	doc.AddHeaderSecurity("authToken", "X-Auth-Token", "配置 authToken 时需要")
+	doc.AddHeaderSecurity("deviceToken", "X-Device-Token", "开启 pairing 时需要，由 /pair/confirm 获得")
+	doc.SetError(ErrorResponse{})
+
+	v1 := func(op utils.OpenAPIOperation) {
+		op.Parameters = append([]utils.OpenAPIParameter{apiVersionHeader, clientNameHeader}, op.Parameters...)
+		if strings.HasPrefix(op.Path, "/history") {
+			op.Parameters = append(op.Parameters, historyPassphraseHeader)
+		}
+		doc.Add(op)
+	}
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/",
+		Summary:    "获取剪切板内容",
+		Parameters: append([]utils.OpenAPIParameter{modeQuery}, textRangeQuery...),
+		Response:   utils.OneOf{TextResponse{}, TextChunkResponse{}, FilesResponse{}, FileManifest{}},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/",
+		Summary:    "设置剪切板内容",
+		Parameters: []utils.OpenAPIParameter{contentTypeHeader, idempotencyHeader, pasteAsHeader, textFormatHeader, codeLanguageHeader},
+		Request:    utils.OneOf{TextBody{}, FileBody{}},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:      http.MethodPost,
+		Path:        "/raw",
+		Summary:     "上传单个文件到剪切板",
+		Parameters:  []utils.OpenAPIParameter{filenameHeader, modifiedHeader, folderHeader, idempotencyHeader},
+		RequestType: MIMEOctetStream,
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/fetch",
+		Summary:    "下载链接内容到剪切板",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    FetchBody{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/uploads",
+		Summary:    "创建分块上传",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    UploadCreateBody{},
+		Response:   UploadSession{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/uploads/:id",
+		Summary:  "获取分块上传进度",
+		Response: UploadSession{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:      http.MethodPut,
+		Path:        "/uploads/:id",
+		Summary:     "上传分块",
+		Parameters:  []utils.OpenAPIParameter{{Name: "offset", In: "query", Required: true, Description: "分块在文件中的偏移"}},
+		RequestType: MIMEOctetStream,
+		Response:    UploadSession{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/uploads/:id/finalize",
+		Summary:    "完成分块上传并放入剪切板",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/files",
+		Summary:    "列出剪切板中的文件",
+		Parameters: pageQuery,
+		Response:   FilePage{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/files/:index",
+		Summary:      "下载剪切板中的文件",
+		ResponseType: MIMEOctetStream,
+	})
+	v1(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/image.png",
+		Summary:      "获取剪切板中的图片",
+		ResponseType: MIMEPNG,
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/formats",
+		Summary:  "列出剪切板中的格式",
+		Response: FormatList{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/status",
+		Summary:  "获取剪切板状态",
+		Response: ClipboardStatus{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/custom",
+		Summary:    "获取剪切板中的自定义格式",
+		Parameters: []utils.OpenAPIParameter{formatQuery},
+		Response:   CustomFormats{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/custom",
+		Summary:    "以自定义格式设置剪切板",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    CustomFormats{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/zip",
+		Summary:      "打包下载剪切板中的文件",
+		ResponseType: "application/zip",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/audit",
+		Summary:    "获取审计日志",
+		Parameters: pageQuery,
+		Response:   AuditPage{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/stats",
+		Summary:    "获取使用统计",
+		Parameters: []utils.OpenAPIParameter{statsSinceQuery},
+		Response:   Stats{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/history",
+		Summary:    "列出剪切板历史",
+		Parameters: historyQuery,
+		Response:   HistoryPage{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodDelete,
+		Path:       "/history",
+		Summary:    "清空剪切板历史，移到回收站",
+		Parameters: []utils.OpenAPIParameter{historyTrashQuery},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/history.zip",
+		Summary:      "导出剪切板历史",
+		ResponseType: "application/zip",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:      http.MethodPost,
+		Path:        "/history.zip",
+		Summary:     "导入剪切板历史",
+		Parameters:  []utils.OpenAPIParameter{idempotencyHeader},
+		RequestType: "application/zip",
+		Response:    HistoryImportResult{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/history/:id",
+		Summary:    "获取历史记录的内容，id 为 latest 时获取最新的记录",
+		Parameters: []utils.OpenAPIParameter{historyOffsetQuery},
+		Response:   utils.OneOf{TextResponse{}, FilesResponse{}},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/history/:id/thumbnail",
+		Summary:      "获取历史记录的缩略图",
+		ResponseType: MIMEPNG,
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/history/:id",
+		Summary: "删除历史记录，移到回收站，回收站中的记录被彻底删除",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodPut,
+		Path:    "/history/:id/pin",
+		Summary: "置顶历史记录",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/history/:id/pin",
+		Summary: "取消置顶历史记录",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodPut,
+		Path:     "/history/:id/tags",
+		Summary:  "设置历史记录的标签",
+		Request:  HistoryTags{},
+		Response: HistoryTags{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodPost,
+		Path:    "/history/:id/recover",
+		Summary: "从回收站恢复历史记录",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/history/:id/restore",
+		Summary:    "将历史记录放回剪切板，id 为 latest 时放回最新的记录",
+		Parameters: []utils.OpenAPIParameter{historyOffsetQuery, idempotencyHeader},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/queue",
+		Summary:  "列出发送队列",
+		Response: QueueList{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/queue/next",
+		Summary:  "获取发送队列中最早的内容，X-Queue-Item-Id 为其 id，队列为空时返回 204",
+		Response: utils.OneOf{TextResponse{}, FilesResponse{}},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/queue/:id",
+		Summary: "确认已获取发送队列中的内容，将其移出队列",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/queue",
+		Summary: "清空发送队列",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/snippets",
+		Summary:  "列出常用片段",
+		Response: SnippetList{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/snippets",
+		Summary:    "添加常用片段",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    SnippetBody{},
+		Response:   Snippet{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/snippets/:id",
+		Summary:  "获取常用片段的内容",
+		Response: TextResponse{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPut,
+		Path:       "/snippets/:id",
+		Summary:    "修改常用片段",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    SnippetBody{},
+		Response:   Snippet{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/snippets/:id",
+		Summary: "删除常用片段",
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodPost,
+		Path:     "/link",
+		Summary:  "创建一次性下载链接",
+		Response: DownloadLink{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/share",
+		Summary:    "创建剪切板内容的签名分享链接",
+		Parameters: []utils.OpenAPIParameter{{Name: "ttl", In: "query", Description: "有效秒数"}},
+		Response:   DownloadLink{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:  http.MethodGet,
+		Path:    "/wait",
+		Summary: "等待剪切板变化",
+		Parameters: []utils.OpenAPIParameter{
+			{Name: "since", In: "query", Required: true, Description: "X-Clipboard-Sequence 的值"},
+			{Name: "timeout", In: "query", Description: "超时秒数"},
+		},
+		Response: utils.OneOf{TextResponse{}, FilesResponse{}},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/batch",
+		Summary:    "批量执行操作",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    []BatchOperation{},
+		Response:   BatchResponse{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/transfers",
+		Summary:  "列出传输",
+		Response: TransferList{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/transfers/:id",
+		Summary:  "获取传输进度",
+		Response: Transfer{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodPost,
+		Path:     "/pair/request",
+		Summary:  "请求配对",
+		Response: PairRequestResponse{},
+	})
+	v1(utils.OpenAPIOperation{
+		Method:   http.MethodPost,
+		Path:     "/pair/confirm",
+		Summary:  "确认配对",
+		Request:  PairConfirmBody{},
+		Response: PairConfirmResponse{},
+	})
+
+	doc.Add(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/download/:token",
+		Summary:      "通过一次性链接下载文件",
+		ResponseType: MIMEOctetStream,
+		Public:       true,
+	})
+
+	doc.Add(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/share/:id",
+		Summary:      "通过签名链接查看分享的内容",
+		Parameters:   []utils.OpenAPIParameter{{Name: "expires", In: "query", Required: true}, {Name: "signature", In: "query", Required: true}},
+		ResponseType: MIMEOctetStream,
+		Public:       true,
+	})
+	doc.Add(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/ping",
+		Summary:  "检查连接",
+		Response: PingResponse{},
+		Public:   true,
+	})
+
+	v2 := func(op utils.OpenAPIOperation) {
+		op.Parameters = append([]utils.OpenAPIParameter{clientNameHeader}, op.Parameters...)
+		if strings.HasPrefix(op.Path, "/v2/history") {
+			op.Parameters = append(op.Parameters, historyPassphraseHeader)
+		}
+		op.Error = V2ErrorResponse{}
+		doc.Add(op)
+	}
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/clipboard",
+		Summary:    "获取剪切板内容",
+		Parameters: append([]utils.OpenAPIParameter{modeQuery}, textRangeQuery...),
+		Response:   utils.OneOf{TextResponse{}, TextChunkResponse{}, FilesResponse{}, FileManifest{}},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPut,
+		Path:       "/v2/clipboard",
+		Summary:    "设置剪切板内容",
+		Parameters: []utils.OpenAPIParameter{contentTypeHeader, idempotencyHeader, pasteAsHeader, textFormatHeader, codeLanguageHeader},
+		Request:    utils.OneOf{TextBody{}, FileBody{}},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/v2/clipboard/image.png",
+		Summary:      "获取剪切板中的图片",
+		ResponseType: MIMEPNG,
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/v2/clipboard/fetch",
+		Summary:    "下载链接内容到剪切板",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    FetchBody{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/v2/clipboard/formats",
+		Summary:  "列出剪切板中的格式",
+		Response: FormatList{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/v2/clipboard/status",
+		Summary:  "获取剪切板状态",
+		Response: ClipboardStatus{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/clipboard/custom",
+		Summary:    "获取剪切板中的自定义格式",
+		Parameters: []utils.OpenAPIParameter{formatQuery},
+		Response:   CustomFormats{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPut,
+		Path:       "/v2/clipboard/custom",
+		Summary:    "以自定义格式设置剪切板",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    CustomFormats{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/clipboard/delta",
+		Summary:    "获取剪切板文本的增量",
+		Parameters: []utils.OpenAPIParameter{{Name: "base", In: "query", Description: "客户端已有文本的 sha256"}},
+		Response:   TextDelta{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPatch,
+		Path:       "/v2/clipboard",
+		Summary:    "以增量设置剪切板文本",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    TextDelta{},
+		Response:   TextDelta{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/files",
+		Summary:    "列出剪切板中的文件",
+		Parameters: pageQuery,
+		Response:   FilePage{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:      http.MethodPost,
+		Path:        "/v2/files",
+		Summary:     "上传单个文件到剪切板",
+		Parameters:  []utils.OpenAPIParameter{filenameHeader, modifiedHeader, folderHeader, idempotencyHeader},
+		RequestType: MIMEOctetStream,
+	})
+	v2(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/v2/files/:index",
+		Summary:      "下载剪切板中的文件",
+		ResponseType: MIMEOctetStream,
+	})
+	v2(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/v2/files.zip",
+		Summary:      "打包下载剪切板中的文件",
+		ResponseType: "application/zip",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/history",
+		Summary:    "列出剪切板历史",
+		Parameters: historyQuery,
+		Response:   HistoryPage{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodDelete,
+		Path:       "/v2/history",
+		Summary:    "清空剪切板历史，移到回收站",
+		Parameters: []utils.OpenAPIParameter{historyTrashQuery},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/v2/history.zip",
+		Summary:      "导出剪切板历史",
+		ResponseType: "application/zip",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:      http.MethodPost,
+		Path:        "/v2/history.zip",
+		Summary:     "导入剪切板历史",
+		Parameters:  []utils.OpenAPIParameter{idempotencyHeader},
+		RequestType: "application/zip",
+		Response:    HistoryImportResult{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/history/:id",
+		Summary:    "获取历史记录的内容，id 为 latest 时获取最新的记录",
+		Parameters: []utils.OpenAPIParameter{historyOffsetQuery},
+		Response:   utils.OneOf{TextResponse{}, FilesResponse{}},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:       http.MethodGet,
+		Path:         "/v2/history/:id/thumbnail",
+		Summary:      "获取历史记录的缩略图",
+		ResponseType: MIMEPNG,
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/v2/history/:id",
+		Summary: "删除历史记录，移到回收站，回收站中的记录被彻底删除",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodPut,
+		Path:    "/v2/history/:id/pin",
+		Summary: "置顶历史记录",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/v2/history/:id/pin",
+		Summary: "取消置顶历史记录",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodPut,
+		Path:     "/v2/history/:id/tags",
+		Summary:  "设置历史记录的标签",
+		Request:  HistoryTags{},
+		Response: HistoryTags{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodPost,
+		Path:    "/v2/history/:id/recover",
+		Summary: "从回收站恢复历史记录",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/v2/history/:id/restore",
+		Summary:    "将历史记录放回剪切板，id 为 latest 时放回最新的记录",
+		Parameters: []utils.OpenAPIParameter{historyOffsetQuery, idempotencyHeader},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodGet,
+		Path:       "/v2/stats",
+		Summary:    "获取使用统计",
+		Parameters: []utils.OpenAPIParameter{statsSinceQuery},
+		Response:   Stats{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/v2/queue",
+		Summary:  "列出发送队列",
+		Response: QueueList{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/v2/queue/next",
+		Summary:  "获取发送队列中最早的内容，X-Queue-Item-Id 为其 id，队列为空时返回 204",
+		Response: utils.OneOf{TextResponse{}, FilesResponse{}},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/v2/queue/:id",
+		Summary: "确认已获取发送队列中的内容，将其移出队列",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/v2/queue",
+		Summary: "清空发送队列",
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/v2/snippets",
+		Summary:  "列出常用片段",
+		Response: SnippetList{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPost,
+		Path:       "/v2/snippets",
+		Summary:    "添加常用片段",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    SnippetBody{},
+		Response:   Snippet{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:   http.MethodGet,
+		Path:     "/v2/snippets/:id",
+		Summary:  "获取常用片段的内容",
+		Response: TextResponse{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:     http.MethodPut,
+		Path:       "/v2/snippets/:id",
+		Summary:    "修改常用片段",
+		Parameters: []utils.OpenAPIParameter{idempotencyHeader},
+		Request:    SnippetBody{},
+		Response:   Snippet{},
+	})
+	v2(utils.OpenAPIOperation{
+		Method:  http.MethodDelete,
+		Path:    "/v2/snippets/:id",
+		Summary: "删除常用片段",
+	})
+	return doc
+}
+
+// openAPIHandler serves document of api, it's public so that companion apps
+// can be generated before pairing
+func openAPIHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, apiDocument(requestBaseURL(c)))
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+)
+
+const (
+	OutboxFile        = "outbox.json"
+	maxOutboxWebhooks = 1000
+	// delay of the first retry, which doubles on every failure up to
+	// outboxMaxRetry
+	outboxMinRetry      = 5 * time.Second
+	outboxMaxRetry      = 5 * time.Minute
+	outboxRetryInterval = time.Second
+)
+
+// WebhookDelivery is a webhook event failed to be posted as url was
+// unreachable
+type WebhookDelivery struct {
+	ID       string          `json:"id"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+	Created  time.Time       `json:"created"`
+	Attempts int             `json:"attempts"`
+	Next     time.Time       `json:"next"`
+}
+
+// BridgePending is clipboard content not yet mirrored to bridge peers, which
+// were disconnected when it was copied. It's kept in memory only, so content
+// is never written to disk
+type BridgePending struct {
+	Message *BridgeMessage `json:"message"`
+	Peers   []string       `json:"peers"`
+	Created time.Time      `json:"created"`
+}
+
+type outboxData struct {
+	Webhooks []WebhookDelivery `json:"webhooks"` // oldest first
+}
+
+// Outbox keeps webhook events failed to be delivered in a JSON file, so
+// they're delivered once the target is reachable again, even after restart.
+// Bridge content is kept until peers connect again
+type Outbox struct {
+	mu     sync.Mutex
+	path   string
+	data   outboxData
+	bridge *BridgePending
+	// connected counts connections of bridge peers, peers are all peers
+	// connected since start and the one of bridge.url
+	connected map[string]int
+	peers     map[string]bool
+}
+
+func loadOutbox(path string) (*Outbox, error) {
+	outbox := &Outbox{path: path, connected: make(map[string]int), peers: make(map[string]bool)}
+	if !utils.IsExistFile(path) {
+		return outbox, nil
+	}
+	outboxBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(outboxBytes, &outbox.data); err != nil {
+		return nil, err
+	}
+	return outbox, nil
+}
+
+func (o *Outbox) save() error {
+	outboxBytes, err := json.MarshalIndent(o.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(o.path, outboxBytes, 0600)
+}
+
+// outboxExpired reports whether content created is too old to be delivered
+func outboxExpired(created time.Time) bool {
+	return time.Since(created) > time.Duration(app.config.Outbox.MaxAge)*time.Hour
+}
+
+// retryDelay returns delay before retrying a delivery failed attempts times
+func retryDelay(attempts int) time.Duration {
+	delay := outboxMinRetry
+	for i := 1; i < attempts && delay < outboxMaxRetry; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxRetry {
+		delay = outboxMaxRetry
+	}
+	return delay
+}
+
+// AddWebhook keeps body failed to be posted to url. The oldest delivery is
+// dropped if there are too many
+func (o *Outbox) AddWebhook(url string, body []byte) {
+	if !app.config.Outbox.Enable {
+		return
+	}
+	id, err := utils.SecureRandString(12)
+	if err != nil {
+		log.WithError(err).Warn("failed to keep webhook in outbox")
+		return
+	}
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.data.Webhooks = append(o.data.Webhooks, WebhookDelivery{
+		ID:       id,
+		URL:      url,
+		Body:     body,
+		Created:  now,
+		Attempts: 1,
+		Next:     now.Add(retryDelay(1)),
+	})
+	if len(o.data.Webhooks) > maxOutboxWebhooks {
+		o.data.Webhooks = o.data.Webhooks[len(o.data.Webhooks)-maxOutboxWebhooks:]
+	}
+	if err := o.save(); err != nil {
+		log.WithError(err).Warn("failed to save outbox")
+	}
+}
+
+// dueWebhooks drops expired deliveries and those of urls no longer
+// configured, then returns deliveries due to be retried
+func (o *Outbox) dueWebhooks(urls []string) []WebhookDelivery {
+	configured := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		configured[url] = true
+	}
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	kept := o.data.Webhooks[:0]
+	var due []WebhookDelivery
+	for _, delivery := range o.data.Webhooks {
+		if !configured[delivery.URL] || outboxExpired(delivery.Created) {
+			log.WithField("url", delivery.URL).WithField("attempts", delivery.Attempts).Warn("webhook dropped from outbox")
+			continue
+		}
+		kept = append(kept, delivery)
+		if !delivery.Next.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	if len(kept) != len(o.data.Webhooks) {
+		o.data.Webhooks = kept
+		if err := o.save(); err != nil {
+			log.WithError(err).Warn("failed to save outbox")
+		}
+	}
+	return due
+}
+
+// webhookDone removes delivery of id if it's posted, otherwise it's retried
+// later
+func (o *Outbox) webhookDone(id string, posted bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.data.Webhooks {
+		if o.data.Webhooks[i].ID != id {
+			continue
+		}
+		if posted {
+			o.data.Webhooks = append(o.data.Webhooks[:i], o.data.Webhooks[i+1:]...)
+		} else {
+			o.data.Webhooks[i].Attempts++
+			o.data.Webhooks[i].Next = time.Now().Add(retryDelay(o.data.Webhooks[i].Attempts))
+		}
+		if err := o.save(); err != nil {
+			log.WithError(err).Warn("failed to save outbox")
+		}
+		return
+	}
+}
+
+// retryWebhooks posts deliveries in outbox when they are due. Once a url is
+// unreachable, remaining deliveries to it wait for the next retry
+func (o *Outbox) retryWebhooks(client *http.Client) {
+	for range time.Tick(outboxRetryInterval) {
+		unreachable := make(map[string]bool)
+		for _, delivery := range o.dueWebhooks(app.config.Webhook.URLs) {
+			if unreachable[delivery.URL] {
+				o.webhookDone(delivery.ID, false)
+				continue
+			}
+			if err := postWebhook(client, delivery.URL, delivery.Body); err != nil {
+				unreachable[delivery.URL] = true
+				o.webhookDone(delivery.ID, false)
+				continue
+			}
+			log.WithField("url", delivery.URL).WithField("attempts", delivery.Attempts).Info("webhook posted from outbox")
+			o.webhookDone(delivery.ID, true)
+		}
+	}
+}
+
+// KeepBridge keeps message for peers to be sent once they connect. It
+// replaces content kept before, which would be overwritten by message
+// anyway, for peers of both. Sensitive text is never kept
+func (o *Outbox) KeepBridge(peers []string, message *BridgeMessage) {
+	if !app.config.Outbox.Enable {
+		return
+	}
+	if message.Type == utils.TypeText && app.config.Sensitive.Enable && app.sensitive.Match(message.Text) {
+		log.Info("sensitive content is not kept for bridge peers")
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := &BridgePending{Message: message, Peers: peers, Created: time.Now()}
+	if previous := o.bridge; previous != nil && !outboxExpired(previous.Created) {
+		for _, peer := range previous.Peers {
+			if !containsString(pending.Peers, peer) {
+				pending.Peers = append(pending.Peers, peer)
+			}
+		}
+	}
+	o.bridge = pending
+}
+
+// connectBridge marks peer as connected and returns content pending for it
+func (o *Outbox) connectBridge(peer string) *BridgeMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.connected[peer]++
+	o.peers[peer] = true
+	pending := o.bridge
+	if pending == nil || !containsString(pending.Peers, peer) {
+		return nil
+	}
+	peers := make([]string, 0, len(pending.Peers))
+	for _, p := range pending.Peers {
+		if p != peer {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) == 0 {
+		o.bridge = nil
+	} else {
+		o.bridge = &BridgePending{Message: pending.Message, Peers: peers, Created: pending.Created}
+	}
+	if outboxExpired(pending.Created) {
+		return nil
+	}
+	return pending.Message
+}
+
+// disconnectBridge marks a connection of peer as lost
+func (o *Outbox) disconnectBridge(peer string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.connected[peer]--; o.connected[peer] <= 0 {
+		delete(o.connected, peer)
+	}
+}
+
+// addBridgePeer marks peer to keep content for even before it connects
+func (o *Outbox) addBridgePeer(peer string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.peers[peer] = true
+}
+
+// disconnectedPeers returns bridge peers not connected now
+func (o *Outbox) disconnectedPeers() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var peers []string
+	for peer := range o.peers {
+		if o.connected[peer] == 0 {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// watchBridge keeps clipboard content copied while bridge peers are
+// disconnected, so it's mirrored once they connect again
+func (o *Outbox) watchBridge() {
+	if !app.config.Outbox.Enable {
+		return
+	}
+	events := app.events.Subscribe()
+	go func() {
+		for event := range events {
+			if event.Event != EventClipboard {
+				continue
+			}
+			peers := o.disconnectedPeers()
+			if len(peers) == 0 {
+				continue
+			}
+			message, err := localBridgeMessage()
+			if err != nil {
+				log.WithError(err).Warn("failed to keep clipboard for bridge")
+				continue
+			}
+			if message != nil {
+				o.KeepBridge(peers, message)
+				log.WithField("peers", peers).Info("clipboard kept for disconnected bridge peers")
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// Page is limit, offset and since of list requests
+type Page struct {
+	Limit  int
+	Offset int
+	Since  time.Time // zero if not filtered
+}
+
+// parsePage parses limit, offset and since query parameters. since is unix
+// timestamp in seconds or RFC 3339 time. 400 is responded if any of them is
+// invalid
+func parsePage(c *gin.Context) (Page, bool) {
+	page := Page{Limit: defaultPageLimit}
+	var err error
+	if limit := c.Query("limit"); limit != "" {
+		if page.Limit, err = strconv.Atoi(limit); err != nil || page.Limit <= 0 || page.Limit > maxPageLimit {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "limit 参数错误")
+			return page, false
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if page.Offset, err = strconv.Atoi(offset); err != nil || page.Offset < 0 {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "offset 参数错误")
+			return page, false
+		}
+	}
+	if since := c.Query("since"); since != "" {
+		if page.Since, err = parseTime(since); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "since 参数错误")
+			return page, false
+		}
+	}
+	return page, true
+}
+
+func parseTime(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// after reports whether t passes since filter of page
+func (p Page) after(t time.Time) bool {
+	return p.Since.IsZero() || t.After(p.Since)
+}
+
+// bounds returns range of items of page in a collection of total items
+func (p Page) bounds(total int) (int, int) {
+	start := p.Offset
+	if start > total {
+		start = total
+	}
+	end := start + p.Limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// PageInfo is embedded in response bodies of lists, Total is count of
+// collection after filtering
+type PageInfo struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// info returns PageInfo of page in a collection of total items
+func (p Page) info(total int) PageInfo {
+	return PageInfo{Total: total, Limit: p.Limit, Offset: p.Offset}
+}
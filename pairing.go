@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	pairingTimeout     = 2 * time.Minute
+	pairingMaxAttempts = 3
+	// a client or ip may request pairing pairingMaxRequests times within
+	// pairingRequestWindow, so that pin can't be guessed by new sessions
+	pairingMaxRequests   = 5
+	pairingRequestWindow = 10 * time.Minute
+)
+
+var errPairingPending = errors.New("another pairing is pending")
+
+type pairingSession struct {
+	pin       string
+	expiresAt time.Time
+	attempts  int
+}
+
+// PairingManager keeps pending pairing sessions by client name and one-time
+// pairing tokens shown by qr code
+type PairingManager struct {
+	mu       sync.Mutex
+	sessions map[string]*pairingSession
+	tokens   map[string]time.Time
+	requests *utils.Lockout
+}
+
+func NewPairingManager() *PairingManager {
+	return &PairingManager{
+		sessions: make(map[string]*pairingSession),
+		tokens:   make(map[string]time.Time),
+		requests: utils.NewLockout(pairingMaxRequests, pairingRequestWindow, pairingRequestWindow),
+	}
+}
+
+// MintToken returns a one-time pairing token which expires after pairingTimeout
+func (m *PairingManager) MintToken() (string, error) {
+	token, err := utils.SecureRandString(24)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for t, expiresAt := range m.tokens {
+		if now.After(expiresAt) {
+			delete(m.tokens, t)
+		}
+	}
+	m.tokens[token] = now.Add(pairingTimeout)
+	return token, nil
+}
+
+// ConsumeToken reports whether token is a valid pairing token and invalidates it
+func (m *PairingManager) ConsumeToken(token string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiresAt, ok := m.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(m.tokens, token)
+	return time.Now().Before(expiresAt)
+}
+
+// Allow records a pairing request of client from ip, and reports whether it's
+// allowed, or how long to wait if either of them requested too many times
+func (m *PairingManager) Allow(client, ip string) (bool, time.Duration) {
+	keys := []string{"client|" + client, "ip|" + ip}
+	for _, key := range keys {
+		if banned, remaining := m.requests.Banned(key); banned {
+			return false, remaining
+		}
+	}
+	for _, key := range keys {
+		m.requests.Fail(key)
+	}
+	return true, 0
+}
+
+// Start creates a pairing session for client and returns its pin. It fails
+// with errPairingPending while a session of any client is pending
+func (m *PairingManager) Start(client string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	pin := fmt.Sprintf("%06d", n.Int64())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for name, session := range m.sessions {
+		if !now.After(session.expiresAt) {
+			return "", errPairingPending
+		}
+		delete(m.sessions, name)
+	}
+	m.sessions[client] = &pairingSession{pin: pin, expiresAt: now.Add(pairingTimeout)}
+	return pin, nil
+}
+
+// Confirm reports whether pin matches the pending session of client. The
+// session is dropped once confirmed, expired or failed too many times
+func (m *PairingManager) Confirm(client, pin string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[client]
+	if !ok {
+		return false
+	}
+	if time.Now().After(session.expiresAt) {
+		delete(m.sessions, client)
+		return false
+	}
+	if session.pin == pin {
+		delete(m.sessions, client)
+		return true
+	}
+	session.attempts++
+	if session.attempts >= pairingMaxAttempts {
+		delete(m.sessions, client)
+	}
+	return false
+}
+
+// paired rejects requests from devices that have not been paired if pairing is enabled
+func paired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.config.Pairing {
+			c.Next()
+			return
+		}
+
+		if app.devices.Verify(c.GetString("clientName"), c.GetHeader("X-Device-Token")) {
+			c.Next()
+			return
+		}
+
+		abortWithAuthError(c, http.StatusUnauthorized, "device_not_paired", "操作被拒绝：设备未配对")
+	}
+}
+
+// PairRequestResponse is response body of pairing request, pin is shown on
+// screen for ExpiresIn seconds
+type PairRequestResponse struct {
+	ExpiresIn int `json:"expiresIn"`
+}
+
+// PairConfirmResponse is response body of confirmed pairing, Token is sent
+// as X-Device-Token header afterwards
+type PairConfirmResponse struct {
+	Token string `json:"token"`
+}
+
+func pairRequestHandler(c *gin.Context) {
+	if c.GetHeader("X-Client-Name") == "" {
+		respondError(c, http.StatusBadRequest, "client_name_required", "配对需要设置设备名称")
+		return
+	}
+	clientName := c.GetString("clientName")
+	if _, ok := app.devices.Get(clientName); ok {
+		respondAlreadyPaired(c, clientName)
+		return
+	}
+	if allowed, wait := app.pairing.Allow(clientName, c.ClientIP()); !allowed {
+		log.WithField("clientName", clientName).WithField("clientIP", c.ClientIP()).Warn("too many pairing requests")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondError(c, http.StatusTooManyRequests, "pairing_rate_limited", "配对请求过于频繁，请稍后再试")
+		return
+	}
+	pin, err := app.pairing.Start(clientName)
+	if err == errPairingPending {
+		respondError(c, http.StatusConflict, "pairing_pending", "已有设备正在配对，请稍后再试")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to start pairing")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	log.WithField("clientName", clientName).Info("pairing requested")
+	// a notification doesn't block tray like a dialog
+	message := fmt.Sprintf("配对码：%s，%d 分钟内有效", pin, int(pairingTimeout.Minutes()))
+	if err := app.ni.ShowInfo(fmt.Sprintf("设备 %s 请求配对", clientName), message); err != nil {
+		log.WithError(err).Warn("failed to send notification")
+	}
+	c.JSON(http.StatusOK, PairRequestResponse{int(pairingTimeout.Seconds())})
+}
+
+// respondAlreadyPaired rejects pairing of client name which is already
+// paired. It must be unpaired by removing it from devices.json first
+func respondAlreadyPaired(c *gin.Context, clientName string) {
+	log.WithField("clientName", clientName).Warn("pairing of paired device rejected")
+	respondError(c, http.StatusConflict, "device_already_paired", "该设备名称已配对，如需重新配对请先从 devices.json 中移除")
+}
+
+// PairConfirmBody is a struct of request body when device confirms pairing.
+// Either pin shown on screen or pairing token scanned from qr code is required
+type PairConfirmBody struct {
+	Pin          string `json:"pin"`
+	PairingToken string `json:"pairingToken"`
+}
+
+func pairConfirmHandler(c *gin.Context) {
+	if c.GetHeader("X-Client-Name") == "" {
+		respondError(c, http.StatusBadRequest, "client_name_required", "配对需要设置设备名称")
+		return
+	}
+
+	var body PairConfirmBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		log.WithError(err).Warn("failed to bind pair confirm body")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	clientName := c.GetString("clientName")
+	var confirmed bool
+	if body.PairingToken != "" {
+		confirmed = app.pairing.ConsumeToken(body.PairingToken)
+	} else {
+		confirmed = app.pairing.Confirm(clientName, body.Pin)
+	}
+	if !confirmed {
+		log.WithField("clientName", clientName).Warn("pairing rejected")
+		abortWithAuthError(c, http.StatusForbidden, "invalid_pin", "配对码错误或已过期")
+		return
+	}
+
+	token, err := app.devices.Pair(clientName)
+	if err == errDeviceAlreadyPaired {
+		respondAlreadyPaired(c, clientName)
+		return
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to save paired device")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	log.WithField("clientName", clientName).Info("device paired")
+	c.JSON(http.StatusOK, PairConfirmResponse{token})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleReadOnly  = "read-only"
+	RoleWriteOnly = "write-only"
+	RoleReadWrite = "read-write"
+)
+
+// Capabilities which can be disabled globally or per device
+const (
+	CapabilityRead      = "read"
+	CapabilityWrite     = "write"
+	CapabilityReadText  = "read-text"
+	CapabilityReadFile  = "read-file" // including images
+	CapabilityWriteText = "write-text"
+	CapabilityWriteFile = "write-file" // including media
+	CapabilityAudit     = "audit"
+	CapabilityLink      = "link"
+	CapabilityHistory   = "history"
+	CapabilitySnippets  = "snippets"
+	CapabilityQueue     = "queue"
+)
+
+var capabilities = []string{
+	CapabilityRead,
+	CapabilityWrite,
+	CapabilityReadText,
+	CapabilityReadFile,
+	CapabilityWriteText,
+	CapabilityWriteFile,
+	CapabilityAudit,
+	CapabilityLink,
+	CapabilityHistory,
+	CapabilitySnippets,
+	CapabilityQueue,
+}
+
+// deviceConfig returns configuration of device with client name
+func deviceConfig(clientName string) ConfigDevice {
+	if device, ok := app.config.Devices[clientName]; ok {
+		return device
+	}
+	return ConfigDevice{Role: RoleReadWrite}
+}
+
+func canRead(role string) bool {
+	return role != RoleWriteOnly
+}
+
+func canWrite(role string) bool {
+	return role != RoleReadOnly
+}
+
+// readPermission rejects devices which are not allowed to get clipboard
+func readPermission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if canRead(deviceConfig(c.GetString("clientName")).Role) {
+			c.Next()
+			return
+		}
+		abortWithError(c, http.StatusForbidden, "read_forbidden", "操作被拒绝：该设备没有读取剪切板的权限")
+	}
+}
+
+// writePermission rejects devices which are not allowed to set clipboard
+func writePermission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if canWrite(deviceConfig(c.GetString("clientName")).Role) {
+			c.Next()
+			return
+		}
+		abortWithError(c, http.StatusForbidden, "write_forbidden", "操作被拒绝：该设备没有设置剪切板的权限")
+	}
+}
+
+// validateCapabilities returns error if there is an unknown capability in config
+func validateCapabilities() error {
+	lists := [][]string{app.config.Disabled}
+	for _, device := range app.config.Devices {
+		lists = append(lists, device.Disabled)
+	}
+	for _, list := range lists {
+		for _, name := range list {
+			if !containsString(capabilities, name) {
+				return fmt.Errorf("unknown capability: %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+// isDisabled reports whether capability is disabled globally or for client
+func isDisabled(clientName, capability string) bool {
+	return containsString(app.config.Disabled, capability) ||
+		containsString(deviceConfig(clientName).Disabled, capability)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func abortDisabled(c *gin.Context) {
+	abortWithError(c, http.StatusForbidden, "capability_disabled", "操作被拒绝：该功能已被禁用")
+}
+
+// capability rejects requests if any of names is disabled
+func capability(names ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientName := c.GetString("clientName")
+		for _, name := range names {
+			if isDisabled(clientName, name) {
+				abortDisabled(c)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// readCapability rejects reading clipboard if reading its current content
+// type is disabled
+func readCapability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isReadDisabled(c.GetString("clientName")) {
+			abortDisabled(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// isReadDisabled reports whether reading current content type of clipboard
+// is disabled for client
+func isReadDisabled(clientName string) bool {
+	if isDisabled(clientName, CapabilityRead) {
+		return true
+	}
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		return false
+	}
+	if contentType == utils.TypeText {
+		return isDisabled(clientName, CapabilityReadText)
+	}
+	return isDisabled(clientName, CapabilityReadFile)
+}
+
+// writeCapability rejects setting clipboard if writing X-Content-Type is disabled
+func writeCapability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := CapabilityWriteFile
+		if contentType := requestContentType(c); contentType == utils.TypeText || contentType == utils.TypeColor {
+			name = CapabilityWriteText
+		}
+		capability(CapabilityWrite, name)(c)
+	}
+}
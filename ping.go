@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PingResponse is response body of /ping. Time lets clients estimate clock
+// skew together with round trip of the request
+type PingResponse struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	APIVersion string    `json:"apiVersion"`
+	Uptime     int64     `json:"uptime"` // seconds
+	Time       time.Time `json:"time"`
+	Timestamp  int64     `json:"timestamp"` // unix milliseconds
+}
+
+// pingHandler responds identity of server. It skips authentication and
+// version check, so clients can test connectivity before anything else
+func pingHandler(c *gin.Context) {
+	now := time.Now()
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, PingResponse{
+		Name:       app.serverName(),
+		Version:    version,
+		APIVersion: apiVersion,
+		Uptime:     int64(now.Sub(app.startedAt).Seconds()),
+		Time:       now,
+		Timestamp:  now.UnixNano() / int64(time.Millisecond),
+	})
+}
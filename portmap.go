@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	natpmp "github.com/jackpal/go-nat-pmp"
+)
+
+const (
+	portMappingLifetime    = 2 * time.Hour
+	portMappingTimeout     = 5 * time.Second
+	portMappingRetry       = time.Minute
+	portMappingDescription = "clipboard-online"
+)
+
+// portMappingClient maps tcp port on router by NAT-PMP or UPnP
+type portMappingClient interface {
+	// add maps externalPort to internalPort and returns the port actually mapped
+	add(internalPort, externalPort int, lifetime time.Duration) (int, error)
+	remove(internalPort, externalPort int) error
+	externalIP() (net.IP, error)
+	String() string
+}
+
+type natpmpClient struct {
+	*natpmp.Client
+}
+
+func (c natpmpClient) add(internalPort, externalPort int, lifetime time.Duration) (int, error) {
+	result, err := c.AddPortMapping("tcp", internalPort, externalPort, int(lifetime.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	return int(result.MappedExternalPort), nil
+}
+
+func (c natpmpClient) remove(internalPort, externalPort int) error {
+	// a mapping is deleted by requesting it with lifetime 0
+	_, err := c.AddPortMapping("tcp", internalPort, 0, 0)
+	return err
+}
+
+func (c natpmpClient) externalIP() (net.IP, error) {
+	result, err := c.GetExternalAddress()
+	if err != nil {
+		return nil, err
+	}
+	return net.IP(result.ExternalIPAddress[:]), nil
+}
+
+func (natpmpClient) String() string {
+	return "NAT-PMP"
+}
+
+type upnpClient struct {
+	*utils.UPnPGateway
+	internalIP net.IP
+}
+
+func (c upnpClient) add(internalPort, externalPort int, lifetime time.Duration) (int, error) {
+	if externalPort == 0 {
+		externalPort = internalPort
+	}
+	return externalPort, c.AddPortMapping(externalPort, internalPort, c.internalIP, portMappingDescription, lifetime)
+}
+
+func (c upnpClient) remove(internalPort, externalPort int) error {
+	return c.DeletePortMapping(externalPort)
+}
+
+func (c upnpClient) externalIP() (net.IP, error) {
+	return c.ExternalIP()
+}
+
+func (upnpClient) String() string {
+	return "UPnP"
+}
+
+// newPortMappingClient tries NAT-PMP first and falls back to UPnP
+func newPortMappingClient() (portMappingClient, error) {
+	if gateway, err := utils.DefaultGateway(); err == nil {
+		client := natpmpClient{natpmp.NewClientWithTimeout(gateway, portMappingTimeout)}
+		if _, err := client.GetExternalAddress(); err == nil {
+			return client, nil
+		}
+	}
+
+	gateway, err := utils.DiscoverUPnPGateway(portMappingTimeout)
+	if err != nil {
+		return nil, err
+	}
+	internalIP, err := routeIP(gateway.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+	return upnpClient{UPnPGateway: gateway, internalIP: internalIP}, nil
+}
+
+// routeIP returns local ip address used to reach host of rawURL
+func routeIP(rawURL string) (net.IP, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	// no packet is sent by dialing udp
+	conn, err := net.Dial("udp4", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// PortMapper keeps a port mapping on router alive so that server can be
+// reached from internet
+type PortMapper struct {
+	mu           sync.Mutex
+	client       portMappingClient
+	internalPort int
+	externalPort int
+	externalURL  string
+	stop         chan struct{}
+}
+
+// RunPortMapping maps server port on router and renews it before it expires
+func (app *Application) RunPortMapping() {
+	if !app.config.PortMapping.Enable {
+		return
+	}
+	internalPort, err := strconv.Atoi(app.serverPort())
+	if err != nil {
+		log.WithError(err).Warn("invalid server port, port mapping is disabled")
+		return
+	}
+	externalPort := 0
+	if app.config.PortMapping.ExternalPort != "" {
+		if externalPort, err = strconv.Atoi(app.config.PortMapping.ExternalPort); err != nil {
+			log.WithError(err).Warn("invalid external port, port mapping is disabled")
+			return
+		}
+	}
+	app.portMapper = &PortMapper{internalPort: internalPort, externalPort: externalPort, stop: make(chan struct{})}
+	go app.portMapper.run()
+}
+
+func (m *PortMapper) run() {
+	notified := false
+	for {
+		wait := portMappingRetry
+		if err := m.renew(); err != nil {
+			log.WithError(err).Warn("failed to map port on router")
+		} else {
+			wait = portMappingLifetime / 2
+			if !notified {
+				notified = true
+				showExposedWarning(m.ExternalURL())
+			}
+		}
+		select {
+		case <-time.After(wait):
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *PortMapper) renew() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client == nil {
+		client, err := newPortMappingClient()
+		if err != nil {
+			return err
+		}
+		m.client = client
+	}
+	mapped, err := m.client.add(m.internalPort, m.externalPort, portMappingLifetime)
+	if err != nil {
+		// gateway may be changed, discover it again next time
+		m.client = nil
+		return err
+	}
+	ip, err := m.client.externalIP()
+	if err != nil {
+		return err
+	}
+	// keep the same external port on renewal
+	m.externalPort = mapped
+	externalURL := app.serverURL(ip.String(), strconv.Itoa(mapped))
+	if externalURL != m.externalURL {
+		log.WithField("url", externalURL).WithField("protocol", m.client.String()).Warn("server is exposed to internet by port mapping")
+	}
+	m.externalURL = externalURL
+	return nil
+}
+
+// ExternalURL returns url of server on internet, it is empty before port is mapped
+func (m *PortMapper) ExternalURL() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.externalURL
+}
+
+// Close stops renewal and removes port mapping from router
+func (m *PortMapper) Close() {
+	close(m.stop)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client == nil || m.externalURL == "" {
+		return
+	}
+	if err := m.client.remove(m.internalPort, m.externalPort); err != nil {
+		log.WithError(err).Warn("failed to remove port mapping")
+	}
+}
+
+func showExposedWarning(externalURL string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "路由器已将 %s 转发到本机，任何人都可以通过互联网访问剪贴板服务。", externalURL)
+	if app.config.Authkey == "" && app.config.AuthToken == "" && !app.config.Pairing {
+		b.WriteString("\n未设置 authkey、authToken 或配对，任何人都可以读写你的剪贴板！")
+	}
+	if !app.config.TLS.Enable {
+		b.WriteString("\n未启用 TLS，剪贴板内容将以明文传输。")
+	}
+	if app.config.LANOnly {
+		b.WriteString("\n已开启 lanOnly，来自互联网的请求将被拒绝。")
+	}
+	if err := app.ni.ShowWarning("已开启端口映射", b.String()); err != nil {
+		log.WithError(err).Warn("failed to show port mapping warning")
+	}
+}
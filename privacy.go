@@ -0,0 +1,36 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+)
+
+// contentSummary returns s for logging, or only its length and hash in
+// privacy mode so that clipboard contents never reach log file
+func contentSummary(s string) string {
+	if !app.config.Privacy {
+		return s
+	}
+	hash := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("len=%d sha256=%s", len(s), hex.EncodeToString(hash[:8]))
+}
+
+// contentSummaries returns contentSummary of each item in list
+func contentSummaries(list []string) []string {
+	summaries := make([]string, 0, len(list))
+	for _, s := range list {
+		summaries = append(summaries, contentSummary(s))
+	}
+	return summaries
+}
+
+// notificationPreview returns text shown in notification, which is only its
+// length in privacy mode
+func notificationPreview(text string) string {
+	if !app.config.Privacy || text == "" {
+		return text
+	}
+	return fmt.Sprintf("[文本] %d 个字符", utf8.RuneCountInString(text))
+}
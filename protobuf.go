@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// MIMEProtobuf is content type of ClipboardContent message of
+// proto/clipboard.proto, accepted by v2 clipboard endpoints as cheaper
+// alternative of base64 in json
+const MIMEProtobuf = "application/x-protobuf"
+
+var (
+	errClipboardUnavailable = errors.New("clipboard is unavailable")
+	errUnknownContent       = errors.New("unknown content of clipboard")
+	// errResponded means request has been rejected and responded, e.g. by
+	// approval or sensitive filter
+	errResponded = errors.New("request has been responded")
+)
+
+// ProtoFile is File message of proto/clipboard.proto
+type ProtoFile struct {
+	Name string
+	Data []byte
+}
+
+// ClipboardMessage is decoded ClipboardContent message
+type ClipboardMessage struct {
+	Type  string
+	Text  string
+	Files []ProtoFile
+}
+
+func encodeProtoFile(name string, data []byte) []byte {
+	var file utils.ProtoBuffer
+	file.AppendString(1, name)
+	file.AppendBytes(2, data)
+	return file.Bytes()
+}
+
+func decodeProtoFile(data []byte) (ProtoFile, error) {
+	var file ProtoFile
+	fields, err := utils.ParseProto(data)
+	if err != nil {
+		return file, err
+	}
+	for _, f := range fields {
+		switch {
+		case f.Number == 1 && f.WireType == utils.WireBytes:
+			file.Name = string(f.Bytes)
+		case f.Number == 2 && f.WireType == utils.WireBytes:
+			file.Data = f.Bytes
+		}
+	}
+	return file, nil
+}
+
+func decodeClipboardContent(data []byte) (ClipboardMessage, error) {
+	var message ClipboardMessage
+	fields, err := utils.ParseProto(data)
+	if err != nil {
+		return message, err
+	}
+	for _, f := range fields {
+		if f.WireType != utils.WireBytes {
+			continue
+		}
+		switch f.Number {
+		case 1:
+			message.Type = string(f.Bytes)
+		case 2:
+			message.Text = string(f.Bytes)
+		case 3:
+			file, err := decodeProtoFile(f.Bytes)
+			if err != nil {
+				return message, err
+			}
+			message.Files = append(message.Files, file)
+		}
+	}
+	return message, nil
+}
+
+// encodeClipboardContent encodes clipboard as ClipboardContent message and
+// returns it with notification of the read. Bitmap is encoded as
+// clipboard.png
+func encodeClipboardContent(c *gin.Context) ([]byte, string, error) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		log.WithError(err).Info("failed to get content type of clipboard")
+		return nil, "", errClipboardUnavailable
+	}
+
+	var content utils.ProtoBuffer
+	var notify string
+	switch contentType {
+	case utils.TypeText:
+		str, err := clipboardText()
+		if err != nil {
+			log.WithError(err).Warn("failed to get clipboard")
+			return nil, "", errClipboardUnavailable
+		}
+		str, ok := filterSensitiveText(c, str)
+		if !ok || !approveRead(c, str) {
+			return nil, "", errResponded
+		}
+		app.textVersions.Add(str)
+		content.AppendString(1, "text")
+		content.AppendString(2, str)
+		setAuditInfo(c, utils.TypeText, len(str))
+		notify = notificationPreview(str)
+	case utils.TypeBitmap:
+		pngBytes, err := clipboardPNG()
+		if err != nil {
+			return nil, "", errClipboardUnavailable
+		}
+		if !approveRead(c, "[图片媒体]") {
+			return nil, "", errResponded
+		}
+		content.AppendString(1, "file")
+		content.AppendBytes(3, encodeProtoFile("clipboard.png", pngBytes))
+		setAuditInfo(c, utils.TypeBitmap, len(pngBytes))
+		notify = "[图片媒体] 被复制"
+	case utils.TypeFile:
+		filenames, err := utils.Clipboard().Files()
+		if err != nil {
+			log.WithError(err).Warn("failed to get path of files from clipboard")
+			return nil, "", errClipboardUnavailable
+		}
+		basenames := make([]string, 0, len(filenames))
+		for _, path := range filenames {
+			basenames = append(basenames, filepath.Base(path))
+		}
+		if !approveRead(c, "[文件] "+strings.Join(basenames, ", ")) {
+			return nil, "", errResponded
+		}
+		content.AppendString(1, "file")
+		size := 0
+		for _, path := range filenames {
+			fileBytes, err := ioutil.ReadFile(path)
+			if err != nil {
+				log.WithError(err).WithField("filepath", contentSummary(path)).Warning("read file failed")
+				continue
+			}
+			size += len(fileBytes)
+			content.AppendBytes(3, encodeProtoFile(filepath.Base(path), fileBytes))
+		}
+		setAuditInfo(c, utils.TypeFile, size)
+		notify = "[文件] 被复制"
+	default:
+		return nil, "", errUnknownContent
+	}
+	return content.Bytes(), notify, nil
+}
+
+// getProtobufHandler responds clipboard as ClipboardContent message
+func getProtobufHandler(c *gin.Context) {
+	content, notify, err := encodeClipboardContent(c)
+	switch err {
+	case nil:
+	case errResponded:
+		return
+	case errUnknownContent:
+		respondError(c, http.StatusBadRequest, "unknown_content", "无法识别剪切板内容")
+		return
+	default:
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	log.Info("get clipboard by protobuf")
+	c.Data(http.StatusOK, MIMEProtobuf, content)
+	sendCopyNotification(log, c.GetString("clientName"), notify)
+}
+
+// setProtobufHandler sets clipboard from ClipboardContent message. Type of
+// message must agree with X-Content-Type, which permissions are checked by
+func setProtobufHandler(c *gin.Context) {
+	if isEncrypted(c) {
+		respondError(c, http.StatusBadRequest, "encryption_unsupported", "加密请求不支持 protobuf")
+		return
+	}
+	maxSize := app.config.ProtobufMaxSize << 20
+	data, err := ioutil.ReadAll(io.LimitReader(utils.ContextReader(c.Request.Context(), c.Request.Body), maxSize+1))
+	if err != nil {
+		log.WithError(err).Warn("failed to read protobuf body")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("请求内容超过 %d MB", app.config.ProtobufMaxSize))
+		return
+	}
+	message, err := decodeClipboardContent(data)
+	if err != nil {
+		log.WithError(err).Warn("failed to decode protobuf body")
+		respondError(c, http.StatusBadRequest, "invalid_body", "请求内容格式错误")
+		return
+	}
+
+	contentType := requestContentType(c)
+	if (contentType == utils.TypeText) != (message.Type == "text") {
+		respondError(c, http.StatusBadRequest, "content_type_mismatch", "X-Content-Type 与消息类型不一致")
+		return
+	}
+	if message.Type == "text" {
+		if err := putClipboardText(c, message.Text); err != nil {
+			log.WithError(err).Warn("failed to set clipboard")
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	paths := make([]string, 0, len(message.Files))
+	size := 0
+	for _, file := range message.Files {
+		if file.Name == "" {
+			respondError(c, http.StatusBadRequest, "missing_filename", "文件名不能为空")
+			return
+		}
+		path := utils.LatestFilename(app.GetTempFilePath(requestFilename(c, filepath.Base(file.Name))))
+		if err := newFile(path, file.Data); err != nil {
+			log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to create file")
+			continue
+		}
+		size += len(file.Data)
+		paths = append(paths, path)
+	}
+	setClipboardFiles(c, contentType, paths, size)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type forwardedProtoKey struct{}
+
+// basePath returns configured path prefix without trailing slash, it's empty
+// if server is served at root
+func (app *Application) basePath() string {
+	p := strings.Trim(app.config.Proxy.BasePath, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// proxyHandler makes handler work behind reverse proxies. For requests from
+// trusted proxies, remote address is replaced by client address in
+// X-Forwarded-For or X-Real-IP so that client ip is logged and rate limited
+// instead of proxy's, and X-Forwarded-Proto and X-Forwarded-Host are
+// honored. Base path is stripped and requests outside of it are not found
+func (app *Application) proxyHandler(handler http.Handler) (http.Handler, error) {
+	trusted, err := utils.ParseCIDRs(app.config.Proxy.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	basePath := app.basePath()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err == nil && utils.ContainsIP(trusted, net.ParseIP(host)) {
+			if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+				ctx = context.WithValue(ctx, forwardedProtoKey{}, proto)
+			}
+			ip := utils.ForwardedIP(net.ParseIP(host), r.Header.Get("X-Forwarded-For"), r.Header.Get("X-Real-IP"), trusted)
+			r.RemoteAddr = net.JoinHostPort(ip.String(), port)
+			if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+				r.Host = forwardedHost
+			}
+		}
+		r = r.WithContext(ctx)
+
+		if basePath != "" {
+			p := strings.TrimPrefix(r.URL.Path, basePath)
+			if p == r.URL.Path || (p != "" && p[0] != '/') {
+				http.NotFound(w, r)
+				return
+			}
+			u := *r.URL
+			u.Path = "/" + strings.TrimPrefix(p, "/")
+			u.RawPath = ""
+			r.URL = &u
+		}
+		handler.ServeHTTP(w, r)
+	}), nil
+}
+
+// requestBaseURL returns url of server root as seen by client
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto, ok := c.Request.Context().Value(forwardedProtoKey{}).(string); ok {
+		scheme = proto
+	}
+	u := url.URL{Scheme: scheme, Host: c.Request.Host, Path: app.basePath()}
+	return u.String()
+}
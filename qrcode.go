@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lxn/walk"
+	"github.com/skip2/go-qrcode"
+)
+
+const qrCodeSize = 320
+
+// PairingQRCode is the content of qr code for pairing
+type PairingQRCode struct {
+	URL             string `json:"url"`
+	Port            string `json:"port"`
+	PairingToken    string `json:"pairingToken"`
+	TOTPSecret      string `json:"totpSecret,omitempty"`
+	CertFingerprint string `json:"certFingerprint,omitempty"`
+}
+
+func showPairingQRCode() {
+	if err := runPairingQRCodeDialog(); err != nil {
+		log.WithError(err).Warn("failed to show pairing qr code")
+		walk.MsgBox(app.MainWindow, "扫码配对", "二维码生成失败", walk.MsgBoxIconError)
+	}
+}
+
+func runPairingQRCodeDialog() error {
+	token, err := app.pairing.MintToken()
+	if err != nil {
+		return err
+	}
+	urls := app.serverURLs()
+	qrCode := PairingQRCode{
+		URL:          urls[0],
+		Port:         app.serverPort(),
+		PairingToken: token,
+	}
+	if app.config.TOTP.Enable {
+		qrCode.TOTPSecret = app.config.TOTP.Secret
+	}
+	if app.config.TLS.Enable {
+		qrCode.CertFingerprint, err = app.certFingerprint()
+		if err != nil {
+			log.WithError(err).Warn("failed to get certificate fingerprint")
+		}
+	}
+	content, err := json.Marshal(qrCode)
+	if err != nil {
+		return err
+	}
+	qr, err := qrcode.New(string(content), qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	bitmap, err := walk.NewBitmapFromImage(qr.Image(qrCodeSize))
+	if err != nil {
+		return err
+	}
+	defer bitmap.Dispose()
+
+	dlg, err := walk.NewDialogWithFixedSize(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("扫码配对"); err != nil {
+		return err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return err
+	}
+
+	imageView, err := walk.NewImageView(dlg)
+	if err != nil {
+		return err
+	}
+	if err := imageView.SetImage(bitmap); err != nil {
+		return err
+	}
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("%s\n二维码 %d 分钟内有效，仅可使用一次", strings.Join(urls, "\n"), int(pairingTimeout.Minutes()))
+	if qrCode.CertFingerprint != "" {
+		text += "\n\n证书指纹 (SHA-256)：\n" + qrCode.CertFingerprint
+	}
+	if err := label.SetText(text); err != nil {
+		return err
+	}
+
+	closeButton, err := walk.NewPushButton(dlg)
+	if err != nil {
+		return err
+	}
+	if err := closeButton.SetText("关闭"); err != nil {
+		return err
+	}
+	closeButton.Clicked().Attach(dlg.Accept)
+	if err := dlg.SetCancelButton(closeButton); err != nil {
+		return err
+	}
+
+	dlg.Run()
+	return nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+)
+
+const (
+	QueueFile = "queue.json"
+	// AuditActionQueue is audit action of taking an item from queue
+	AuditActionQueue = "queue"
+	maxQueueItems    = 100
+	// maxQueueImageSize is the largest png kept in queue
+	maxQueueImageSize = 10 << 20
+	// queueWatchDelay is how long a copy on windows waits to be pushed, so
+	// that content set by a client is marked before
+	queueWatchDelay = time.Second
+)
+
+var (
+	errQueueFull         = errors.New("queue is full")
+	errQueueItemNotFound = errors.New("queue item not found")
+)
+
+// QueueItem is clipboard content pushed on windows, which devices take one
+// by one in order. Content is kept so that the item survives later copies
+type QueueItem struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Preview string    `json:"preview"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+	Text    string    `json:"text,omitempty"`
+	PNG     []byte    `json:"png,omitempty"`
+	Paths   []string  `json:"paths,omitempty"`
+}
+
+// QueueList is response body of listing queue
+type QueueList struct {
+	Data []QueueItem `json:"data"`
+}
+
+// QueueStore keeps queue in a JSON file
+type QueueStore struct {
+	mu    sync.Mutex
+	path  string
+	items []QueueItem // oldest first
+	// setSequence is clipboard sequence of content set by a client, which
+	// is not pushed by queue.watch
+	setSequence uint32
+}
+
+func loadQueueStore(path string) (*QueueStore, error) {
+	store := &QueueStore{path: path}
+	if !utils.IsExistFile(path) {
+		return store, nil
+	}
+	queueBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(queueBytes, &store.items); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// List returns items oldest first, without content
+func (q *QueueStore) List() []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]QueueItem, 0, len(q.items))
+	for _, item := range q.items {
+		item.Text, item.PNG, item.Paths = "", nil, nil
+		items = append(items, item)
+	}
+	return items
+}
+
+// Next returns the oldest item with its content, or false if queue is empty
+func (q *QueueStore) Next() (QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return QueueItem{}, false
+	}
+	return q.items[0], true
+}
+
+// Push appends s to queue and returns it as an item
+func (q *QueueStore) Push(s *share) (QueueItem, error) {
+	id, err := utils.SecureRandString(12)
+	if err != nil {
+		return QueueItem{}, err
+	}
+	item := QueueItem{
+		ID:      id,
+		Type:    s.contentType,
+		Preview: truncate(s.preview(), historyPreviewLength),
+		Created: time.Now(),
+		Text:    s.text,
+		PNG:     s.png,
+		Paths:   s.paths,
+	}
+	switch s.contentType {
+	case utils.TypeText:
+		item.Size = int64(len(s.text))
+	case utils.TypeBitmap:
+		item.Size = int64(len(s.png))
+	default:
+		item.Size = pathsSize(s.paths)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= maxQueueItems {
+		return QueueItem{}, errQueueFull
+	}
+	q.items = append(q.items, item)
+	if err := q.save(); err != nil {
+		q.items = q.items[:len(q.items)-1]
+		return QueueItem{}, err
+	}
+	return item, nil
+}
+
+// Ack removes item of id after a device has taken it
+func (q *QueueStore) Ack(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item.ID == id {
+			items := append(append([]QueueItem{}, q.items[:i]...), q.items[i+1:]...)
+			previous := q.items
+			q.items = items
+			if err := q.save(); err != nil {
+				q.items = previous
+				return err
+			}
+			return nil
+		}
+	}
+	return errQueueItemNotFound
+}
+
+// Clear removes all items
+func (q *QueueStore) Clear() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	previous := q.items
+	q.items = nil
+	if err := q.save(); err != nil {
+		q.items = previous
+		return err
+	}
+	return nil
+}
+
+// Len returns count of items
+func (q *QueueStore) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *QueueStore) save() error {
+	queueBytes, err := json.MarshalIndent(q.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(q.path, queueBytes, 0600)
+}
+
+// markSet marks clipboard of sequence as set by a client
+func (q *QueueStore) markSet(sequence uint32) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.setSequence = sequence
+}
+
+// isSet reports whether clipboard of sequence was set by a client
+func (q *QueueStore) isSet(sequence uint32) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.setSequence == sequence
+}
+
+// pushClipboard pushes current clipboard content to queue
+func pushClipboard() (QueueItem, error) {
+	s, err := snapshotClipboard()
+	if err != nil {
+		return QueueItem{}, err
+	}
+	if s.contentType == utils.TypeBitmap && len(s.png) > maxQueueImageSize {
+		return QueueItem{}, fmt.Errorf("图片超过 %d MB", maxQueueImageSize>>20)
+	}
+	item, err := app.queue.Push(s)
+	if err != nil {
+		return item, err
+	}
+	log.WithField("type", item.Type).WithField("count", app.queue.Len()).Info("clipboard pushed to queue")
+	return item, nil
+}
+
+// markQueueSet marks clipboard set by a request, so queue.watch doesn't
+// push it back to the queue
+func markQueueSet(action string) {
+	if action == AuditActionWrite {
+		app.queue.markSet(utils.Clipboard().SequenceNumber())
+	}
+}
+
+// watchQueue pushes clipboard copied on windows to queue after a while if
+// queue.watch, unless it has been set by a client
+func watchQueue() {
+	if !app.config.Queue.Watch {
+		return
+	}
+	sequence := utils.Clipboard().SequenceNumber()
+	time.AfterFunc(queueWatchDelay, func() {
+		if app.queue.isSet(sequence) || utils.Clipboard().SequenceNumber() != sequence {
+			return
+		}
+		if _, err := pushClipboard(); err != nil {
+			log.WithError(err).Warn("failed to push clipboard to queue")
+		}
+	})
+}
+
+// listQueueHandler responds items in queue, oldest first, without content
+func listQueueHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, QueueList{app.queue.List()})
+}
+
+// nextQueueHandler responds the oldest item in queue like GET /, with its
+// id in X-Queue-Item-Id to acknowledge it by DELETE /queue/:id. 204 is
+// responded if queue is empty
+func nextQueueHandler(c *gin.Context) {
+	item, ok := app.queue.Next()
+	if !ok {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	capabilityName := CapabilityReadFile
+	if item.Type == utils.TypeText {
+		capabilityName = CapabilityReadText
+	}
+	if isDisabled(c.GetString("clientName"), capabilityName) {
+		abortDisabled(c)
+		return
+	}
+	c.Header("X-Queue-Item-Id", item.ID)
+
+	switch item.Type {
+	case utils.TypeText:
+		if !approveRead(c, item.Text) {
+			return
+		}
+		data, err := encodeText(c, item.Text)
+		if err != nil {
+			log.WithError(err).Warn("failed to encrypt queue text")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		setAuditInfo(c, utils.TypeText, len(item.Text))
+		c.JSON(http.StatusOK, TextResponse{Type: "text", Data: data})
+	case utils.TypeBitmap:
+		if !approveRead(c, "[图片媒体]") {
+			return
+		}
+		data, err := encodeContent(c, item.PNG)
+		if err != nil {
+			log.WithError(err).Warn("failed to encrypt queue png")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		setAuditInfo(c, utils.TypeBitmap, len(item.PNG))
+		c.JSON(http.StatusOK, FilesResponse{"file", []ResponseFile{{Name: "clipboard.png", Content: data}}})
+	default:
+		paths := existingPaths(item.Paths)
+		if len(paths) == 0 {
+			respondError(c, http.StatusGone, "queue_content_unavailable", "文件已不存在")
+			return
+		}
+		if !approveRead(c, item.Preview) {
+			return
+		}
+		responseFiles, size := readResponseFiles(c, paths)
+		setAuditInfo(c, utils.TypeFile, size)
+		c.JSON(http.StatusOK, FilesResponse{"file", responseFiles})
+	}
+}
+
+// ackQueueHandler removes an item taken from queue
+func ackQueueHandler(c *gin.Context) {
+	err := app.queue.Ack(c.Param("id"))
+	if err == errQueueItemNotFound {
+		respondError(c, http.StatusNotFound, "queue_item_not_found", "队列中没有该内容")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to acknowledge queue item")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// clearQueueHandler removes all items in queue
+func clearQueueHandler(c *gin.Context) {
+	if err := app.queue.Clear(); err != nil {
+		log.WithError(err).Warn("failed to clear queue")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// pushClipboardToQueue pushes clipboard to queue from tray
+func pushClipboardToQueue() {
+	_, err := pushClipboard()
+	if err == errQueueFull {
+		err = fmt.Errorf("队列中的内容不能超过 %d 个", maxQueueItems)
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to push clipboard to queue")
+		walk.MsgBox(app.MainWindow, "发送队列", "加入失败："+err.Error(), walk.MsgBoxIconError)
+		return
+	}
+	if err := app.ni.ShowInfo("发送队列", fmt.Sprintf("已加入发送队列，共 %d 个", app.queue.Len())); err != nil {
+		log.WithError(err).Warn("failed to show notification")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// rawHandler saves application/octet-stream body as a file named by
+// X-Filename header and puts it on clipboard. It is put as media if
+// X-Content-Type is media or an Apple UTI of media, e.g. public.png
+func rawHandler(c *gin.Context) {
+	filename, err := url.PathUnescape(c.GetHeader("X-Filename"))
+	if err != nil || filename == "" {
+		respondError(c, http.StatusBadRequest, "missing_filename", "缺少 X-Filename 请求头")
+		return
+	}
+	modified, ok := parseModifiedHeader(c)
+	if !ok {
+		return
+	}
+	if !prepareSet(c) {
+		return
+	}
+
+	folder := c.GetHeader("X-Folder") == "1"
+	path := utils.LatestFilename(app.GetTempFilePath(requestFilename(c, filepath.Base(filename))))
+	if folder {
+		path = folderZipPath(filename)
+	}
+	size, err := saveFile(c, path, c.Request.Body)
+	if err != nil {
+		log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to create file")
+		respondError(c, http.StatusBadRequest, "save_failed", "无法保存文件")
+		return
+	}
+	if folder {
+		if path, err = extractFolder(path, filename); err != nil {
+			log.WithError(err).WithField("filename", contentSummary(filename)).Warn("failed to extract folder")
+			respondError(c, http.StatusBadRequest, "invalid_folder", "无法解压文件夹")
+			return
+		}
+	}
+	setFileModified(path, modified)
+	contentType := utils.TypeFile
+	if requestContentType(c) == utils.TypeMedia && !folder {
+		contentType = utils.TypeMedia
+	}
+	setClipboardFiles(c, contentType, []string{path}, int(size))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SensitiveActionBlock   = "block"
+	SensitiveActionRedact  = "redact"
+	SensitiveActionConfirm = "confirm"
+)
+
+// filterSensitiveText applies configured action if text contains sensitive
+// content. It returns text to be served and false if request has been aborted
+func filterSensitiveText(c *gin.Context, text string) (string, bool) {
+	if !app.config.Sensitive.Enable || !app.sensitive.Match(text) {
+		return text, true
+	}
+	clientName := c.GetString("clientName")
+	log.WithField("clientName", clientName).WithField("action", app.config.Sensitive.Action).Info("sensitive content detected")
+
+	switch app.config.Sensitive.Action {
+	case SensitiveActionRedact:
+		return app.sensitive.Redact(text), true
+	case SensitiveActionConfirm:
+		return text, requireApproval(c, "[敏感内容] "+app.sensitive.Redact(text))
+	default:
+		abortWithError(c, http.StatusForbidden, "sensitive_content", "操作被拒绝：剪切板内容包含敏感信息")
+		return "", false
+	}
+}
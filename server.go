@@ -4,11 +4,15 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"image/png"
+	"io"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,7 +23,6 @@ import (
 
 	"github.com/YanxinTang/clipboard-online/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/lxn/walk"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/image/bmp"
 )
@@ -28,11 +31,122 @@ const (
 	apiVersion = "1"
 )
 
-func setupRoute(engin *gin.Engine) {
-	engin.Use(clientName(), logger(), gin.Recovery(), apiVersionChecker(), auth())
-	engin.GET("/", getHandler)
-	engin.POST("/", setHandler)
+func setupRoute(engin *gin.Engine) error {
+	ipFilterMiddleware, err := ipFilter()
+	if err != nil {
+		return err
+	}
+	if err := validateCapabilities(); err != nil {
+		return err
+	}
+	engin.Use(clientName(), logger(), gin.Recovery(), localGuard(), requestTimeout(), tailscaleIdentity(), lanOnly(), ipFilterMiddleware, knownClient(), lockout(), rateLimit())
+	// one-time download links are authorized by token in url
+	engin.GET("/download/:token", audit(AuditActionDownload), trackTransfer(TransferDownload), downloadHandler)
+	engin.GET("/openapi.json", openAPIHandler)
+	engin.GET("/ping", pingHandler)
+	// signed share urls are authorized by signature in query
+	engin.GET("/share/:id", audit(AuditActionShare), trackTransfer(TransferDownload), shareHandler)
+
+	api := engin.Group("/", apiVersionChecker(), skipForTrusted(auth()), skipForTrusted(tokenAuth()), skipForTrusted(totp()), skipForTrusted(signature()), decompress(), encryption())
+	pair := api.Group("/pair")
+	pair.POST("/request", pairRequestHandler)
+	pair.POST("/confirm", pairConfirmHandler)
+
+	clipboard := api.Group("/", skipForTrusted(paired()))
+	clipboard.GET("/", readPermission(), readCapability(), audit(AuditActionRead), trackTransfer(TransferDownload), getHandler)
+	clipboard.POST("/", writePermission(), writeCapability(), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), setHandler)
+	clipboard.POST("/raw", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), rawHandler)
+	clipboard.POST("/fetch", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), fetchHandler)
+	clipboard.POST("/uploads", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), createUploadHandler)
+	clipboard.GET("/uploads/:id", writePermission(), getUploadHandler)
+	clipboard.PUT("/uploads/:id", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), trackTransfer(TransferUpload), putChunkHandler)
+	clipboard.POST("/uploads/:id/finalize", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), finalizeUploadHandler)
+	clipboard.GET("/files", readPermission(), capability(CapabilityRead, CapabilityReadFile), listFilesHandler)
+	clipboard.GET("/files/:index", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), fileHandler)
+	clipboard.GET("/image.png", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), imageHandler)
+	clipboard.GET("/formats", readPermission(), capability(CapabilityRead), formatsHandler)
+	clipboard.GET("/status", readPermission(), capability(CapabilityRead), statusHandler)
+	clipboard.GET("/custom", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), getCustomFormatsHandler)
+	clipboard.POST("/custom", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), setCustomFormatsHandler)
+	clipboard.GET("/zip", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), zipHandler)
+	clipboard.GET("/audit", readPermission(), capability(CapabilityAudit), auditHandler)
+	clipboard.GET("/stats", readPermission(), capability(CapabilityAudit), statsHandler)
+	clipboard.GET("/history", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), listHistoryHandler)
+	clipboard.DELETE("/history", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), clearHistoryHandler)
+	clipboard.GET("/history.zip", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), audit(AuditActionHistory), trackTransfer(TransferDownload), exportHistoryHandler)
+	clipboard.POST("/history.zip", writePermission(), capability(CapabilityWrite, CapabilityHistory), historyEnabled(), historyUnlocked(), idempotency(), audit(AuditActionHistory), trackTransfer(TransferUpload), importHistoryHandler)
+	clipboard.GET("/history/:id", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), audit(AuditActionHistory), trackTransfer(TransferDownload), getHistoryHandler)
+	clipboard.GET("/history/:id/thumbnail", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), historyThumbnailHandler)
+	clipboard.DELETE("/history/:id", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), deleteHistoryHandler)
+	clipboard.PUT("/history/:id/pin", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), pinHistoryHandler)
+	clipboard.DELETE("/history/:id/pin", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), unpinHistoryHandler)
+	clipboard.PUT("/history/:id/tags", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), setHistoryTagsHandler)
+	clipboard.POST("/history/:id/recover", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), recoverHistoryHandler)
+	clipboard.POST("/history/:id/restore", writePermission(), capability(CapabilityWrite, CapabilityHistory), historyEnabled(), historyUnlocked(), idempotency(), audit(AuditActionWrite), restoreHistoryHandler)
+	clipboard.GET("/queue", readPermission(), capability(CapabilityRead, CapabilityQueue), listQueueHandler)
+	clipboard.GET("/queue/next", readPermission(), capability(CapabilityRead, CapabilityQueue), audit(AuditActionQueue), trackTransfer(TransferDownload), nextQueueHandler)
+	clipboard.DELETE("/queue", readPermission(), capability(CapabilityRead, CapabilityQueue), clearQueueHandler)
+	clipboard.DELETE("/queue/:id", readPermission(), capability(CapabilityRead, CapabilityQueue), ackQueueHandler)
+	clipboard.GET("/snippets", readPermission(), capability(CapabilitySnippets), listSnippetsHandler)
+	clipboard.POST("/snippets", writePermission(), capability(CapabilitySnippets), idempotency(), createSnippetHandler)
+	clipboard.GET("/snippets/:id", readPermission(), capability(CapabilitySnippets), getSnippetHandler)
+	clipboard.PUT("/snippets/:id", writePermission(), capability(CapabilitySnippets), idempotency(), updateSnippetHandler)
+	clipboard.DELETE("/snippets/:id", writePermission(), capability(CapabilitySnippets), deleteSnippetHandler)
+	clipboard.POST("/link", readPermission(), readCapability(), capability(CapabilityLink), createDownloadLinkHandler)
+	clipboard.POST("/share", readPermission(), readCapability(), capability(CapabilityLink), createShareHandler)
+	clipboard.GET("/ws", readPermission(), capability(CapabilityRead), wsHandler)
+	clipboard.GET("/events", readPermission(), capability(CapabilityRead), eventsHandler)
+	clipboard.GET("/wait", readPermission(), capability(CapabilityRead), audit(AuditActionRead), waitHandler)
+	clipboard.POST("/batch", idempotency(), batchHandler(engin))
+	clipboard.GET("/transfers", listTransfersHandler)
+	clipboard.GET("/transfers/:id", transferHandler)
+	clipboard.GET("/bridge", readPermission(), writePermission(), capability(CapabilityRead, CapabilityWrite), bridgeHandler)
+
+	rpc := clipboard.Group(grpcService, grpcRequest())
+	rpc.POST("/GetClipboard", readPermission(), readCapability(), audit(AuditActionRead), grpcGetClipboardHandler)
+	rpc.POST("/SetText", writePermission(), capability(CapabilityWrite, CapabilityWriteText), audit(AuditActionWrite), grpcSetTextHandler)
+	rpc.POST("/SetFiles", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), audit(AuditActionWrite), grpcSetFilesHandler)
+	rpc.POST("/WatchChanges", readPermission(), capability(CapabilityRead), grpcWatchChangesHandler)
+
+	v2 := api.Group("/v2", v2Errors(), skipForTrusted(paired()))
+	v2.GET("/clipboard", readPermission(), readCapability(), audit(AuditActionRead), trackTransfer(TransferDownload), getHandler)
+	v2.PUT("/clipboard", writePermission(), writeCapability(), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), setHandler)
+	v2.POST("/clipboard/fetch", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), fetchHandler)
+	v2.GET("/clipboard/image.png", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), imageHandler)
+	v2.GET("/clipboard/formats", readPermission(), capability(CapabilityRead), formatsHandler)
+	v2.GET("/clipboard/status", readPermission(), capability(CapabilityRead), statusHandler)
+	v2.GET("/clipboard/custom", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), getCustomFormatsHandler)
+	v2.PUT("/clipboard/custom", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), setCustomFormatsHandler)
+	v2.GET("/clipboard/delta", readPermission(), capability(CapabilityRead, CapabilityReadText), audit(AuditActionRead), getDeltaHandler)
+	v2.PATCH("/clipboard", writePermission(), capability(CapabilityWrite, CapabilityWriteText), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), patchDeltaHandler)
+	v2.GET("/files", readPermission(), capability(CapabilityRead, CapabilityReadFile), listFilesHandler)
+	v2.POST("/files", writePermission(), capability(CapabilityWrite, CapabilityWriteFile), idempotency(), audit(AuditActionWrite), trackTransfer(TransferUpload), rawHandler)
+	v2.GET("/files/:index", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), fileHandler)
+	v2.GET("/history", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), listHistoryHandler)
+	v2.DELETE("/history", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), clearHistoryHandler)
+	v2.GET("/history.zip", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), audit(AuditActionHistory), trackTransfer(TransferDownload), exportHistoryHandler)
+	v2.POST("/history.zip", writePermission(), capability(CapabilityWrite, CapabilityHistory), historyEnabled(), historyUnlocked(), idempotency(), audit(AuditActionHistory), trackTransfer(TransferUpload), importHistoryHandler)
+	v2.GET("/history/:id", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), audit(AuditActionHistory), trackTransfer(TransferDownload), getHistoryHandler)
+	v2.GET("/history/:id/thumbnail", readPermission(), capability(CapabilityRead, CapabilityHistory), historyEnabled(), historyUnlocked(), historyThumbnailHandler)
+	v2.DELETE("/history/:id", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), deleteHistoryHandler)
+	v2.PUT("/history/:id/pin", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), pinHistoryHandler)
+	v2.DELETE("/history/:id/pin", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), unpinHistoryHandler)
+	v2.PUT("/history/:id/tags", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), setHistoryTagsHandler)
+	v2.POST("/history/:id/recover", writePermission(), capability(CapabilityHistory), historyEnabled(), historyUnlocked(), recoverHistoryHandler)
+	v2.POST("/history/:id/restore", writePermission(), capability(CapabilityWrite, CapabilityHistory), historyEnabled(), historyUnlocked(), idempotency(), audit(AuditActionWrite), restoreHistoryHandler)
+	v2.GET("/stats", readPermission(), capability(CapabilityAudit), statsHandler)
+	v2.GET("/queue", readPermission(), capability(CapabilityRead, CapabilityQueue), listQueueHandler)
+	v2.GET("/queue/next", readPermission(), capability(CapabilityRead, CapabilityQueue), audit(AuditActionQueue), trackTransfer(TransferDownload), nextQueueHandler)
+	v2.DELETE("/queue", readPermission(), capability(CapabilityRead, CapabilityQueue), clearQueueHandler)
+	v2.DELETE("/queue/:id", readPermission(), capability(CapabilityRead, CapabilityQueue), ackQueueHandler)
+	v2.GET("/snippets", readPermission(), capability(CapabilitySnippets), listSnippetsHandler)
+	v2.POST("/snippets", writePermission(), capability(CapabilitySnippets), idempotency(), createSnippetHandler)
+	v2.GET("/snippets/:id", readPermission(), capability(CapabilitySnippets), getSnippetHandler)
+	v2.PUT("/snippets/:id", writePermission(), capability(CapabilitySnippets), idempotency(), updateSnippetHandler)
+	v2.DELETE("/snippets/:id", writePermission(), capability(CapabilitySnippets), deleteSnippetHandler)
+	v2.GET("/files.zip", readPermission(), capability(CapabilityRead, CapabilityReadFile), audit(AuditActionRead), trackTransfer(TransferDownload), zipHandler)
 	engin.NoRoute(notFoundHandler)
+	return nil
 }
 
 func clientName() gin.HandlerFunc {
@@ -47,16 +161,77 @@ func clientName() gin.HandlerFunc {
 	}
 }
 
+// lanOnly rejects requests whose source address is not a private network
+// address. Remote address of connection is used since headers like
+// X-Forwarded-For can be forged, it's only resolved from them for requests
+// of proxy.trustedProxies
+func lanOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.config.LANOnly {
+			c.Next()
+			return
+		}
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err == nil && utils.IsPrivateIP(net.ParseIP(host)) {
+			c.Next()
+			return
+		}
+		abortWithError(c, http.StatusForbidden, "lan_only", "操作被拒绝：仅允许局域网访问")
+	}
+}
+
+// ipFilter rejects requests from client ip in denyIPs or not in allowIPs
+func ipFilter() (gin.HandlerFunc, error) {
+	allowNets, err := utils.ParseCIDRs(app.config.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := utils.ParseCIDRs(app.config.DenyIPs)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		ip := net.ParseIP(c.ClientIP())
+		denied := utils.ContainsIP(denyNets, ip)
+		if !denied && len(allowNets) > 0 {
+			denied = !utils.ContainsIP(allowNets, ip)
+		}
+		if !denied {
+			c.Next()
+			return
+		}
+		abortWithError(c, http.StatusForbidden, "ip_forbidden", "操作被拒绝：IP 不在允许范围内")
+	}, nil
+}
+
+// rateLimit limits requests per client ip and client name
+func rateLimit() gin.HandlerFunc {
+	limiter := utils.NewRateLimiter(app.config.RateLimit.Rate, app.config.RateLimit.Burst)
+	return func(c *gin.Context) {
+		if !app.config.RateLimit.Enable {
+			c.Next()
+			return
+		}
+		key := c.ClientIP() + "|" + c.GetString("clientName")
+		allowed, wait := limiter.Allow(key)
+		if allowed {
+			c.Next()
+			return
+		}
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		abortWithError(c, http.StatusTooManyRequests, "rate_limited", "请求过于频繁，请稍后再试")
+	}
+}
+
 func apiVersionChecker() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		version := c.GetHeader("X-API-Version")
-		if version == apiVersion {
+		if version == apiVersion || isV2(c) || isGRPC(c) {
 			c.Next()
 			return
 		}
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "接口版本不匹配，请升级您的捷径",
-		})
+		abortWithError(c, http.StatusBadRequest, "api_version_mismatch", "接口版本不匹配，请升级您的捷径")
 	}
 }
 
@@ -82,9 +257,25 @@ func auth() gin.HandlerFunc {
 			return
 		}
 
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-			"error": "操作被拒绝：Authkey 验证失败",
-		})
+		abortWithAuthError(c, http.StatusForbidden, "invalid_authkey", "操作被拒绝：Authkey 验证失败")
+	}
+}
+
+// tokenAuth requires a valid X-Auth-Token header if authToken is configured
+func tokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.config.AuthToken == "" {
+			c.Next()
+			return
+		}
+
+		reqToken := c.GetHeader("X-Auth-Token")
+		if subtle.ConstantTimeCompare([]byte(reqToken), []byte(app.config.AuthToken)) == 1 {
+			c.Next()
+			return
+		}
+
+		abortWithAuthError(c, http.StatusUnauthorized, "invalid_token", "操作被拒绝：Token 验证失败")
 	}
 }
 
@@ -99,6 +290,7 @@ func logger() gin.HandlerFunc {
 		clientName := c.GetString("clientName")
 		requestLogger := log.WithFields(logrus.Fields{
 			"method":     c.Request.Method,
+			"proto":      c.Request.Proto,
 			"statusCode": statusCode,
 			"clientIP":   clientIP,
 			"path":       path,
@@ -117,13 +309,47 @@ func logger() gin.HandlerFunc {
 }
 
 type ResponseFile struct {
-	Name    string `json:"name"`
-	Content string `json:"content"`
+	Name     string     `json:"name"`
+	Content  string     `json:"content"`
+	Modified *time.Time `json:"modified,omitempty"` // modification time of file
+	Folder   bool       `json:"folder,omitempty"`   // content is zip of a folder
 }
 
 type ResponseFiles []ResponseFile
 
+// TextResponse is json response body of text in clipboard
+type TextResponse struct {
+	Type  string      `json:"type"` // text
+	Data  string      `json:"data"`
+	Hint  string      `json:"hint,omitempty"`  // color if text is a color
+	Color *ColorValue `json:"color,omitempty"` // representations of color hint
+}
+
+// FilesResponse is json response body of files or image in clipboard
+type FilesResponse struct {
+	Type string         `json:"type"` // file
+	Data []ResponseFile `json:"data"`
+}
+
 func getHandler(c *gin.Context) {
+	sequence := setSequenceHeader(c)
+	c.Header("Vary", "Accept")
+	if notModified(c, sequence) {
+		return
+	}
+	if c.Query("mode") == "list" {
+		manifestHandler(c)
+		return
+	}
+	format := responseFormat(c)
+	if format == "" {
+		respondNotAcceptable(c)
+		return
+	}
+	if format == MIMEProtobuf {
+		getProtobufHandler(c)
+		return
+	}
 	contentType, err := utils.Clipboard().ContentType()
 	if err != nil {
 		log.WithError(err).Info("failed to get content type of clipboard")
@@ -132,54 +358,87 @@ func getHandler(c *gin.Context) {
 	}
 
 	if contentType == utils.TypeText {
-		str, err := walk.Clipboard().Text()
+		str, err := clipboardText()
 		if err != nil {
 			c.Status(http.StatusBadRequest)
 			log.WithError(err).Warn("failed to get clipboard")
 			return
 		}
+		str, ok := filterSensitiveText(c, str)
+		if !ok {
+			return
+		}
+		textRange, ok := parseTextRange(c)
+		if !ok {
+			return
+		}
+		if textRange != nil {
+			textChunkHandler(c, format, sequence, str, textRange)
+			return
+		}
+		if !approveRead(c, str) {
+			return
+		}
+		app.textVersions.Add(str)
+		if format != gin.MIMEJSON {
+			log.Info("get clipboard text")
+			setAuditInfo(c, utils.TypeText, len(str))
+			if format == gin.MIMEPlain {
+				format += "; charset=utf-8"
+			}
+			c.Data(http.StatusOK, format, []byte(str))
+			defer sendCopyNotification(log, c.GetString("clientName"), notificationPreview(str))
+			return
+		}
+		data, err := encodeText(c, str)
+		if err != nil {
+			log.WithError(err).Warn("failed to encrypt clipboard text")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
 		log.Info("get clipboard text")
-		c.JSON(http.StatusOK, gin.H{
-			"type": "text",
-			"data": str,
-		})
-		defer sendCopyNotification(log, c.GetString("clientName"), str)
+		setAuditInfo(c, utils.TypeText, len(str))
+		response := TextResponse{Type: "text", Data: data}
+		if color, ok := utils.ParseColor(str); ok {
+			response.Hint, response.Color = utils.TypeColor, newColorValue(color)
+		}
+		c.JSON(http.StatusOK, response)
+		defer sendCopyNotification(log, c.GetString("clientName"), notificationPreview(str))
 		return
 	}
 
 	if contentType == utils.TypeBitmap {
-		bmpBytes, err := utils.Clipboard().Bitmap()
+		if format == gin.MIMEPlain {
+			respondNotAcceptable(c)
+			return
+		}
+		pngBytes, err := clipboardPNG()
 		if err != nil {
-			log.WithError(err).Warn("failed to get bmp bytes from clipboard")
+			respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+			return
 		}
 
-		bmpBytesReader := bytes.NewReader(bmpBytes)
-		bmpImage, err := bmp.Decode(bmpBytesReader)
-		if err != nil {
-			log.WithError(err).Warn("failed to decode bmp")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无法获取剪切板内容"})
+		if !approveRead(c, "[图片媒体]") {
 			return
 		}
-		pngBytesBuffer := new(bytes.Buffer)
-		if err = png.Encode(pngBytesBuffer, bmpImage); err != nil {
-			log.WithError(err).Warn("failed to encode bmp as png")
+		if format == MIMEOctetStream {
+			setAuditInfo(c, utils.TypeBitmap, len(pngBytes))
+			c.Header("Content-Disposition", `attachment; filename="clipboard.png"`)
+			c.Data(http.StatusOK, format, pngBytes)
+			defer sendCopyNotification(log, c.GetString("clientName"), "[图片媒体] 被复制")
+			return
 		}
-
+		content, err := encodeContent(c, pngBytes)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无法获取剪切板内容"})
+			log.WithError(err).Warn("failed to encrypt png")
+			c.Status(http.StatusInternalServerError)
 			return
 		}
-
 		responseFiles := make([]ResponseFile, 0, 1)
-		responseFiles = append(responseFiles, ResponseFile{
-			"clipboard.png",
-			base64.StdEncoding.EncodeToString(pngBytesBuffer.Bytes()),
-		})
+		responseFiles = append(responseFiles, ResponseFile{Name: "clipboard.png", Content: content})
+		setAuditInfo(c, utils.TypeBitmap, len(pngBytes))
 
-		c.JSON(http.StatusOK, gin.H{
-			"type": "file",
-			"data": responseFiles,
-		})
+		c.JSON(http.StatusOK, FilesResponse{"file", responseFiles})
 		defer sendCopyNotification(log, c.GetString("clientName"), "[图片媒体] 被复制")
 		return
 	}
@@ -192,34 +451,105 @@ func getHandler(c *gin.Context) {
 			c.Status(http.StatusBadRequest)
 			return
 		}
+		if format == gin.MIMEPlain || format == MIMEOctetStream && !isSingleFile(filenames) {
+			respondNotAcceptable(c)
+			return
+		}
 
-		responseFiles := make([]ResponseFile, 0, len(filenames))
+		basenames := make([]string, 0, len(filenames))
 		for _, path := range filenames {
-			base64, err := readBase64FromFile(path)
-			if err != nil {
-				log.WithError(err).WithField("filepath", path).Warning("read base64 from file failed")
-				continue
-			}
-			responseFiles = append(responseFiles, ResponseFile{filepath.Base(path), base64})
+			basenames = append(basenames, filepath.Base(path))
+		}
+		if !approveRead(c, "[文件] "+strings.Join(basenames, ", ")) {
+			return
+		}
+
+		if format == MIMEOctetStream {
+			log.Info("get clipboard file")
+			setAuditInfo(c, utils.TypeFile, int(fileSize(filenames[0])))
+			c.Header("Content-Type", MIMEOctetStream)
+			c.FileAttachment(filenames[0], basenames[0])
+			defer sendCopyNotification(log, c.GetString("clientName"), "[文件] 被复制")
+			return
 		}
+
+		responseFiles, size := readResponseFiles(c, filenames)
 		log.Info("get clipboard files")
+		setAuditInfo(c, utils.TypeFile, size)
 
-		c.JSON(http.StatusOK, gin.H{
-			"type": "file",
-			"data": responseFiles,
-		})
+		c.JSON(http.StatusOK, FilesResponse{"file", responseFiles})
 		defer sendCopyNotification(log, c.GetString("clientName"), "[文件] 被复制")
 		return
 	}
-	c.JSON(http.StatusBadRequest, gin.H{"error": "无法识别剪切板内容"})
+	respondError(c, http.StatusBadRequest, "unknown_content", "无法识别剪切板内容")
 }
 
-func readBase64FromFile(path string) (string, error) {
+// readResponseFiles returns encoded content of files of paths and their
+// total size. Folders are zipped, and files which can't be read are skipped
+func readResponseFiles(c *gin.Context, paths []string) ([]ResponseFile, int) {
+	responseFiles := make([]ResponseFile, 0, len(paths))
+	size := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			log.WithError(err).WithField("filepath", contentSummary(path)).Warning("stat file failed")
+			continue
+		}
+		modified := info.ModTime()
+		file := ResponseFile{Name: filepath.Base(path), Modified: &modified}
+		var n int
+		if info.IsDir() {
+			file.Name += ".zip"
+			file.Folder = true
+			file.Content, n, err = readFolderContent(c, path)
+		} else {
+			file.Content, n, err = readContentFromFile(c, path)
+		}
+		if err != nil {
+			log.WithError(err).WithField("filepath", contentSummary(path)).Warning("read base64 from file failed")
+			continue
+		}
+		size += n
+		responseFiles = append(responseFiles, file)
+	}
+	return responseFiles, size
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// clipboardPNG returns image on clipboard as png. PNG format is used as is
+// if it's available, otherwise bitmap is encoded as png
+func clipboardPNG() ([]byte, error) {
+	if pngBytes, err := utils.Clipboard().PNG(); err == nil && bytes.HasPrefix(pngBytes, pngSignature) {
+		return pngBytes, nil
+	}
+	bmpBytes, err := utils.Clipboard().Bitmap()
+	if err != nil {
+		log.WithError(err).Warn("failed to get bmp bytes from clipboard")
+		return nil, err
+	}
+
+	bmpImage, err := bmp.Decode(bytes.NewReader(bmpBytes))
+	if err != nil {
+		log.WithError(err).Warn("failed to decode bmp")
+		return nil, err
+	}
+	pngBytesBuffer := new(bytes.Buffer)
+	if err = png.Encode(pngBytesBuffer, bmpImage); err != nil {
+		log.WithError(err).Warn("failed to encode bmp as png")
+		return nil, err
+	}
+	return pngBytesBuffer.Bytes(), nil
+}
+
+// readContentFromFile returns encoded content and size of file
+func readContentFromFile(c *gin.Context, path string) (string, int, error) {
 	fileBytes, err := ioutil.ReadFile(path)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
-	return base64.StdEncoding.EncodeToString(fileBytes), nil
+	content, err := encodeContent(c, fileBytes)
+	return content, len(fileBytes), err
 }
 
 // Set clipboard handler
@@ -230,40 +560,140 @@ type TextBody struct {
 }
 
 func setHandler(c *gin.Context) {
-	if !app.config.ReserveHistory {
-		cleanTempFiles()
+	if !prepareSet(c) {
+		return
+	}
+	if isV2(c) && c.ContentType() == MIMEProtobuf {
+		setProtobufHandler(c)
+		return
 	}
 
-	contentType := c.GetHeader("X-Content-Type")
+	contentType := requestContentType(c)
 	if contentType == utils.TypeText {
 		setTextHandler(c)
 		return
 	}
+	if contentType == utils.TypeColor {
+		setColorHandler(c)
+		return
+	}
 
 	setFileHandler(c)
 }
 
-func setTextHandler(c *gin.Context) {
+// prepareSet checks preconditions and parses common headers of set requests,
+// then cleans temp files of last request. It reports whether request can
+// continue
+func prepareSet(c *gin.Context) bool {
+	if !parseSetHeaders(c) {
+		return false
+	}
+	if !app.config.ReserveHistory {
+		cleanTempFiles()
+	}
+	return true
+}
+
+// parseSetHeaders checks preconditions and parses common headers of set
+// requests. It reports whether request can continue
+func parseSetHeaders(c *gin.Context) bool {
+	if preconditionFailed(c) {
+		return false
+	}
+	expireSeconds, err := parseExpireSeconds(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "X-Expire-Seconds 参数错误")
+		return false
+	}
+	c.Set("expireSeconds", expireSeconds)
+	return true
+}
+
+// bindText returns text of TextBody, which is decrypted if request is
+// encrypted
+func bindText(c *gin.Context) (string, bool) {
 	var body TextBody
 	if err := c.ShouldBindJSON(&body); err != nil {
 		log.WithError(err).Warn("failed to bind text body")
 		c.Status(http.StatusBadRequest)
-		return
+		return "", false
+	}
+
+	if isEncrypted(c) {
+		text, err := decryptPayload(body.Text)
+		if err != nil {
+			log.WithError(err).Warn("failed to decrypt text body")
+			respondError(c, http.StatusBadRequest, "decrypt_failed", "无法解密请求内容")
+			return "", false
+		}
+		body.Text = string(text)
 	}
+	return body.Text, true
+}
 
-	if err := utils.Clipboard().SetText(body.Text); err != nil {
+func setTextHandler(c *gin.Context) {
+	text, ok := bindText(c)
+	if !ok {
+		return
+	}
+	if err := putClipboardText(c, text); err != nil {
 		log.WithError(err).Warn("failed to set clipboard")
 		c.Status(http.StatusBadRequest)
 		return
 	}
+	c.Status(http.StatusOK)
+}
+
+// putClipboardText sets text to clipboard on behalf of client
+func putClipboardText(c *gin.Context, text string) error {
+	// client may have given up waiting
+	if err := c.Request.Context().Err(); err != nil {
+		return err
+	}
+	text = app.transforms.Set(text)
+	if app.config.DecodeImageText && !isDisabled(c.GetString("clientName"), CapabilityWriteFile) {
+		if data, ext, ok := utils.DecodeImageText(text); ok {
+			return putClipboardImageText(c, data, ext)
+		}
+	}
+	setText := setClipboardText
+	if language := codeLanguage(c); language != "" {
+		setText = func(text string) error {
+			return setClipboardCode(text, language)
+		}
+	} else if isMarkdown(c) {
+		setText = setClipboardMarkdown
+	}
+	if err := setText(text); err != nil {
+		return err
+	}
+	textPasted(c, text)
+	return nil
+}
+
+// textPasted records text set on clipboard by client and notifies it
+func textPasted(c *gin.Context, text string) {
+	app.textVersions.Add(text)
+	scheduleClipboardExpiry(c.GetInt("expireSeconds"))
 
 	var notify string = "粘贴内容为空"
-	if body.Text != "" {
-		notify = body.Text
+	if text != "" {
+		notify = notificationPreview(text)
 	}
 	defer sendPasteNotification(log, c.GetString("clientName"), notify)
-	log.WithField("text", body.Text).Info("set clipboard text")
-	c.Status(http.StatusOK)
+	log.WithField("text", contentSummary(text)).Info("set clipboard text")
+	setAuditInfo(c, utils.TypeText, len(text))
+}
+
+// putClipboardImageText saves image decoded from text and puts it on
+// clipboard as media instead of the base64 text
+func putClipboardImageText(c *gin.Context, data []byte, ext string) error {
+	path := utils.LatestFilename(app.GetTempFilePath("image" + ext))
+	if err := newFile(path, data); err != nil {
+		return err
+	}
+	log.WithField("path", contentSummary(path)).Info("decoded image from text")
+	return putClipboardFiles(c, utils.TypeMedia, []string{path}, len(data))
 }
 
 // FileBody is a struct of request body when iOS send files to windows
@@ -273,9 +703,11 @@ type FileBody struct {
 
 // File is a struct represtents request file
 type File struct {
-	Name   string `json:"name"` // filename
-	Base64 string `json:"base64"`
-	_bytes []byte `json:"-"` // don't use this directly. use *File.Bytes() to get bytes
+	Name     string     `json:"name"` // filename
+	Base64   string     `json:"base64"`
+	Modified *time.Time `json:"modified,omitempty"` // modification time to keep
+	Folder   bool       `json:"folder,omitempty"`   // base64 is zip of a folder named by name
+	_bytes   []byte     `json:"-"`                  // don't use this directly. use *File.Bytes() to get bytes
 }
 
 // Bytes returns byte slice of file
@@ -292,62 +724,188 @@ func (f *File) Bytes() ([]byte, error) {
 }
 
 func setFileHandler(c *gin.Context) {
-	contentType := c.GetHeader("X-Content-Type")
+	contentType := requestContentType(c)
 
-	var body FileBody
-	if err := c.ShouldBindJSON(&body); err != nil {
-		log.WithError(err).Warn("failed to bind file body")
+	var paths []string
+	var size int
+	var err error
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		paths, size, err = saveMultipartFiles(c)
+	} else {
+		paths, size, err = saveJSONFiles(c)
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to read file body")
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	setClipboardFiles(c, contentType, paths, size)
+}
+
+// setClipboardFiles puts saved files on clipboard and responds
+func setClipboardFiles(c *gin.Context, contentType string, paths []string, size int) {
+	if err := putClipboardFiles(c, contentType, paths, size); err != nil {
+		log.WithError(err).Warn("failed to set clipboard")
 		c.Status(http.StatusBadRequest)
 		return
 	}
+	c.Status(http.StatusOK)
+}
+
+// putClipboardFiles puts saved files on clipboard on behalf of client
+func putClipboardFiles(c *gin.Context, contentType string, paths []string, size int) error {
+	if err := c.Request.Context().Err(); err != nil {
+		return err
+	}
+	if app.config.ReserveHistory {
+		// clean paths in _filename.txt
+		setLastFilenames(nil)
+	} else {
+		// write paths to file
+		setLastFilenames(paths)
+	}
+
+	if err := setClipboardPaths(c, contentType, paths); err != nil {
+		return err
+	}
+	scheduleClipboardExpiry(c.GetInt("expireSeconds"))
+
+	if contentType == utils.TypeMedia {
+		setAuditInfo(c, utils.TypeMedia, size)
+	} else {
+		setAuditInfo(c, utils.TypeFile, size)
+	}
+
+	log.WithField("paths", contentSummaries(paths)).Info("set clipboard file")
+	if contentType == utils.TypeMedia {
+		sendMediaNotification(c.GetString("clientName"), "[图片媒体] 已复制到剪贴板", paths)
+	} else {
+		sendPasteNotification(log, c.GetString("clientName"), "[文件] 已复制到剪贴板")
+	}
+	return nil
+}
+
+// saveJSONFiles saves base64 encoded files in json body to temp directory
+// and returns their paths and total size
+func saveJSONFiles(c *gin.Context) ([]string, int, error) {
+	var body FileBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		return nil, 0, err
+	}
 
 	paths := make([]string, 0, len(body.Files))
+	size := 0
 	for _, file := range body.Files {
 		if file.Name == "-" && file.Base64 == "-" {
 			continue
 		}
-		path := utils.LatestFilename(app.GetTempFilePath(file.Name))
+		path := utils.LatestFilename(app.GetTempFilePath(requestFilename(c, file.Name)))
 		fileBytes, err := file.Bytes()
 		if err != nil {
-			log.WithField("filename", file.Name).Warn("failed to read file bytes")
+			log.WithField("filename", contentSummary(file.Name)).Warn("failed to read file bytes")
 			continue
 		}
+		if isEncrypted(c) {
+			fileBytes, err = decryptBytes(fileBytes)
+			if err != nil {
+				log.WithError(err).WithField("filename", contentSummary(file.Name)).Warn("failed to decrypt file bytes")
+				continue
+			}
+		}
+		if file.Folder {
+			path = folderZipPath(file.Name)
+		}
 		if err := newFile(path, fileBytes); err != nil {
-			log.WithError(err).WithField("path", path).Warn("failed to create file")
+			log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to create file")
 			continue
 		}
+		if file.Folder {
+			if path, err = extractFolder(path, file.Name); err != nil {
+				log.WithError(err).WithField("filename", contentSummary(file.Name)).Warn("failed to extract folder")
+				continue
+			}
+		}
+		setFileModified(path, file.Modified)
+		size += len(fileBytes)
 		paths = append(paths, path)
 	}
+	return paths, size, nil
+}
 
-	if app.config.ReserveHistory {
-		// clean paths in _filename.txt
-		setLastFilenames(nil)
-	} else {
-		// write paths to file
-		setLastFilenames(paths)
+// saveMultipartFiles streams files in multipart/form-data body to temp
+// directory and returns their paths and total size
+func saveMultipartFiles(c *gin.Context) ([]string, int, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, 0, err
 	}
-
-	if err := utils.Clipboard().SetFiles(paths); err != nil {
-		log.WithError(err).Warn("failed to set clipboard")
-		c.Status(http.StatusBadRequest)
-		return
+	paths := make([]string, 0)
+	size := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		filename := part.FileName()
+		if filename == "" {
+			// skip non-file fields
+			part.Close()
+			continue
+		}
+		path := utils.LatestFilename(app.GetTempFilePath(requestFilename(c, filename)))
+		n, err := saveFile(c, path, part)
+		part.Close()
+		if err != nil {
+			log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to create file")
+			continue
+		}
+		size += int(n)
+		paths = append(paths, path)
 	}
+	return paths, size, nil
+}
 
-	var notify string
-	if contentType == utils.TypeMedia {
-		notify = "[图片媒体] 已复制到剪贴板"
-	} else {
-		notify = "[文件] 已复制到剪贴板"
+// saveFile writes content read from r to path and returns its size. Content
+// is decrypted if request is encrypted, otherwise it's streamed to disk
+// without buffering in memory
+func saveFile(c *gin.Context, path string, r io.Reader) (int64, error) {
+	r = utils.ContextReader(c.Request.Context(), r)
+	if isEncrypted(c) {
+		encrypted, err := ioutil.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		fileBytes, err := decryptBytes(encrypted)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(fileBytes)), newFile(path, fileBytes)
 	}
-
-	defer sendPasteNotification(log, c.GetString("clientName"), notify)
-	log.WithField("paths", paths).Info("set clipboard file")
-	c.Status(http.StatusOK)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return n, err
 }
 
 func notFoundHandler(c *gin.Context) {
 	requestLogger := log.WithFields(logrus.Fields{"user_ip": c.Request.RemoteAddr})
 	requestLogger.Info("404 not found")
+	if isV2(c) {
+		respondError(c, http.StatusNotFound, "not_found", "接口不存在")
+		return
+	}
 	c.Status(http.StatusNotFound)
 }
 
@@ -363,12 +921,15 @@ func sendPasteNotification(logger *logrus.Logger, client, notify string) {
 	}
 }
 
+func notificationTitle(action, client string) string {
+	return fmt.Sprintf("%s自 %s", action, client)
+}
+
 func sendNotification(logger *logrus.Logger, action, client, notify string) {
 	if notify == "" {
 		notify = action + "内容为空"
 	}
-	title := fmt.Sprintf("%s自 %s", action, client)
-	if err := app.ni.ShowInfo(title, notify); err != nil {
+	if err := app.ni.ShowInfo(notificationTitle(action, client), notify); err != nil {
 		logger.WithError(err).WithField("notify", notify).Warn("failed to send notification")
 	}
 }
@@ -383,6 +944,33 @@ func newFile(path string, bytes []byte) error {
 	return ioutil.WriteFile(path, bytes, 0644)
 }
 
+// setFileModified sets modification time of saved file to the time sent by
+// client, so that it's kept on clipboard. Nothing is done if it's nil
+func setFileModified(path string, modified *time.Time) {
+	if modified == nil {
+		return
+	}
+	if err := os.Chtimes(path, time.Now(), *modified); err != nil {
+		log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to set modification time")
+	}
+}
+
+// parseModifiedHeader parses X-Modified header, unix timestamp in seconds or
+// RFC 3339 time. It returns nil if the header is absent and responds 400 if
+// it's invalid
+func parseModifiedHeader(c *gin.Context) (*time.Time, bool) {
+	header := c.GetHeader("X-Modified")
+	if header == "" {
+		return nil, true
+	}
+	modified, err := parseTime(header)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "X-Modified 参数错误")
+		return nil, false
+	}
+	return &modified, true
+}
+
 func cleanTempFiles() {
 	path := app.GetTempFilePath("_filename.txt")
 	if utils.IsExistFile(path) {
@@ -395,7 +983,7 @@ func cleanTempFiles() {
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
 			delPath := scanner.Text()
-			if err = os.Remove(delPath); err != nil {
+			if err = os.RemoveAll(delPath); err != nil {
 				log.WithError(err).WithField("delPath", delPath).Warn("failed to delete specify path")
 			}
 		}
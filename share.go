@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+	"github.com/skip2/go-qrcode"
+)
+
+// AuditActionShare is audit action of opening a signed share url
+const AuditActionShare = "share"
+
+// share is a snapshot of clipboard content, so that a url keeps showing
+// what was shared even if clipboard changes later
+type share struct {
+	contentType string
+	text        string
+	png         []byte
+	paths       []string
+	expiresAt   time.Time
+}
+
+// preview describes content of share for approval dialog
+func (s *share) preview() string {
+	switch s.contentType {
+	case utils.TypeText:
+		return s.text
+	case utils.TypeBitmap:
+		return "[图片媒体]"
+	}
+	names := make([]string, 0, len(s.paths))
+	for _, path := range s.paths {
+		names = append(names, filepath.Base(path))
+	}
+	return "[文件] " + strings.Join(names, ", ")
+}
+
+// ShareManager keeps shares of clipboard and signs their urls with a key
+// generated at startup, so urls are invalid after restart
+type ShareManager struct {
+	mu     sync.Mutex
+	key    []byte
+	shares map[string]*share
+}
+
+func NewShareManager() (*ShareManager, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &ShareManager{key: key, shares: make(map[string]*share)}, nil
+}
+
+func (m *ShareManager) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, m.key)
+	fmt.Fprintf(mac, "%s.%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Add keeps s until ttl elapses and returns path with query of its signed url
+func (m *ShareManager) Add(s *share, ttl time.Duration) (string, error) {
+	id, err := utils.SecureRandString(16)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, s := range m.shares {
+		if now.After(s.expiresAt) {
+			delete(m.shares, k)
+		}
+	}
+	s.expiresAt = now.Add(ttl).Truncate(time.Second)
+	m.shares[id] = s
+	expires := s.expiresAt.Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", m.sign(id, expires))
+	return "/share/" + id + "?" + query.Encode(), nil
+}
+
+// Get returns share of id if signature of expires is valid and it has not
+// expired
+func (m *ShareManager) Get(id, expires, signature string) (*share, bool) {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(m.sign(id, exp))) != 1 {
+		return nil, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.shares[id]
+	if !ok || s.expiresAt.Unix() != exp {
+		return nil, false
+	}
+	return s, true
+}
+
+// snapshotClipboard returns share of current clipboard content
+func snapshotClipboard() (*share, error) {
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		return nil, err
+	}
+	s := &share{contentType: contentType}
+	switch contentType {
+	case utils.TypeText:
+		s.text, err = clipboardText()
+	case utils.TypeBitmap:
+		s.png, err = clipboardPNG()
+	case utils.TypeFile:
+		s.paths, err = utils.Clipboard().Files()
+	default:
+		err = errUnknownContent
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// shareTTL returns ttl query in seconds, or share.timeout if it's absent.
+// ttl can't exceed share.maxTimeout
+func shareTTL(c *gin.Context) (time.Duration, bool) {
+	ttl := app.config.Share.Timeout
+	if q := c.Query("ttl"); q != "" {
+		var err error
+		if ttl, err = strconv.ParseInt(q, 10, 64); err != nil || ttl <= 0 || ttl > app.config.Share.MaxTimeout {
+			return 0, false
+		}
+	}
+	return time.Duration(ttl) * time.Second, true
+}
+
+// createShareHandler creates a signed url of current clipboard content,
+// which can be opened by anyone without credentials until it expires
+func createShareHandler(c *gin.Context) {
+	ttl, ok := shareTTL(c)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("ttl 参数错误，应为 1 到 %d 秒", app.config.Share.MaxTimeout))
+		return
+	}
+	s, err := snapshotClipboard()
+	if err != nil {
+		log.WithError(err).Warn("failed to snapshot clipboard")
+		respondError(c, http.StatusBadRequest, "clipboard_unavailable", "无法获取剪切板内容")
+		return
+	}
+	if s.contentType == utils.TypeText {
+		if s.text, ok = filterSensitiveText(c, s.text); !ok {
+			return
+		}
+	}
+	if !approveRead(c, s.preview()) {
+		return
+	}
+	path, err := app.shares.Add(s, ttl)
+	if err != nil {
+		log.WithError(err).Warn("failed to create share")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	log.WithField("type", s.contentType).Info("share created")
+	c.JSON(http.StatusOK, DownloadLink{requestBaseURL(c) + path, s.expiresAt})
+}
+
+// shareHandler serves content of a signed share url. Text and image are
+// shown inline, files are downloaded and multiple files are zipped
+func shareHandler(c *gin.Context) {
+	s, ok := app.shares.Get(c.Param("id"), c.Query("expires"), c.Query("signature"))
+	if !ok {
+		respondError(c, http.StatusNotFound, "link_not_found", "链接无效或已过期")
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+
+	switch s.contentType {
+	case utils.TypeText:
+		setAuditInfo(c, utils.TypeText, len(s.text))
+		c.Data(http.StatusOK, gin.MIMEPlain+"; charset=utf-8", []byte(s.text))
+		return
+	case utils.TypeBitmap:
+		setAuditInfo(c, utils.TypeBitmap, len(s.png))
+		c.Header("Content-Disposition", `inline; filename="clipboard.png"`)
+		c.Data(http.StatusOK, MIMEPNG, s.png)
+		return
+	}
+
+	if len(s.paths) == 1 {
+		info, err := os.Stat(s.paths[0])
+		if err == nil && !info.IsDir() {
+			setAuditInfo(c, utils.TypeFile, int(info.Size()))
+			c.FileAttachment(s.paths[0], filepath.Base(s.paths[0]))
+			return
+		}
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="clipboard.zip"`)
+	c.Status(http.StatusOK)
+	size, err := writeZip(c.Request.Context(), c.Writer, s.paths)
+	if err != nil {
+		log.WithError(err).Warn("failed to write zip")
+	}
+	setAuditInfo(c, utils.TypeFile, int(size))
+}
+
+// showShareDialog shares clipboard from tray, and shows url with its qr code
+func showShareDialog() {
+	if err := runShareDialog(); err != nil {
+		log.WithError(err).Warn("failed to share clipboard")
+		walk.MsgBox(app.MainWindow, "分享剪切板", "分享失败："+err.Error(), walk.MsgBoxIconError)
+	}
+}
+
+func runShareDialog() error {
+	s, err := snapshotClipboard()
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(app.config.Share.Timeout) * time.Second
+	path, err := app.shares.Add(s, ttl)
+	if err != nil {
+		return err
+	}
+	shareURL := app.serverURLs()[0] + path
+
+	qr, err := qrcode.New(shareURL, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	bitmap, err := walk.NewBitmapFromImage(qr.Image(qrCodeSize))
+	if err != nil {
+		return err
+	}
+	defer bitmap.Dispose()
+
+	dlg, err := walk.NewDialogWithFixedSize(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("分享剪切板"); err != nil {
+		return err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return err
+	}
+
+	imageView, err := walk.NewImageView(dlg)
+	if err != nil {
+		return err
+	}
+	if err := imageView.SetImage(bitmap); err != nil {
+		return err
+	}
+
+	urlEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	if err := urlEdit.SetText(shareURL); err != nil {
+		return err
+	}
+	if err := urlEdit.SetReadOnly(true); err != nil {
+		return err
+	}
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		return err
+	}
+	if err := label.SetText(fmt.Sprintf("任何人都可以通过此链接查看当前剪切板内容，链接在 %s 前有效", s.expiresAt.Format("01-02 15:04"))); err != nil {
+		return err
+	}
+
+	closeButton, err := walk.NewPushButton(dlg)
+	if err != nil {
+		return err
+	}
+	if err := closeButton.SetText("关闭"); err != nil {
+		return err
+	}
+	closeButton.Clicked().Attach(dlg.Accept)
+	if err := dlg.SetCancelButton(closeButton); err != nil {
+		return err
+	}
+
+	dlg.Run()
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// signature verifies HMAC-SHA256 signature of request if it is enabled.
+// Signed string is joined by "\n" with method, request uri, X-Timestamp,
+// X-Nonce and hex encoded sha256 of body. Requests with timestamp out of
+// maxSkew or used nonce are rejected
+func signature() gin.HandlerFunc {
+	maxSkew := time.Duration(app.config.Signature.MaxSkew) * time.Second
+	// nonce only needs to be remembered while its timestamp is acceptable
+	nonces := utils.NewNonceCache(2 * maxSkew)
+	return func(c *gin.Context) {
+		if !app.config.Signature.Enable {
+			c.Next()
+			return
+		}
+
+		timestamp := c.GetHeader("X-Timestamp")
+		nonce := c.GetHeader("X-Nonce")
+		reqSignature := c.GetHeader("X-Signature")
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || nonce == "" || reqSignature == "" {
+			abortWithSignatureError(c, "缺少签名信息")
+			return
+		}
+		skew := time.Since(time.Unix(unix, 0))
+		if skew > maxSkew || skew < -maxSkew {
+			abortWithSignatureError(c, "请求时间戳已过期，请检查设备时间")
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithSignatureError(c, "无法读取请求内容")
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		bodyHash := sha256.Sum256(body)
+		signed := strings.Join([]string{
+			c.Request.Method,
+			c.Request.URL.RequestURI(),
+			timestamp,
+			nonce,
+			hex.EncodeToString(bodyHash[:]),
+		}, "\n")
+		mac := hmac.New(sha256.New, []byte(app.config.Signature.Key))
+		mac.Write([]byte(signed))
+		expected := hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(reqSignature))) {
+			abortWithSignatureError(c, "签名验证失败")
+			return
+		}
+
+		if !nonces.Use(nonce) {
+			abortWithSignatureError(c, "请求已被使用")
+			return
+		}
+		c.Next()
+	}
+}
+
+func abortWithSignatureError(c *gin.Context, message string) {
+	abortWithAuthError(c, http.StatusUnauthorized, "invalid_signature", "操作被拒绝："+message)
+}
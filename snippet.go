@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+)
+
+const SnippetsFile = "snippets.json"
+
+const (
+	maxSnippets          = 200
+	maxSnippetNameLength = 100
+	maxSnippetSize       = 1 << 20
+)
+
+var (
+	errSnippetNotFound = errors.New("snippet not found")
+	errTooManySnippets = errors.New("too many snippets")
+)
+
+// Snippet is a named text kept until it's deleted, unlike history
+type Snippet struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Text    string    `json:"text,omitempty"` // omitted in list
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// SnippetStore keeps snippets and persists them to file. onChange is called
+// after snippets change, e.g. to update tray menu
+type SnippetStore struct {
+	mu       sync.RWMutex
+	path     string
+	snippets []Snippet
+	onChange func()
+}
+
+func loadSnippetStore(path string) (*SnippetStore, error) {
+	store := &SnippetStore{path: path}
+	if !utils.IsExistFile(path) {
+		return store, nil
+	}
+	snippetsBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snippetsBytes, &store.snippets); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// List returns snippets ordered by name, without text
+func (s *SnippetStore) List() []Snippet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snippets := make([]Snippet, 0, len(s.snippets))
+	for _, snippet := range s.snippets {
+		snippet.Text = ""
+		snippets = append(snippets, snippet)
+	}
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Name < snippets[j].Name
+	})
+	return snippets
+}
+
+// Get returns snippet of id
+func (s *SnippetStore) Get(id string) (Snippet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.index(id); i >= 0 {
+		return s.snippets[i], nil
+	}
+	return Snippet{}, errSnippetNotFound
+}
+
+// Add saves a new snippet of name and text
+func (s *SnippetStore) Add(name, text string) (Snippet, error) {
+	id, err := utils.SecureRandString(12)
+	if err != nil {
+		return Snippet{}, err
+	}
+	now := time.Now()
+	snippet := Snippet{ID: id, Name: name, Text: text, Created: now, Updated: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.snippets) >= maxSnippets {
+		return Snippet{}, errTooManySnippets
+	}
+	s.snippets = append(s.snippets, snippet)
+	return snippet, s.save()
+}
+
+// Update replaces name and text of snippet of id
+func (s *SnippetStore) Update(id, name, text string) (Snippet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return Snippet{}, errSnippetNotFound
+	}
+	s.snippets[i].Name = name
+	s.snippets[i].Text = text
+	s.snippets[i].Updated = time.Now()
+	return s.snippets[i], s.save()
+}
+
+// Delete removes snippet of id
+func (s *SnippetStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return errSnippetNotFound
+	}
+	s.snippets = append(s.snippets[:i], s.snippets[i+1:]...)
+	return s.save()
+}
+
+func (s *SnippetStore) index(id string) int {
+	for i := range s.snippets {
+		if s.snippets[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *SnippetStore) save() error {
+	snippetsBytes, err := json.MarshalIndent(s.snippets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.path, snippetsBytes, 0600); err != nil {
+		return err
+	}
+	if s.onChange != nil {
+		go s.onChange()
+	}
+	return nil
+}
+
+// SnippetList is response body of listing snippets
+type SnippetList struct {
+	Data []Snippet `json:"data"`
+}
+
+// SnippetBody is request body of creating or updating a snippet. Text is
+// encrypted like TextBody if request is encrypted
+type SnippetBody struct {
+	Name string `json:"name"`
+	Text string `json:"data"`
+}
+
+// bindSnippet returns name and text of SnippetBody, errors are responded if
+// they are empty or too long
+func bindSnippet(c *gin.Context) (string, string, bool) {
+	var body SnippetBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_body", "请求内容格式错误")
+		return "", "", false
+	}
+	if isEncrypted(c) {
+		text, err := decryptPayload(body.Text)
+		if err != nil {
+			log.WithError(err).Warn("failed to decrypt snippet body")
+			respondError(c, http.StatusBadRequest, "decrypt_failed", "无法解密请求内容")
+			return "", "", false
+		}
+		body.Text = string(text)
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len([]rune(body.Name)) > maxSnippetNameLength {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("name 不能为空且不能超过 %d 个字符", maxSnippetNameLength))
+		return "", "", false
+	}
+	if body.Text == "" {
+		respondError(c, http.StatusBadRequest, "invalid_body", "片段内容不能为空")
+		return "", "", false
+	}
+	if len(body.Text) > maxSnippetSize {
+		respondError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("片段内容超过 %d MB", maxSnippetSize>>20))
+		return "", "", false
+	}
+	return body.Name, body.Text, true
+}
+
+// respondSnippetError responds error of snippet store
+func respondSnippetError(c *gin.Context, err error) {
+	switch err {
+	case errSnippetNotFound:
+		respondError(c, http.StatusNotFound, "snippet_not_found", "片段不存在")
+	case errTooManySnippets:
+		respondError(c, http.StatusConflict, "too_many_snippets", fmt.Sprintf("片段数量不能超过 %d 个", maxSnippets))
+	default:
+		log.WithError(err).Warn("failed to save snippets")
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+func listSnippetsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, SnippetList{app.snippets.List()})
+}
+
+// getSnippetHandler responds text of a snippet like GET /
+func getSnippetHandler(c *gin.Context) {
+	snippet, err := app.snippets.Get(c.Param("id"))
+	if err != nil {
+		respondSnippetError(c, err)
+		return
+	}
+	data, err := encodeText(c, snippet.Text)
+	if err != nil {
+		log.WithError(err).Warn("failed to encrypt snippet")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, TextResponse{Type: "text", Data: data})
+}
+
+func createSnippetHandler(c *gin.Context) {
+	name, text, ok := bindSnippet(c)
+	if !ok {
+		return
+	}
+	snippet, err := app.snippets.Add(name, text)
+	if err != nil {
+		respondSnippetError(c, err)
+		return
+	}
+	log.WithField("name", name).Info("snippet created")
+	snippet.Text = ""
+	c.JSON(http.StatusOK, snippet)
+}
+
+func updateSnippetHandler(c *gin.Context) {
+	name, text, ok := bindSnippet(c)
+	if !ok {
+		return
+	}
+	snippet, err := app.snippets.Update(c.Param("id"), name, text)
+	if err != nil {
+		respondSnippetError(c, err)
+		return
+	}
+	snippet.Text = ""
+	c.JSON(http.StatusOK, snippet)
+}
+
+func deleteSnippetHandler(c *gin.Context) {
+	if err := app.snippets.Delete(c.Param("id")); err != nil {
+		respondSnippetError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// newSnippetsMenu returns submenu of tray copying snippets, which is
+// updated when they change
+func newSnippetsMenu() (*walk.Menu, error) {
+	menu, err := walk.NewMenu()
+	if err != nil {
+		return nil, err
+	}
+	if err := fillSnippetsMenu(menu); err != nil {
+		return nil, err
+	}
+	app.snippets.onChange = func() {
+		app.Synchronize(func() {
+			if err := fillSnippetsMenu(menu); err != nil {
+				log.WithError(err).Warn("failed to update snippets menu")
+			}
+		})
+	}
+	return menu, nil
+}
+
+// fillSnippetsMenu replaces actions of menu with current snippets, followed
+// by an action saving clipboard text as one
+func fillSnippetsMenu(menu *walk.Menu) error {
+	actions := menu.Actions()
+	if err := actions.Clear(); err != nil {
+		return err
+	}
+	for _, snippet := range app.snippets.List() {
+		id := snippet.ID
+		action := walk.NewAction()
+		if err := action.SetText(truncate(snippet.Name, 40)); err != nil {
+			return err
+		}
+		action.Triggered().Attach(func() {
+			copySnippet(id)
+		})
+		if err := actions.Add(action); err != nil {
+			return err
+		}
+	}
+	if actions.Len() > 0 {
+		if err := actions.Add(walk.NewSeparatorAction()); err != nil {
+			return err
+		}
+	}
+	saveAction := walk.NewAction()
+	if err := saveAction.SetText("将剪切板文本保存为片段"); err != nil {
+		return err
+	}
+	saveAction.Triggered().Attach(saveClipboardSnippet)
+	return actions.Add(saveAction)
+}
+
+// copySnippet puts text of snippet of id on clipboard
+func copySnippet(id string) {
+	snippet, err := app.snippets.Get(id)
+	if err == nil {
+		err = setClipboardText(snippet.Text)
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to copy snippet")
+		walk.MsgBox(app.MainWindow, "常用片段", "复制失败："+err.Error(), walk.MsgBoxIconError)
+	}
+}
+
+// saveClipboardSnippet saves clipboard text as a snippet named by its first
+// line, which can be renamed by PUT /snippets/:id
+func saveClipboardSnippet() {
+	text, err := clipboardText()
+	if err == nil && text == "" {
+		err = errors.New("剪切板中没有文本")
+	}
+	if err == nil && len(text) > maxSnippetSize {
+		err = fmt.Errorf("文本超过 %d MB", maxSnippetSize>>20)
+	}
+	if err == nil {
+		name := strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+		if name == "" {
+			name = "片段"
+		}
+		_, err = app.snippets.Add(truncate(name, maxSnippetNameLength-3), text)
+	}
+	if err == errTooManySnippets {
+		err = fmt.Errorf("片段数量不能超过 %d 个", maxSnippets)
+	}
+	if err != nil {
+		log.WithError(err).Warn("failed to save snippet")
+		walk.MsgBox(app.MainWindow, "常用片段", "保存失败："+err.Error(), walk.MsgBoxIconError)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sseKeepAliveInterval = 30 * time.Second
+
+// eventsHandler streams clipboard changes and transfer completions as
+// server-sent events
+func eventsHandler(c *gin.Context) {
+	events := app.events.Subscribe()
+	defer app.events.Unsubscribe(events)
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+	c.Header("Cache-Control", "no-cache")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			c.SSEvent(event.Event, event)
+			return true
+		case <-ticker.C:
+			// comment line keeps connection alive through proxies
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+)
+
+// defaultStatsPeriod is period of stats if since is not specified
+const defaultStatsPeriod = 30 * 24 * time.Hour
+
+// Stats is usage since Since. Days and Types are counted from history,
+// Devices and Hours from audit log, so they are empty if history or audit
+// is disabled
+type Stats struct {
+	Since   time.Time     `json:"since"`
+	Days    []DayStats    `json:"days"`    // oldest first, days without entries are omitted
+	Devices []DeviceStats `json:"devices"` // most bytes first
+	Hours   [24]int       `json:"hours"`   // requests in each hour of day in local time
+	Types   []TypeStats   `json:"types"`   // most entries first
+}
+
+// DayStats is count of history entries recorded on a day in local time
+type DayStats struct {
+	Date    string `json:"date"` // like 2021-11-20
+	Entries int    `json:"entries"`
+}
+
+// DeviceStats is successful requests of a device and bytes of them
+type DeviceStats struct {
+	Name     string `json:"name"`
+	Requests int    `json:"requests"`
+	Read     int64  `json:"read"`    // bytes of content read or downloaded
+	Written  int64  `json:"written"` // bytes of content set
+}
+
+// TypeStats is count and total size of history entries of a type
+type TypeStats struct {
+	Type    string `json:"type"`
+	Entries int    `json:"entries"`
+	Size    int64  `json:"size"`
+}
+
+// Stats returns entries per day and per type recorded after since
+func (h *HistoryStore) Stats(since time.Time) ([]DayStats, []TypeStats, error) {
+	rows, err := h.db.Query("SELECT type, size, created_at FROM history WHERE julianday(created_at) > julianday(?)", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	days := make(map[string]int)
+	types := make(map[string]*TypeStats)
+	for rows.Next() {
+		var (
+			contentType string
+			size        int64
+			created     time.Time
+		)
+		if err := rows.Scan(&contentType, &size, &created); err != nil {
+			return nil, nil, err
+		}
+		days[created.Local().Format("2006-01-02")]++
+		t, ok := types[contentType]
+		if !ok {
+			t = &TypeStats{Type: contentType}
+			types[contentType] = t
+		}
+		t.Entries++
+		t.Size += size
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	dayStats := make([]DayStats, 0, len(days))
+	for date, entries := range days {
+		dayStats = append(dayStats, DayStats{Date: date, Entries: entries})
+	}
+	sort.Slice(dayStats, func(i, j int) bool { return dayStats[i].Date < dayStats[j].Date })
+	typeStats := make([]TypeStats, 0, len(types))
+	for _, t := range types {
+		typeStats = append(typeStats, *t)
+	}
+	sort.Slice(typeStats, func(i, j int) bool {
+		if typeStats[i].Entries != typeStats[j].Entries {
+			return typeStats[i].Entries > typeStats[j].Entries
+		}
+		return typeStats[i].Type < typeStats[j].Type
+	})
+	return dayStats, typeStats, nil
+}
+
+// auditStats returns bytes per device and requests per hour of successful
+// audit entries. Content set by write requests is written, content of other
+// requests is read
+func auditStats(entries []AuditEntry) ([]DeviceStats, [24]int) {
+	var hours [24]int
+	devices := make(map[string]*DeviceStats)
+	for _, entry := range entries {
+		if entry.StatusCode != http.StatusOK {
+			continue
+		}
+		hours[entry.Time.Local().Hour()]++
+		d, ok := devices[entry.ClientName]
+		if !ok {
+			d = &DeviceStats{Name: entry.ClientName}
+			devices[entry.ClientName] = d
+		}
+		d.Requests++
+		if entry.Action == AuditActionWrite {
+			d.Written += int64(entry.Size)
+		} else {
+			d.Read += int64(entry.Size)
+		}
+	}
+	deviceStats := make([]DeviceStats, 0, len(devices))
+	for _, d := range devices {
+		deviceStats = append(deviceStats, *d)
+	}
+	sort.Slice(deviceStats, func(i, j int) bool {
+		a, b := deviceStats[i], deviceStats[j]
+		if a.Read+a.Written != b.Read+b.Written {
+			return a.Read+a.Written > b.Read+b.Written
+		}
+		return a.Name < b.Name
+	})
+	return deviceStats, hours
+}
+
+// collectStats returns usage after since
+func collectStats(since time.Time) (Stats, error) {
+	stats := Stats{Since: since, Days: []DayStats{}, Types: []TypeStats{}}
+	if app.history != nil {
+		var err error
+		if stats.Days, stats.Types, err = app.history.Stats(since); err != nil {
+			return stats, err
+		}
+	}
+	entries, err := app.audit.Since(since)
+	if err != nil {
+		return stats, err
+	}
+	stats.Devices, stats.Hours = auditStats(entries)
+	return stats, nil
+}
+
+// statsHandler responds usage since query since, or the last 30 days
+func statsHandler(c *gin.Context) {
+	since := time.Now().Add(-defaultStatsPeriod)
+	if q := c.Query("since"); q != "" {
+		var err error
+		if since, err = parseTime(q); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "since 参数错误")
+			return
+		}
+	}
+	stats, err := collectStats(since)
+	if err != nil {
+		log.WithError(err).Warn("failed to collect stats")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func showStatsViewer() {
+	if err := runStatsViewer(); err != nil {
+		log.WithError(err).Warn("failed to show stats viewer")
+		walk.MsgBox(app.MainWindow, "使用统计", "无法读取使用统计", walk.MsgBoxIconError)
+	}
+}
+
+func runStatsViewer() error {
+	stats, err := collectStats(time.Now().Add(-defaultStatsPeriod))
+	if err != nil {
+		return err
+	}
+	lines := []string{"最近 30 天", "", "每日记录："}
+	for _, day := range stats.Days {
+		lines = append(lines, fmt.Sprintf("  %s  %d 条", day.Date, day.Entries))
+	}
+	if len(stats.Days) == 0 {
+		lines = append(lines, "  暂无剪切板历史")
+	}
+	lines = append(lines, "", "内容类型：")
+	for _, t := range stats.Types {
+		lines = append(lines, fmt.Sprintf("  %s  %d 条  %d 字节", t.Type, t.Entries, t.Size))
+	}
+	if len(stats.Types) == 0 {
+		lines = append(lines, "  暂无剪切板历史")
+	}
+	lines = append(lines, "", "设备流量：")
+	for _, d := range stats.Devices {
+		lines = append(lines, fmt.Sprintf("  %s  %d 次  读取 %d 字节  写入 %d 字节", d.Name, d.Requests, d.Read, d.Written))
+	}
+	if len(stats.Devices) == 0 {
+		lines = append(lines, "  暂无访问记录")
+	}
+	lines = append(lines, "", "活跃时段：")
+	for hour, count := range stats.Hours {
+		if count > 0 {
+			lines = append(lines, fmt.Sprintf("  %02d:00  %d 次", hour, count))
+		}
+	}
+	if len(stats.Devices) == 0 {
+		lines = append(lines, "  暂无访问记录")
+	}
+
+	dlg, err := walk.NewDialog(app.MainWindow)
+	if err != nil {
+		return err
+	}
+	defer dlg.Dispose()
+	if err := dlg.SetTitle("使用统计"); err != nil {
+		return err
+	}
+	if err := dlg.SetLayout(walk.NewVBoxLayout()); err != nil {
+		return err
+	}
+	if err := dlg.SetSize(walk.Size{Width: 480, Height: 480}); err != nil {
+		return err
+	}
+
+	textEdit, err := walk.NewTextEditWithStyle(dlg, win.WS_VSCROLL)
+	if err != nil {
+		return err
+	}
+	if err := textEdit.SetReadOnly(true); err != nil {
+		return err
+	}
+	if err := textEdit.SetText(strings.Join(lines, "\r\n")); err != nil {
+		return err
+	}
+
+	dlg.Run()
+	return nil
+}
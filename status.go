@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/lxn/win"
+)
+
+// ClipboardStatus describes clipboard without its content, so that clients
+// can poll it cheaply and get content only if it has changed
+type ClipboardStatus struct {
+	Type     string    `json:"type"`
+	Sequence uint32    `json:"sequence"`
+	Size     int64     `json:"size"`    // estimated bytes of content, -1 if unknown
+	Changed  time.Time `json:"changed"` // time of the last change, or server start if it hasn't changed since
+}
+
+// ChangeTracker keeps time of the last clipboard change
+type ChangeTracker struct {
+	mu        sync.Mutex
+	changedAt time.Time
+}
+
+func NewChangeTracker(startedAt time.Time) *ChangeTracker {
+	return &ChangeTracker{changedAt: startedAt}
+}
+
+// Touch records clipboard change at t
+func (t *ChangeTracker) Touch(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changedAt = at
+}
+
+// Time returns time of the last clipboard change
+func (t *ChangeTracker) Time() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.changedAt
+}
+
+// clipboardSize estimates size of content of type. Text is bytes in utf-8,
+// bitmap is bytes of dib, which is larger than png of GET /image.png, and
+// files are their total size
+func clipboardSize(contentType string) int64 {
+	switch contentType {
+	case utils.TypeText:
+		if text, err := clipboardText(); err == nil {
+			return int64(len(text))
+		}
+	case utils.TypeBitmap:
+		formats, err := utils.Clipboard().Formats()
+		if err != nil {
+			return -1
+		}
+		for _, format := range formats {
+			if format.ID == win.CF_DIB {
+				return format.Size
+			}
+		}
+	case utils.TypeFile:
+		paths, err := utils.Clipboard().Files()
+		if err != nil {
+			return -1
+		}
+		return pathsSize(paths)
+	}
+	return -1
+}
+
+// pathsSize returns total size of files and folders of paths
+func pathsSize(paths []string) int64 {
+	var size int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			size += folderSize(path)
+		} else {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// statusHandler responds type, sequence number, size and time of change of
+// clipboard. It responds 304 if sequence in If-None-Match is current
+func statusHandler(c *gin.Context) {
+	sequence := setSequenceHeader(c)
+	if notModified(c, sequence) {
+		return
+	}
+	contentType, err := utils.Clipboard().ContentType()
+	if err != nil {
+		contentType = utils.TypeUnknown
+	}
+	c.JSON(http.StatusOK, ClipboardStatus{
+		Type:     contentType,
+		Sequence: sequence,
+		Size:     clipboardSize(contentType),
+		Changed:  app.changes.Time(),
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTailscaled is where the Windows installer puts tailscaled
+const DefaultTailscaled = `C:\Program Files\Tailscale\tailscaled.exe`
+
+// tailscaleIdentity authenticates requests proxied by Tailscale Serve by
+// Tailscale-User-Login header. The header is ignored unless connection is
+// made by tailscaled, as any other local process could forge it
+func tailscaleIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.config.Tailscale.Enable {
+			c.Next()
+			return
+		}
+		login := c.GetHeader("Tailscale-User-Login")
+		if login == "" || !isTailscaled(c) {
+			c.Next()
+			return
+		}
+		clientName, ok := app.config.Tailscale.Users[login]
+		if !ok {
+			if len(app.config.Tailscale.Users) > 0 {
+				abortWithError(c, http.StatusForbidden, "tailscale_user_forbidden", "操作被拒绝：Tailscale 用户未授权")
+				return
+			}
+			clientName = login
+		}
+		c.Set("clientName", clientName)
+		c.Set("tailscaleLogin", login)
+		c.Next()
+	}
+}
+
+// skipForTrusted skips auth middleware for requests authenticated by
+// tailscale, received by local listener or being operations of an
+// authenticated batch request
+func skipForTrusted(middleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("tailscaleLogin") != "" || isLocalRequest(c) || isBatchRequest(c) {
+			c.Next()
+			return
+		}
+		middleware(c)
+	}
+}
+
+// isTailscaled reports whether request is made by tailscale.tailscaled from
+// localhost, by the process at the other end of its connection
+func isTailscaled(c *gin.Context) bool {
+	if !isLoopback(c.Request.RemoteAddr) {
+		return false
+	}
+	local, ok := c.Request.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	remote, err := net.ResolveTCPAddr("tcp", c.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	image, err := utils.TCPPeerImage(local, remote)
+	if err != nil {
+		log.WithError(err).Warn("failed to find process of tailscale connection")
+		return false
+	}
+	if !strings.EqualFold(filepath.Clean(image), filepath.Clean(app.config.Tailscale.Tailscaled)) {
+		log.WithField("process", image).Warn("tailscale header from other process than tailscaled ignored")
+		return false
+	}
+	return true
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// TextChunkResponse is json response body of a range of clipboard text.
+// Offset, length and total count unicode characters, so that a range never
+// splits one
+type TextChunkResponse struct {
+	Type   string `json:"type"` // text
+	Data   string `json:"data"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"` // characters in data, offset+length is offset of next range
+	Total  int    `json:"total"`  // characters of the whole text
+}
+
+// TextRange is range of text in query offset and length
+type TextRange struct {
+	Offset int
+	Length int // -1 for the rest of text
+}
+
+// parseTextRange returns range in query, nil if neither offset nor length
+// is given
+func parseTextRange(c *gin.Context) (*TextRange, bool) {
+	offset, length := c.Query("offset"), c.Query("length")
+	if offset == "" && length == "" {
+		return nil, true
+	}
+	r := &TextRange{Length: -1}
+	var err error
+	if offset != "" {
+		if r.Offset, err = strconv.Atoi(offset); err != nil || r.Offset < 0 {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "offset 参数错误")
+			return nil, false
+		}
+	}
+	if length != "" {
+		if r.Length, err = strconv.Atoi(length); err != nil || r.Length <= 0 {
+			respondError(c, http.StatusBadRequest, "invalid_parameter", "length 参数错误")
+			return nil, false
+		}
+	}
+	return r, true
+}
+
+// sliceText returns characters of s in r, and count of characters of s
+func sliceText(s string, r *TextRange) (string, int) {
+	total := utf8.RuneCountInString(s)
+	if r.Offset >= total {
+		return "", total
+	}
+	start := 0
+	for i := 0; i < r.Offset; i++ {
+		_, size := utf8.DecodeRuneInString(s[start:])
+		start += size
+	}
+	if r.Length < 0 || r.Offset+r.Length >= total {
+		return s[start:], total
+	}
+	end := start
+	for i := 0; i < r.Length; i++ {
+		_, size := utf8.DecodeRuneInString(s[end:])
+		end += size
+	}
+	return s[start:end], total
+}
+
+// ChunkApprovals keeps clipboard sequence number approved for ranges by
+// client, so that reading text in ranges is approved once
+type ChunkApprovals struct {
+	mu       sync.Mutex
+	approved map[string]uint32
+}
+
+func NewChunkApprovals() *ChunkApprovals {
+	return &ChunkApprovals{approved: make(map[string]uint32)}
+}
+
+// approve asks user to approve reading clipboard of sequence unless client
+// has been approved for it
+func (a *ChunkApprovals) approve(c *gin.Context, sequence uint32, preview string) bool {
+	clientName := c.GetString("clientName")
+	a.mu.Lock()
+	approved, ok := a.approved[clientName]
+	a.mu.Unlock()
+	if ok && approved == sequence {
+		return true
+	}
+	if !approveRead(c, preview) {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.approved[clientName] = sequence
+	return true
+}
+
+// textChunkHandler responds range r of clipboard text, for clients which
+// fail to parse multi-megabyte json. Copy notification is only sent for the
+// first range
+func textChunkHandler(c *gin.Context, format string, sequence uint32, text string, r *TextRange) {
+	if !app.chunkApprovals.approve(c, sequence, text) {
+		return
+	}
+	app.textVersions.Add(text)
+	chunk, total := sliceText(text, r)
+	if r.Offset > total {
+		respondError(c, http.StatusRequestedRangeNotSatisfiable, "invalid_range", "offset 超出文本长度")
+		return
+	}
+	c.Header("X-Text-Total", strconv.Itoa(total))
+	if format != gin.MIMEJSON {
+		if format == gin.MIMEPlain {
+			format += "; charset=utf-8"
+		}
+		setAuditInfo(c, utils.TypeText, len(chunk))
+		c.Data(http.StatusOK, format, []byte(chunk))
+	} else {
+		data, err := encodeText(c, chunk)
+		if err != nil {
+			log.WithError(err).Warn("failed to encrypt clipboard text")
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		setAuditInfo(c, utils.TypeText, len(chunk))
+		c.JSON(http.StatusOK, TextChunkResponse{"text", data, r.Offset, utf8.RuneCountInString(chunk), total})
+	}
+	log.WithField("offset", r.Offset).WithField("total", total).Info("get clipboard text range")
+	if r.Offset == 0 {
+		sendCopyNotification(log, c.GetString("clientName"), notificationPreview(text))
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/lxn/walk"
+)
+
+const (
+	thumbnailsDir    = ".thumbnails"
+	maxThumbnails    = 500
+	thumbnailTimeout = 30 * time.Second
+)
+
+var errNoThumbnail = errors.New("media has no thumbnail")
+
+// extensions of videos, thumbnails of which are generated by ffmpeg
+var videoExts = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".webm": true,
+	".mkv":  true,
+	".avi":  true,
+}
+
+// extensions of images, thumbnails of which are made for file history
+var imageExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+}
+
+// ThumbnailCache generates thumbnails of animated media, gif and video,
+// and keeps them in temp directory by path, size and modification time of
+// media, so the oldest are removed beyond maxThumbnails
+type ThumbnailCache struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewThumbnailCache(dir string) *ThumbnailCache {
+	return &ThumbnailCache{dir: dir}
+}
+
+// hasThumbnail reports whether thumbnail of media of path can be generated
+func hasThumbnail(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".gif" || videoExts[ext] && app.config.Thumbnail.FFmpeg != ""
+}
+
+// Get returns path of png thumbnail of media of path, which is generated if
+// it's not cached
+func (t *ThumbnailCache) Get(ctx context.Context, path string) (string, error) {
+	if !hasThumbnail(path) {
+		return "", errNoThumbnail
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%d", path, info.Size(), info.ModTime().UnixNano())))
+	thumbnailPath := filepath.Join(t.dir, hex.EncodeToString(sum[:16])+".png")
+	if utils.IsExistFile(thumbnailPath) {
+		return thumbnailPath, nil
+	}
+
+	frame, err := decodeFrame(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	thumbnail, err := thumbnailPNG(frame)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(t.dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := newFile(thumbnailPath, thumbnail); err != nil {
+		return "", err
+	}
+	t.prune()
+	return thumbnailPath, nil
+}
+
+// prune removes the oldest thumbnails beyond maxThumbnails
+func (t *ThumbnailCache) prune() {
+	infos, err := ioutil.ReadDir(t.dir)
+	if err != nil || len(infos) <= maxThumbnails {
+		return
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos[:len(infos)-maxThumbnails] {
+		os.Remove(filepath.Join(t.dir, info.Name()))
+	}
+}
+
+// thumbnailPNG returns png of thumbnail of img in thumbnail.size
+func thumbnailPNG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, utils.Thumbnail(img, app.config.Thumbnail.Size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// historyThumbnail returns png thumbnail of the first of paths which is an
+// image no larger than maxSize, a gif or a video, or nil if there's none
+func historyThumbnail(paths []string, maxSize int64) []byte {
+	for _, path := range paths {
+		if !imageExts[strings.ToLower(filepath.Ext(path))] && !hasThumbnail(path) {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() || !hasThumbnail(path) && info.Size() > maxSize {
+			return nil
+		}
+		frame, err := decodeFrame(context.Background(), path)
+		if err != nil {
+			log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to decode thumbnail of history")
+			return nil
+		}
+		thumbnail, err := thumbnailPNG(frame)
+		if err != nil {
+			return nil
+		}
+		return thumbnail
+	}
+	return nil
+}
+
+// decodeFrame returns the first frame of gif or video of path
+func decodeFrame(ctx context.Context, path string) (image.Image, error) {
+	if !videoExts[strings.ToLower(filepath.Ext(path))] {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		return img, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, thumbnailTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, app.config.Thumbnail.FFmpeg, "-v", "error", "-i", path, "-frames:v", "1", "-f", "image2pipe", "-vcodec", "png", "-")
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(output))
+}
+
+// sendMediaNotification sends paste notification of media with thumbnail of
+// it, which is generated in background. Notification without thumbnail is
+// sent if it can't be generated
+func sendMediaNotification(client, notify string, paths []string) {
+	if !app.config.Notify.Paste {
+		return
+	}
+	if !app.config.Thumbnail.Enable || len(paths) != 1 || !hasThumbnail(paths[0]) {
+		sendPasteNotification(log, client, notify)
+		return
+	}
+	go func() {
+		thumbnailPath, err := app.thumbnails.Get(context.Background(), paths[0])
+		if err != nil {
+			log.WithError(err).WithField("path", contentSummary(paths[0])).Warn("failed to generate thumbnail")
+			sendPasteNotification(log, client, notify)
+			return
+		}
+		app.Synchronize(func() {
+			bitmap, err := walk.NewBitmapFromFile(thumbnailPath)
+			if err != nil {
+				log.WithError(err).Warn("failed to load thumbnail")
+				sendPasteNotification(log, client, notify)
+				return
+			}
+			defer bitmap.Dispose()
+			if err := app.ni.ShowCustom(notificationTitle("粘贴", client), notify, bitmap); err != nil {
+				log.WithError(err).WithField("notify", notify).Warn("failed to send notification")
+			}
+		})
+	}()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type connKey struct{}
+
+// saveConn is ConnContext of http server, which keeps connection in context
+// so that blocked reads of request can be interrupted on timeout
+func saveConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// streamingHandlers serve long-lived requests, which are not subject to
+// request timeout on whichever route they are registered
+var streamingHandlers = []gin.HandlerFunc{
+	wsHandler,
+	eventsHandler,
+	waitHandler,
+	bridgeHandler,
+	grpcWatchChangesHandler,
+}
+
+// isStreaming reports whether handler of request is one of streamingHandlers
+func isStreaming(c *gin.Context) bool {
+	name := c.HandlerName()
+	for _, handler := range streamingHandlers {
+		if name == runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// requestTimeout cancels context of request after requestTimeout. Body of
+// HTTP/1 requests can't be read after that, so a handler waiting for an
+// abandoned upload returns instead of blocking forever
+func requestTimeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := time.Duration(app.config.RequestTimeout) * time.Second
+		if timeout <= 0 || isStreaming(c) {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// connection of HTTP/2 is shared by other streams, so it is left
+		// to be closed by client
+		conn, ok := ctx.Value(connKey{}).(net.Conn)
+		if ok && c.Request.ProtoMajor == 1 {
+			done := make(chan struct{})
+			interrupted := make(chan bool, 1)
+			go func() {
+				select {
+				case <-ctx.Done():
+					if ctx.Err() == context.DeadlineExceeded {
+						conn.SetReadDeadline(time.Now())
+						interrupted <- true
+						return
+					}
+				case <-done:
+				}
+				interrupted <- false
+			}()
+			defer func() {
+				close(done)
+				// connection is kept alive for the next request
+				if <-interrupted {
+					conn.SetReadDeadline(time.Time{})
+				}
+			}()
+		}
+
+		c.Next()
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		log.WithField("path", c.Request.URL.Path).Warn("request timed out")
+		if !c.Writer.Written() {
+			abortWithError(c, http.StatusRequestTimeout, "request_timeout", "请求超时")
+		}
+	}
+}
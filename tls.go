@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/lxn/walk"
+	"golang.org/x/crypto/acme"
+)
+
+const clientCertsDir = "clients"
+
+// ensureCertificate returns paths of certificate and private key for https
+// server. A self-signed certificate is generated if they don't exist yet
+func (app *Application) ensureCertificate() (certFile, keyFile string, err error) {
+	certFile = app.GetExecFilePath(app.config.TLS.CertFile)
+	keyFile = app.GetExecFilePath(app.config.TLS.KeyFile)
+	if utils.IsExistFile(certFile) && utils.IsExistFile(keyFile) {
+		return certFile, keyFile, nil
+	}
+	log.WithField("certFile", certFile).Info("generate self-signed certificate")
+	if err := utils.GenerateSelfSignedCert(certFile, keyFile, app.config.ProtectSecrets); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// ensureCA returns paths of certificate authority which issues client
+// certificates. It will be generated if it doesn't exist yet
+func (app *Application) ensureCA() (certFile, keyFile string, err error) {
+	certFile = app.GetExecFilePath(app.config.TLS.CACertFile)
+	keyFile = app.GetExecFilePath(app.config.TLS.CAKeyFile)
+	if utils.IsExistFile(certFile) && utils.IsExistFile(keyFile) {
+		return certFile, keyFile, nil
+	}
+	log.WithField("caCertFile", certFile).Info("generate certificate authority")
+	if err := utils.GenerateCA(certFile, keyFile, app.config.ProtectSecrets); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// loadCertificate loads certificate of https server. Private key encrypted by
+// DPAPI is decrypted transparently
+func (app *Application) loadCertificate() (tls.Certificate, error) {
+	certFile, keyFile, err := app.ensureCertificate()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := utils.ReadSecretFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func (app *Application) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if app.config.TLS.ACME.Enable {
+		manager := NewACMEManager()
+		go manager.Run()
+		tlsConfig.GetCertificate = manager.GetCertificate
+		tlsConfig.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	} else {
+		cert, err := app.loadCertificate()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if !app.config.TLS.ClientAuth {
+		return tlsConfig, nil
+	}
+
+	caCertFile, _, err := app.ensureCA()
+	if err != nil {
+		return nil, err
+	}
+	caCertPEM, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCertPEM) {
+		return nil, fmt.Errorf("no certificate found in %s", caCertFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// issueClientCert issues a new client certificate signed by local CA and
+// exports it as PKCS#12 file which can be installed on iOS devices
+func (app *Application) issueClientCert() (path, password string, err error) {
+	caCertFile, caKeyFile, err := app.ensureCA()
+	if err != nil {
+		return "", "", err
+	}
+	caCert, caKey, err := utils.LoadCA(caCertFile, caKeyFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	suffix, err := utils.SecureRandString(8)
+	if err != nil {
+		return "", "", err
+	}
+	commonName := "clipboard-online-client-" + suffix
+	cert, key, err := utils.IssueClientCert(caCert, caKey, commonName)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = utils.SecureRandString(12)
+	if err != nil {
+		return "", "", err
+	}
+	p12, err := utils.EncodePKCS12(key, cert, []*x509.Certificate{caCert}, commonName, password)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := app.GetExecFilePath(clientCertsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", "", err
+	}
+	path = filepath.Join(dir, commonName+".p12")
+	if err := ioutil.WriteFile(path, p12, 0600); err != nil {
+		return "", "", err
+	}
+	return path, password, nil
+}
+
+func exportClientCertHandler() {
+	path, password, err := app.issueClientCert()
+	if err != nil {
+		log.WithError(err).Warn("failed to issue client certificate")
+		walk.MsgBox(app.MainWindow, "导出客户端证书", "客户端证书生成失败", walk.MsgBoxIconError)
+		return
+	}
+	log.WithField("path", path).Info("issue client certificate")
+	message := fmt.Sprintf("证书已导出到：\n%s\n\n安装密码：%s\n\n请将证书发送到您的设备并安装描述文件", path, password)
+	walk.MsgBox(app.MainWindow, "导出客户端证书", message, walk.MsgBoxIconInformation)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ensureTOTPSecret generates and saves a secret if totp is enabled without one
+func ensureTOTPSecret(path string, config *Config) error {
+	if !config.TOTP.Enable || config.TOTP.Secret != "" {
+		return nil
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return err
+	}
+	config.TOTP.Secret = secret
+	return saveConfig(path, config)
+}
+
+// totp requires a valid code of totp secret in X-TOTP header
+func totp() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.config.TOTP.Enable {
+			c.Next()
+			return
+		}
+		code := c.GetHeader("X-TOTP")
+		if utils.VerifyTOTP(app.config.TOTP.Secret, code, time.Now(), app.config.TOTP.Skew) {
+			c.Next()
+			return
+		}
+		abortWithAuthError(c, http.StatusUnauthorized, "invalid_totp", "操作被拒绝：动态验证码错误")
+	}
+}
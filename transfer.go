@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+const (
+	// progress of a transfer is published at most once per interval, so
+	// transfers finishing within it publish nothing
+	transferProgressInterval = 500 * time.Millisecond
+	// finished transfers are kept for clients to get their final state
+	transferKeepDuration = time.Minute
+	maxTransferIDLength  = 64
+)
+
+// Transfer is progress of an upload or download. Total is -1 if unknown
+type Transfer struct {
+	ID          string    `json:"id"`
+	Direction   string    `json:"direction"`
+	Path        string    `json:"path"`
+	ClientName  string    `json:"clientName"`
+	Total       int64     `json:"total"`
+	Transferred int64     `json:"transferred"`
+	Rate        float64   `json:"rate"`          // bytes per second
+	ETA         float64   `json:"eta,omitempty"` // seconds, 0 if unknown
+	StartedAt   time.Time `json:"startedAt"`
+	Done        bool      `json:"done"`
+}
+
+type transferState struct {
+	Transfer
+	lastPublish time.Time
+	published   bool
+	finishedAt  time.Time
+}
+
+// TransferTracker keeps progress of transfers and publishes it as events
+type TransferTracker struct {
+	mu        sync.Mutex
+	transfers map[string]*transferState
+}
+
+func NewTransferTracker() *TransferTracker {
+	return &TransferTracker{transfers: make(map[string]*transferState)}
+}
+
+// start tracks a new transfer. id is replaced by a random one if it's empty
+// or in use
+func (t *TransferTracker) start(id, direction, path, clientName string, total int64) (*transferState, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for k, s := range t.transfers {
+		if s.Done && now.Sub(s.finishedAt) > transferKeepDuration {
+			delete(t.transfers, k)
+		}
+	}
+	if _, ok := t.transfers[id]; ok || id == "" || len(id) > maxTransferIDLength {
+		var err error
+		if id, err = utils.SecureRandString(16); err != nil {
+			return nil, err
+		}
+	}
+	s := &transferState{
+		Transfer: Transfer{
+			ID:         id,
+			Direction:  direction,
+			Path:       path,
+			ClientName: clientName,
+			Total:      total,
+			StartedAt:  now,
+		},
+		lastPublish: now,
+	}
+	t.transfers[id] = s
+	return s, nil
+}
+
+func (t *TransferTracker) setTotal(s *transferState, total int64) {
+	t.mu.Lock()
+	s.Total = total
+	t.mu.Unlock()
+}
+
+func (t *TransferTracker) add(s *transferState, n int) {
+	t.mu.Lock()
+	s.Transferred += int64(n)
+	now := time.Now()
+	if now.Sub(s.lastPublish) < transferProgressInterval {
+		t.mu.Unlock()
+		return
+	}
+	s.lastPublish = now
+	s.published = true
+	transfer := s.snapshot(now)
+	t.mu.Unlock()
+	publishTransfer(transfer)
+}
+
+func (t *TransferTracker) finish(s *transferState) {
+	t.mu.Lock()
+	s.Done = true
+	s.finishedAt = time.Now()
+	published := s.published
+	transfer := s.snapshot(s.finishedAt)
+	t.mu.Unlock()
+	if published {
+		publishTransfer(transfer)
+	}
+}
+
+// snapshot returns transfer with rate and ETA at now
+func (s *transferState) snapshot(now time.Time) Transfer {
+	transfer := s.Transfer
+	if elapsed := now.Sub(s.StartedAt).Seconds(); elapsed > 0 {
+		transfer.Rate = float64(s.Transferred) / elapsed
+	}
+	if !s.Done && s.Total > s.Transferred && transfer.Rate > 0 {
+		transfer.ETA = float64(s.Total-s.Transferred) / transfer.Rate
+	}
+	return transfer
+}
+
+// Get returns transfer of id
+func (t *TransferTracker) Get(id string) (Transfer, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.transfers[id]
+	if !ok {
+		return Transfer{}, false
+	}
+	if s.Done {
+		return s.snapshot(s.finishedAt), true
+	}
+	return s.snapshot(time.Now()), true
+}
+
+// List returns running and recently finished transfers
+func (t *TransferTracker) List() []Transfer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	transfers := make([]Transfer, 0, len(t.transfers))
+	for _, s := range t.transfers {
+		if s.Done {
+			transfers = append(transfers, s.snapshot(s.finishedAt))
+		} else {
+			transfers = append(transfers, s.snapshot(now))
+		}
+	}
+	return transfers
+}
+
+func publishTransfer(transfer Transfer) {
+	app.events.Publish(Event{
+		Event:      EventProgress,
+		ClientName: transfer.ClientName,
+		Transfer:   &transfer,
+		Time:       time.Now(),
+	})
+	app.Synchronize(func() {
+		app.ni.SetToolTip(transferToolTip(transfer))
+	})
+}
+
+// transferToolTip shows progress of transfer in tooltip of notify icon until
+// it's done
+func transferToolTip(transfer Transfer) string {
+	if transfer.Done {
+		return app.toolTip()
+	}
+	action := "接收"
+	if transfer.Direction == TransferDownload {
+		action = "发送"
+	}
+	if transfer.Total <= 0 {
+		return fmt.Sprintf("%s %s：%.1f MB", action, transfer.ClientName, float64(transfer.Transferred)/(1<<20))
+	}
+	return fmt.Sprintf("%s %s：%d%%", action, transfer.ClientName, transfer.Transferred*100/transfer.Total)
+}
+
+type progressReader struct {
+	io.ReadCloser
+	state *transferState
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	app.transfers.add(r.state, n)
+	return n, err
+}
+
+type progressWriter struct {
+	gin.ResponseWriter
+	state   *transferState
+	started bool
+}
+
+func (w *progressWriter) Write(data []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		if size, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+			app.transfers.setTotal(w.state, size)
+		}
+	}
+	n, err := w.ResponseWriter.Write(data)
+	app.transfers.add(w.state, n)
+	return n, err
+}
+
+func (w *progressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// trackTransfer tracks progress of request body for uploads, or response
+// body for downloads. Clients may name the transfer by X-Transfer-Id to poll
+// it while uploading, the id is responded in X-Transfer-Id
+func trackTransfer(direction string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		total := int64(-1)
+		// decompressed size is unknown
+		if direction == TransferUpload && c.GetHeader("Content-Encoding") == "" {
+			total = c.Request.ContentLength
+		}
+		state, err := app.transfers.start(c.GetHeader("X-Transfer-Id"), direction, c.Request.URL.Path, c.GetString("clientName"), total)
+		if err != nil {
+			log.WithError(err).Warn("failed to track transfer")
+			c.Next()
+			return
+		}
+		defer app.transfers.finish(state)
+		c.Header("X-Transfer-Id", state.ID)
+		if direction == TransferUpload {
+			c.Request.Body = &progressReader{ReadCloser: c.Request.Body, state: state}
+		} else {
+			c.Writer = &progressWriter{ResponseWriter: c.Writer, state: state}
+		}
+		c.Next()
+	}
+}
+
+// TransferList is response body of /transfers
+type TransferList struct {
+	Data []Transfer `json:"data"`
+}
+
+func listTransfersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, TransferList{app.transfers.List()})
+}
+
+func transferHandler(c *gin.Context) {
+	transfer, ok := app.transfers.Get(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, "transfer_not_found", "传输不存在")
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+)
+
+// directions of text transforms
+const (
+	TransformOnSet = "set"
+	TransformOnGet = "get"
+)
+
+// TextTransforms applies transforms.rules to text set and got by clients,
+// while they're enabled
+type TextTransforms struct {
+	mu      sync.RWMutex
+	enabled bool
+	set     []*utils.TextTransform
+	get     []*utils.TextTransform
+}
+
+func NewTextTransforms(config ConfigTransforms) (*TextTransforms, error) {
+	t := &TextTransforms{enabled: config.Enable}
+	for i, rule := range config.Rules {
+		transform, err := utils.NewTextTransform(rule.Type, rule.Pattern, rule.Replacement)
+		if err != nil {
+			return nil, fmt.Errorf("transforms.rules[%d]: %w", i, err)
+		}
+		switch rule.On {
+		case TransformOnSet:
+			t.set = append(t.set, transform)
+		case TransformOnGet:
+			t.get = append(t.get, transform)
+		case "":
+			t.set = append(t.set, transform)
+			t.get = append(t.get, transform)
+		default:
+			return nil, fmt.Errorf("transforms.rules[%d]: unknown direction: %s", i, rule.On)
+		}
+	}
+	return t, nil
+}
+
+func (t *TextTransforms) Enabled() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.enabled
+}
+
+func (t *TextTransforms) SetEnabled(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enabled = enabled
+}
+
+// Set returns text set by client transformed
+func (t *TextTransforms) Set(text string) string {
+	return t.apply(t.set, text)
+}
+
+// Get returns text got by client transformed
+func (t *TextTransforms) Get(text string) string {
+	return t.apply(t.get, text)
+}
+
+func (t *TextTransforms) apply(transforms []*utils.TextTransform, text string) string {
+	if !t.Enabled() {
+		return text
+	}
+	for _, transform := range transforms {
+		text = transform.Apply(text)
+	}
+	return text
+}
+
+// toggleTransforms enables or disables transforms from tray and saves it
+// to config
+func toggleTransforms(enable bool) error {
+	app.transforms.SetEnabled(enable)
+	app.config.Transforms.Enable = enable
+	log.WithField("enable", enable).Info("toggle text transforms")
+	return saveConfig(app.GetExecFilePath(ConfigFile), app.config)
+}
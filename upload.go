@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	uploadsDir    = ".uploads"
+	uploadTimeout = 24 * time.Hour
+
+	uploadChunkOverhead = 1024
+)
+
+// UploadSession is a resumable upload of a file in chunks
+type UploadSession struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Size     int64      `json:"size"`
+	Received int64      `json:"received"`
+	Modified *time.Time `json:"modified,omitempty"`
+
+	mu        sync.Mutex
+	path      string
+	updatedAt time.Time
+}
+
+// UploadManager keeps upload sessions in memory, partial files of sessions
+// inactive for uploadTimeout are removed
+type UploadManager struct {
+	mu       sync.Mutex
+	sessions map[string]*UploadSession
+}
+
+func NewUploadManager() *UploadManager {
+	return &UploadManager{sessions: make(map[string]*UploadSession)}
+}
+
+// Create creates a session and its empty partial file, modified is set on
+// the file when it is finalized
+func (m *UploadManager) Create(name string, size int64, modified *time.Time) (*UploadSession, error) {
+	id, err := utils.SecureRandString(24)
+	if err != nil {
+		return nil, err
+	}
+	dir := app.GetTempFilePath(uploadsDir)
+	if err := utils.CreateDirectory(dir); err != nil {
+		return nil, err
+	}
+	session := &UploadSession{
+		ID:        id,
+		Name:      filepath.Base(name),
+		Size:      size,
+		Modified:  modified,
+		path:      filepath.Join(dir, id+".part"),
+		updatedAt: time.Now(),
+	}
+	if err := ioutil.WriteFile(session.path, nil, 0644); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.sessions {
+		if time.Since(s.updatedAt) > uploadTimeout {
+			os.Remove(s.path)
+			delete(m.sessions, id)
+		}
+	}
+	m.sessions[session.ID] = session
+	return session, nil
+}
+
+// Get returns session by id
+func (m *UploadManager) Get(id string) (*UploadSession, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	return session, ok
+}
+
+// Remove deletes session without removing its file
+func (m *UploadManager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+}
+
+// WriteChunk writes chunk at offset, which must not be after received bytes
+// so that there is no hole in file
+func (s *UploadSession) WriteChunk(offset int64, chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if offset < 0 || offset > s.Received {
+		return errors.New("offset is after received bytes")
+	}
+	if offset+int64(len(chunk)) > s.Size {
+		return errors.New("chunk exceeds file size")
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(chunk, offset); err != nil {
+		return err
+	}
+	if end := offset + int64(len(chunk)); end > s.Received {
+		s.Received = end
+	}
+	s.updatedAt = time.Now()
+	return nil
+}
+
+// UploadCreateBody is the body of creating upload session
+type UploadCreateBody struct {
+	Name     string     `json:"name" binding:"required"`
+	Size     int64      `json:"size"`
+	Modified *time.Time `json:"modified,omitempty"` // modification time to keep
+}
+
+func createUploadHandler(c *gin.Context) {
+	var body UploadCreateBody
+	if err := c.ShouldBindJSON(&body); err != nil || body.Size < 0 {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "请求参数错误")
+		return
+	}
+	session, err := app.uploads.Create(body.Name, body.Size, body.Modified)
+	if err != nil {
+		log.WithError(err).Warn("failed to create upload session")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func getUploadHandler(c *gin.Context) {
+	session, ok := app.uploads.Get(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, "upload_not_found", "上传会话不存在或已过期")
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	c.JSON(http.StatusOK, session)
+}
+
+// putChunkHandler writes body as a chunk at offset of query
+func putChunkHandler(c *gin.Context) {
+	session, ok := app.uploads.Get(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, "upload_not_found", "上传会话不存在或已过期")
+		return
+	}
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_parameter", "offset 参数错误")
+		return
+	}
+	// allow overhead of encryption, size is checked again after decrypting
+	chunk, err := ioutil.ReadAll(utils.ContextReader(c.Request.Context(), io.LimitReader(c.Request.Body, session.Size+uploadChunkOverhead)))
+	if err != nil {
+		log.WithError(err).Warn("failed to read chunk")
+		respondError(c, http.StatusBadRequest, "read_body_failed", "无法读取上传内容")
+		return
+	}
+	if isEncrypted(c) {
+		chunk, err = decryptBytes(chunk)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "decrypt_failed", "无法解密请求内容")
+			return
+		}
+	}
+	if err := session.WriteChunk(offset, chunk); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_chunk", err.Error())
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	c.JSON(http.StatusOK, session)
+}
+
+// finalizeUploadHandler moves completed file to temp directory and puts it on
+// clipboard
+func finalizeUploadHandler(c *gin.Context) {
+	session, ok := app.uploads.Get(c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, "upload_not_found", "上传会话不存在或已过期")
+		return
+	}
+	session.mu.Lock()
+	complete := session.Received == session.Size
+	session.mu.Unlock()
+	if !complete {
+		respondError(c, http.StatusBadRequest, "upload_incomplete", "文件尚未上传完成")
+		return
+	}
+	if !prepareSet(c) {
+		return
+	}
+
+	path := utils.LatestFilename(app.GetTempFilePath(session.Name))
+	if err := os.Rename(session.path, path); err != nil {
+		log.WithError(err).WithField("path", contentSummary(path)).Warn("failed to move uploaded file")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	app.uploads.Remove(session.ID)
+	setFileModified(path, session.Modified)
+	setClipboardFiles(c, utils.TypeFile, []string{path}, int(session.Size))
+}
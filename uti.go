@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/YanxinTang/clipboard-online/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// requestContentType returns X-Content-Type of request. Apple UTIs like
+// public.png are mapped to text, file or media
+func requestContentType(c *gin.Context) string {
+	header := c.GetHeader("X-Content-Type")
+	if utils.IsUTI(header) {
+		contentType, _ := utils.LookupUTI(header)
+		return contentType
+	}
+	return header
+}
+
+// requestFilename appends extension of X-Content-Type UTI to name of a file
+// sent without one
+func requestFilename(c *gin.Context, name string) string {
+	if uti := c.GetHeader("X-Content-Type"); utils.IsUTI(uti) {
+		return utils.FilenameWithUTI(name, uti)
+	}
+	return name
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+)
+
+var wideCharToMultiByte = libkernel32.NewProc("WideCharToMultiByte")
+
+// DecodeCodePage converts text of code page to a string
+func DecodeCodePage(data []byte, codePage uint32) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	n, err := windows.MultiByteToWideChar(codePage, 0, &data[0], int32(len(data)), nil, 0)
+	if err != nil {
+		return "", err
+	}
+	utf16 := make([]uint16, n)
+	if _, err := windows.MultiByteToWideChar(codePage, 0, &data[0], int32(len(data)), &utf16[0], n); err != nil {
+		return "", err
+	}
+	return syscall.UTF16ToString(utf16), nil
+}
+
+// EncodeCodePage converts s to text of code page. Characters the code page
+// doesn't have are replaced by its default character
+func EncodeCodePage(s string, codePage uint32) ([]byte, error) {
+	utf16, err := syscall.UTF16FromString(s)
+	if err != nil {
+		return nil, err
+	}
+	n, _, err := wideCharToMultiByte.Call(uintptr(codePage), 0, uintptr(unsafe.Pointer(&utf16[0])), uintptr(len(utf16)), 0, 0, 0, 0)
+	if n == 0 {
+		return nil, err
+	}
+	data := make([]byte, n)
+	n, _, err = wideCharToMultiByte.Call(uintptr(codePage), 0, uintptr(unsafe.Pointer(&utf16[0])), uintptr(len(utf16)), uintptr(unsafe.Pointer(&data[0])), n, 0, 0)
+	if n == 0 {
+		return nil, err
+	}
+	// data ends with nul of utf16
+	return data[:n], nil
+}
+
+// ANSIText returns CF_TEXT of clipboard decoded by code page, if the app
+// placed CF_TEXT itself rather than CF_UNICODETEXT. Otherwise ok is false,
+// since CF_TEXT is converted from CF_UNICODETEXT by the system
+func (c *ClipboardService) ANSIText(codePage uint32) (text string, ok bool, err error) {
+	err = c.withOpenClipboard(func() error {
+		var format uintptr
+		for {
+			format, _, _ = enumClipboardFormats.Call(format)
+			if format == 0 || format == win.CF_UNICODETEXT {
+				return nil
+			}
+			if format == win.CF_TEXT {
+				break
+			}
+		}
+		data, err := clipboardData(win.CF_TEXT)
+		if err != nil {
+			return err
+		}
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			data = data[:i]
+		}
+		text, err = DecodeCodePage(data, codePage)
+		ok = err == nil
+		return err
+	})
+	return
+}
+
+// SetTextCodePage sets s as CF_UNICODETEXT and as CF_TEXT of code page, for
+// legacy apps which read CF_TEXT in a code page other than the system's
+func (c *ClipboardService) SetTextCodePage(s string, codePage uint32) error {
+	unicode, err := unicodeBytes(s)
+	if err != nil {
+		return err
+	}
+	ansi, err := EncodeCodePage(s, codePage)
+	if err != nil {
+		return err
+	}
+	return c.withOpenClipboard(func() error {
+		win.EmptyClipboard()
+		if err := setClipboardBytes(win.CF_UNICODETEXT, unicode); err != nil {
+			return err
+		}
+		return setClipboardBytes(win.CF_TEXT, ansi)
+	})
+}
+
+// unicodeBytes returns s as null-terminated utf-16 data of CF_UNICODETEXT
+func unicodeBytes(s string) ([]byte, error) {
+	utf16, err := syscall.UTF16FromString(s)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 2*len(utf16))
+	for i, u := range utf16 {
+		binary.LittleEndian.PutUint16(data[2*i:], u)
+	}
+	return data, nil
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	cfHTMLHeader        = "Version:0.9\r\nStartHTML:%010d\r\nEndHTML:%010d\r\nStartFragment:%010d\r\nEndFragment:%010d\r\n"
+	cfHTMLStartFragment = "<html><body>\r\n<!--StartFragment-->"
+	cfHTMLEndFragment   = "<!--EndFragment-->\r\n</body></html>"
+)
+
+// EncodeCFHTML returns data of "HTML Format" with fragment, whose header
+// has byte offsets of html and fragment in utf-8
+// https://docs.microsoft.com/en-us/windows/win32/dataxchg/html-clipboard-format
+func EncodeCFHTML(fragment string) []byte {
+	headerLen := len(fmt.Sprintf(cfHTMLHeader, 0, 0, 0, 0))
+	startFragment := headerLen + len(cfHTMLStartFragment)
+	endFragment := startFragment + len(fragment)
+	endHTML := endFragment + len(cfHTMLEndFragment)
+
+	var b strings.Builder
+	b.Grow(endHTML)
+	fmt.Fprintf(&b, cfHTMLHeader, headerLen, endHTML, startFragment, endFragment)
+	b.WriteString(cfHTMLStartFragment)
+	b.WriteString(fragment)
+	b.WriteString(cfHTMLEndFragment)
+	return []byte(b.String())
+}
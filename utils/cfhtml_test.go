@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestEncodeCFHTML(t *testing.T) {
+	fragment := "<p>你好 <strong>world</strong></p>"
+	data := string(EncodeCFHTML(fragment))
+
+	offset := func(name string) int {
+		m := regexp.MustCompile(name + `:(\d{10})\r\n`).FindStringSubmatch(data)
+		if m == nil {
+			t.Fatalf("%s is missing in %q", name, data)
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	if got := data[offset("StartFragment"):offset("EndFragment")]; got != fragment {
+		t.Errorf("fragment = %q, want %q", got, fragment)
+	}
+	if got := data[offset("StartHTML"):offset("EndHTML")]; got != "<html><body>\r\n<!--StartFragment-->"+fragment+"<!--EndFragment-->\r\n</body></html>" {
+		t.Errorf("html = %q", got)
+	}
+	if offset("EndHTML") != len(data) {
+		t.Errorf("EndHTML = %d, want %d", offset("EndHTML"), len(data))
+	}
+}
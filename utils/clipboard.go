@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 	"syscall"
 	"unsafe"
 
@@ -20,11 +21,48 @@ const (
 	TypeMedia   = "media"
 	TypeBitmap  = "bitmap"
 	TypeUnknown = "unknown"
+	TypeColor   = "color" // text of a color, e.g. #FF8800
 )
 
 var clipboard ClipboardService
+
+var (
+	libuser32                  = windows.NewLazySystemDLL("user32.dll")
+	libkernel32                = windows.NewLazySystemDLL("kernel32.dll")
+	getClipboardSequenceNumber = libuser32.NewProc("GetClipboardSequenceNumber")
+	registerClipboardFormat    = libuser32.NewProc("RegisterClipboardFormatW")
+	globalSize                 = libkernel32.NewProc("GlobalSize")
+)
 var Formats = []uint32{win.CF_HDROP, win.CF_DIBV5, win.CF_UNICODETEXT}
 
+var (
+	pngFormatOnce sync.Once
+	pngFormatID   uint32
+)
+
+// pngFormat returns id of the registered "PNG" clipboard format, 0 if it
+// can't be registered
+func pngFormat() uint32 {
+	pngFormatOnce.Do(func() {
+		pngFormatID, _ = RegisterFormat("PNG")
+	})
+	return pngFormatID
+}
+
+// RegisterFormat returns id of registered clipboard format name, which is
+// the same in all apps. Predefined formats like CF_TEXT have no names
+func RegisterFormat(name string) (uint32, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	id, _, _ := registerClipboardFormat.Call(uintptr(unsafe.Pointer(p)))
+	if id == 0 {
+		return 0, lastError("RegisterClipboardFormat")
+	}
+	return uint32(id), nil
+}
+
 // Clipboard returns an object that provides access to the system clipboard.
 func Clipboard() *ClipboardService {
 	return &clipboard
@@ -75,6 +113,11 @@ func (c *ClipboardService) ContentType() (string, error) {
 				return nil
 			}
 		}
+		// some apps only put png on clipboard
+		if png := pngFormat(); png != 0 && win.IsClipboardFormatAvailable(png) {
+			format = win.CF_DIBV5
+			return nil
+		}
 		return lastError("get content type of clipboard")
 	})
 	if err != nil {
@@ -92,6 +135,13 @@ func (c *ClipboardService) ContentType() (string, error) {
 	}
 }
 
+// SequenceNumber returns the clipboard sequence number, which changes
+// whenever the contents of the clipboard change.
+func (c *ClipboardService) SequenceNumber() uint32 {
+	seq, _, _ := getClipboardSequenceNumber.Call()
+	return uint32(seq)
+}
+
 // Text returns the current text data of the clipboard.
 func (c *ClipboardService) Text() (text string, err error) {
 	err = c.withOpenClipboard(func() error {
@@ -121,62 +171,160 @@ func int32Abs(val int32) uint32 {
 	return uint32(val)
 }
 
+// Bitmap returns CF_DIBV5 data of the clipboard as a bmp file. Data is
+// copied before it's adjusted for image/bmp, clipboard is never modified
 func (c *ClipboardService) Bitmap() (bmpBytes []byte, err error) {
 	err = c.withOpenClipboard(func() error {
-		hMem := win.HGLOBAL(win.GetClipboardData(win.CF_DIBV5))
-		if hMem == 0 {
-			return lastError("GetClipboardData")
+		data, err := clipboardData(win.CF_DIBV5)
+		if err != nil {
+			return err
 		}
-
-		p := win.GlobalLock(hMem)
-		if p == nil {
-			return lastError("GlobalLock()")
+		if len(data) < int(unsafe.Sizeof(win.BITMAPV5HEADER{})) {
+			return errors.New("invalid bitmap on clipboard")
 		}
-		defer win.GlobalUnlock(hMem)
 
-		header := (*win.BITMAPV5HEADER)(unsafe.Pointer(p))
-		var biSizeImage uint32
-		// BiSizeImage is 0 when use tencent TIM
-		if header.BiBitCount == 32 {
-			biSizeImage = 4 * int32Abs(header.BiWidth) * int32Abs(header.BiHeight)
-		} else {
-			biSizeImage = header.BiSizeImage
+		header := (*win.BITMAPV5HEADER)(unsafe.Pointer(&data[0]))
+		if int(header.BiSize) > len(data) {
+			return errors.New("invalid bitmap on clipboard")
 		}
-
-		var data []byte
-		sh := (*reflect.SliceHeader)(unsafe.Pointer(&data))
-		sh.Data = uintptr(p)
-		sh.Cap = int(header.BiSize + biSizeImage)
-		sh.Len = int(header.BiSize + biSizeImage)
+		// color masks follow BITMAPINFOHEADER, while they are part of
+		// BITMAPV4HEADER and BITMAPV5HEADER
+		var masksSize uint32
+		if header.BiSize == uint32(unsafe.Sizeof(win.BITMAPINFOHEADER{})) && header.BiCompression == win.BI_BITFIELDS {
+			masksSize = 12
+		}
+		colors := header.BiClrUsed
+		if colors == 0 && header.BiBitCount <= 8 {
+			colors = 1 << header.BiBitCount
+		}
+		pixelOffset := header.BiSize + masksSize + 4*colors
 
 		// In this place, we omit AlphaMask to make sure the BiV5Header can be decoded by image/bmp
 		// https://github.com/golang/image/blob/35266b937fa69456d24ed72a04d75eb6857f7d52/bmp/reader.go#L177
-		if header.BiCompression == 3 && header.BV4RedMask == 0xff0000 && header.BV4GreenMask == 0xff00 && header.BV4BlueMask == 0xff {
+		if header.BiBitCount == 32 && header.BiCompression == win.BI_BITFIELDS && header.BV4RedMask == 0xff0000 && header.BV4GreenMask == 0xff00 && header.BV4BlueMask == 0xff {
 			header.BiCompression = win.BI_RGB
 
 			// always set alpha channel value as 0xFF to make image untransparent
-			// to fix screenshot from PicPick is transparent when converted to png
-			pixelStartAt := header.BiSize
-			for i := pixelStartAt + 3; i < uint32(len(data)); i += 4 {
+			// to fix screenshot from PicPick is transparent when converted to png.
+			// BiSizeImage is 0 when use tencent TIM
+			end := pixelOffset + 4*int32Abs(header.BiWidth)*int32Abs(header.BiHeight)
+			if end > uint32(len(data)) {
+				end = uint32(len(data))
+			}
+			for i := pixelOffset + 3; i < end; i += 4 {
 				data[i] = 0xff
 			}
 		}
 
-		bmpFileSize := 14 + header.BiSize + biSizeImage
+		bmpFileSize := 14 + uint32(len(data))
 		bmpBytes = make([]byte, bmpFileSize)
 
 		binary.LittleEndian.PutUint16(bmpBytes[0:], 0x4d42) // start with 'BM'
 		binary.LittleEndian.PutUint32(bmpBytes[2:], bmpFileSize)
 		binary.LittleEndian.PutUint16(bmpBytes[6:], 0)
 		binary.LittleEndian.PutUint16(bmpBytes[8:], 0)
-		binary.LittleEndian.PutUint32(bmpBytes[10:], 14+header.BiSize)
-		copy(bmpBytes[14:], data[:])
+		binary.LittleEndian.PutUint32(bmpBytes[10:], 14+pixelOffset)
+		copy(bmpBytes[14:], data)
 
 		return nil
 	})
 	return
 }
 
+// PNG returns data of the registered "PNG" format, which browsers and
+// office apps put on clipboard along with bitmap to keep transparency
+func (c *ClipboardService) PNG() (pngBytes []byte, err error) {
+	format := pngFormat()
+	if format == 0 {
+		return nil, lastError("RegisterClipboardFormat")
+	}
+	err = c.withOpenClipboard(func() error {
+		pngBytes, err = clipboardData(format)
+		return err
+	})
+	return
+}
+
+// FormatData returns data of registered formats by name, formats that are
+// not on clipboard are omitted
+func (c *ClipboardService) FormatData(names []string) (map[string][]byte, error) {
+	ids := make(map[string]uint32, len(names))
+	for _, name := range names {
+		id, err := RegisterFormat(name)
+		if err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	data := make(map[string][]byte, len(ids))
+	err := c.withOpenClipboard(func() error {
+		for name, id := range ids {
+			if !win.IsClipboardFormatAvailable(id) {
+				continue
+			}
+			d, err := clipboardData(id)
+			if err != nil {
+				return err
+			}
+			data[name] = d
+		}
+		return nil
+	})
+	return data, err
+}
+
+// SetFormats replaces clipboard with data of registered formats by name.
+// Empty data is skipped since it can't be allocated
+func (c *ClipboardService) SetFormats(data map[string][]byte) error {
+	ids := make(map[string]uint32, len(data))
+	for name := range data {
+		id, err := RegisterFormat(name)
+		if err != nil {
+			return err
+		}
+		ids[name] = id
+	}
+	return c.withOpenClipboard(func() error {
+		win.EmptyClipboard()
+		for name, d := range data {
+			if len(d) == 0 {
+				continue
+			}
+			if err := setClipboardBytes(ids[name], d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// clipboardData returns a copy of data of format, clipboard must be opened
+func clipboardData(format uint32) ([]byte, error) {
+	hMem := win.HGLOBAL(win.GetClipboardData(format))
+	if hMem == 0 {
+		return nil, lastError("GetClipboardData")
+	}
+	size, _, _ := globalSize.Call(uintptr(hMem))
+	if size == 0 {
+		return nil, lastError("GlobalSize")
+	}
+
+	p := win.GlobalLock(hMem)
+	if p == nil {
+		return nil, lastError("GlobalLock()")
+	}
+	defer win.GlobalUnlock(hMem)
+
+	var src []byte
+	sh := (*reflect.SliceHeader)(unsafe.Pointer(&src))
+	sh.Data = uintptr(p)
+	sh.Cap = int(size)
+	sh.Len = int(size)
+	data := make([]byte, size)
+	copy(data, src)
+	return data, nil
+}
+
 func (c *ClipboardService) Files() (filenames []string, err error) {
 	err = c.withOpenClipboard(func() error {
 		hMem := win.HGLOBAL(win.GetClipboardData(win.CF_HDROP))
@@ -252,52 +400,124 @@ type DROPFILES struct {
 func (c *ClipboardService) SetFiles(paths []string) error {
 	return c.withOpenClipboard(func() error {
 		win.EmptyClipboard()
-		// https://docs.microsoft.com/en-us/windows/win32/shell/clipboard#cf_hdrop
-		var utf16 []uint16
-		for _, path := range paths {
-			_utf16, err := syscall.UTF16FromString(path)
-			if err != nil {
+		return setFilesData(paths)
+	})
+}
+
+// SetImage sets dib as CF_DIB and png as the registered "PNG" format, so
+// that image can be pasted into apps directly. File drop of paths is set as
+// well if it's not empty
+func (c *ClipboardService) SetImage(dib, png []byte, paths []string) error {
+	return c.withOpenClipboard(func() error {
+		win.EmptyClipboard()
+		if err := setClipboardBytes(win.CF_DIB, dib); err != nil {
+			return err
+		}
+		if format := pngFormat(); format != 0 && len(png) > 0 {
+			if err := setClipboardBytes(format, png); err != nil {
 				return err
 			}
-			utf16 = append(utf16, _utf16...)
 		}
-		utf16 = append(utf16, uint16(0))
+		if len(paths) > 0 {
+			return setFilesData(paths)
+		}
+		return nil
+	})
+}
 
-		const dropFilesSize = unsafe.Sizeof(DROPFILES{}) - 4
+// SetHTML sets html fragment as the registered "HTML Format", so that
+// rich text editors paste it formatted, and text as CF_UNICODETEXT for the
+// others
+func (c *ClipboardService) SetHTML(text, html string) error {
+	unicode, err := unicodeBytes(text)
+	if err != nil {
+		return err
+	}
+	format, err := RegisterFormat("HTML Format")
+	if err != nil {
+		return err
+	}
+	return c.withOpenClipboard(func() error {
+		win.EmptyClipboard()
+		if err := setClipboardBytes(win.CF_UNICODETEXT, unicode); err != nil {
+			return err
+		}
+		return setClipboardBytes(format, EncodeCFHTML(html))
+	})
+}
 
-		size := dropFilesSize + uintptr((len(utf16))*2+2)
+// setClipboardBytes sets data of format, clipboard must be opened
+func setClipboardBytes(format uint32, data []byte) error {
+	hMem := win.GlobalAlloc(win.GMEM_MOVEABLE, uintptr(len(data)))
+	if hMem == 0 {
+		return lastError("GlobalAlloc")
+	}
 
-		hMem := win.GlobalAlloc(win.GHND, size)
-		if hMem == 0 {
-			return lastError("GlobalAlloc")
-		}
+	p := win.GlobalLock(hMem)
+	if p == nil {
+		win.GlobalFree(hMem)
+		return lastError("GlobalLock()")
+	}
+	win.MoveMemory(p, unsafe.Pointer(&data[0]), uintptr(len(data)))
+	win.GlobalUnlock(hMem)
 
-		p := win.GlobalLock(hMem)
-		if p == nil {
-			return lastError("GlobalLock()")
+	if 0 == win.SetClipboardData(format, win.HANDLE(hMem)) {
+		// We need to free hMem.
+		defer win.GlobalFree(hMem)
+
+		return lastError("SetClipboardData")
+	}
+	// The system now owns the memory referred to by hMem.
+	return nil
+}
+
+// setFilesData sets file drop data of paths, clipboard must be opened
+func setFilesData(paths []string) error {
+	// https://docs.microsoft.com/en-us/windows/win32/shell/clipboard#cf_hdrop
+	var utf16 []uint16
+	for _, path := range paths {
+		_utf16, err := syscall.UTF16FromString(path)
+		if err != nil {
+			return err
 		}
+		utf16 = append(utf16, _utf16...)
+	}
+	utf16 = append(utf16, uint16(0))
 
-		zeroMem := make([]byte, size)
-		win.MoveMemory(p, unsafe.Pointer(&zeroMem[0]), size)
+	const dropFilesSize = unsafe.Sizeof(DROPFILES{}) - 4
 
-		pD := (*DROPFILES)(p)
-		pD.pFiles = dropFilesSize
-		pD.fWide = false
-		pD.fNC = true
-		win.MoveMemory(unsafe.Pointer(uintptr(p)+dropFilesSize), unsafe.Pointer(&utf16[0]), uintptr(len(utf16)*2))
+	size := dropFilesSize + uintptr((len(utf16))*2+2)
 
-		win.GlobalUnlock(hMem)
+	hMem := win.GlobalAlloc(win.GHND, size)
+	if hMem == 0 {
+		return lastError("GlobalAlloc")
+	}
 
-		if 0 == win.SetClipboardData(win.CF_HDROP, win.HANDLE(hMem)) {
-			// We need to free hMem.
-			defer win.GlobalFree(hMem)
+	p := win.GlobalLock(hMem)
+	if p == nil {
+		return lastError("GlobalLock()")
+	}
 
-			return lastError("SetClipboardData")
-		}
-		// The system now owns the memory referred to by hMem.
+	zeroMem := make([]byte, size)
+	win.MoveMemory(p, unsafe.Pointer(&zeroMem[0]), size)
 
-		return nil
-	})
+	pD := (*DROPFILES)(p)
+	pD.pFiles = dropFilesSize
+	pD.fWide = false
+	pD.fNC = true
+	win.MoveMemory(unsafe.Pointer(uintptr(p)+dropFilesSize), unsafe.Pointer(&utf16[0]), uintptr(len(utf16)*2))
+
+	win.GlobalUnlock(hMem)
+
+	if 0 == win.SetClipboardData(win.CF_HDROP, win.HANDLE(hMem)) {
+		// We need to free hMem.
+		defer win.GlobalFree(hMem)
+
+		return lastError("SetClipboardData")
+	}
+	// The system now owns the memory referred to by hMem.
+
+	return nil
 }
 
 func (c *ClipboardService) withOpenClipboard(f func() error) error {
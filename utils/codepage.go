@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CodePageUTF8 is code page of UTF-8
+const CodePageUTF8 = 65001
+
+// code pages of common charset names of legacy apps
+var codePages = map[string]uint32{
+	"gbk":          936,
+	"gb2312":       936,
+	"cp936":        936,
+	"big5":         950,
+	"cp950":        950,
+	"shift_jis":    932,
+	"shift-jis":    932,
+	"sjis":         932,
+	"cp932":        932,
+	"euc-kr":       949,
+	"cp949":        949,
+	"windows-1252": 1252,
+	"utf-8":        CodePageUTF8,
+	"utf8":         CodePageUTF8,
+}
+
+// ParseCodePage returns windows code page of charset name like gbk or big5,
+// or of a code page number like 936
+func ParseCodePage(name string) (uint32, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if codePage, ok := codePages[name]; ok {
+		return codePage, nil
+	}
+	if codePage, err := strconv.ParseUint(name, 10, 16); err == nil && codePage > 0 {
+		return uint32(codePage), nil
+	}
+	return 0, fmt.Errorf("unknown charset: %s", name)
+}
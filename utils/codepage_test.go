@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestParseCodePage(t *testing.T) {
+	cases := []struct {
+		name     string
+		codePage uint32
+	}{
+		{"gbk", 936},
+		{"GB2312", 936},
+		{" Big5 ", 950},
+		{"shift_jis", 932},
+		{"utf-8", CodePageUTF8},
+		{"1251", 1251},
+	}
+	for _, tc := range cases {
+		codePage, err := ParseCodePage(tc.name)
+		if err != nil || codePage != tc.codePage {
+			t.Errorf("ParseCodePage(%q) = %d, %v, want %d", tc.name, codePage, err, tc.codePage)
+		}
+	}
+	for _, name := range []string{"", "latin-9", "0", "70000"} {
+		if _, err := ParseCodePage(name); err == nil {
+			t.Errorf("ParseCodePage(%q) succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	rgbColor = regexp.MustCompile(`(?i)^rgba?\(\s*(\d{1,3})(?:\s*,\s*|\s+)(\d{1,3})(?:\s*,\s*|\s+)(\d{1,3})(?:\s*[,/]\s*(\d*\.?\d+)(%?))?\s*\)$`)
+)
+
+// Color is an srgb color, alpha is from 0 to 1
+type Color struct {
+	R, G, B uint8
+	A       float64
+}
+
+// ParseColor parses s as #rgb, #rgba, #rrggbb, #rrggbbaa, rgb(r, g, b) or
+// rgba(r, g, b, a), surrounding whitespace is ignored
+func ParseColor(s string) (Color, bool) {
+	s = strings.TrimSpace(s)
+	if hexColor.MatchString(s) {
+		digits := s[1:]
+		if len(digits) <= 4 {
+			var b strings.Builder
+			for _, d := range digits {
+				b.WriteRune(d)
+				b.WriteRune(d)
+			}
+			digits = b.String()
+		}
+		n, _ := strconv.ParseUint(digits, 16, 32)
+		if len(digits) == 6 {
+			return Color{uint8(n >> 16), uint8(n >> 8), uint8(n), 1}, true
+		}
+		return Color{uint8(n >> 24), uint8(n >> 16), uint8(n >> 8), float64(uint8(n)) / 255}, true
+	}
+
+	m := rgbColor.FindStringSubmatch(s)
+	if m == nil {
+		return Color{}, false
+	}
+	var rgb [3]uint8
+	for i := range rgb {
+		v, _ := strconv.Atoi(m[i+1])
+		if v > 255 {
+			return Color{}, false
+		}
+		rgb[i] = uint8(v)
+	}
+	alpha := 1.0
+	if m[4] != "" {
+		alpha, _ = strconv.ParseFloat(m[4], 64)
+		if m[5] == "%" {
+			alpha /= 100
+		}
+		if alpha > 1 {
+			return Color{}, false
+		}
+	}
+	return Color{rgb[0], rgb[1], rgb[2], alpha}, true
+}
+
+// Hex returns color as #RRGGBB, or #RRGGBBAA if it's not opaque
+func (c Color) Hex() string {
+	if c.A < 1 {
+		return fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, uint8(math.Round(c.A*255)))
+	}
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// RGB returns color as rgb(r, g, b), or rgba(r, g, b, a) if it's not opaque
+func (c Color) RGB() string {
+	if c.A < 1 {
+		return fmt.Sprintf("rgba(%d, %d, %d, %s)", c.R, c.G, c.B, formatAlpha(c.A))
+	}
+	return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B)
+}
+
+// HSL returns color as hsl(h, s%, l%), or hsla(h, s%, l%, a) if it's not
+// opaque. Components are rounded to integers
+func (c Color) HSL() string {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (max + min) / 2
+	var h, s float64
+	if d := max - min; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+		switch max {
+		case r:
+			h = math.Mod((g-b)/d+6, 6)
+		case g:
+			h = (b-r)/d + 2
+		default:
+			h = (r-g)/d + 4
+		}
+		h *= 60
+	}
+	hsl := fmt.Sprintf("%d, %d%%, %d%%", int(math.Round(h))%360, int(math.Round(s*100)), int(math.Round(l*100)))
+	if c.A < 1 {
+		return "hsla(" + hsl + ", " + formatAlpha(c.A) + ")"
+	}
+	return "hsl(" + hsl + ")"
+}
+
+func formatAlpha(a float64) string {
+	return strconv.FormatFloat(math.Round(a*100)/100, 'f', -1, 64)
+}
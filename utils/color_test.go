@@ -0,0 +1,34 @@
+package utils
+
+import "testing"
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s             string
+		hex, rgb, hsl string
+	}{
+		{"#ff8800", "#FF8800", "rgb(255, 136, 0)", "hsl(32, 100%, 50%)"},
+		{" #F80\n", "#FF8800", "rgb(255, 136, 0)", "hsl(32, 100%, 50%)"},
+		{"#0000ff80", "#0000FF80", "rgba(0, 0, 255, 0.5)", "hsla(240, 100%, 50%, 0.5)"},
+		{"#fff", "#FFFFFF", "rgb(255, 255, 255)", "hsl(0, 0%, 100%)"},
+		{"rgb(0, 128, 0)", "#008000", "rgb(0, 128, 0)", "hsl(120, 100%, 25%)"},
+		{"RGBA(255,0,0,.25)", "#FF000040", "rgba(255, 0, 0, 0.25)", "hsla(0, 100%, 50%, 0.25)"},
+		{"rgb(255 0 255 / 50%)", "#FF00FF80", "rgba(255, 0, 255, 0.5)", "hsla(300, 100%, 50%, 0.5)"},
+	}
+	for _, test := range tests {
+		c, ok := ParseColor(test.s)
+		if !ok {
+			t.Errorf("ParseColor(%q) failed", test.s)
+			continue
+		}
+		if c.Hex() != test.hex || c.RGB() != test.rgb || c.HSL() != test.hsl {
+			t.Errorf("ParseColor(%q) = %s %s %s, want %s %s %s", test.s, c.Hex(), c.RGB(), c.HSL(), test.hex, test.rgb, test.hsl)
+		}
+	}
+
+	for _, s := range []string{"", "ff8800", "#ff888", "#ggg", "#ff88001", "rgb(256, 0, 0)", "rgb(1, 2)", "rgba(1, 2, 3, 2)", "color: #fff", "#fff #000"} {
+		if _, ok := ParseColor(s); ok {
+			t.Errorf("ParseColor(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := DeriveKey("secret")
+	plaintext := []byte("剪切板内容")
+
+	ciphertext, err := Encrypt(key, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	got, err := Decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %s, want %s", got, plaintext)
+	}
+
+	if _, err := Decrypt(DeriveKey("wrong"), ciphertext); err == nil {
+		t.Error("Decrypt() with wrong key should return error")
+	}
+	if _, err := Decrypt(key, ciphertext[:4]); err == nil {
+		t.Error("Decrypt() with short ciphertext should return error")
+	}
+}
+
+func TestDeriveKeySalted(t *testing.T) {
+	key, err := DeriveKeySalted("secret", []byte("salt"))
+	if err != nil {
+		t.Fatalf("DeriveKeySalted() error = %v", err)
+	}
+	if len(key) != 32 {
+		t.Errorf("len(DeriveKeySalted()) = %d, want 32", len(key))
+	}
+	again, err := DeriveKeySalted("secret", []byte("salt"))
+	if err != nil {
+		t.Fatalf("DeriveKeySalted() error = %v", err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("DeriveKeySalted() should return the same key for the same passphrase and salt")
+	}
+	other, err := DeriveKeySalted("secret", []byte("other"))
+	if err != nil {
+		t.Fatalf("DeriveKeySalted() error = %v", err)
+	}
+	if bytes.Equal(key, other) {
+		t.Error("DeriveKeySalted() should return different keys for different salts")
+	}
+	if bytes.Equal(key, DeriveKey("secret")) {
+		t.Error("DeriveKeySalted() should differ from DeriveKey()")
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+var errInvalidDelta = errors.New("delta exceeds length of base text")
+
+// DeltaOp is an operation of a text delta, only one of its fields is set.
+// Counts are in unicode code points
+type DeltaOp struct {
+	Retain int    `json:"retain,omitempty"`
+	Delete int    `json:"delete,omitempty"`
+	Insert string `json:"insert,omitempty"`
+}
+
+// TextHash returns hex encoded sha256 of text, which identifies a version
+// of text in deltas
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff returns ops turning old into new. Common prefix and suffix are
+// retained and the rest is replaced, which is compact for edits in one
+// place, e.g. typing in a note
+func Diff(old, new string) []DeltaOp {
+	a, b := []rune(old), []rune(new)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]DeltaOp, 0, 3)
+	if prefix > 0 {
+		ops = append(ops, DeltaOp{Retain: prefix})
+	}
+	if deleted := len(a) - prefix - suffix; deleted > 0 {
+		ops = append(ops, DeltaOp{Delete: deleted})
+	}
+	if inserted := b[prefix : len(b)-suffix]; len(inserted) > 0 {
+		ops = append(ops, DeltaOp{Insert: string(inserted)})
+	}
+	// rest of base is retained implicitly
+	return ops
+}
+
+// ApplyDelta applies ops to base. Text of base after the last op is
+// retained
+func ApplyDelta(base string, ops []DeltaOp) (string, error) {
+	a := []rune(base)
+	result := make([]rune, 0, len(a))
+	pos := 0
+	for _, op := range ops {
+		switch {
+		case op.Retain > 0:
+			if pos+op.Retain > len(a) {
+				return "", errInvalidDelta
+			}
+			result = append(result, a[pos:pos+op.Retain]...)
+			pos += op.Retain
+		case op.Delete > 0:
+			if pos+op.Delete > len(a) {
+				return "", errInvalidDelta
+			}
+			pos += op.Delete
+		default:
+			result = append(result, []rune(op.Insert)...)
+		}
+	}
+	result = append(result, a[pos:]...)
+	return string(result), nil
+}
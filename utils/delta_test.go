@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		old, new string
+		ops      []DeltaOp
+	}{
+		{"", "", []DeltaOp{}},
+		{"", "你好", []DeltaOp{{Insert: "你好"}}},
+		{"hello world", "hello world", []DeltaOp{{Retain: 11}}},
+		{"hello world", "hello, world", []DeltaOp{{Retain: 5}, {Insert: ","}}},
+		{"hello world", "hello", []DeltaOp{{Retain: 5}, {Delete: 6}}},
+		{"剪切板同步", "剪贴板同步", []DeltaOp{{Retain: 1}, {Delete: 1}, {Insert: "贴"}}},
+		{"aaa", "aa", []DeltaOp{{Retain: 2}, {Delete: 1}}},
+	}
+	for _, tc := range cases {
+		ops := Diff(tc.old, tc.new)
+		if !reflect.DeepEqual(ops, tc.ops) {
+			t.Errorf("Diff(%q, %q) = %v, want %v", tc.old, tc.new, ops, tc.ops)
+		}
+		got, err := ApplyDelta(tc.old, ops)
+		if err != nil || got != tc.new {
+			t.Errorf("ApplyDelta(%q, %v) = %q, %v, want %q", tc.old, ops, got, err, tc.new)
+		}
+	}
+}
+
+func TestApplyDeltaInvalid(t *testing.T) {
+	for _, ops := range [][]DeltaOp{
+		{{Retain: 4}},
+		{{Retain: 1}, {Delete: 3}},
+	} {
+		if _, err := ApplyDelta("abc", ops); err == nil {
+			t.Errorf("ApplyDelta(%v) succeeded", ops)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+const bitmapInfoHeaderSize = 40
+
+// EncodeDIB encodes img as a device independent bitmap of CF_DIB, which is
+// a BITMAPINFOHEADER followed by 32-bit BGRA rows from bottom to top
+func EncodeDIB(img image.Image) []byte {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	stride := 4 * width
+	dib := make([]byte, bitmapInfoHeaderSize+stride*height)
+
+	binary.LittleEndian.PutUint32(dib[0:], bitmapInfoHeaderSize)
+	binary.LittleEndian.PutUint32(dib[4:], uint32(width))
+	binary.LittleEndian.PutUint32(dib[8:], uint32(height)) // positive height is bottom-up
+	binary.LittleEndian.PutUint16(dib[12:], 1)             // planes
+	binary.LittleEndian.PutUint16(dib[14:], 32)            // bits per pixel
+	binary.LittleEndian.PutUint32(dib[16:], 0)             // BI_RGB
+	binary.LittleEndian.PutUint32(dib[20:], uint32(stride*height))
+
+	pixels := dib[bitmapInfoHeaderSize:]
+	for y := 0; y < height; y++ {
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			row[4*x] = c.B
+			row[4*x+1] = c.G
+			row[4*x+2] = c.R
+			row[4*x+3] = c.A
+		}
+	}
+	return dib
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeDIB(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 3))
+	img.Set(0, 0, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+	img.Set(1, 2, color.NRGBA{R: 4, G: 5, B: 6, A: 128})
+
+	dib := EncodeDIB(img)
+	if len(dib) != bitmapInfoHeaderSize+4*2*3 {
+		t.Fatalf("len(dib) = %d", len(dib))
+	}
+	if w, h := binary.LittleEndian.Uint32(dib[4:]), binary.LittleEndian.Uint32(dib[8:]); w != 2 || h != 3 {
+		t.Errorf("size = %dx%d", w, h)
+	}
+	pixels := dib[bitmapInfoHeaderSize:]
+	// top left pixel is in the last row
+	if got := pixels[2*4*2 : 2*4*2+4]; got[0] != 3 || got[1] != 2 || got[2] != 1 || got[3] != 255 {
+		t.Errorf("top left = %v", got)
+	}
+	// bottom right pixel is in the first row
+	if got := pixels[4 : 4+4]; got[0] != 6 || got[1] != 5 || got[2] != 4 || got[3] != 128 {
+		t.Errorf("bottom right = %v", got)
+	}
+}
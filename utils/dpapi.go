@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const dpapiPEMType = "DPAPI PROTECTED DATA"
+
+// ProtectData encrypts data by Windows DPAPI, which can only be decrypted by
+// current user on this machine
+func ProtectData(data []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(newDataBlob(data), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+// UnprotectData decrypts data encrypted by ProtectData
+func UnprotectData(data []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(newDataBlob(data), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+// WriteSecretFile writes data encrypted by DPAPI as a PEM block to path
+func WriteSecretFile(path string, data []byte) error {
+	protected, err := ProtectData(data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: dpapiPEMType, Bytes: protected}), 0600)
+}
+
+// ReadSecretFile reads file written by WriteSecretFile. Files which are not
+// protected are returned as is
+func ReadSecretFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != dpapiPEMType {
+		return data, nil
+	}
+	return UnprotectData(block.Bytes)
+}
+
+func newDataBlob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// takeDataBlob copies data allocated by DPAPI and frees it
+func takeDataBlob(blob *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	data := make([]byte, blob.Size)
+	copy(data, (*[1 << 30]byte)(unsafe.Pointer(blob.Data))[:blob.Size:blob.Size])
+	return data
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+var (
+	enumClipboardFormats   = libuser32.NewProc("EnumClipboardFormats")
+	getClipboardFormatName = libuser32.NewProc("GetClipboardFormatNameW")
+)
+
+// names of predefined formats, which have no registered names
+var predefinedFormats = map[uint32]string{
+	win.CF_TEXT:            "CF_TEXT",
+	win.CF_BITMAP:          "CF_BITMAP",
+	win.CF_METAFILEPICT:    "CF_METAFILEPICT",
+	win.CF_SYLK:            "CF_SYLK",
+	win.CF_DIF:             "CF_DIF",
+	win.CF_TIFF:            "CF_TIFF",
+	win.CF_OEMTEXT:         "CF_OEMTEXT",
+	win.CF_DIB:             "CF_DIB",
+	win.CF_PALETTE:         "CF_PALETTE",
+	win.CF_PENDATA:         "CF_PENDATA",
+	win.CF_RIFF:            "CF_RIFF",
+	win.CF_WAVE:            "CF_WAVE",
+	win.CF_UNICODETEXT:     "CF_UNICODETEXT",
+	win.CF_ENHMETAFILE:     "CF_ENHMETAFILE",
+	win.CF_HDROP:           "CF_HDROP",
+	win.CF_LOCALE:          "CF_LOCALE",
+	win.CF_DIBV5:           "CF_DIBV5",
+	win.CF_OWNERDISPLAY:    "CF_OWNERDISPLAY",
+	win.CF_DSPTEXT:         "CF_DSPTEXT",
+	win.CF_DSPBITMAP:       "CF_DSPBITMAP",
+	win.CF_DSPMETAFILEPICT: "CF_DSPMETAFILEPICT",
+	win.CF_DSPENHMETAFILE:  "CF_DSPENHMETAFILE",
+}
+
+// ClipboardFormat is a format on clipboard
+type ClipboardFormat struct {
+	ID         uint32 `json:"id"`
+	Name       string `json:"name"`
+	Registered bool   `json:"registered"` // name can be used by FormatData and SetFormats
+	Size       int64  `json:"size"`       // -1 if data is not global memory, e.g. CF_BITMAP
+}
+
+// handleFormat reports whether data of format is a gdi handle rather than
+// global memory, so its size is unknown
+func handleFormat(format uint32) bool {
+	switch format {
+	case win.CF_BITMAP, win.CF_PALETTE, win.CF_ENHMETAFILE, win.CF_OWNERDISPLAY, win.CF_DSPBITMAP, win.CF_DSPENHMETAFILE:
+		return true
+	}
+	return format >= win.CF_PRIVATEFIRST && format <= win.CF_GDIOBJLAST
+}
+
+// formatName returns name of format, registered tells whether it's a
+// registered format
+func formatName(format uint32) (name string, registered bool) {
+	if name, ok := predefinedFormats[format]; ok {
+		return name, false
+	}
+	buf := make([]uint16, 256)
+	n, _, _ := getClipboardFormatName.Call(uintptr(format), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 {
+		return fmt.Sprintf("#%d", format), false
+	}
+	return syscall.UTF16ToString(buf[:n]), true
+}
+
+// Formats returns formats on clipboard in order of their placement, which
+// is usually from the most to the least descriptive. Data of formats
+// rendered on demand is rendered to get its size
+func (c *ClipboardService) Formats() (formats []ClipboardFormat, err error) {
+	err = c.withOpenClipboard(func() error {
+		var format uintptr
+		for {
+			format, _, _ = enumClipboardFormats.Call(format)
+			if format == 0 {
+				break
+			}
+			f := ClipboardFormat{ID: uint32(format), Size: -1}
+			f.Name, f.Registered = formatName(f.ID)
+			if !handleFormat(f.ID) {
+				if hMem := win.GetClipboardData(f.ID); hMem != 0 {
+					size, _, _ := globalSize.Call(uintptr(hMem))
+					f.Size = int64(size)
+				}
+			}
+			formats = append(formats, f)
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	libiphlpapi  = windows.NewLazySystemDLL("iphlpapi.dll")
+	getBestRoute = libiphlpapi.NewProc("GetBestRoute")
+)
+
+// mibIPForwardRow is MIB_IPFORWARDROW
+type mibIPForwardRow struct {
+	ForwardDest      uint32
+	ForwardMask      uint32
+	ForwardPolicy    uint32
+	ForwardNextHop   uint32
+	ForwardIfIndex   uint32
+	ForwardType      uint32
+	ForwardProto     uint32
+	ForwardAge       uint32
+	ForwardNextHopAS uint32
+	ForwardMetric1   uint32
+	ForwardMetric2   uint32
+	ForwardMetric3   uint32
+	ForwardMetric4   uint32
+	ForwardMetric5   uint32
+}
+
+// DefaultGateway returns ipv4 address of gateway routing to internet
+func DefaultGateway() (net.IP, error) {
+	// addresses are in network byte order
+	dest := binary.LittleEndian.Uint32(net.IPv4(8, 8, 8, 8).To4())
+	var row mibIPForwardRow
+	r, _, _ := getBestRoute.Call(uintptr(dest), 0, uintptr(unsafe.Pointer(&row)))
+	if r != 0 {
+		return nil, syscall.Errno(r)
+	}
+	gateway := make(net.IP, net.IPv4len)
+	binary.LittleEndian.PutUint32(gateway, row.ForwardNextHop)
+	if gateway.IsUnspecified() || row.ForwardNextHop == dest {
+		return nil, errors.New("no default gateway")
+	}
+	return gateway, nil
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"html"
+	"strings"
+)
+
+// inline styles of tokens, since rich text editors like Outlook drop
+// stylesheets of pasted html. Colors are of Visual Studio light theme
+const (
+	codeBlockStyle = "font-family:Consolas,'Courier New',monospace;font-size:10pt;background:#f8f8f8;padding:8px;white-space:pre"
+	keywordStyle   = "color:#0000ff"
+	stringStyle    = "color:#a31515"
+	commentStyle   = "color:#008000"
+	numberStyle    = "color:#098658"
+)
+
+// codeLanguage is syntax of a language for highlighting
+type codeLanguage struct {
+	keywords       map[string]bool
+	ignoreCase     bool // keywords are case insensitive, e.g. sql
+	lineComments   []string
+	blockComment   [2]string
+	quotes         string // quotes of strings
+	multilineQuote byte   // quote of strings spanning lines, e.g. ` of go
+}
+
+func keywordSet(keywords string) map[string]bool {
+	set := make(map[string]bool)
+	for _, keyword := range strings.Fields(keywords) {
+		set[keyword] = true
+	}
+	return set
+}
+
+var (
+	cKeywords = "auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while bool true false NULL"
+
+	goLanguage = &codeLanguage{
+		keywords:       keywordSet("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var true false nil iota"),
+		lineComments:   []string{"//"},
+		blockComment:   [2]string{"/*", "*/"},
+		quotes:         "\"'`",
+		multilineQuote: '`',
+	}
+	jsLanguage = &codeLanguage{
+		keywords:       keywordSet("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield true false null undefined interface type enum implements"),
+		lineComments:   []string{"//"},
+		blockComment:   [2]string{"/*", "*/"},
+		quotes:         "\"'`",
+		multilineQuote: '`',
+	}
+	pythonLanguage = &codeLanguage{
+		keywords:     keywordSet("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield True False None self"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	javaLanguage = &codeLanguage{
+		keywords:     keywordSet("abstract boolean break byte case catch char class const continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return short static super switch synchronized this throw throws try var void volatile while true false null"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	cLanguage = &codeLanguage{
+		keywords:     keywordSet(cKeywords),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	cppLanguage = &codeLanguage{
+		keywords:     keywordSet(cKeywords + " class namespace template typename public private protected virtual override new delete this using try catch throw nullptr auto constexpr"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	csharpLanguage = &codeLanguage{
+		keywords:     keywordSet("abstract as async await base bool break byte case catch char class const continue decimal default delegate do double else enum event false finally float for foreach if in int interface internal is long namespace new null object out override private protected public readonly ref return sealed short static string struct switch this throw true try typeof using var virtual void while"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	rustLanguage = &codeLanguage{
+		keywords:     keywordSet("as async await break const continue crate else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"",
+	}
+	shellLanguage = &codeLanguage{
+		keywords:     keywordSet("if then else elif fi for while until do done case esac in function return local export echo exit"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	sqlLanguage = &codeLanguage{
+		keywords:     keywordSet("select from where and or not insert into values update set delete create table drop alter index join left right inner outer on as group by order having limit offset distinct union all null is in like between case when then else end primary key foreign references default"),
+		ignoreCase:   true,
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+	}
+	jsonLanguage = &codeLanguage{
+		keywords: keywordSet("true false null"),
+		quotes:   "\"",
+	}
+)
+
+// codeLanguages are languages by lowercase names and aliases
+var codeLanguages = map[string]*codeLanguage{
+	"go": goLanguage, "golang": goLanguage,
+	"js": jsLanguage, "javascript": jsLanguage, "jsx": jsLanguage, "ts": jsLanguage, "typescript": jsLanguage, "tsx": jsLanguage,
+	"py": pythonLanguage, "python": pythonLanguage,
+	"java": javaLanguage, "kotlin": javaLanguage, "kt": javaLanguage,
+	"c": cLanguage, "h": cLanguage,
+	"cpp": cppLanguage, "c++": cppLanguage, "cc": cppLanguage, "hpp": cppLanguage,
+	"cs": csharpLanguage, "csharp": csharpLanguage, "c#": csharpLanguage,
+	"rust": rustLanguage, "rs": rustLanguage,
+	"sh": shellLanguage, "bash": shellLanguage, "shell": shellLanguage, "zsh": shellLanguage,
+	"sql":  sqlLanguage,
+	"json": jsonLanguage,
+}
+
+// HighlightCode returns html of code in a monospace block, with keywords,
+// strings, comments and numbers colored by inline styles if language is
+// known
+func HighlightCode(code, language string) string {
+	var b strings.Builder
+	b.WriteString(`<pre style="` + codeBlockStyle + `">`)
+	if lang, ok := codeLanguages[strings.ToLower(language)]; ok {
+		lang.highlight(&b, code)
+	} else {
+		b.WriteString(html.EscapeString(code))
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func writeToken(b *strings.Builder, style, token string) {
+	b.WriteString(`<span style="` + style + `">` + html.EscapeString(token) + `</span>`)
+}
+
+// highlight writes html of code to b
+func (lang *codeLanguage) highlight(b *strings.Builder, code string) {
+	i := 0
+	for i < len(code) {
+		rest := code[i:]
+		if end := lang.commentEnd(rest); end > 0 {
+			writeToken(b, commentStyle, rest[:end])
+			i += end
+			continue
+		}
+		c := code[i]
+		switch {
+		case strings.IndexByte(lang.quotes, c) >= 0:
+			end := lang.stringEnd(rest)
+			writeToken(b, stringStyle, rest[:end])
+			i += end
+		case isDigit(c) && (i == 0 || !isIdentByte(code[i-1])):
+			end := 1
+			for end < len(rest) && (isIdentByte(rest[end]) || rest[end] == '.' && end+1 < len(rest) && isDigit(rest[end+1])) {
+				end++
+			}
+			writeToken(b, numberStyle, rest[:end])
+			i += end
+		case isIdentByte(c):
+			end := 1
+			for end < len(rest) && isIdentByte(rest[end]) {
+				end++
+			}
+			word := rest[:end]
+			if lang.keywords[word] || lang.ignoreCase && lang.keywords[strings.ToLower(word)] {
+				writeToken(b, keywordStyle, word)
+			} else {
+				b.WriteString(html.EscapeString(word))
+			}
+			i += end
+		default:
+			b.WriteString(html.EscapeString(code[i : i+1]))
+			i++
+		}
+	}
+}
+
+// commentEnd returns length of comment at start of s, 0 if there's none.
+// An unclosed comment runs to the end
+func (lang *codeLanguage) commentEnd(s string) int {
+	for _, prefix := range lang.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	if open := lang.blockComment[0]; open != "" && strings.HasPrefix(s, open) {
+		if end := strings.Index(s[len(open):], lang.blockComment[1]); end >= 0 {
+			return len(open) + end + len(lang.blockComment[1])
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// stringEnd returns length of string at start of s, which ends at the
+// closing quote, or at end of line if it's not closed
+func (lang *codeLanguage) stringEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != lang.multilineQuote:
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n' && quote != lang.multilineQuote:
+			return i
+		}
+	}
+	return len(s)
+}
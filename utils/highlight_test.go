@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightCode(t *testing.T) {
+	span := func(style, token string) string {
+		return `<span style="` + style + `">` + token + `</span>`
+	}
+	tests := []struct {
+		code, language string
+		want           string
+	}{
+		{"func main() {}", "go", span(keywordStyle, "func") + " main() {}"},
+		{`x := "a\"<b>" // done`, "Go", `x := ` + span(stringStyle, `&#34;a\&#34;&lt;b&gt;&#34;`) + " " + span(commentStyle, "// done")},
+		{"s := `a\nb`", "go", "s := " + span(stringStyle, "`a\nb`")},
+		{"n = 3.14 + x2", "python", "n = " + span(numberStyle, "3.14") + " + x2"},
+		{"# note\nreturn None", "py", span(commentStyle, "# note") + "\n" + span(keywordStyle, "return") + " " + span(keywordStyle, "None")},
+		{"/* a\nb */int", "c", span(commentStyle, "/* a\nb */") + span(keywordStyle, "int")},
+		{"SELECT id FROM t -- x", "sql", span(keywordStyle, "SELECT") + " id " + span(keywordStyle, "FROM") + " t " + span(commentStyle, "-- x")},
+		{"'open\nnext", "js", span(stringStyle, "&#39;open") + "\nnext"},
+		{"func <b>", "brainfuck", "func &lt;b&gt;"},
+	}
+	for _, test := range tests {
+		got := HighlightCode(test.code, test.language)
+		if !strings.HasPrefix(got, `<pre style="`) || !strings.HasSuffix(got, "</pre>") {
+			t.Errorf("HighlightCode(%q) = %q is not a pre block", test.code, got)
+			continue
+		}
+		got = got[strings.IndexByte(got, '>')+1 : len(got)-len("</pre>")]
+		if got != test.want {
+			t.Errorf("HighlightCode(%q, %q) = %q, want %q", test.code, test.language, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// minImageTextLen is length of the shortest base64 text taken as an image
+// without data uri, so that short words which happen to be base64 are kept
+const minImageTextLen = 64
+
+// extensions of image types detected by http.DetectContentType
+var imageTextExts = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/bmp":                ".bmp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// DecodeImageText returns image and its extension if text is a base64 data
+// uri of image, e.g. data:image/png;base64,iVBORw0KGgo..., or base64 of
+// png, jpeg, gif, webp, bmp or ico. Line breaks in base64 are ignored
+func DecodeImageText(text string) (data []byte, ext string, ok bool) {
+	payload := strings.TrimSpace(text)
+	mediaType := ""
+	if len(payload) > 5 && strings.EqualFold(payload[:5], "data:") {
+		comma := strings.IndexByte(payload, ',')
+		if comma < 0 {
+			return nil, "", false
+		}
+		params := strings.Split(strings.ToLower(payload[5:comma]), ";")
+		if len(params) < 2 || params[len(params)-1] != "base64" || !strings.HasPrefix(params[0], "image/") {
+			return nil, "", false
+		}
+		mediaType, payload = params[0], payload[comma+1:]
+	} else if len(payload) < minImageTextLen {
+		return nil, "", false
+	}
+
+	payload = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, payload)
+	data, ok = decodeBase64(payload)
+	if !ok {
+		return nil, "", false
+	}
+	if ext, ok = imageTextExts[http.DetectContentType(data)]; ok {
+		return data, ext, true
+	}
+	// svg is text, so it's only known by media type of data uri
+	if mediaType == "image/svg+xml" {
+		return data, ".svg", true
+	}
+	return nil, "", false
+}
+
+// decodeBase64 decodes s of standard or url encoding, padded or not
+func decodeBase64(s string) ([]byte, bool) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(s); err == nil && len(data) > 0 {
+			return data, true
+		}
+	}
+	return nil, false
+}
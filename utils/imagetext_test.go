@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestDecodeImageText(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	pngBytes := buf.Bytes()
+	encoded := base64.StdEncoding.EncodeToString(pngBytes)
+	svg := base64.StdEncoding.EncodeToString([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))
+
+	tests := []struct {
+		text string
+		ext  string
+		ok   bool
+	}{
+		{"data:image/png;base64," + encoded, ".png", true},
+		{"  DATA:image/PNG;BASE64," + encoded + "\n", ".png", true},
+		{"data:image/svg+xml;base64," + svg, ".svg", true},
+		{encoded, ".png", true},
+		{encoded[:40] + "\r\n" + encoded[40:], ".png", true},
+		{strings.TrimRight(encoded, "="), ".png", true},
+		{"data:image/png," + encoded, "", false},
+		{"data:text/plain;base64," + encoded, "", false},
+		{"data:image/png;base64,!!!", "", false},
+		{svg, "", false},
+		{"aGVsbG8=", "", false},
+		{base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("hello world "), 10)), "", false},
+		{"just some text, not an image at all, but long enough to be checked", "", false},
+	}
+	for _, test := range tests {
+		data, ext, ok := DecodeImageText(test.text)
+		if ok != test.ok || ext != test.ext {
+			t.Errorf("DecodeImageText(%.40q) = %q, %v, want %q, %v", test.text, ext, ok, test.ext, test.ok)
+			continue
+		}
+		if ok && ext == ".png" && !bytes.Equal(data, pngBytes) {
+			t.Errorf("DecodeImageText(%.40q) returns wrong data", test.text)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestContextReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	data, err := ioutil.ReadAll(ContextReader(ctx, strings.NewReader("foo")))
+	if err != nil || string(data) != "foo" {
+		t.Fatalf("ReadAll() = %q, %v", data, err)
+	}
+
+	cancel()
+	if _, err := ioutil.ReadAll(ContextReader(ctx, strings.NewReader("foo"))); err != context.Canceled {
+		t.Errorf("ReadAll() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "strings"
+
+// Line endings of text
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+)
+
+// NormalizeLineEndings converts line endings of s to lf or crlf. s is
+// returned as is for other endings
+func NormalizeLineEndings(s, ending string) string {
+	switch ending {
+	case LineEndingLF:
+		return strings.ReplaceAll(s, "\r\n", "\n")
+	case LineEndingCRLF:
+		return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	}
+	return s
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "testing"
+
+func TestNormalizeLineEndings(t *testing.T) {
+	cases := []struct {
+		s, ending, want string
+	}{
+		{"a\r\nb\nc", LineEndingLF, "a\nb\nc"},
+		{"a\r\nb\nc", LineEndingCRLF, "a\r\nb\r\nc"},
+		{"a\r\nb\nc", "", "a\r\nb\nc"},
+		{"a\r\r\nb\n", LineEndingLF, "a\r\nb\n"},
+		{"a\r\r\nb\n", LineEndingCRLF, "a\r\r\nb\r\n"},
+		{"", LineEndingCRLF, ""},
+	}
+	for _, tc := range cases {
+		if got := NormalizeLineEndings(tc.s, tc.ending); got != tc.want {
+			t.Errorf("NormalizeLineEndings(%q, %q) = %q, want %q", tc.s, tc.ending, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+type failureRecord struct {
+	failures    []time.Time
+	bannedUntil time.Time
+}
+
+// Lockout bans a key temporarily once it fails too many times within a window
+type Lockout struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	banDuration time.Duration
+	records     map[string]*failureRecord
+	now         func() time.Time
+}
+
+func NewLockout(maxFailures int, window, banDuration time.Duration) *Lockout {
+	return &Lockout{
+		maxFailures: maxFailures,
+		window:      window,
+		banDuration: banDuration,
+		records:     make(map[string]*failureRecord),
+		now:         time.Now,
+	}
+}
+
+// Banned reports whether key is banned and how long the ban remains
+func (l *Lockout) Banned(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.records[key]
+	if !ok {
+		return false, 0
+	}
+	remaining := record.bannedUntil.Sub(l.now())
+	return remaining > 0, remaining
+}
+
+// Fail records a failure of key and reports whether key gets banned by it
+func (l *Lockout) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	record, ok := l.records[key]
+	if !ok {
+		record = &failureRecord{}
+		l.records[key] = record
+	}
+
+	failures := record.failures[:0]
+	for _, t := range record.failures {
+		if now.Sub(t) < l.window {
+			failures = append(failures, t)
+		}
+	}
+	record.failures = append(failures, now)
+
+	if len(record.failures) >= l.maxFailures {
+		record.failures = nil
+		record.bannedUntil = now.Add(l.banDuration)
+		return true
+	}
+	return false
+}
+
+// Reset forgets failures of key
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if record, ok := l.records[key]; ok && !record.bannedUntil.After(l.now()) {
+		delete(l.records, key)
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	now := time.Unix(0, 0)
+	lockout := NewLockout(3, time.Minute, 10*time.Minute)
+	lockout.now = func() time.Time { return now }
+
+	if lockout.Fail("foo") || lockout.Fail("foo") {
+		t.Fatal("Fail() should not ban before reaching max failures")
+	}
+
+	// failures out of window are forgotten
+	now = now.Add(2 * time.Minute)
+	if lockout.Fail("foo") || lockout.Fail("foo") {
+		t.Fatal("Fail() should not count failures out of window")
+	}
+	if !lockout.Fail("foo") {
+		t.Fatal("Fail() should ban after reaching max failures")
+	}
+	if banned, _ := lockout.Banned("foo"); !banned {
+		t.Error("Banned(foo) = false, want true")
+	}
+	if banned, _ := lockout.Banned("bar"); banned {
+		t.Error("Banned(bar) = true, want false")
+	}
+
+	// reset doesn't lift a ban
+	lockout.Reset("foo")
+	if banned, _ := lockout.Banned("foo"); !banned {
+		t.Error("Banned(foo) after Reset() = false, want true")
+	}
+
+	now = now.Add(10 * time.Minute)
+	if banned, _ := lockout.Banned("foo"); banned {
+		t.Error("Banned(foo) after ban duration = true, want false")
+	}
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Markdown rendering of common syntax for notes, without nested blocks or
+// reference links
+
+var (
+	mdHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdRule        = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	mdUnordered   = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	mdOrdered     = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	mdQuote       = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	mdFence       = regexp.MustCompile("^\\s{0,3}(```|~~~)\\s*([\\w+#-]*)")
+	mdImage       = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	mdLink        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdStrong      = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdEmphasis    = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	mdStrike      = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdUnsafeLinks = regexp.MustCompile(`(?i)^\s*(javascript|vbscript|data):`)
+)
+
+// MarkdownToHTML renders markdown src as html: headings, paragraphs, lists,
+// quotes, fenced code, rules, links, images, code spans, strong, emphasis
+// and strikethrough
+func MarkdownToHTML(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	var paragraph []string
+	listTag := ""
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + markdownInline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			b.WriteString("</" + listTag + ">\n")
+			listTag = ""
+		}
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			b.WriteString("<" + tag + ">\n")
+			listTag = tag
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]); i++ {
+				code = append(code, lines[i])
+			}
+			class := ""
+			if m[2] != "" {
+				class = ` class="language-` + html.EscapeString(m[2]) + `"`
+			}
+			b.WriteString("<pre><code" + class + ">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			closeList()
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			closeList()
+			tag := "h" + string(rune('0'+len(m[1])))
+			b.WriteString("<" + tag + ">" + markdownInline(m[2]) + "</" + tag + ">\n")
+			continue
+		}
+		if mdRule.MatchString(line) {
+			flushParagraph()
+			closeList()
+			b.WriteString("<hr>\n")
+			continue
+		}
+		if m := mdUnordered.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			openList("ul")
+			b.WriteString("<li>" + markdownInline(m[1]) + "</li>\n")
+			continue
+		}
+		if m := mdOrdered.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			openList("ol")
+			b.WriteString("<li>" + markdownInline(m[1]) + "</li>\n")
+			continue
+		}
+		if m := mdQuote.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			closeList()
+			quote := []string{m[1]}
+			for i+1 < len(lines) && mdQuote.MatchString(lines[i+1]) {
+				i++
+				quote = append(quote, mdQuote.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote><p>" + markdownInline(strings.Join(quote, "\n")) + "</p></blockquote>\n")
+			continue
+		}
+		closeList()
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+	flushParagraph()
+	closeList()
+	return b.String()
+}
+
+// markdownInline renders inline syntax of s. Code spans are kept as is
+func markdownInline(s string) string {
+	parts := strings.Split(s, "`")
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 {
+			if i < len(parts)-1 {
+				b.WriteString("<code>" + html.EscapeString(part) + "</code>")
+				continue
+			}
+			// unmatched backtick
+			b.WriteString("`")
+		}
+		b.WriteString(markdownSpans(html.EscapeString(part)))
+	}
+	return b.String()
+}
+
+// markdownSpans renders links, images and emphasis of escaped s
+func markdownSpans(s string) string {
+	s = mdImage.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdImage.FindStringSubmatch(m)
+		if mdUnsafeLinks.MatchString(sub[2]) {
+			return sub[1]
+		}
+		return `<img src="` + sub[2] + `" alt="` + sub[1] + `">`
+	})
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdLink.FindStringSubmatch(m)
+		if mdUnsafeLinks.MatchString(sub[2]) {
+			return sub[1]
+		}
+		return `<a href="` + sub[2] + `">` + sub[1] + `</a>`
+	})
+	s = mdStrong.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = mdEmphasis.ReplaceAllString(s, "<em>$1</em>")
+	s = mdStrike.ReplaceAllString(s, "<del>$1</del>")
+	return s
+}
@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", ""},
+		{"hello\nworld", "<p>hello\nworld</p>\n"},
+		{"# Title #\n\ntext", "<h1>Title</h1>\n<p>text</p>\n"},
+		{"### **bold** title", "<h3><strong>bold</strong> title</h3>\n"},
+		{"a *b* __c__ ~~d~~ `*e*`", "<p>a <em>b</em> <strong>c</strong> <del>d</del> <code>*e*</code></p>\n"},
+		{"snake_case_name", "<p>snake_case_name</p>\n"},
+		{"1 * 2 * 3", "<p>1 * 2 * 3</p>\n"},
+		{"a `b", "<p>a `b</p>\n"},
+		{"<script>&", "<p>&lt;script&gt;&amp;</p>\n"},
+		{"[link](https://a.com/?x=1&y=2)", `<p><a href="https://a.com/?x=1&amp;y=2">link</a></p>` + "\n"},
+		{"[bad](JavaScript:evil)", "<p>bad</p>\n"},
+		{"![cat](https://a.com/cat.png)", `<p><img src="https://a.com/cat.png" alt="cat"></p>` + "\n"},
+		{"- a\n- b\n1. c", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>\n"},
+		{"text\n- item", "<p>text</p>\n<ul>\n<li>item</li>\n</ul>\n"},
+		{"> a\n> b", "<blockquote><p>a\nb</p></blockquote>\n"},
+		{"---", "<hr>\n"},
+		{"* * *", "<hr>\n"},
+		{"```go\nif a < b {\n\n}\n```\nafter", "<pre><code class=\"language-go\">if a &lt; b {\n\n}</code></pre>\n<p>after</p>\n"},
+		{"```\nunclosed", "<pre><code>unclosed</code></pre>\n"},
+		{"a\r\nb", "<p>a\nb</p>\n"},
+	}
+	for _, test := range tests {
+		if got := MarkdownToHTML(test.src); got != test.want {
+			t.Errorf("MarkdownToHTML(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
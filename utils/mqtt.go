@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types.
+// http://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPingreq    = 12
+	mqttDisconnect = 14
+)
+
+const mqttMaxRemainingLength = 268435455
+
+var errMQTTMalformed = errors.New("malformed mqtt packet")
+
+// MQTTOptions are options of CONNECT packet
+type MQTTOptions struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+}
+
+// MQTTClient is a minimal MQTT 3.1.1 client which publishes messages at
+// QoS 0. Packets sent by broker after CONNACK are discarded
+type MQTTClient struct {
+	conn net.Conn
+	mu   sync.Mutex
+	done chan struct{}
+	err  error
+}
+
+// NewMQTTClient connects to broker over conn and waits for CONNACK
+func NewMQTTClient(conn net.Conn, opts MQTTOptions) (*MQTTClient, error) {
+	client := &MQTTClient{conn: conn, done: make(chan struct{})}
+	if _, err := conn.Write(mqttConnectPacket(opts)); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	packetType, body, err := readMQTTPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if packetType != mqttConnack || len(body) != 2 {
+		return nil, errMQTTMalformed
+	}
+	if body[1] != 0 {
+		return nil, fmt.Errorf("mqtt connection refused: return code %d", body[1])
+	}
+	go client.discard(r)
+	return client, nil
+}
+
+// discard reads packets until connection is closed, so that broker never
+// blocks on writing PINGRESP
+func (c *MQTTClient) discard(r *bufio.Reader) {
+	var err error
+	for err == nil {
+		_, _, err = readMQTTPacket(r)
+	}
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// Done is closed when connection is lost
+func (c *MQTTClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Publish sends message to topic at QoS 0
+func (c *MQTTClient) Publish(topic string, payload []byte, retain bool) error {
+	var flags byte
+	if retain {
+		flags = 1
+	}
+	body := appendMQTTString(nil, topic)
+	body = append(body, payload...)
+	return c.write(mqttPublish<<4|flags, body)
+}
+
+// Ping sends PINGREQ to keep connection alive
+func (c *MQTTClient) Ping() error {
+	return c.write(mqttPingreq<<4, nil)
+}
+
+// Close sends DISCONNECT and closes connection
+func (c *MQTTClient) Close() error {
+	c.write(mqttDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+func (c *MQTTClient) write(header byte, body []byte) error {
+	packet, err := mqttPacket(header, body)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	_, err = c.conn.Write(packet)
+	return err
+}
+
+func mqttConnectPacket(opts MQTTOptions) []byte {
+	// clean session
+	var flags byte = 0x02
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+	keepAlive := uint16(opts.KeepAlive / time.Second)
+
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendMQTTString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = appendMQTTString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendMQTTString(body, opts.Password)
+	}
+	packet, _ := mqttPacket(mqttConnect<<4, body)
+	return packet
+}
+
+func mqttPacket(header byte, body []byte) ([]byte, error) {
+	length := len(body)
+	if length > mqttMaxRemainingLength {
+		return nil, errors.New("mqtt packet is too large")
+	}
+	packet := []byte{header}
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...), nil
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTPacket returns type and body of next packet
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errMQTTMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMQTTPacketLength(t *testing.T) {
+	tests := []struct {
+		length int
+		want   []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+	}
+	for _, test := range tests {
+		packet, err := mqttPacket(mqttPublish<<4, make([]byte, test.length))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := packet[1 : 1+len(test.want)]; !bytes.Equal(got, test.want) {
+			t.Errorf("remaining length of %d = % x, want % x", test.length, got, test.want)
+		}
+		packetType, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(packet)))
+		if err != nil || packetType != mqttPublish || len(body) != test.length {
+			t.Errorf("readMQTTPacket() = %d, %d bytes, %v", packetType, len(body), err)
+		}
+	}
+}
+
+func TestMQTTClient(t *testing.T) {
+	clientConn, brokerConn := net.Pipe()
+	defer brokerConn.Close()
+
+	published := make(chan []byte, 1)
+	go func() {
+		r := bufio.NewReader(brokerConn)
+		packetType, body, err := readMQTTPacket(r)
+		if err != nil || packetType != mqttConnect {
+			t.Errorf("expected CONNECT, got %d, %v", packetType, err)
+			return
+		}
+		want := []byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0xc2, 0, 30, 0, 2, 'i', 'd', 0, 1, 'u', 0, 1, 'p'}
+		if !bytes.Equal(body, want) {
+			t.Errorf("CONNECT = % x, want % x", body, want)
+		}
+		brokerConn.Write([]byte{mqttConnack << 4, 2, 0, 0})
+		packetType, body, err = readMQTTPacket(r)
+		if err != nil || packetType != mqttPublish {
+			t.Errorf("expected PUBLISH, got %d, %v", packetType, err)
+			return
+		}
+		published <- body
+		// drain DISCONNECT
+		io.Copy(ioutil.Discard, r)
+	}()
+
+	client, err := NewMQTTClient(clientConn, MQTTOptions{ClientID: "id", Username: "u", Password: "p", KeepAlive: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Publish("a/b", []byte("hi"), false); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 3, 'a', '/', 'b', 'h', 'i'}
+	if body := <-published; !bytes.Equal(body, want) {
+		t.Errorf("PUBLISH = % x, want % x", body, want)
+	}
+}
+
+func TestMQTTClientRefused(t *testing.T) {
+	clientConn, brokerConn := net.Pipe()
+	defer brokerConn.Close()
+	go func() {
+		readMQTTPacket(bufio.NewReader(brokerConn))
+		brokerConn.Write([]byte{mqttConnack << 4, 2, 0, 5})
+	}()
+	if _, err := NewMQTTClient(clientConn, MQTTOptions{ClientID: "id"}); err == nil {
+		t.Error("connection should be refused")
+	}
+}
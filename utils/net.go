@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// LocalIPs returns all non-loopback unicast addresses of local network interfaces
+func LocalIPs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP)
+	}
+	return ips, nil
+}
+
+// ParseCIDRs parses list of CIDR notations. A single ip address is treated
+// as a network that contains only itself
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address: %s", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ContainsIP reports whether ip is in any of nets
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var privateNets, _ = ParseCIDRs([]string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"127.0.0.0/8",
+	"fc00::/7",
+	"fe80::/10",
+	"::1",
+})
+
+// IsPrivateIP reports whether ip is a private (RFC 1918, RFC 4193),
+// link-local or loopback address
+func IsPrivateIP(ip net.IP) bool {
+	return ip != nil && ContainsIP(privateNets, ip)
+}
+
+// sharedNets are not private but not public either: shared address space of
+// carrier-grade NAT, which Tailscale uses as well (RFC 6598), and "this"
+// network (RFC 1122)
+var sharedNets, _ = ParseCIDRs([]string{
+	"100.64.0.0/10",
+	"0.0.0.0/8",
+})
+
+// IsInternalIP reports whether ip is a private address, or one of carrier-grade
+// NAT or "this" network, which is not reachable from the internet either
+func IsInternalIP(ip net.IP) bool {
+	return IsPrivateIP(ip) || ip != nil && ContainsIP(sharedNets, ip)
+}
+
+// ForwardedIP returns ip of client of a request proxied by trusted proxies.
+// X-Forwarded-For is walked from right to left, the first address not of a
+// trusted proxy is the client, since addresses on its left can be forged.
+// X-Real-IP is used if X-Forwarded-For is absent. remote is returned if
+// it's not a trusted proxy or headers are invalid
+func ForwardedIP(remote net.IP, forwardedFor, realIP string, trusted []*net.IPNet) net.IP {
+	if !ContainsIP(trusted, remote) {
+		return remote
+	}
+	if forwardedFor == "" {
+		if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+			return ip
+		}
+		return remote
+	}
+	client := remote
+	items := strings.Split(forwardedFor, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(items[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !ContainsIP(trusted, ip) {
+			break
+		}
+	}
+	return client
+}
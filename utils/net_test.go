@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestContainsIP(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"192.168.1.0/24", "10.0.0.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseCIDRs() error = %v", err)
+	}
+
+	tcs := []struct {
+		input string
+		want  bool
+	}{
+		{"192.168.1.20", true},
+		{"192.168.2.20", false},
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"::ffff:10.0.0.1", true},
+		{"fd12::1", true},
+		{"fe80::1", false},
+	}
+
+	for _, tc := range tcs {
+		got := ContainsIP(nets, net.ParseIP(tc.input))
+		if got != tc.want {
+			t.Errorf("ContainsIP(%s) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestParseCIDRsInvalid(t *testing.T) {
+	for _, input := range []string{"192.168.1.256", "10.0.0.0/33", "foo"} {
+		if _, err := ParseCIDRs([]string{input}); err == nil {
+			t.Errorf("ParseCIDRs(%s) should return error", input)
+		}
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	tcs := []struct {
+		input string
+		want  bool
+	}{
+		{"192.168.1.2", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"169.254.1.1", true},
+		{"127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::1", true},
+		{"2001:db8::1", false},
+	}
+
+	for _, tc := range tcs {
+		got := IsPrivateIP(net.ParseIP(tc.input))
+		if got != tc.want {
+			t.Errorf("IsPrivateIP(%s) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestIsInternalIP(t *testing.T) {
+	tcs := []struct {
+		input string
+		want  bool
+	}{
+		{"192.168.1.2", true},
+		{"127.0.0.1", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"100.128.0.1", false},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"8.8.8.8", false},
+		{"fd00::1", true},
+		{"2001:db8::1", false},
+	}
+
+	for _, tc := range tcs {
+		got := IsInternalIP(net.ParseIP(tc.input))
+		if got != tc.want {
+			t.Errorf("IsInternalIP(%s) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestForwardedIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		remote       string
+		forwardedFor string
+		realIP       string
+		want         string
+	}{
+		{"192.168.1.2", "1.2.3.4", "", "192.168.1.2"},
+		{"127.0.0.1", "", "", "127.0.0.1"},
+		{"127.0.0.1", "", "1.2.3.4", "1.2.3.4"},
+		{"127.0.0.1", "1.2.3.4", "5.6.7.8", "1.2.3.4"},
+		{"127.0.0.1", "6.6.6.6, 1.2.3.4, 10.0.0.2", "", "1.2.3.4"},
+		{"127.0.0.1", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"127.0.0.1", "foo, 10.0.0.2", "", "10.0.0.2"},
+	}
+
+	for _, tc := range tcs {
+		got := ForwardedIP(net.ParseIP(tc.remote), tc.forwardedFor, tc.realIP, trusted)
+		if !got.Equal(net.ParseIP(tc.want)) {
+			t.Errorf("ForwardedIP(%s, %q, %q) = %v, want %s", tc.remote, tc.forwardedFor, tc.realIP, got, tc.want)
+		}
+	}
+}
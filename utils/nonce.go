@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceCache remembers nonces for a period of time to detect replays
+type NonceCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	nonces map[string]time.Time
+}
+
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{ttl: ttl, nonces: make(map[string]time.Time)}
+}
+
+// Use records nonce and reports whether it has not been used before
+func (n *NonceCache) Use(nonce string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	for k, expiresAt := range n.nonces {
+		if now.After(expiresAt) {
+			delete(n.nonces, k)
+		}
+	}
+	if _, ok := n.nonces[nonce]; ok {
+		return false
+	}
+	n.nonces[nonce] = now.Add(n.ttl)
+	return true
+}
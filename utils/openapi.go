@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// OpenAPI 3 document builder.
+// https://spec.openapis.org/oas/v3.0.3
+const openAPIVersion = "3.0.3"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// OneOf is a body of any of its types, e.g. when body is chosen by a header
+type OneOf []interface{}
+
+// OpenAPIParameter is a header, query or path parameter of an operation
+type OpenAPIParameter struct {
+	Name        string
+	In          string // header, query or path
+	Description string
+	Required    bool
+}
+
+// OpenAPIOperation describes a route. Schemas of Request and Response are
+// generated from their go types by json tags, binary bodies are described
+// by RequestType or ResponseType only
+type OpenAPIOperation struct {
+	Method       string
+	Path         string // gin style, :name is converted to {name}
+	Summary      string
+	Parameters   []OpenAPIParameter
+	RequestType  string      // content type of request body, defaults to application/json
+	Request      interface{} // value of request body type, nil if request has no json body
+	ResponseType string      // content type of response body, defaults to application/json
+	Response     interface{} // value of response body type, nil if response has no json body
+	Error        interface{} // value of error body type, defaults to type of SetError
+	Public       bool        // no security requirements
+}
+
+// OpenAPI builds an OpenAPI document from operations
+type OpenAPI struct {
+	title           string
+	version         string
+	servers         []interface{}
+	paths           map[string]map[string]interface{}
+	schemas         map[string]interface{}
+	securitySchemes map[string]interface{}
+	security        []interface{}
+	errorSchema     map[string]interface{}
+}
+
+// NewOpenAPI returns an empty document of api title and version
+func NewOpenAPI(title, version string) *OpenAPI {
+	return &OpenAPI{
+		title:           title,
+		version:         version,
+		paths:           make(map[string]map[string]interface{}),
+		schemas:         make(map[string]interface{}),
+		securitySchemes: make(map[string]interface{}),
+	}
+}
+
+// AddServer adds base url of api
+func (d *OpenAPI) AddServer(url string) {
+	d.servers = append(d.servers, map[string]interface{}{"url": url})
+}
+
+// AddHeaderSecurity adds an api key scheme sent in header. Schemes are
+// alternatives, any of them satisfies security requirement
+func (d *OpenAPI) AddHeaderSecurity(name, header, description string) {
+	d.securitySchemes[name] = map[string]interface{}{
+		"type":        "apiKey",
+		"in":          "header",
+		"name":        header,
+		"description": description,
+	}
+	d.security = append(d.security, map[string]interface{}{name: []string{}})
+}
+
+// SetError sets body type of error responses
+func (d *OpenAPI) SetError(v interface{}) {
+	d.errorSchema = d.schema(reflect.TypeOf(v))
+}
+
+// Add adds an operation to document
+func (d *OpenAPI) Add(op OpenAPIOperation) {
+	path, names := openAPIPath(op.Path)
+	operation := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": operationID(op.Method, op.Path),
+	}
+
+	parameters := make([]interface{}, 0, len(op.Parameters)+len(names))
+	for _, name := range names {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range op.Parameters {
+		parameter := map[string]interface{}{
+			"name":     p.Name,
+			"in":       p.In,
+			"required": p.Required,
+			"schema":   map[string]interface{}{"type": "string"},
+		}
+		if p.Description != "" {
+			parameter["description"] = p.Description
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if op.Request != nil || op.RequestType != "" {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  d.content(op.RequestType, op.Request),
+		}
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	if op.Response != nil || op.ResponseType != "" {
+		success["content"] = d.content(op.ResponseType, op.Response)
+	}
+	responses := map[string]interface{}{"200": success}
+	errorSchema := d.errorSchema
+	if op.Error != nil {
+		errorSchema = d.schema(reflect.TypeOf(op.Error))
+	}
+	if errorSchema != nil {
+		responses["default"] = map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": errorSchema},
+			},
+		}
+	}
+	operation["responses"] = responses
+	if op.Public {
+		operation["security"] = []interface{}{}
+	}
+
+	if d.paths[path] == nil {
+		d.paths[path] = make(map[string]interface{})
+	}
+	d.paths[path][strings.ToLower(op.Method)] = operation
+}
+
+// MarshalJSON encodes document as json
+func (d *OpenAPI) MarshalJSON() ([]byte, error) {
+	doc := map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info":    map[string]interface{}{"title": d.title, "version": d.version},
+		"paths":   d.paths,
+		"components": map[string]interface{}{
+			"schemas":         d.schemas,
+			"securitySchemes": d.securitySchemes,
+		},
+	}
+	if len(d.servers) > 0 {
+		doc["servers"] = d.servers
+	}
+	if len(d.security) > 0 {
+		doc["security"] = d.security
+	}
+	return json.Marshal(doc)
+}
+
+func (d *OpenAPI) content(contentType string, v interface{}) map[string]interface{} {
+	media := map[string]interface{}{}
+	if oneOf, ok := v.(OneOf); ok {
+		schemas := make([]interface{}, 0, len(oneOf))
+		for _, v := range oneOf {
+			schemas = append(schemas, d.schema(reflect.TypeOf(v)))
+		}
+		media["schema"] = map[string]interface{}{"oneOf": schemas}
+	} else if v != nil {
+		media["schema"] = d.schema(reflect.TypeOf(v))
+	} else {
+		media["schema"] = map[string]interface{}{"type": "string", "format": "binary"}
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return map[string]interface{}{contentType: media}
+}
+
+// schema returns schema of t. Named structs are added to components and
+// referenced
+func (d *OpenAPI) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return d.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": d.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.object(t)
+		}
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := d.schemas[t.Name()]; !ok {
+			// placeholder stops recursion of self referencing types
+			d.schemas[t.Name()] = nil
+			d.schemas[t.Name()] = d.object(t)
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// object returns object schema of struct t by json tags, fields without
+// omitempty are required. Fields of embedded structs are promoted
+func (d *OpenAPI) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options := tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, options = tag[:i], tag[i+1:]
+			}
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				add(field.Type)
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = d.schema(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	add(t)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// openAPIPath converts gin path params to OpenAPI templates and returns
+// names of them
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	names := make([]string, 0)
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// operationID derives an identifier like getFilesIndex from method and path
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		id += strings.ToUpper(word[:1]) + word[1:]
+	}
+	if id == strings.ToLower(method) {
+		id += "Root"
+	}
+	return id
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testPageInfo struct {
+	Total int `json:"total"`
+}
+
+type testItem struct {
+	Name     string     `json:"name"`
+	Size     int64      `json:"size,omitempty"`
+	Modified time.Time  `json:"modified"`
+	Children []testItem `json:"children"`
+	internal string
+}
+
+type testPage struct {
+	Data []testItem `json:"data"`
+	testPageInfo
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	path, names := openAPIPath("/uploads/:id/finalize")
+	if path != "/uploads/{id}/finalize" || !reflect.DeepEqual(names, []string{"id"}) {
+		t.Errorf("openAPIPath() = %q, %v", path, names)
+	}
+	if id := operationID("GET", "/v2/files/:index"); id != "getV2FilesIndex" {
+		t.Errorf("operationID() = %q", id)
+	}
+	if id := operationID("POST", "/"); id != "postRoot" {
+		t.Errorf("operationID() = %q", id)
+	}
+}
+
+func TestOpenAPISchema(t *testing.T) {
+	doc := NewOpenAPI("test", "1")
+	doc.Add(OpenAPIOperation{Method: "GET", Path: "/items/:id", Response: testPage{}})
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{}
+				Required   []string
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Paths["/items/{id}"]["get"]; !ok {
+		t.Fatalf("paths = %v", got.Paths)
+	}
+
+	page := got.Components.Schemas["testPage"]
+	if _, ok := page.Properties["total"]; !ok {
+		t.Errorf("embedded field not promoted: %v", page.Properties)
+	}
+	item := got.Components.Schemas["testItem"]
+	if !reflect.DeepEqual(item.Required, []string{"name", "modified", "children"}) {
+		t.Errorf("required = %v", item.Required)
+	}
+	if len(item.Properties) != 4 {
+		t.Errorf("properties = %v", item.Properties)
+	}
+	if item.Properties["modified"]["format"] != "date-time" || item.Properties["size"]["format"] != "int64" {
+		t.Errorf("properties = %v", item.Properties)
+	}
+}
@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 is the only format iOS accepts for installing a client identity.
+// Key bag is encrypted by pbeWithSHAAnd3-KeyTripleDES-CBC and the whole
+// store is protected by a HMAC-SHA1 mac, which is supported by both iOS and
+// Windows. https://tools.ietf.org/html/rfc7292
+
+var (
+	oidDataContentType       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCertBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS8ShroudedKeyBag   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertTypeX509          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidFriendlyName          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidPBEWithSHAAnd3KeyTDES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidSHA1                  = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+const pkcs12Iterations = 2048
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm algorithmIdentifier
+	Digest    []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+// EncodePKCS12 encodes private key and certificate chain as a password
+// protected PKCS#12 file which can be installed on iOS and Windows
+func EncodePKCS12(privateKey interface{}, cert *x509.Certificate, caCerts []*x509.Certificate, friendlyName, password string) ([]byte, error) {
+	encodedPassword := bmpString(password)
+
+	keyID := sha1.Sum(cert.Raw)
+	localKeyIDAttr, err := newAttribute(oidLocalKeyID, keyID[:])
+	if err != nil {
+		return nil, err
+	}
+	friendlyNameAttr, err := newAttribute(oidFriendlyName, asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(friendlyName)[:len(bmpString(friendlyName))-2]})
+	if err != nil {
+		return nil, err
+	}
+
+	certBags := make([]safeBag, 0, len(caCerts)+1)
+	bag, err := newCertBag(cert)
+	if err != nil {
+		return nil, err
+	}
+	bag.Attributes = []pkcs12Attribute{localKeyIDAttr, friendlyNameAttr}
+	certBags = append(certBags, bag)
+	for _, caCert := range caCerts {
+		bag, err := newCertBag(caCert)
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, bag)
+	}
+
+	keyBag, err := newShroudedKeyBag(privateKey, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	keyBag.Attributes = []pkcs12Attribute{localKeyIDAttr, friendlyNameAttr}
+
+	certContentInfo, err := newDataContentInfo(certBags)
+	if err != nil {
+		return nil, err
+	}
+	keyContentInfo, err := newDataContentInfo([]safeBag{keyBag})
+	if err != nil {
+		return nil, err
+	}
+	authenticatedSafe, err := asn1.Marshal([]contentInfo{certContentInfo, keyContentInfo})
+	if err != nil {
+		return nil, err
+	}
+	authSafeContent, err := asn1.Marshal(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	macSalt := make([]byte, 8)
+	if _, err := rand.Read(macSalt); err != nil {
+		return nil, err
+	}
+	macKey := pkcs12KDF(macSalt, encodedPassword, pkcs12Iterations, 3, sha1.Size)
+	mac := hmac.New(sha1.New, macKey)
+	mac.Write(authenticatedSafe)
+
+	pfx := pfxPdu{
+		Version: 3,
+		AuthSafe: contentInfo{
+			ContentType: oidDataContentType,
+			Content:     explicitValue(authSafeContent),
+		},
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: algorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    macSalt,
+			Iterations: pkcs12Iterations,
+		},
+	}
+	return asn1.Marshal(pfx)
+}
+
+func newAttribute(id asn1.ObjectIdentifier, value interface{}) (pkcs12Attribute, error) {
+	valueBytes, err := asn1.Marshal(value)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{ID: id, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: valueBytes}}, nil
+}
+
+func newCertBag(cert *x509.Certificate) (safeBag, error) {
+	bagBytes, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: cert.Raw})
+	if err != nil {
+		return safeBag{}, err
+	}
+	return safeBag{ID: oidCertBag, Value: explicitValue(bagBytes)}, nil
+}
+
+func newShroudedKeyBag(privateKey interface{}, encodedPassword []byte) (safeBag, error) {
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return safeBag{}, err
+	}
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: pkcs12Iterations})
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	key := pkcs12KDF(salt, encodedPassword, pkcs12Iterations, 1, 24)
+	iv := pkcs12KDF(salt, encodedPassword, pkcs12Iterations, 2, des.BlockSize)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return safeBag{}, err
+	}
+	encrypted := pkcs7Pad(pkcs8Key, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	keyInfo, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTDES, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		return safeBag{}, err
+	}
+	return safeBag{ID: oidPKCS8ShroudedKeyBag, Value: explicitValue(keyInfo)}, nil
+}
+
+func newDataContentInfo(bags []safeBag) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	data, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{
+		ContentType: oidDataContentType,
+		Content:     explicitValue(data),
+	}, nil
+}
+
+// explicitValue wraps DER encoded bytes with an explicit [0] tag
+func explicitValue(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	padded := make([]byte, len(data), len(data)+padding)
+	copy(padded, data)
+	for i := 0; i < padding; i++ {
+		padded = append(padded, byte(padding))
+	}
+	return padded
+}
+
+// bmpString returns password as null terminated UTF-16BE bytes
+func bmpString(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, 0, len(encoded)*2+2)
+	for _, r := range encoded {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return append(b, 0, 0)
+}
+
+// pkcs12KDF derives key material from password with SHA-1.
+// https://tools.ietf.org/html/rfc7292#appendix-B.2
+func pkcs12KDF(salt, password []byte, iterations int, id byte, size int) []byte {
+	const u = sha1.Size
+	const v = 64
+
+	fill := func(src []byte) []byte {
+		if len(src) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(src)+v-1)/v))
+		for i := range out {
+			out[i] = src[i%len(src)]
+		}
+		return out
+	}
+
+	D := make([]byte, v)
+	for i := range D {
+		D[i] = id
+	}
+	I := append(fill(salt), fill(password)...)
+
+	one := big.NewInt(1)
+	result := make([]byte, 0, size+u)
+	for len(result) < size {
+		h := sha1.New()
+		h.Write(D)
+		h.Write(I)
+		A := h.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			sum := sha1.Sum(A)
+			A = sum[:]
+		}
+		result = append(result, A...)
+
+		B := new(big.Int).SetBytes(fill(A)[:v])
+		B.Add(B, one)
+		for j := 0; j < len(I); j += v {
+			Ij := new(big.Int).SetBytes(I[j : j+v])
+			Ij.Add(Ij, B)
+			IjBytes := Ij.Bytes()
+			if len(IjBytes) > v {
+				IjBytes = IjBytes[len(IjBytes)-v:]
+			}
+			block := I[j : j+v]
+			for k := range block {
+				block[k] = 0
+			}
+			copy(block[v-len(IjBytes):], IjBytes)
+		}
+	}
+	return result[:size]
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+func newTestClientCert(t *testing.T) (*x509.Certificate, *x509.Certificate, interface{}) {
+	dir, err := ioutil.TempDir("", "pkcs12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	if err := GenerateCA(certFile, keyFile, false); err != nil {
+		t.Fatal(err)
+	}
+	caCert, caKey, err := LoadCA(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, key, err := IssueClientCert(caCert, caKey, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return caCert, cert, key
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	_, cert, key := newTestClientCert(t)
+	p12, err := EncodePKCS12(key, cert, nil, "client", "密码 password")
+	if err != nil {
+		t.Fatalf("EncodePKCS12() error = %v", err)
+	}
+
+	decodedKey, decodedCert, err := pkcs12.Decode(p12, "密码 password")
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !bytes.Equal(decodedCert.Raw, cert.Raw) {
+		t.Error("decoded certificate differs")
+	}
+	want, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := x509.MarshalPKCS8PrivateKey(decodedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("decoded private key differs")
+	}
+
+	if _, _, err := pkcs12.Decode(p12, "wrong"); err != pkcs12.ErrIncorrectPassword {
+		t.Errorf("Decode() with wrong password error = %v, want %v", err, pkcs12.ErrIncorrectPassword)
+	}
+}
+
+func TestEncodePKCS12Chain(t *testing.T) {
+	caCert, cert, key := newTestClientCert(t)
+	p12, err := EncodePKCS12(key, cert, []*x509.Certificate{caCert}, "client", "")
+	if err != nil {
+		t.Fatalf("EncodePKCS12() error = %v", err)
+	}
+
+	blocks, err := pkcs12.ToPEM(p12, "")
+	if err != nil {
+		t.Fatalf("ToPEM() error = %v", err)
+	}
+	var certs [][]byte
+	keys := 0
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			certs = append(certs, block.Bytes)
+		case "PRIVATE KEY":
+			keys++
+			if name := block.Headers["friendlyName"]; name != "client" {
+				t.Errorf("friendlyName of key = %q, want client", name)
+			}
+		}
+	}
+	if keys != 1 {
+		t.Errorf("got %d private keys, want 1", keys)
+	}
+	if len(certs) != 2 || !bytes.Equal(certs[0], cert.Raw) || !bytes.Equal(certs[1], caCert.Raw) {
+		t.Errorf("got %d certificates, want certificate followed by ca certificate", len(certs))
+	}
+}
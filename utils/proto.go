@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Protocol buffers wire types.
+// https://developers.google.com/protocol-buffers/docs/encoding
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+var errInvalidProto = errors.New("invalid protobuf message")
+
+// ProtoBuffer builds a protobuf message field by field
+type ProtoBuffer struct {
+	buf []byte
+}
+
+func (b *ProtoBuffer) appendVarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	b.buf = append(b.buf, tmp[:n]...)
+}
+
+func (b *ProtoBuffer) appendTag(field, wireType int) {
+	b.appendVarint(uint64(field)<<3 | uint64(wireType))
+}
+
+// AppendVarint appends an integer field
+func (b *ProtoBuffer) AppendVarint(field int, v uint64) {
+	b.appendTag(field, WireVarint)
+	b.appendVarint(v)
+}
+
+// AppendBytes appends a bytes or embedded message field
+func (b *ProtoBuffer) AppendBytes(field int, v []byte) {
+	b.appendTag(field, WireBytes)
+	b.appendVarint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+// AppendString appends a string field
+func (b *ProtoBuffer) AppendString(field int, v string) {
+	b.appendTag(field, WireBytes)
+	b.appendVarint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+// Bytes returns encoded message
+func (b *ProtoBuffer) Bytes() []byte {
+	return b.buf
+}
+
+// ProtoField is a field of a decoded protobuf message. Varint holds value of
+// varint and fixed fields, Bytes holds value of length-delimited fields
+type ProtoField struct {
+	Number   int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+// ParseProto decodes fields of message in wire order
+func ParseProto(data []byte) ([]ProtoField, error) {
+	fields := make([]ProtoField, 0)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errInvalidProto
+		}
+		data = data[n:]
+		field := ProtoField{Number: int(tag >> 3), WireType: int(tag & 7)}
+		if field.Number == 0 {
+			return nil, errInvalidProto
+		}
+		switch field.WireType {
+		case WireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errInvalidProto
+			}
+			field.Varint = v
+			data = data[n:]
+		case WireFixed64:
+			if len(data) < 8 {
+				return nil, errInvalidProto
+			}
+			field.Varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case WireFixed32:
+			if len(data) < 4 {
+				return nil, errInvalidProto
+			}
+			field.Varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case WireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return nil, errInvalidProto
+			}
+			field.Bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			// groups are deprecated and never used by our messages
+			return nil, errInvalidProto
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+// examples from https://developers.google.com/protocol-buffers/docs/encoding
+func TestProtoBuffer(t *testing.T) {
+	var b ProtoBuffer
+	b.AppendVarint(1, 150)
+	b.AppendString(2, "testing")
+	want := []byte{0x08, 0x96, 0x01, 0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("Bytes() = % x, want % x", b.Bytes(), want)
+	}
+}
+
+func TestParseProto(t *testing.T) {
+	var inner ProtoBuffer
+	inner.AppendBytes(2, []byte{0, 1, 2})
+	var b ProtoBuffer
+	b.AppendVarint(1, 300)
+	b.AppendBytes(3, inner.Bytes())
+
+	fields, err := ParseProto(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("got %d fields, want 2", len(fields))
+	}
+	if fields[0].Number != 1 || fields[0].WireType != WireVarint || fields[0].Varint != 300 {
+		t.Errorf("unexpected field %+v", fields[0])
+	}
+	if fields[1].Number != 3 || fields[1].WireType != WireBytes || !bytes.Equal(fields[1].Bytes, inner.Bytes()) {
+		t.Errorf("unexpected field %+v", fields[1])
+	}
+}
+
+func TestParseProtoInvalid(t *testing.T) {
+	tests := [][]byte{
+		{0x08},             // missing varint
+		{0x12, 0x05, 'a'},  // truncated bytes
+		{0x00, 0x01},       // field number 0
+		{0x0b},             // group
+		{0x0d, 0x01, 0x02}, // truncated fixed32
+	}
+	for _, test := range tests {
+		if _, err := ParseProto(test); err == nil {
+			t.Errorf("ParseProto(% x) should fail", test)
+		}
+	}
+}
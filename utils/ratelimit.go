@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	updateAt time.Time
+}
+
+// RateLimiter is a token bucket rate limiter keyed by string
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewRateLimiter returns a limiter allows rate requests per second with
+// bursts of at most burst requests for each key
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether a request of key may happen now. If not, it also
+// returns how long to wait before next request is allowed
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		l.cleanup(now)
+		b = &bucket{tokens: l.burst, updateAt: now}
+		l.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.updateAt).Seconds()
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+		b.updateAt = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// cleanup drops buckets which have been refilled completely
+func (l *RateLimiter) cleanup(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updateAt).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
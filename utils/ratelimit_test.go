@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	tcs := []struct {
+		key     string
+		advance time.Duration
+		want    bool
+	}{
+		{"foo", 0, true},
+		{"foo", 0, true},
+		{"foo", 0, false},
+		{"bar", 0, true},
+		{"foo", 500 * time.Millisecond, false},
+		{"foo", 500 * time.Millisecond, true},
+		{"foo", 0, false},
+		{"foo", 10 * time.Second, true},
+		{"foo", 0, true},
+		{"foo", 0, false},
+	}
+
+	for i, tc := range tcs {
+		now = now.Add(tc.advance)
+		got, _ := limiter.Allow(tc.key)
+		if got != tc.want {
+			t.Errorf("#%d Allow(%s) = %v, want %v", i, tc.key, got, tc.want)
+		}
+	}
+}
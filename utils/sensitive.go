@@ -0,0 +1,49 @@
+package utils
+
+import "regexp"
+
+const RedactedText = "[已隐藏]"
+
+// DefaultSensitivePatterns matches passwords, api tokens, credit card numbers and TOTP codes
+var DefaultSensitivePatterns = []string{
+	`(?i)(password|passwd|pwd|密码)\s*[:=：]\s*\S+`,
+	`\b(?:sk|pk|ghp|gho|ghs|github_pat|xox[abprs])[-_][A-Za-z0-9_-]{16,}\b`,
+	`\bAKIA[0-9A-Z]{16}\b`,
+	`\b(?:\d{4}[ -]?){3}\d{4}\b`,
+	`^\s*\d{6}\s*$`,
+}
+
+// SensitiveDetector detects sensitive content by regular expressions
+type SensitiveDetector struct {
+	patterns []*regexp.Regexp
+}
+
+func NewSensitiveDetector(patterns []string) (*SensitiveDetector, error) {
+	detector := &SensitiveDetector{patterns: make([]*regexp.Regexp, 0, len(patterns))}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		detector.patterns = append(detector.patterns, re)
+	}
+	return detector, nil
+}
+
+// Match reports whether text contains sensitive content
+func (d *SensitiveDetector) Match(text string) bool {
+	for _, re := range d.patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces all sensitive content in text with RedactedText
+func (d *SensitiveDetector) Redact(text string) string {
+	for _, re := range d.patterns {
+		text = re.ReplaceAllLiteralString(text, RedactedText)
+	}
+	return text
+}
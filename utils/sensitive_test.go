@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestSensitiveDetector(t *testing.T) {
+	detector, err := NewSensitiveDetector(DefaultSensitivePatterns)
+	if err != nil {
+		t.Fatalf("NewSensitiveDetector() error = %v", err)
+	}
+
+	tcs := []struct {
+		input  string
+		match  bool
+		redact string
+	}{
+		{"hello world", false, "hello world"},
+		{"password: hunter2", true, RedactedText},
+		{"wifi 密码：12345678 thanks", true, "wifi " + RedactedText + " thanks"},
+		{"token ghp_0123456789abcdefABCDEF", true, "token " + RedactedText},
+		{"card 4111 1111 1111 1111", true, "card " + RedactedText},
+		{" 123456\n", true, RedactedText},
+		{"order 123456 shipped", false, "order 123456 shipped"},
+	}
+
+	for _, tc := range tcs {
+		if got := detector.Match(tc.input); got != tc.match {
+			t.Errorf("Match(%q) = %v, want %v", tc.input, got, tc.match)
+		}
+		if got := detector.Redact(tc.input); got != tc.redact {
+			t.Errorf("Redact(%q) = %q, want %q", tc.input, got, tc.redact)
+		}
+	}
+}
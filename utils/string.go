@@ -1,6 +1,10 @@
 package utils
 
-import "math/rand"
+import (
+	crand "crypto/rand"
+	"math/big"
+	"math/rand"
+)
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
@@ -11,3 +15,18 @@ func RandStringBytes(n int) string {
 	}
 	return string(b)
 }
+
+// SecureRandString returns a random string of letters generated by crypto/rand,
+// which is suitable for passwords and tokens
+func SecureRandString(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(letterBytes)))
+	for i := range b {
+		idx, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = letterBytes[idx.Int64()]
+	}
+	return string(b), nil
+}
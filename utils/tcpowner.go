@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var getExtendedTCPTable = libiphlpapi.NewProc("GetExtendedTcpTable")
+
+// TCP_TABLE_OWNER_PID_CONNECTIONS
+const tcpTableOwnerPIDConnections = 4
+
+// sizes of MIB_TCPROW_OWNER_PID and MIB_TCP6ROW_OWNER_PID
+const (
+	tcpRowSize  = 24
+	tcp6RowSize = 56
+)
+
+var errTCPOwnerNotFound = errors.New("tcp connection not found")
+
+// TCPPeerImage returns path of executable of the local process at the other
+// end of tcp connection accepted at local from remote, which must be a local
+// address as well
+func TCPPeerImage(local, remote *net.TCPAddr) (string, error) {
+	pid, err := tcpOwnerPID(remote, local)
+	if err != nil {
+		return "", err
+	}
+	return processImage(pid)
+}
+
+// tcpOwnerPID returns id of process owning tcp connection from local to remote
+func tcpOwnerPID(local, remote *net.TCPAddr) (uint32, error) {
+	family, rowSize := uint32(windows.AF_INET), tcpRowSize
+	if local.IP.To4() == nil {
+		family, rowSize = windows.AF_INET6, tcp6RowSize
+	}
+	table, err := tcpTable(family)
+	if err != nil {
+		return 0, err
+	}
+	if len(table) < 4 {
+		return 0, errTCPOwnerNotFound
+	}
+	count := int(binary.LittleEndian.Uint32(table))
+	for i := 0; i < count && 4+(i+1)*rowSize <= len(table); i++ {
+		row := table[4+i*rowSize : 4+(i+1)*rowSize]
+		var rowLocal, rowRemote net.TCPAddr
+		var pid uint32
+		if family == windows.AF_INET {
+			// state, local address, local port, remote address, remote
+			// port, owning pid
+			rowLocal = net.TCPAddr{IP: net.IP(row[4:8]), Port: tcpPort(row[8:12])}
+			rowRemote = net.TCPAddr{IP: net.IP(row[12:16]), Port: tcpPort(row[16:20])}
+			pid = binary.LittleEndian.Uint32(row[20:24])
+		} else {
+			// local address, scope, port, remote address, scope, port,
+			// state, owning pid
+			rowLocal = net.TCPAddr{IP: net.IP(row[0:16]), Port: tcpPort(row[20:24])}
+			rowRemote = net.TCPAddr{IP: net.IP(row[24:40]), Port: tcpPort(row[44:48])}
+			pid = binary.LittleEndian.Uint32(row[52:56])
+		}
+		if rowLocal.IP.Equal(local.IP) && rowLocal.Port == local.Port &&
+			rowRemote.IP.Equal(remote.IP) && rowRemote.Port == remote.Port {
+			return pid, nil
+		}
+	}
+	return 0, errTCPOwnerNotFound
+}
+
+// tcpPort decodes port of a row, which is in network byte order
+func tcpPort(b []byte) int {
+	return int(binary.BigEndian.Uint16(b[:2]))
+}
+
+// tcpTable returns tcp connections of family with their owning processes
+func tcpTable(family uint32) ([]byte, error) {
+	var size uint32
+	for {
+		var table []byte
+		var p uintptr
+		if size > 0 {
+			table = make([]byte, size)
+			p = uintptr(unsafe.Pointer(&table[0]))
+		}
+		r, _, _ := getExtendedTCPTable.Call(p, uintptr(unsafe.Pointer(&size)), 0, uintptr(family), tcpTableOwnerPIDConnections, 0)
+		switch syscall.Errno(r) {
+		case 0:
+			return table[:size], nil
+		case windows.ERROR_INSUFFICIENT_BUFFER:
+			// connections may be added between calls, so size is asked again
+			continue
+		default:
+			return nil, syscall.Errno(r)
+		}
+	}
+}
+
+// processImage returns path of executable of process
+func processImage(pid uint32) (string, error) {
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(process)
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(process, 0, &buf[0], &size); err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf[:size]), nil
+}
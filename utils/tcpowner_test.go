@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTCPPeerImage(t *testing.T) {
+	for _, network := range []string{"tcp4", "tcp6"} {
+		listener, err := net.Listen(network, "localhost:0")
+		if err != nil {
+			t.Logf("skip %s: %v", network, err)
+			continue
+		}
+		defer listener.Close()
+		client, err := net.Dial(network, listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		image, err := TCPPeerImage(conn.LocalAddr().(*net.TCPAddr), conn.RemoteAddr().(*net.TCPAddr))
+		if err != nil {
+			t.Fatalf("TCPPeerImage() over %s error = %v", network, err)
+		}
+		executable, err := os.Executable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.EqualFold(filepath.Clean(image), filepath.Clean(executable)) {
+			t.Errorf("TCPPeerImage() over %s = %s, want %s", network, image, executable)
+		}
+	}
+
+	unknown := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	if _, err := TCPPeerImage(unknown, unknown); err == nil {
+		t.Error("TCPPeerImage() of unknown connection should return error")
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"image"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	cases := []struct {
+		width, height int
+		want          image.Point
+	}{
+		{1920, 1080, image.Pt(256, 144)},
+		{1080, 1920, image.Pt(144, 256)},
+		{100, 50, image.Pt(100, 50)},
+		{4000, 2, image.Pt(256, 1)},
+	}
+	for _, tc := range cases {
+		img := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+		if got := Thumbnail(img, 256).Bounds().Size(); got != tc.want {
+			t.Errorf("Thumbnail(%dx%d) = %v, want %v", tc.width, tc.height, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// secret and codes from https://tools.ietf.org/html/rfc6238#appendix-B,
+// truncated to 6 digits
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		code, err := TOTPCode(rfcSecret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("TOTPCode(%d) = %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	if !VerifyTOTP(rfcSecret, "081804", now, 1) {
+		t.Error("VerifyTOTP() = false for current code")
+	}
+	if !VerifyTOTP(rfcSecret, "081804", now.Add(30*time.Second), 1) {
+		t.Error("VerifyTOTP() = false for code in skew window")
+	}
+	if VerifyTOTP(rfcSecret, "081804", now.Add(90*time.Second), 1) {
+		t.Error("VerifyTOTP() = true for code out of skew window")
+	}
+	if VerifyTOTP(rfcSecret, "000000", now, 1) {
+		t.Error("VerifyTOTP() = true for wrong code")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TOTPCode(secret, time.Now()); err != nil {
+		t.Errorf("TOTPCode() of generated secret error: %v", err)
+	}
+}
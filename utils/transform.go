@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// types of text transforms
+const (
+	TransformTrim          = "trim"
+	TransformStripTracking = "strip-tracking"
+	TransformReplace       = "replace"
+	TransformUpper         = "upper"
+	TransformLower         = "lower"
+)
+
+// query parameters added by sites and mail campaigns for tracking
+var (
+	trackingParams = map[string]bool{
+		"fbclid": true, "gclid": true, "gclsrc": true, "dclid": true, "msclkid": true,
+		"yclid": true, "igshid": true, "mc_cid": true, "mc_eid": true, "_hsenc": true,
+		"_hsmi": true, "mkt_tok": true, "spm": true, "si": true, "ref_src": true,
+	}
+	trackingURL = regexp.MustCompile(`https?://[^\s<>"']+\?[^\s<>"']*`)
+)
+
+// TextTransform is a transform of text, e.g. trimming whitespace
+type TextTransform struct {
+	kind        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// NewTextTransform returns transform of kind. pattern and replacement are
+// only used by replace, and replacement can refer submatches like $1
+func NewTextTransform(kind, pattern, replacement string) (*TextTransform, error) {
+	t := &TextTransform{kind: kind, replacement: replacement}
+	switch kind {
+	case TransformTrim, TransformStripTracking, TransformUpper, TransformLower:
+	case TransformReplace:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		t.re = re
+	default:
+		return nil, fmt.Errorf("unknown transform: %s", kind)
+	}
+	return t, nil
+}
+
+// Apply returns s transformed
+func (t *TextTransform) Apply(s string) string {
+	switch t.kind {
+	case TransformTrim:
+		return strings.TrimSpace(s)
+	case TransformStripTracking:
+		return StripTrackingParams(s)
+	case TransformReplace:
+		return t.re.ReplaceAllString(s, t.replacement)
+	case TransformUpper:
+		return strings.ToUpper(s)
+	case TransformLower:
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// StripTrackingParams removes tracking parameters like utm_source and
+// fbclid from query of urls in s. Other parameters keep their order
+func StripTrackingParams(s string) string {
+	return trackingURL.ReplaceAllStringFunc(s, func(u string) string {
+		i := strings.IndexByte(u, '?')
+		query, fragment := u[i+1:], ""
+		if j := strings.IndexByte(query, '#'); j >= 0 {
+			query, fragment = query[:j], query[j:]
+		}
+		var kept []string
+		for _, param := range strings.Split(query, "&") {
+			key := param
+			if k := strings.IndexByte(param, '='); k >= 0 {
+				key = param[:k]
+			}
+			key = strings.ToLower(key)
+			if param == "" || trackingParams[key] || strings.HasPrefix(key, "utm_") {
+				continue
+			}
+			kept = append(kept, param)
+		}
+		if len(kept) == 0 {
+			return u[:i] + fragment
+		}
+		return u[:i+1] + strings.Join(kept, "&") + fragment
+	})
+}
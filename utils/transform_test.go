@@ -0,0 +1,53 @@
+package utils
+
+import "testing"
+
+func TestStripTrackingParams(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"no url here", "no url here"},
+		{"https://a.com/path", "https://a.com/path"},
+		{"https://a.com/?utm_source=x&utm_medium=y", "https://a.com/"},
+		{"https://a.com/?b=2&fbclid=abc&a=1", "https://a.com/?b=2&a=1"},
+		{"https://a.com/?UTM_Campaign=x&id=1#top", "https://a.com/?id=1#top"},
+		{"https://a.com/?gclid=1#top", "https://a.com/#top"},
+		{"see https://a.com/?si=1 and http://b.com/x?q=go&spm=2 now", "see https://a.com/ and http://b.com/x?q=go now"},
+		{"https://youtu.be/abc?si=xyz\nhttps://c.com/?utmost=1", "https://youtu.be/abc\nhttps://c.com/?utmost=1"},
+	}
+	for _, test := range tests {
+		if got := StripTrackingParams(test.text); got != test.want {
+			t.Errorf("StripTrackingParams(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestTextTransform(t *testing.T) {
+	tests := []struct {
+		kind, pattern, replacement string
+		text, want                 string
+	}{
+		{TransformTrim, "", "", " \n hello \t", "hello"},
+		{TransformUpper, "", "", "Hello", "HELLO"},
+		{TransformLower, "", "", "Hello", "hello"},
+		{TransformReplace, `(\d{3})\d{4}(\d{4})`, "$1****$2", "tel 13812345678", "tel 138****5678"},
+		{TransformStripTracking, "", "", "https://a.com/?utm_source=x", "https://a.com/"},
+	}
+	for _, test := range tests {
+		transform, err := NewTextTransform(test.kind, test.pattern, test.replacement)
+		if err != nil {
+			t.Fatalf("NewTextTransform(%q) error: %v", test.kind, err)
+		}
+		if got := transform.Apply(test.text); got != test.want {
+			t.Errorf("%s.Apply(%q) = %q, want %q", test.kind, test.text, got, test.want)
+		}
+	}
+
+	if _, err := NewTextTransform("reverse", "", ""); err == nil {
+		t.Error("NewTextTransform of unknown kind should fail")
+	}
+	if _, err := NewTextTransform(TransformReplace, "(", ""); err == nil {
+		t.Error("NewTextTransform of invalid pattern should fail")
+	}
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// UPnP internet gateway device protocol.
+// http://upnp.org/specs/gw/UPnP-gw-WANIPConnection-v1-Service.pdf
+const (
+	ssdpAddr         = "239.255.255.250:1900"
+	ssdpSearchTarget = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+)
+
+var errNoUPnPGateway = errors.New("no upnp internet gateway device found")
+
+// UPnPGateway is WANIPConnection or WANPPPConnection service of an internet
+// gateway device
+type UPnPGateway struct {
+	ServiceType string
+	ControlURL  string
+	client      *http.Client
+}
+
+// DiscoverUPnPGateway searches internet gateway device by SSDP
+func DiscoverUPnPGateway(timeout time.Duration) (*UPnPGateway, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"ST: " + ssdpSearchTarget + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), addr); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	deadline := time.Now().Add(timeout)
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, errNoUPnPGateway
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+		gateway, err := newUPnPGateway(client, location)
+		if err != nil {
+			continue
+		}
+		return gateway, nil
+	}
+}
+
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+type upnpDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+func newUPnPGateway(client *http.Client, location string) (*UPnPGateway, error) {
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	serviceType, controlURL, err := findUPnPService(location, body)
+	if err != nil {
+		return nil, err
+	}
+	return &UPnPGateway{ServiceType: serviceType, ControlURL: controlURL, client: client}, nil
+}
+
+// findUPnPService finds wan connection service in device description and
+// returns its type and absolute control url
+func findUPnPService(location string, description []byte) (string, string, error) {
+	var desc upnpDescription
+	if err := xml.Unmarshal(description, &desc); err != nil {
+		return "", "", err
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", "", err
+	}
+	if desc.URLBase != "" {
+		if base, err = url.Parse(desc.URLBase); err != nil {
+			return "", "", err
+		}
+	}
+
+	devices := []upnpDevice{desc.Device}
+	for len(devices) > 0 {
+		device := devices[0]
+		devices = append(devices[1:], device.Devices...)
+		for _, service := range device.Services {
+			if !strings.Contains(service.ServiceType, ":WANIPConnection:") &&
+				!strings.Contains(service.ServiceType, ":WANPPPConnection:") {
+				continue
+			}
+			controlURL, err := base.Parse(strings.TrimSpace(service.ControlURL))
+			if err != nil {
+				return "", "", err
+			}
+			return service.ServiceType, controlURL.String(), nil
+		}
+	}
+	return "", "", errNoUPnPGateway
+}
+
+// upnpArg is an argument of soap action, they must be in order of the spec
+type upnpArg struct {
+	name, value string
+}
+
+// soap calls action of service and returns body of response
+func (g *UPnPGateway) soap(action string, args ...upnpArg) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, g.ServiceType)
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>", arg.name)
+		xml.EscapeText(&body, []byte(arg.value))
+		fmt.Fprintf(&body, "</%s>", arg.name)
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest(http.MethodPost, g.ControlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, g.ServiceType, action))
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upnp %s failed: %s", action, resp.Status)
+	}
+	return respBody, nil
+}
+
+// ExternalIP returns external ip address of gateway
+func (g *UPnPGateway) ExternalIP() (net.IP, error) {
+	body, err := g.soap("GetExternalIPAddress")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(resp.IP))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid external ip address: %q", resp.IP)
+	}
+	return ip, nil
+}
+
+// AddPortMapping forwards tcp externalPort of gateway to internalPort of
+// internalClient for lease
+func (g *UPnPGateway) AddPortMapping(externalPort, internalPort int, internalClient net.IP, description string, lease time.Duration) error {
+	_, err := g.soap("AddPortMapping",
+		upnpArg{"NewRemoteHost", ""},
+		upnpArg{"NewExternalPort", fmt.Sprint(externalPort)},
+		upnpArg{"NewProtocol", "TCP"},
+		upnpArg{"NewInternalPort", fmt.Sprint(internalPort)},
+		upnpArg{"NewInternalClient", internalClient.String()},
+		upnpArg{"NewEnabled", "1"},
+		upnpArg{"NewPortMappingDescription", description},
+		upnpArg{"NewLeaseDuration", fmt.Sprint(int(lease.Seconds()))},
+	)
+	return err
+}
+
+// DeletePortMapping removes tcp mapping of externalPort
+func (g *UPnPGateway) DeletePortMapping(externalPort int) error {
+	_, err := g.soap("DeletePortMapping",
+		upnpArg{"NewRemoteHost", ""},
+		upnpArg{"NewExternalPort", fmt.Sprint(externalPort)},
+		upnpArg{"NewProtocol", "TCP"},
+	)
+	return err
+}
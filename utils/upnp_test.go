@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType>
+        <controlURL>/ctl/L3F</controlURL>
+      </service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+func TestFindUPnPService(t *testing.T) {
+	serviceType, controlURL, err := findUPnPService("http://192.168.1.1:5000/rootDesc.xml", []byte(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceType != "urn:schemas-upnp-org:service:WANIPConnection:1" {
+		t.Errorf("serviceType = %q", serviceType)
+	}
+	if controlURL != "http://192.168.1.1:5000/ctl/IPConn" {
+		t.Errorf("controlURL = %q", controlURL)
+	}
+
+	if _, _, err := findUPnPService("http://192.168.1.1/", []byte(`<root><device></device></root>`)); err == nil {
+		t.Error("findUPnPService should fail without wan connection service")
+	}
+}
+
+func TestUPnPGateway(t *testing.T) {
+	const serviceType = "urn:schemas-upnp-org:service:WANIPConnection:1"
+	var action, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action = r.Header.Get("SOAPAction")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+			`<u:GetExternalIPAddressResponse xmlns:u="` + serviceType + `"><NewExternalIPAddress>203.0.113.7</NewExternalIPAddress></u:GetExternalIPAddressResponse>` +
+			`</s:Body></s:Envelope>`))
+	}))
+	defer server.Close()
+	gateway := &UPnPGateway{ServiceType: serviceType, ControlURL: server.URL, client: server.Client()}
+
+	ip, err := gateway.ExternalIP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(net.ParseIP("203.0.113.7")) {
+		t.Errorf("ExternalIP() = %v", ip)
+	}
+	if action != `"`+serviceType+`#GetExternalIPAddress"` {
+		t.Errorf("SOAPAction = %s", action)
+	}
+
+	if err := gateway.AddPortMapping(8086, 8086, net.ParseIP("192.168.1.2"), "a&b", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<NewExternalPort>8086</NewExternalPort>",
+		"<NewInternalClient>192.168.1.2</NewInternalClient>",
+		"<NewPortMappingDescription>a&amp;b</NewPortMappingDescription>",
+		"<NewLeaseDuration>3600</NewLeaseDuration>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("AddPortMapping body %s does not contain %s", body, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type utiType struct {
+	contentType string
+	ext         string
+}
+
+// Apple uniform type identifiers sent by iOS, with content types and
+// extensions of files of them
+var utiTypes = map[string]utiType{
+	"public.text":                        {TypeText, ""},
+	"public.plain-text":                  {TypeText, ""},
+	"public.utf8-plain-text":             {TypeText, ""},
+	"public.utf16-plain-text":            {TypeText, ""},
+	"public.url":                         {TypeText, ""},
+	"public.image":                       {TypeMedia, ""},
+	"public.png":                         {TypeMedia, ".png"},
+	"public.jpeg":                        {TypeMedia, ".jpg"},
+	"public.heic":                        {TypeMedia, ".heic"},
+	"public.heif":                        {TypeMedia, ".heif"},
+	"public.tiff":                        {TypeMedia, ".tiff"},
+	"com.compuserve.gif":                 {TypeMedia, ".gif"},
+	"com.microsoft.bmp":                  {TypeMedia, ".bmp"},
+	"org.webmproject.webp":               {TypeMedia, ".webp"},
+	"public.movie":                       {TypeMedia, ""},
+	"public.mpeg-4":                      {TypeMedia, ".mp4"},
+	"com.apple.quicktime-movie":          {TypeMedia, ".mov"},
+	"public.data":                        {TypeFile, ""},
+	"public.html":                        {TypeFile, ".html"},
+	"public.rtf":                         {TypeFile, ".rtf"},
+	"public.json":                        {TypeFile, ".json"},
+	"public.comma-separated-values-text": {TypeFile, ".csv"},
+	"public.zip-archive":                 {TypeFile, ".zip"},
+	"public.mp3":                         {TypeFile, ".mp3"},
+	"com.apple.m4a-audio":                {TypeFile, ".m4a"},
+	"com.adobe.pdf":                      {TypeFile, ".pdf"},
+	"com.microsoft.word.doc":             {TypeFile, ".doc"},
+	"com.microsoft.excel.xls":            {TypeFile, ".xls"},
+	"com.microsoft.powerpoint.ppt":       {TypeFile, ".ppt"},
+	"org.openxmlformats.wordprocessingml.document":   {TypeFile, ".docx"},
+	"org.openxmlformats.spreadsheetml.sheet":         {TypeFile, ".xlsx"},
+	"org.openxmlformats.presentationml.presentation": {TypeFile, ".pptx"},
+}
+
+// IsUTI reports whether s looks like a uniform type identifier, which is
+// in reverse DNS form like public.png
+func IsUTI(s string) bool {
+	return strings.Contains(s, ".")
+}
+
+// LookupUTI returns content type, text, file or media, and extension of
+// files of uti. Unknown utis are files without extension
+func LookupUTI(uti string) (contentType string, ext string) {
+	if t, ok := utiTypes[strings.ToLower(uti)]; ok {
+		return t.contentType, t.ext
+	}
+	return TypeFile, ""
+}
+
+// FilenameWithUTI appends extension of uti to name if name has none, so
+// that Windows can open the file by type
+func FilenameWithUTI(name, uti string) string {
+	if filepath.Ext(name) != "" {
+		return name
+	}
+	_, ext := LookupUTI(uti)
+	return name + ext
+}
@@ -0,0 +1,41 @@
+package utils
+
+import "testing"
+
+func TestLookupUTI(t *testing.T) {
+	cases := []struct {
+		uti, contentType, ext string
+	}{
+		{"public.utf8-plain-text", TypeText, ""},
+		{"public.png", TypeMedia, ".png"},
+		{"Public.JPEG", TypeMedia, ".jpg"},
+		{"com.apple.quicktime-movie", TypeMedia, ".mov"},
+		{"com.adobe.pdf", TypeFile, ".pdf"},
+		{"com.example.unknown", TypeFile, ""},
+	}
+	for _, tc := range cases {
+		contentType, ext := LookupUTI(tc.uti)
+		if contentType != tc.contentType || ext != tc.ext {
+			t.Errorf("LookupUTI(%q) = %q, %q, want %q, %q", tc.uti, contentType, ext, tc.contentType, tc.ext)
+		}
+	}
+}
+
+func TestFilenameWithUTI(t *testing.T) {
+	cases := []struct {
+		name, uti, want string
+	}{
+		{"IMG_0001", "public.heic", "IMG_0001.heic"},
+		{"IMG_0001.HEIC", "public.jpeg", "IMG_0001.HEIC"},
+		{"report", "com.adobe.pdf", "report.pdf"},
+		{"notes", "com.example.unknown", "notes"},
+	}
+	for _, tc := range cases {
+		if got := FilenameWithUTI(tc.name, tc.uti); got != tc.want {
+			t.Errorf("FilenameWithUTI(%q, %q) = %q, want %q", tc.name, tc.uti, got, tc.want)
+		}
+	}
+	if IsUTI("text") || !IsUTI("public.png") {
+		t.Error("IsUTI() should tell utis from content types")
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrZipTooLarge means files of a zip exceed the size limit of extraction
+var ErrZipTooLarge = errors.New("zip is too large")
+
+// ExtractZip extracts files of r into dir, which is created if it doesn't
+// exist. Entries escaping dir and symlinks are rejected, and extraction
+// stops once files exceed maxSize bytes. Modification times are kept
+func ExtractZip(r *zip.Reader, dir string, maxSize int64) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	var size int64
+	for _, f := range r.File {
+		name := filepath.FromSlash(strings.ReplaceAll(f.Name, "\\", "/"))
+		path := filepath.Join(dir, name)
+		if path != dir && !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal path in zip: %s", f.Name)
+		}
+		mode := f.Mode()
+		if mode&os.ModeSymlink != 0 {
+			return fmt.Errorf("symlink in zip: %s", f.Name)
+		}
+		if mode.IsDir() {
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		n, err := extractZipFile(f, path, maxSize-size)
+		size += n
+		if err != nil {
+			return err
+		}
+		if !f.Modified.IsZero() {
+			os.Chtimes(path, f.Modified, f.Modified)
+		}
+	}
+	return nil
+}
+
+// extractZipFile writes f to path and returns its size, at most limit bytes
+func extractZipFile(f *zip.File, path string, limit int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > limit {
+		err = ErrZipTooLarge
+	}
+	return n, err
+}
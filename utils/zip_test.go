@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newZip(t *testing.T, files map[string]string) *zip.Reader {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestExtractZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := newZip(t, map[string]string{"a.txt": "foo", "docs/": "", "docs/b.txt": "bar"})
+	if err := ExtractZip(r, filepath.Join(dir, "folder"), 1024); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "foo", "docs/b.txt": "bar"} {
+		path := filepath.Join(dir, "folder", filepath.FromSlash(name))
+		data, err := ioutil.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("modification time of %s is not kept", name)
+		}
+	}
+}
+
+func TestExtractZipInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ExtractZip(newZip(t, map[string]string{"../evil.txt": "x"}), filepath.Join(dir, "a"), 1024); err == nil {
+		t.Error("ExtractZip() should reject path escaping dir")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("file escaping dir is written")
+	}
+	if err := ExtractZip(newZip(t, map[string]string{"a.txt": "foo", "b.txt": "bar"}), filepath.Join(dir, "b"), 4); err != ErrZipTooLarge {
+		t.Errorf("ExtractZip() = %v, want ErrZipTooLarge", err)
+	}
+}
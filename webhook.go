@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RunWebhooks posts clipboard changes and content pushed by devices to
+// configured urls
+func (app *Application) RunWebhooks() {
+	if !app.config.Webhook.Enable || len(app.config.Webhook.URLs) == 0 {
+		return
+	}
+	client := &http.Client{Timeout: time.Duration(app.config.Webhook.Timeout) * time.Second}
+	events := app.events.Subscribe()
+	go func() {
+		for event := range events {
+			if event.Event == EventProgress || (event.Event == EventTransfer && event.Action != AuditActionWrite) {
+				continue
+			}
+			body, err := json.Marshal(event)
+			if err != nil {
+				log.WithError(err).Warn("failed to marshal webhook event")
+				continue
+			}
+			for _, url := range app.config.Webhook.URLs {
+				go func(url string) {
+					if err := postWebhook(client, url, body); err != nil {
+						log.WithError(err).WithField("url", url).Warn("failed to post webhook")
+						app.outbox.AddWebhook(url, body)
+					}
+				}(url)
+			}
+		}
+	}()
+	if app.config.Outbox.Enable {
+		go app.outbox.retryWebhooks(client)
+	}
+}
+
+// postWebhook sends body to url. If secret is configured, X-Signature is hex
+// encoded HMAC-SHA256 of X-Timestamp and body joined by "\n". Error is
+// returned only if url is unreachable, error responses are logged
+func postWebhook(client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).WithField("url", url).Warn("failed to create webhook request")
+		return nil
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Timestamp", timestamp)
+	if secret := app.config.Webhook.Secret; secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "%s\n%s", timestamp, body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.WithField("url", url).WithField("statusCode", resp.StatusCode).Warn("webhook responded error")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{}
+
+// wsHandler upgrades to websocket and pushes an event every time clipboard
+// changes
+func wsHandler(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.WithError(err).Warn("failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	events := app.events.Subscribe()
+	defer app.events.Unsubscribe(events)
+
+	// read messages to handle close and pong frames
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-events:
+			if event.Event != EventClipboard {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				log.WithError(err).Debug("failed to write websocket event")
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(wsWriteTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}